@@ -0,0 +1,27 @@
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// NewAnonymousFs creates a file system for a public bucket, such as one of
+// the open data sets, that can be read without credentials. Its requests are
+// not signed, so no credentials need to be configured, and any that are in
+// the environment are not used. Further configuration, e.g. an endpoint, can
+// be given too.
+func NewAnonymousFs(bucket, region string, cfgs ...*aws.Config) (*Fs, error) {
+	cfg := aws.NewConfig().WithRegion(region)
+	for _, c := range cfgs {
+		cfg.MergeIn(c)
+	}
+	cfg.Credentials = credentials.AnonymousCredentials
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewFs(bucket, s3.New(sess)), nil
+}
@@ -0,0 +1,42 @@
+package s3
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+func TestNewAnonymousFs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var authorization []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Length", "5")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	fs, err := NewAnonymousFs("open-data", "us-east-1", &aws.Config{
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	data, err := afero.ReadFile(fs, "/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("hello"))
+
+	g.Expect(authorization).NotTo(BeEmpty())
+	for _, a := range authorization {
+		g.Expect(a).To(BeEmpty())
+	}
+}
@@ -0,0 +1,133 @@
+package s3
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ArchiveFormat is the format of an archive written by Archive.
+type ArchiveFormat int
+
+const (
+	// Tar is the tar format.
+	Tar ArchiveFormat = iota
+	// TarGzip is the tar format, compressed using gzip.
+	TarGzip
+	// Zip is the zip format, with each file compressed using deflate.
+	Zip
+)
+
+var errArchiveFormat = errors.New("unknown archive format")
+
+// Archive writes the files with a given prefix, including those in all
+// subdirectories, to w as an archive. The names in the archive are relative
+// to the prefix. Each file is streamed from S3 into the archive as the
+// listing is paged through, so neither the files nor the archive need be
+// held in memory; this allows a directory to be downloaded as a zip file by
+// a web handler, for example.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Archive(prefix string, w io.Writer, format ArchiveFormat) error {
+	start := fs.begin("Archive", prefix)
+	n, err := fs.archive(prefix, w, format)
+	err = pathError("archive", prefix, err)
+	fs.logOp("Archive", prefix, start, err, "count", n)
+	return err
+}
+
+// archiveWriter is the common part of the tar and zip writers.
+type archiveWriter interface {
+	add(name string, fi FileInfo) (io.Writer, error)
+	Close() error
+}
+
+func (fs Fs) archive(prefix string, w io.Writer, format ArchiveFormat) (int, error) {
+	var aw archiveWriter
+	switch format {
+	case Tar:
+		aw = tarWriter{tar.NewWriter(w)}
+	case TarGzip:
+		gz := gzip.NewWriter(w)
+		aw = gzipTarWriter{tarWriter{tar.NewWriter(gz)}, gz}
+	case Zip:
+		aw = zipWriter{zip.NewWriter(w)}
+	default:
+		return 0, errArchiveFormat
+	}
+
+	base := addTrailingSlash(fs.key(prefix))
+	count := 0
+	it := fs.Objects(prefix)
+	for it.Next() {
+		fi := it.FileInfo()
+		name := strings.TrimPrefix(fs.key(fi.Path()), base)
+		if err := fs.addToArchive(aw, name, fi); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		return count, err
+	}
+	return count, aw.Close()
+}
+
+func (fs Fs) addToArchive(aw archiveWriter, name string, fi FileInfo) error {
+	f, err := fs.Open(fi.Path())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := aw.add(name, fi)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+type tarWriter struct{ *tar.Writer }
+
+func (tw tarWriter) add(name string, fi FileInfo) (io.Writer, error) {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     fi.Size(),
+		Mode:     int64(fi.Mode().Perm()),
+		ModTime:  fi.ModTime(),
+		Format:   tar.FormatPAX,
+	}
+	if hdr.Mode == 0 {
+		hdr.Mode = 0644
+	}
+	return tw.Writer, tw.WriteHeader(hdr)
+}
+
+type gzipTarWriter struct {
+	tarWriter
+	gz *gzip.Writer
+}
+
+func (gw gzipTarWriter) Close() error {
+	if err := gw.tarWriter.Close(); err != nil {
+		return err
+	}
+	return gw.gz.Close()
+}
+
+type zipWriter struct{ *zip.Writer }
+
+func (zw zipWriter) add(name string, fi FileInfo) (io.Writer, error) {
+	hdr := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: fi.ModTime(),
+	}
+	hdr.SetMode(fi.Mode().Perm())
+	return zw.CreateHeader(hdr)
+}
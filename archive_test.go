@@ -0,0 +1,75 @@
+package s3
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func archiveTestFs(g *WithT) *Fs {
+	fs := NewFs("mybucket", s3fake.New())
+	for name, content := range map[string]string{
+		"/d/a.txt":     "hello",
+		"/d/sub/b.txt": "world",
+		"/e/c.txt":     "other",
+	} {
+		g.Expect(afero.WriteFile(fs, name, []byte(content), 0644)).To(Succeed())
+	}
+	return fs
+}
+
+func TestArchiveTar(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fs := archiveTestFs(g)
+
+	buf := &bytes.Buffer{}
+	g.Expect(fs.Archive("/d", buf, TarGzip)).To(Succeed())
+
+	gz, err := gzip.NewReader(buf)
+	g.Expect(err).NotTo(HaveOccurred())
+	tr := tar.NewReader(gz)
+
+	contents := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		g.Expect(err).NotTo(HaveOccurred())
+		data, err := ioutil.ReadAll(tr)
+		g.Expect(err).NotTo(HaveOccurred())
+		contents[hdr.Name] = string(data)
+	}
+	g.Expect(contents).To(Equal(map[string]string{"a.txt": "hello", "sub/b.txt": "world"}))
+}
+
+func TestArchiveZip(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fs := archiveTestFs(g)
+
+	buf := &bytes.Buffer{}
+	g.Expect(fs.Archive("/", buf, Zip)).To(Succeed())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	g.Expect(names).To(Equal([]string{"d/a.txt", "d/sub/b.txt", "e/c.txt"}))
+
+	r, err := zr.File[2].Open()
+	g.Expect(err).NotTo(HaveOccurred())
+	data, err := ioutil.ReadAll(r)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("other"))
+}
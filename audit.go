@@ -0,0 +1,129 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// AuditRecord describes a change made to the file system, for an Auditor.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Principal is the caller, as set by Fs.WithPrincipal.
+	Principal string `json:"principal,omitempty"`
+	// Op is "create" or "write" when a file is written (on closing it),
+	// "remove", "rename" or "symlink".
+	Op     string `json:"op"`
+	Bucket string `json:"bucket"`
+	Path   string `json:"path"`
+	// NewPath is the new name, for "rename", or the target, for "symlink".
+	NewPath string `json:"newPath,omitempty"`
+	// Size is the number of bytes written, for "create" and "write".
+	Size int64 `json:"size,omitempty"`
+	// ETag is the entity tag of the object written, if any.
+	ETag string `json:"etag,omitempty"`
+}
+
+// Auditor receives a record of every change made to the file system, after
+// the change has succeeded; see Fs.WithAudit. If Audit returns an error,
+// this is logged as an error but the change is not undone.
+type Auditor interface {
+	Audit(record AuditRecord) error
+}
+
+// AuditorFunc is an Auditor made from a function.
+type AuditorFunc func(record AuditRecord) error
+
+// Audit calls the function.
+func (fn AuditorFunc) Audit(record AuditRecord) error {
+	return fn(record)
+}
+
+// WithAudit sets the auditor in a new instance of the file system, which
+// then records every file that is created, written, removed or renamed,
+// and every symbolic link made. Nil disables auditing.
+func (fs Fs) WithAudit(auditor Auditor) *Fs {
+	fs.auditor = auditor
+	return &fs
+}
+
+// WithPrincipal sets the name of the caller, such as a user or service, in a
+// new instance of the file system; this is included in each AuditRecord.
+func (fs Fs) WithPrincipal(principal string) *Fs {
+	fs.principal = principal
+	return &fs
+}
+
+// audit sends a record of a change to the auditor, if any.
+func (fs Fs) audit(record AuditRecord) {
+	if fs.auditor == nil {
+		return
+	}
+
+	record.Time = time.Now().UTC()
+	record.Principal = fs.principal
+	record.Bucket = fs.bucket
+	if err := fs.auditor.Audit(record); err != nil {
+		fs.log(LevelError, "Audit", "bucket", fs.bucket, "key", fs.key(record.Path), "op", record.Op, "error", err)
+		lgr("Audit %s %q > %+v\n", record.Op, record.Path, err)
+	}
+}
+
+// NewAuditWriter creates an Auditor that writes each record to w as a line
+// of JSON. It is safe for concurrent use.
+func NewAuditWriter(w io.Writer) Auditor {
+	aw := &auditWriter{w: w}
+	return AuditorFunc(aw.audit)
+}
+
+type auditWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (aw *auditWriter) audit(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	_, err = aw.w.Write(append(line, '\n'))
+	return err
+}
+
+// NewAuditLog creates an Auditor that writes each record as a separate JSON
+// object in a directory of a file system, because S3 objects cannot be
+// appended to. The names start with the time of the change, e.g.
+// "/audit/20240101T120000.000000000Z-3f2a9c1b7d4e8a06.json", so they are
+// listed in order. The file system used should not itself be audited.
+func NewAuditLog(log *Fs, dir string) Auditor {
+	return AuditorFunc(func(record AuditRecord) error {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		name := path.Join(dir, record.Time.Format("20060102T150405.000000000Z")+"-"+randomString()+".json")
+		ctx, cancel := withTimeout(log.ctx, log.timeouts.transfer)
+		defer cancel()
+
+		input := &s3.PutObjectInput{
+			Bucket:        aws.String(log.bucket),
+			Key:           aws.String(log.key(name)),
+			Body:          bytes.NewReader(data),
+			ContentLength: aws.Int64(int64(len(data))),
+			ContentType:   aws.String("application/json"),
+		}
+		log.writeOpts.applyToPut(input)
+		_, err = log.s3API.PutObjectWithContext(ctx, input)
+		return pathError("write", name, err)
+	})
+}
@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestAudit(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var records []AuditRecord
+	fs := NewFs("mybucket", s3fake.New()).
+		WithAudit(AuditorFunc(func(record AuditRecord) error {
+			records = append(records, record)
+			return nil
+		})).
+		WithPrincipal("alice")
+
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("hello"), 0644)).To(Succeed())
+	g.Expect(fs.Rename("/a.txt", "/b.txt")).To(Succeed())
+	g.Expect(fs.Remove("/b.txt")).To(Succeed())
+	_, err := fs.Stat("/b.txt")
+	g.Expect(err).To(HaveOccurred())
+
+	g.Expect(records).To(HaveLen(3))
+	g.Expect(records[0].Op).To(Equal("create"))
+	g.Expect(records[0].Path).To(Equal("/a.txt"))
+	g.Expect(records[0].Size).To(BeEquivalentTo(5))
+	g.Expect(records[0].ETag).NotTo(BeEmpty())
+	g.Expect(records[0].Principal).To(Equal("alice"))
+	g.Expect(records[0].Bucket).To(Equal("mybucket"))
+	g.Expect(records[0].Time.IsZero()).To(BeFalse())
+	g.Expect(records[1].Op).To(Equal("rename"))
+	g.Expect(records[1].Path).To(Equal("/a.txt"))
+	g.Expect(records[1].NewPath).To(Equal("/b.txt"))
+	g.Expect(records[2].Op).To(Equal("remove"))
+	g.Expect(records[2].Path).To(Equal("/b.txt"))
+}
+
+func TestAuditWriterAndLog(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	buf := &bytes.Buffer{}
+	fs := NewFs("mybucket", s3fake.New()).WithAudit(NewAuditWriter(buf))
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("hello"), 0644)).To(Succeed())
+
+	var record AuditRecord
+	g.Expect(json.Unmarshal(buf.Bytes(), &record)).To(Succeed())
+	g.Expect(record.Op).To(Equal("create"))
+	g.Expect(strings.Count(buf.String(), "\n")).To(Equal(1))
+
+	logFs := NewFs("logs", s3fake.New())
+	fs = fs.WithAudit(NewAuditLog(logFs, "/audit"))
+	g.Expect(afero.WriteFile(fs, "/b.txt", []byte("hello"), 0644)).To(Succeed())
+	g.Expect(fs.Remove("/b.txt")).To(Succeed())
+
+	list, err := logFs.ListObjects("/audit", -1, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list).To(HaveLen(2))
+
+	data, err := afero.ReadFile(logFs, list[1].Path())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(json.Unmarshal(data, &record)).To(Succeed())
+	g.Expect(record.Op).To(Equal("remove"))
+	g.Expect(record.Path).To(Equal("/b.txt"))
+}
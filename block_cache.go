@@ -0,0 +1,172 @@
+package s3
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// blockCache holds fixed-size blocks of objects in memory, so that repeated
+// small reads of the same parts of objects need not be downloaded again. The
+// total size is limited; the least recently used blocks are removed first.
+// It is safe for concurrent use and is shared by every copy of the Fs that
+// created it.
+type blockCache struct {
+	mu        sync.Mutex
+	blockSize int64
+	maxBytes  int64
+	size      int64
+	entries   map[blockID]*list.Element
+	lru       *list.List
+}
+
+// blockID identifies a block of a version of an object.
+type blockID struct {
+	bucket, key, etag string
+	index             int64
+}
+
+type block struct {
+	id   blockID
+	data []byte
+}
+
+func newBlockCache(blockSize, maxBytes int64) *blockCache {
+	return &blockCache{
+		blockSize: blockSize,
+		maxBytes:  maxBytes,
+		entries:   make(map[blockID]*list.Element),
+		lru:       list.New(),
+	}
+}
+
+func (c *blockCache) get(id blockID) (*block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, exists := c.entries[id]
+	if !exists {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*block), true
+}
+
+func (c *blockCache) put(b *block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, exists := c.entries[b.id]; exists {
+		c.size -= int64(len(el.Value.(*block).data))
+		c.lru.Remove(el)
+	}
+
+	c.entries[b.id] = c.lru.PushFront(b)
+	c.size += int64(len(b.data))
+
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		el := c.lru.Back()
+		old := el.Value.(*block)
+		c.lru.Remove(el)
+		delete(c.entries, old.id)
+		c.size -= int64(len(old.data))
+	}
+}
+
+// readBlocks reads from the block containing the current offset, which is
+// fetched from S3 unless it is in the block cache.
+func (f *File) readBlocks(p []byte) (int, error) {
+	bc := f.s3Fs.blockCache
+	index := f.offset / bc.blockSize
+
+	b, err := f.fetchBlock(bc, index)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := f.offset - index*bc.blockSize
+	if pos >= int64(len(b.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, b.data[pos:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *File) fetchBlock(bc *blockCache, index int64) (*block, error) {
+	id := blockID{bucket: f.bucket, key: f.s3Fs.key(f.name), etag: f.etag, index: index}
+	if f.etag != "" && !f.conditional() {
+		if b, ok := bc.get(id); ok {
+			return b, nil
+		}
+	}
+
+	first := index * bc.blockSize
+	byteRange := fmt.Sprintf("bytes=%d-%d", first, first+bc.blockSize-1)
+
+	ctx, start := f.s3Fs.beginWithContext(f.ctx, "Read", f.name)
+	ctx, cancel := withTimeout(ctx, f.s3Fs.timeouts.transfer)
+	defer cancel()
+
+	input := &s3.GetObjectInput{
+		Bucket:          aws.String(f.bucket),
+		Key:             aws.String(id.key),
+		Range:           aws.String(byteRange),
+		IfNoneMatch:     f.ifNoneMatch,
+		IfModifiedSince: f.ifModifiedSince,
+	}
+	if f.etag != "" {
+		// all the blocks must come from the same version
+		input.IfMatch = aws.String(f.etag)
+	}
+
+	output, err := f.s3API.GetObjectWithContext(ctx, input)
+	if isInvalidRange(err) {
+		// the offset is beyond the end of the file
+		f.s3Fs.logOp("Read", f.name, start, nil, "range", byteRange, "size", 0)
+		return &block{id: id}, nil
+	}
+	if err != nil {
+		err = pathError("read", f.name, err)
+		f.s3Fs.logOp("Read", f.name, start, err, "range", byteRange)
+		return nil, err
+	}
+
+	body := output.Body
+	if limiters := f.limiters(); limiters != nil {
+		body = throttledReader{ReadCloser: body, limiters: limiters}
+	}
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		err = pathError("read", f.name, err)
+		f.s3Fs.logOp("Read", f.name, start, err, "range", byteRange)
+		return nil, err
+	}
+	f.s3Fs.logOp("Read", f.name, start, nil, "range", byteRange, "size", len(data))
+
+	f.etag = aws.StringValue(output.ETag)
+	id.etag = f.etag
+	b := &block{id: id, data: data}
+	if f.etag != "" {
+		bc.put(b)
+	}
+	return b, nil
+}
+
+// isInvalidRange tests whether a request failed because the range starts
+// beyond the end of the object.
+func isInvalidRange(err error) bool {
+	if ae, ok := err.(awserr.Error); ok && ae.Code() == "InvalidRange" {
+		return true
+	}
+	re, ok := err.(awserr.RequestFailure)
+	return ok && re.StatusCode() == 416
+}
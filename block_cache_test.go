@@ -0,0 +1,57 @@
+package s3
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestBlockCacheEviction(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	c := newBlockCache(4, 8)
+	c.put(&block{id: blockID{key: "a", etag: "e", index: 0}, data: []byte("abcd")})
+	c.put(&block{id: blockID{key: "a", etag: "e", index: 1}, data: []byte("efgh")})
+	_, ok := c.get(blockID{key: "a", etag: "e", index: 0})
+	g.Expect(ok).To(BeTrue())
+
+	c.put(&block{id: blockID{key: "b", etag: "e", index: 0}, data: []byte("ijkl")})
+	_, ok = c.get(blockID{key: "a", etag: "e", index: 1})
+	g.Expect(ok).To(BeFalse())
+	_, ok = c.get(blockID{key: "a", etag: "e", index: 0})
+	g.Expect(ok).To(BeTrue())
+	_, ok = c.get(blockID{key: "a", etag: "f", index: 0})
+	g.Expect(ok).To(BeFalse())
+	g.Expect(c.size).To(Equal(int64(8)))
+}
+
+func TestBlockCacheReads(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: bytes.NewBufferString("hello world")}
+	fs := NewFs("mybucket", stub).WithBlockCache(4, 100)
+
+	f1 := NewFile("mybucket", "a.txt", fs.s3API, *fs)
+	b, err := ioutil.ReadAll(f1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("hello world"))
+	g.Expect(stub.getCount).To(Equal(3))
+	g.Expect(f1.ETag()).To(Equal(`"def456"`))
+
+	// a file with a known ETag is read from the cache
+	f2 := NewFile("mybucket", "a.txt", fs.s3API, *fs)
+	f2.etag = `"def456"`
+	_, err = f2.Seek(6, io.SeekStart)
+	g.Expect(err).NotTo(HaveOccurred())
+	p := make([]byte, 5)
+	n, err := io.ReadFull(f2, p)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(p[:n])).To(Equal("world"))
+
+	_, err = f2.Read(p)
+	g.Expect(err).To(Equal(io.EOF))
+	g.Expect(stub.getCount).To(Equal(3))
+}
@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// bucketConfig holds the settings used when EnsureBucket creates the bucket.
+type bucketConfig struct {
+	region string
+	acl    string
+}
+
+// WithCreateBucket sets the region and canned ACL (e.g. s3.BucketCannedACLPrivate)
+// used when EnsureBucket creates the bucket, in a new instance of the file
+// system. Either may be blank: the bucket is then created in the region of
+// the S3 client with the default (private) ACL. The region must match the
+// client's region on AWS, but S3-compatible stores such as MinIO are often
+// less strict.
+func (fs Fs) WithCreateBucket(region, acl string) *Fs {
+	fs.bucketConfig = &bucketConfig{region: region, acl: acl}
+	return &fs
+}
+
+// EnsureBucket creates the bucket if it doesn't already exist, using the
+// settings given to WithCreateBucket, if any. It is intended to be called
+// once at startup, e.g. by test environments and self-provisioning services,
+// because it makes a request each time. It is not an error if the bucket
+// already exists and is owned by the same account, even if another client
+// created it concurrently.
+//
+// The context given is used instead of the one set by WithContext.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) EnsureBucket(ctx context.Context) error {
+	err := fs.Ping(ctx)
+	if !errors.Is(err, ErrBucketNotFound) {
+		return err
+	}
+
+	ctx, start := fs.beginWithContext(ctx, "CreateBucket", "/")
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(fs.bucket),
+	}
+	var region string
+	if fs.bucketConfig != nil {
+		region = fs.bucketConfig.region
+		if fs.bucketConfig.acl != "" {
+			input.ACL = aws.String(fs.bucketConfig.acl)
+		}
+	}
+	// us-east-1 is the default, which S3 rejects if it is given explicitly
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(region),
+		}
+	}
+
+	_, err = fs.s3API.CreateBucketWithContext(ctx, input)
+	if ae, ok := err.(awserr.Error); ok && ae.Code() == s3.ErrCodeBucketAlreadyOwnedByYou {
+		err = nil
+	}
+	err = pathError("createbucket", "/", err)
+	fs.logOp("CreateBucket", "/", start, err, "region", region)
+	return err
+}
@@ -0,0 +1,54 @@
+package s3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+)
+
+// missingBucket behaves as if the bucket doesn't exist until it is created.
+type missingBucket struct {
+	*s3fake.Bucket
+	created *s3.CreateBucketInput
+}
+
+func (b *missingBucket) ListObjectsV2WithContext(ctx aws.Context, req *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	if b.created == nil {
+		return nil, awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist", nil), 404, "")
+	}
+	return b.Bucket.ListObjectsV2WithContext(ctx, req, opts...)
+}
+
+func (b *missingBucket) CreateBucketWithContext(ctx aws.Context, req *s3.CreateBucketInput, opts ...request.Option) (*s3.CreateBucketOutput, error) {
+	b.created = req
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func TestEnsureBucket(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &missingBucket{Bucket: s3fake.New()}
+	fs := NewFs("mybucket", mem).WithCreateBucket("eu-west-2", s3.BucketCannedACLPrivate)
+
+	g.Expect(fs.EnsureBucket(context.Background())).To(Succeed())
+	g.Expect(mem.created).NotTo(BeNil())
+	g.Expect(aws.StringValue(mem.created.Bucket)).To(Equal("mybucket"))
+	g.Expect(aws.StringValue(mem.created.ACL)).To(Equal(s3.BucketCannedACLPrivate))
+	g.Expect(aws.StringValue(mem.created.CreateBucketConfiguration.LocationConstraint)).To(Equal("eu-west-2"))
+	g.Expect(fs.Ping(context.Background())).To(Succeed())
+
+	// the default region is not given explicitly
+	mem = &missingBucket{Bucket: s3fake.New()}
+	g.Expect(NewFs("mybucket", mem).WithCreateBucket("us-east-1", "").EnsureBucket(context.Background())).To(Succeed())
+	g.Expect(mem.created.CreateBucketConfiguration).To(BeNil())
+	g.Expect(mem.created.ACL).To(BeNil())
+
+	// an existing bucket is left alone
+	g.Expect(NewFs("mybucket", s3fake.New()).EnsureBucket(context.Background())).To(Succeed())
+}
@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestCacheOnReadFs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	mem.Now = func() time.Time { return now }
+
+	base := NewFs("mybucket", mem).WithModTimeMetadata(true)
+	layer := afero.NewMemMapFs()
+	cached := afero.NewCacheOnReadFs(base, layer, time.Nanosecond)
+
+	g.Expect(afero.WriteFile(base, "a/b.txt", []byte("hello"), 0644)).To(Succeed())
+
+	// reading copies the file to the layer, with the same modification time
+	b, err := afero.ReadFile(cached, "a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("hello"))
+	g.Expect(mem.Gets).To(Equal(1))
+
+	bfi, err := base.Stat("a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	lfi, err := layer.Stat("a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lfi.Size()).To(Equal(bfi.Size()))
+	g.Expect(lfi.ModTime().Equal(bfi.ModTime())).To(BeTrue())
+
+	// the cached copy is still fresh, so it is read again
+	b, err = afero.ReadFile(cached, "a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("hello"))
+	g.Expect(mem.Gets).To(Equal(1))
+
+	// files written through the cache are not downloaded again, even though
+	// S3 stores them later
+	now = time.Now().Add(time.Second)
+	f, err := cached.Create("a/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString("world")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.Close()).To(Succeed())
+	b, err = afero.ReadFile(cached, "a/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("world"))
+	g.Expect(mem.Gets).To(Equal(1))
+
+	// changed files are downloaded again
+	time.Sleep(time.Millisecond)
+	g.Expect(afero.WriteFile(base, "a/b.txt", []byte("changed"), 0644)).To(Succeed())
+	b, err = afero.ReadFile(cached, "a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("changed"))
+	g.Expect(mem.Gets).To(Equal(2))
+
+	// Chtimes changes both copies
+	mtime := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	g.Expect(cached.Chtimes("a/b.txt", mtime, mtime)).To(Succeed())
+	bfi, err = base.Stat("a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(bfi.ModTime().Equal(mtime)).To(BeTrue())
+	lfi, err = layer.Stat("a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lfi.ModTime().Equal(mtime)).To(BeTrue())
+}
@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"sort"
+	"time"
+)
+
+// Changes are the differences found by ChangesSince.
+type Changes struct {
+	// Modified lists the files created or replaced since the given time, in
+	// the same order as ListObjects.
+	Modified FileInfoList
+	// Removed lists the paths of the files in the previous listing that no
+	// longer exist, sorted.
+	Removed []string
+}
+
+// ChangesSince finds the files with a given prefix, including those in all
+// subdirectories, that have been modified after a given time, such as the
+// time of the last incremental backup. It pages through a listing of every
+// file, so the files needn't all be held in memory. The times are the
+// LastModified times of the objects, which S3 sets when they are written.
+//
+// S3 keeps no record of removed files, so these can only be inferred. If
+// previous is not nil, it is a listing of the files made earlier, e.g. by
+// ListObjects at the time of the last backup; the files in it that no
+// longer exist are reported as removed.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) ChangesSince(prefix string, t time.Time, previous FileInfoList) (Changes, error) {
+	start := fs.begin("ChangesSince", prefix)
+
+	var seen map[string]struct{}
+	if previous != nil {
+		seen = make(map[string]struct{}, len(previous))
+	}
+
+	changes := Changes{Modified: make(FileInfoList, 0)}
+	it := fs.Objects(prefix)
+	for it.Next() {
+		fi := it.FileInfo()
+		if fi.ModTime().After(t) {
+			changes.Modified = append(changes.Modified, fi)
+		}
+		if seen != nil {
+			seen[fi.Path()] = struct{}{}
+		}
+	}
+	if err := it.Err(); err != nil {
+		fs.logOp("ChangesSince", prefix, start, err)
+		return Changes{}, err
+	}
+
+	for _, fi := range previous {
+		if _, exists := seen[fi.Path()]; !exists && !fi.IsDir() {
+			changes.Removed = append(changes.Removed, fi.Path())
+		}
+	}
+	sort.Strings(changes.Removed)
+
+	fs.logOp("ChangesSince", prefix, start, nil, "modified", len(changes.Modified), "removed", len(changes.Removed))
+	return changes, nil
+}
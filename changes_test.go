@@ -0,0 +1,41 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestChangesSince(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mem.Now = func() time.Time { return t0 }
+	for _, name := range []string{"/d/a.txt", "/d/b.txt", "/d/sub/c.txt", "/e/f.txt"} {
+		g.Expect(afero.WriteFile(fs, name, []byte(name), 0644)).To(Succeed())
+	}
+
+	backup, err := fs.ListObjects("/d", -1, false)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mem.Now = func() time.Time { return t0.Add(time.Hour) }
+	g.Expect(afero.WriteFile(fs, "/d/a.txt", []byte("changed"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/d/sub/new.txt", []byte("new"), 0644)).To(Succeed())
+	g.Expect(fs.Remove("/d/b.txt")).To(Succeed())
+
+	changes, err := fs.ChangesSince("/d", t0, backup)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes.Modified.Paths()).To(Equal([]string{"/d/a.txt", "/d/sub/new.txt"}))
+	g.Expect(changes.Removed).To(Equal([]string{"/d/b.txt"}))
+
+	changes, err = fs.ChangesSince("/d", t0.Add(-time.Hour), nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(changes.Modified).To(HaveLen(3))
+	g.Expect(changes.Removed).To(BeEmpty())
+}
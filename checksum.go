@@ -0,0 +1,152 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var errChecksumAlgorithm = errors.New("unknown checksum algorithm")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// newChecksumHash gets the hash for one of the additional checksum
+// algorithms supported by S3, e.g. s3.ChecksumAlgorithmSha256.
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case s3.ChecksumAlgorithmCrc32:
+		return crc32.NewIEEE(), nil
+	case s3.ChecksumAlgorithmCrc32c:
+		return crc32.New(crc32cTable), nil
+	case s3.ChecksumAlgorithmSha1:
+		return sha1.New(), nil
+	case s3.ChecksumAlgorithmSha256:
+		return sha256.New(), nil
+	}
+	return nil, errChecksumAlgorithm
+}
+
+// checksumOf computes the base64-encoded checksum of some data, as sent in
+// the x-amz-checksum-* headers. The CRC checksums are big-endian.
+func checksumOf(algorithm string, data []byte) (string, error) {
+	h, err := newChecksumHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksumFields points to the checksum fields of an S3 input.
+type checksumFields struct {
+	crc32, crc32c, sha1, sha256 **string
+}
+
+// set sets the field for an algorithm.
+func (c checksumFields) set(algorithm, sum string) {
+	switch algorithm {
+	case s3.ChecksumAlgorithmCrc32:
+		*c.crc32 = &sum
+	case s3.ChecksumAlgorithmCrc32c:
+		*c.crc32c = &sum
+	case s3.ChecksumAlgorithmSha1:
+		*c.sha1 = &sum
+	case s3.ChecksumAlgorithmSha256:
+		*c.sha256 = &sum
+	}
+}
+
+// setPutChecksum computes the checksum of the data and sets it in the input.
+func setPutChecksum(input *s3.PutObjectInput, algorithm string, data []byte) error {
+	sum, err := checksumOf(algorithm, data)
+	if err != nil {
+		return err
+	}
+	checksumFields{&input.ChecksumCRC32, &input.ChecksumCRC32C, &input.ChecksumSHA1, &input.ChecksumSHA256}.set(algorithm, sum)
+	return nil
+}
+
+// Checksum gets the stored checksum of the object for an algorithm, e.g.
+// s3.ChecksumAlgorithmSha256, or blank if it is not known. It is known if the
+// object was uploaded with that checksum (see Fs.WithChecksum) and its info
+// came from Stat on a file system with the same checksum algorithm, or from
+// GetObjectAttributes (see Fs.WithStatUsingAttributes).
+func (oi ObjectInfo) Checksum(algorithm string) string {
+	switch algorithm {
+	case s3.ChecksumAlgorithmCrc32:
+		return oi.ChecksumCRC32
+	case s3.ChecksumAlgorithmCrc32c:
+		return oi.ChecksumCRC32C
+	case s3.ChecksumAlgorithmSha1:
+		return oi.ChecksumSHA1
+	case s3.ChecksumAlgorithmSha256:
+		return oi.ChecksumSHA256
+	}
+	return ""
+}
+
+// verifyingReader checks the content of an object as it is read, returning
+// ErrChecksumMismatch instead of io.EOF if it differs from the expected sum.
+type verifyingReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected []byte
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(r.hash.Sum(nil), r.expected) {
+		err = ErrChecksumMismatch
+	}
+	return n, err
+}
+
+// newVerifyingReader wraps the body of a whole object so that it is verified
+// using the strongest checksum S3 provided, or otherwise the ETag if it is the
+// MD5 of the content. This isn't so for multipart uploads, nor when SSE-KMS or
+// SSE-C is used; if there is nothing to verify against, the body is returned
+// unchanged.
+func newVerifyingReader(output *s3.GetObjectOutput) io.ReadCloser {
+	checksums := []struct {
+		algorithm string
+		sum       *string
+	}{
+		{s3.ChecksumAlgorithmSha256, output.ChecksumSHA256},
+		{s3.ChecksumAlgorithmSha1, output.ChecksumSHA1},
+		{s3.ChecksumAlgorithmCrc32c, output.ChecksumCRC32C},
+		{s3.ChecksumAlgorithmCrc32, output.ChecksumCRC32},
+	}
+	for _, c := range checksums {
+		// the checksums of multipart uploads are checksums of the parts' checksums, e.g. "...=-3"
+		if c.sum == nil || strings.Contains(*c.sum, "-") {
+			continue
+		}
+		expected, err := base64.StdEncoding.DecodeString(*c.sum)
+		if err != nil {
+			continue
+		}
+		h, _ := newChecksumHash(c.algorithm)
+		return &verifyingReader{ReadCloser: output.Body, hash: h, expected: expected}
+	}
+
+	if output.SSEKMSKeyId == nil && output.SSECustomerAlgorithm == nil {
+		etag := strings.Trim(aws.StringValue(output.ETag), `"`)
+		if expected, err := hex.DecodeString(etag); err == nil && len(expected) == md5.Size {
+			return &verifyingReader{ReadCloser: output.Body, hash: md5.New(), expected: expected}
+		}
+	}
+	return output.Body
+}
@@ -0,0 +1,157 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestChecksumOf(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	data := []byte("hello world")
+	sha := sha256.Sum256(data)
+
+	sum, err := checksumOf(s3.ChecksumAlgorithmSha256, data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sum).To(Equal(base64.StdEncoding.EncodeToString(sha[:])))
+
+	// the CRC32C of "hello world" is 0xc99465aa
+	sum, err = checksumOf(s3.ChecksumAlgorithmCrc32c, data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sum).To(Equal(base64.StdEncoding.EncodeToString([]byte{0xc9, 0x94, 0x65, 0xaa})))
+
+	sum, err = checksumOf(s3.ChecksumAlgorithmCrc32, data)
+	g.Expect(err).NotTo(HaveOccurred())
+	crc := crc32.ChecksumIEEE(data)
+	g.Expect(sum).To(Equal(base64.StdEncoding.EncodeToString([]byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)})))
+
+	_, err = checksumOf("MD4", data)
+	g.Expect(err).To(Equal(errChecksumAlgorithm))
+}
+
+func TestWithChecksum(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem).WithChecksum(s3.ChecksumAlgorithmSha256)
+
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("hello world"), 0644)).To(Succeed())
+
+	fi, err := fs.Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	sha := sha256.Sum256([]byte("hello world"))
+	oi := fi.Sys().(*ObjectInfo)
+	g.Expect(oi.Checksum(s3.ChecksumAlgorithmSha256)).To(Equal(base64.StdEncoding.EncodeToString(sha[:])))
+	g.Expect(oi.Checksum(s3.ChecksumAlgorithmCrc32)).To(BeEmpty())
+
+	// a file can choose another algorithm
+	af, err := fs.Create("/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	f := af.(*File).WithChecksum(s3.ChecksumAlgorithmCrc32c)
+	_, err = f.WriteString("hello world")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.Close()).To(Succeed())
+
+	fi, err = NewFs("mybucket", mem).WithChecksum(s3.ChecksumAlgorithmCrc32c).Stat("/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Sys().(*ObjectInfo).ChecksumCRC32C).To(Equal("yZRlqg=="))
+
+	// without a checksum algorithm, the checksums are not requested
+	fi, err = NewFs("mybucket", mem).Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Sys().(*ObjectInfo).ChecksumSHA256).To(BeEmpty())
+}
+
+// corruptingBucket flips a bit in the content of every object read.
+type corruptingBucket struct {
+	*s3fake.Bucket
+}
+
+func (b corruptingBucket) GetObjectWithContext(ctx aws.Context, req *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	out, err := b.Bucket.GetObjectWithContext(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := ioutil.ReadAll(out.Body)
+	data[0] ^= 1
+	out.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return out, nil
+}
+
+func TestWithVerifiedReads(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	g.Expect(afero.WriteFile(fs, "/md5.txt", []byte("hello world"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs.WithChecksum(s3.ChecksumAlgorithmSha256), "/sha.txt", []byte("hello world"), 0644)).To(Succeed())
+
+	verified := NewFs("mybucket", mem).WithVerifiedReads(true)
+	for _, name := range []string{"/md5.txt", "/sha.txt"} {
+		data, err := afero.ReadFile(verified, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(Equal("hello world"))
+	}
+
+	corrupted := NewFs("mybucket", corruptingBucket{mem}).WithVerifiedReads(true)
+	for _, name := range []string{"/md5.txt", "/sha.txt"} {
+		_, err := afero.ReadFile(corrupted, name)
+		g.Expect(errors.Is(err, ErrChecksumMismatch)).To(BeTrue(), name)
+	}
+
+	// without verification, the corruption goes unnoticed
+	data, err := afero.ReadFile(NewFs("mybucket", corruptingBucket{mem}), "/md5.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("iello world"))
+
+	// nor can a file be verified if its ETag is not an MD5
+	body := newVerifyingReader(&s3.GetObjectOutput{ETag: aws.String(`"abc-2"`), Body: ioutil.NopCloser(bytes.NewReader(nil))})
+	_, isVerifying := body.(*verifyingReader)
+	g.Expect(isVerifying).To(BeFalse())
+}
+
+// recordingBucket records the input of every PutObject and CopyObject request.
+type recordingBucket struct {
+	*s3fake.Bucket
+	puts   []*s3.PutObjectInput
+	copies []*s3.CopyObjectInput
+}
+
+func (b *recordingBucket) PutObjectWithContext(ctx aws.Context, req *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	b.puts = append(b.puts, req)
+	return b.Bucket.PutObjectWithContext(ctx, req, opts...)
+}
+
+func (b *recordingBucket) CopyObjectWithContext(ctx aws.Context, req *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	b.copies = append(b.copies, req)
+	return b.Bucket.CopyObjectWithContext(ctx, req, opts...)
+}
+
+func TestWithContentMD5(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &recordingBucket{Bucket: s3fake.New()}
+	fs := NewFs("mybucket", mem)
+
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("hello world"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs.WithContentMD5(false), "/b.txt", []byte("hello world"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs.WithContentMD5(false).WithChecksum(s3.ChecksumAlgorithmCrc32c), "/c.txt", []byte("hello world"), 0644)).To(Succeed())
+
+	g.Expect(mem.puts).To(HaveLen(3))
+	g.Expect(aws.StringValue(mem.puts[0].ContentMD5)).To(Equal("XrY7u+Ae7tCTyyK7j1rNww=="))
+	g.Expect(mem.puts[1].ContentMD5).To(BeNil())
+	g.Expect(mem.puts[2].ContentMD5).To(BeNil())
+	g.Expect(aws.StringValue(mem.puts[2].ChecksumCRC32C)).To(Equal("yZRlqg=="))
+}
@@ -0,0 +1,234 @@
+package s3
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// circuitBreaker stops requests being sent to S3 for a while after several
+// consecutive requests have failed, so that callers fail fast instead of
+// waiting for an unresponsive endpoint. After the cool-down period, one trial
+// request is allowed; if it succeeds, the circuit is closed again, otherwise
+// it stays open for another cool-down period.
+//
+// It is shared by every copy of the Fs that created it.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	coolDown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, coolDown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		coolDown:  coolDown,
+		now:       time.Now,
+	}
+}
+
+// allow tests whether a request may be sent.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true // closed
+	}
+
+	if b.trial || b.now().Before(b.openUntil) {
+		return false // open
+	}
+
+	b.trial = true // half-open
+	return true
+}
+
+// record notes the outcome of a request. It returns true when this opens the circuit.
+func (b *circuitBreaker) record(failed bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		return false
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.coolDown)
+		lgr("circuit breaker open until %v after %d failures\n", b.openUntil, b.failures)
+		return true
+	}
+	return false
+}
+
+// isOutage tests whether a failed request suggests that S3 is unavailable,
+// as opposed to the request itself being at fault.
+func isOutage(ctx aws.Context, err error) bool {
+	return err != nil && (isRetryable(err) || ctx.Err() == context.DeadlineExceeded)
+}
+
+// breakingAPI sends requests to the S3 API it wraps only when its circuit
+// breaker allows them.
+type breakingAPI struct {
+	S3APISubset
+	breaker *circuitBreaker
+	logger  Logger
+}
+
+func (b *breakingAPI) call(ctx aws.Context, do func() error) error {
+	if !b.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	err := do()
+	if b.breaker.record(isOutage(ctx, err)) {
+		logTo(b.logger, LevelWarn, "circuit breaker open", "cooldown", b.breaker.coolDown, "error", err)
+	}
+	return err
+}
+
+func (b *breakingAPI) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (output *s3.AbortMultipartUploadOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.AbortMultipartUploadWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (output *s3.CompleteMultipartUploadOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.CompleteMultipartUploadWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (output *s3.CopyObjectOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.CopyObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) CreateBucketWithContext(ctx aws.Context, input *s3.CreateBucketInput, opts ...request.Option) (output *s3.CreateBucketOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.CreateBucketWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (output *s3.CreateMultipartUploadOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.CreateMultipartUploadWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (output *s3.DeleteObjectOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.DeleteObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (output *s3.GetObjectOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.GetObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) GetObjectAttributesWithContext(ctx aws.Context, input *s3.GetObjectAttributesInput, opts ...request.Option) (output *s3.GetObjectAttributesOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.GetObjectAttributesWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (output *s3.HeadObjectOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.HeadObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) ListMultipartUploadsWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, opts ...request.Option) (output *s3.ListMultipartUploadsOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.ListMultipartUploadsWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (output *s3.ListObjectsV2Output, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.ListObjectsV2WithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) ListObjectVersionsWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, opts ...request.Option) (output *s3.ListObjectVersionsOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.ListObjectVersionsWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) ListPartsWithContext(ctx aws.Context, input *s3.ListPartsInput, opts ...request.Option) (output *s3.ListPartsOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.ListPartsWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (output *s3.PutObjectOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.PutObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) SelectObjectContentWithContext(ctx aws.Context, input *s3.SelectObjectContentInput, opts ...request.Option) (output *s3.SelectObjectContentOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.SelectObjectContentWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (output *s3.UploadPartOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.UploadPartWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (b *breakingAPI) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (output *s3.UploadPartCopyOutput, err error) {
+	err = b.call(ctx, func() (e error) {
+		output, e = b.S3APISubset.UploadPartCopyWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
@@ -0,0 +1,111 @@
+package s3
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
+)
+
+var errOutsideOriginPath = errors.New("the file is outside the origin path of the distribution")
+
+// CloudFrontSigner creates signed URLs and signed cookies for the files of an
+// Fs that are served through a CloudFront distribution with restricted
+// viewer access. The names of the files are mapped to URLs in the same way
+// as they are mapped to S3 keys, including any key prefix.
+type CloudFrontSigner struct {
+	fs         Fs
+	domain     string
+	originPath string
+	urls       *sign.URLSigner
+	cookies    *sign.CookieSigner
+}
+
+// NewCloudFrontSigner creates a signer for a CloudFront distribution, which is
+// given by its domain name (e.g. "d111111abcdef8.cloudfront.net" or a custom
+// domain). The key pair ID and private key are those of a trusted key group
+// (or trusted signer) of the distribution. The private key can be loaded using
+// sign.LoadPEMPrivKeyFile.
+func NewCloudFrontSigner(fs *Fs, domain, keyPairID string, privateKey *rsa.PrivateKey) *CloudFrontSigner {
+	return &CloudFrontSigner{
+		fs:      *fs,
+		domain:  domain,
+		urls:    sign.NewURLSigner(keyPairID, privateKey),
+		cookies: sign.NewCookieSigner(keyPairID, privateKey),
+	}
+}
+
+// WithOriginPath sets the origin path of the distribution in a new instance of
+// the signer. CloudFront adds this to the path of each URL to get the S3 key,
+// so it is removed from the keys to get the URLs.
+func (s CloudFrontSigner) WithOriginPath(originPath string) *CloudFrontSigner {
+	s.originPath = strings.Trim(originPath, "/")
+	return &s
+}
+
+// URL gets the unsigned CloudFront URL of a file.
+func (s *CloudFrontSigner) URL(name string) (string, error) {
+	if err := s.fs.checkName("url", name); err != nil {
+		return "", err
+	}
+
+	p := s.fs.key(name)
+	if hasTrailingSlash(name) {
+		p = strings.TrimSuffix(p, "/") + "/"
+	}
+	if s.originPath != "" {
+		if p != s.originPath && !strings.HasPrefix(p, s.originPath+"/") {
+			return "", pathError("url", name, errOutsideOriginPath)
+		}
+		p = strings.TrimPrefix(p[len(s.originPath):], "/")
+	}
+
+	u := url.URL{Scheme: "https", Host: s.domain, Path: "/" + p}
+	return u.String(), nil
+}
+
+// SignedURL creates a URL for downloading a file through CloudFront that is
+// valid until the expiry duration has passed. This uses a canned policy.
+func (s *CloudFrontSigner) SignedURL(name string, expiry time.Duration) (string, error) {
+	u, err := s.URL(name)
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := s.urls.Sign(u, time.Now().Add(expiry))
+	if err != nil {
+		return "", pathError("sign", name, err)
+	}
+	return signed, nil
+}
+
+// SignedCookies creates the cookies that allow a web browser to download a
+// file through CloudFront until the expiry duration has passed. If the name
+// ends with "/", it is a directory and the cookies allow any file within it
+// to be downloaded. The cookies have no domain or path; these can be set as
+// required, e.g. for a custom domain shared by the web site.
+func (s *CloudFrontSigner) SignedCookies(name string, expiry time.Duration) ([]*http.Cookie, error) {
+	u, err := s.URL(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasTrailingSlash(name) {
+		u += "*"
+	}
+
+	policy := sign.NewCannedPolicy(u, time.Now().Add(expiry))
+	cookies, err := s.cookies.SignWithPolicy(policy)
+	if err != nil {
+		return nil, pathError("sign", name, err)
+	}
+
+	for _, c := range cookies {
+		c.Secure = true
+	}
+	return cookies, nil
+}
@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
+	. "github.com/onsi/gomega"
+)
+
+func TestCloudFrontSignedURL(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	fs := NewFs("mybucket", &s3stub{}).WithKeyPrefix("site")
+	signer := NewCloudFrontSigner(fs, "d111111abcdef8.cloudfront.net", "K2JCJMDEHXQW5F", key)
+
+	s, err := signer.SignedURL("/a/b c.txt", time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	u, err := url.Parse(s)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u.Host).To(Equal("d111111abcdef8.cloudfront.net"))
+	g.Expect(u.Path).To(Equal("/site/a/b c.txt"))
+	g.Expect(u.Query().Get("Key-Pair-Id")).To(Equal("K2JCJMDEHXQW5F"))
+	g.Expect(u.Query().Get("Expires")).NotTo(BeEmpty())
+	g.Expect(u.Query().Get("Signature")).NotTo(BeEmpty())
+
+	s, err = signer.WithOriginPath("/site").URL("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s).To(Equal("https://d111111abcdef8.cloudfront.net/a/b.txt"))
+
+	_, err = signer.WithOriginPath("other").URL("/a/b.txt")
+	g.Expect(errors.Is(err, errOutsideOriginPath)).To(BeTrue())
+}
+
+func TestCloudFrontSignedCookies(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	fs := NewFs("mybucket", &s3stub{})
+	signer := NewCloudFrontSigner(fs, "cdn.example.com", "K2JCJMDEHXQW5F", key)
+
+	cookies, err := signer.SignedCookies("/private/", time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cookies).To(HaveLen(3))
+
+	values := map[string]string{}
+	for _, c := range cookies {
+		values[c.Name] = c.Value
+		g.Expect(c.Secure).To(BeTrue())
+	}
+	g.Expect(values).To(HaveKeyWithValue(sign.CookieKeyIDName, "K2JCJMDEHXQW5F"))
+	g.Expect(values).To(HaveKey(sign.CookieSignatureName))
+	g.Expect(values).To(HaveKey(sign.CookiePolicyName))
+}
@@ -0,0 +1,252 @@
+package s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// casPointerPrefix starts the body of each pointer object written by
+// DedupFs; the rest is the SHA-256 hash of the content, in hex.
+const casPointerPrefix = "cas:sha256:"
+
+// casPointerSize is the size of every pointer object.
+const casPointerSize = int64(len(casPointerPrefix) + sha256.Size*2 + 1)
+
+// DedupFs is a file system that stores the content of each file once only,
+// however many files have the same content. This suits build artefacts and
+// attachments, for example, which are often duplicated. The content is
+// stored in a directory, such as "/.cas", under a name derived from its
+// SHA-256 hash; each file is a small pointer object that names the content.
+// Reading a file through the DedupFs reads its content transparently.
+//
+// Reading a file needs a HEAD and a GET request for the pointer before the
+// content is read. Listings of directories, e.g. by Readdir, report the size
+// of the pointer objects; Stat reports the size of the content. Removing a
+// file removes only the pointer; content that is no longer used is not
+// removed. Files written other than through the DedupFs are read as usual.
+type DedupFs struct {
+	source *Fs
+	dir    string
+}
+
+var _ afero.Fs = (*DedupFs)(nil)
+
+// NewDedupFs creates a file system that stores content in the directory dir
+// of another file system, which the files should not be within.
+func NewDedupFs(source *Fs, dir string) *DedupFs {
+	return &DedupFs{source: source, dir: dir}
+}
+
+// contentPath gets the name of the content with a given hash.
+func (dfs *DedupFs) contentPath(hash string) string {
+	return path.Join(dfs.dir, hash[:2], hash)
+}
+
+// resolve finds the content of a file, if it is a pointer. It returns the
+// file's info, with the size of the content, and the content's name.
+func (dfs *DedupFs) resolve(name string) (os.FileInfo, string, error) {
+	fi, err := dfs.source.Stat(name)
+	if err != nil || fi.IsDir() || fi.Size() != casPointerSize {
+		return fi, "", err
+	}
+
+	data, err := afero.ReadFile(dfs.source, name)
+	if err != nil {
+		return nil, "", err
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if !strings.HasPrefix(text, casPointerPrefix) {
+		return fi, "", nil // an ordinary file of the same size
+	}
+	contentPath := dfs.contentPath(strings.TrimPrefix(text, casPointerPrefix))
+
+	content, err := dfs.source.Stat(contentPath)
+	if err != nil {
+		return nil, "", pathError("open", name, err)
+	}
+	if info, ok := fi.(FileInfo); ok {
+		info.sizeInBytes = content.Size()
+		fi = info
+	}
+	return fi, contentPath, nil
+}
+
+// Name returns the name of the source file system.
+func (dfs *DedupFs) Name() string { return "Dedup/" + dfs.source.Name() }
+
+// Create a file.
+func (dfs *DedupFs) Create(name string) (afero.File, error) {
+	return dfs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir makes a directory.
+func (dfs *DedupFs) Mkdir(name string, perm os.FileMode) error {
+	return dfs.source.Mkdir(name, perm)
+}
+
+// MkdirAll creates a directory and all parent directories if necessary.
+func (dfs *DedupFs) MkdirAll(path string, perm os.FileMode) error {
+	return dfs.source.MkdirAll(path, perm)
+}
+
+// Open a file for reading, reading its content if it is a pointer.
+func (dfs *DedupFs) Open(name string) (afero.File, error) {
+	return dfs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens a file. Files opened for writing are written as a pointer
+// to their content when they are closed. Their content is held in memory
+// until then, even if the source file system uploads files in parts (see
+// Fs.WithMultipartUpload), because it is stored under its hash. With
+// os.O_APPEND, the existing content is read into memory first, however
+// large it is.
+func (dfs *DedupFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND) != 0 {
+		var existing []byte
+		if flag&os.O_APPEND != 0 {
+			// the content is appended to, not the pointer
+			var err error
+			existing, err = dfs.readForAppend(name, flag)
+			if err != nil {
+				return nil, err
+			}
+			flag &^= os.O_APPEND
+		}
+
+		// the content must not be uploaded in parts to the pointer's key
+		file, err := dfs.source.WithMultipartUpload(0).OpenFile(name, flag, perm)
+		if err != nil {
+			return file, err
+		}
+		file.Write(existing) // held in the write buffer
+		return &dedupFile{File: file.(*File), dfs: dfs}, nil
+	}
+
+	fi, contentPath, err := dfs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if contentPath == "" {
+		return dfs.source.OpenFile(name, flag, perm)
+	}
+
+	content, err := dfs.source.Open(contentPath)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	return &dedupFile{File: content.(*File), dfs: dfs, name: name, info: fi}, nil
+}
+
+// readForAppend reads the existing content of a file opened with
+// os.O_APPEND, which is nil if it is truncated or if it does not exist and
+// is created.
+func (dfs *DedupFs) readForAppend(name string, flag int) ([]byte, error) {
+	if flag&os.O_TRUNC != 0 {
+		return nil, nil
+	}
+
+	fi, contentPath, err := dfs.resolve(name)
+	switch {
+	case os.IsNotExist(err) && flag&os.O_CREATE != 0:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case fi.IsDir():
+		return nil, pathError("open", name, syscall.EISDIR)
+	case contentPath == "":
+		contentPath = name // an ordinary file
+	}
+	return afero.ReadFile(dfs.source, contentPath)
+}
+
+// Remove a file, but not its content.
+func (dfs *DedupFs) Remove(name string) error {
+	return dfs.source.Remove(name)
+}
+
+// RemoveAll removes a path and any children it contains, but not their
+// content.
+func (dfs *DedupFs) RemoveAll(path string) error {
+	return dfs.source.RemoveAll(path)
+}
+
+// Rename a file. Only the pointer is copied.
+func (dfs *DedupFs) Rename(oldname, newname string) error {
+	return dfs.source.Rename(oldname, newname)
+}
+
+// Stat returns a FileInfo describing the named file, with the size of its
+// content.
+func (dfs *DedupFs) Stat(name string) (os.FileInfo, error) {
+	fi, _, err := dfs.resolve(name)
+	return fi, err
+}
+
+// Chmod changes the mode of a file.
+func (dfs *DedupFs) Chmod(name string, mode os.FileMode) error {
+	return dfs.source.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of a file.
+func (dfs *DedupFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return dfs.source.Chtimes(name, atime, mtime)
+}
+
+// dedupFile is either a file being written, which is stored as content and
+// a pointer when closed, or the content of a file being read.
+type dedupFile struct {
+	*File
+	dfs *DedupFs
+
+	// when reading, the file's name and info, as opposed to its content's
+	name string
+	info os.FileInfo
+}
+
+func (f *dedupFile) Name() string {
+	if f.name != "" {
+		return f.name
+	}
+	return f.File.Name()
+}
+
+func (f *dedupFile) Stat() (os.FileInfo, error) {
+	if f.info != nil {
+		return f.info, nil
+	}
+	return f.dfs.Stat(f.Name())
+}
+
+// Close writes the content, unless it already exists, and then the pointer.
+func (f *dedupFile) Close() error {
+	if f.writeBuf == nil {
+		return f.File.Close()
+	}
+
+	sum := sha256.Sum256(f.writeBuf.Bytes())
+	hash := hex.EncodeToString(sum[:])
+	contentPath := f.dfs.contentPath(hash)
+	if _, err := f.dfs.source.Stat(contentPath); os.IsNotExist(err) {
+		err = afero.WriteFile(f.dfs.source, contentPath, f.writeBuf.Bytes(), 0644)
+		if err != nil {
+			f.discardWrites()
+			f.File.Close()
+			return err
+		}
+	} else if err != nil {
+		f.discardWrites()
+		f.File.Close()
+		return err
+	}
+
+	f.writeBuf.Reset()
+	f.writeBuf.WriteString(casPointerPrefix + hash + "\n")
+	return f.File.Close()
+}
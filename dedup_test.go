@@ -0,0 +1,136 @@
+package s3
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestDedupFs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	source := NewFs("mybucket", mem)
+	fs := NewDedupFs(source, "/.cas")
+
+	content := strings.Repeat("artefact ", 100)
+	g.Expect(afero.WriteFile(fs, "/builds/1/app.bin", []byte(content), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/builds/2/app.bin", []byte(content), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/builds/2/other.bin", []byte("other"), 0644)).To(Succeed())
+
+	// the content is stored once
+	list, err := source.ListObjects("/.cas", -1, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list).To(HaveLen(2))
+
+	fi, err := source.Stat("/builds/2/app.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Size()).To(Equal(casPointerSize))
+
+	// reads are transparent
+	data, err := afero.ReadFile(fs, "/builds/2/app.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(content))
+
+	fi, err = fs.Stat("/builds/1/app.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Size()).To(BeEquivalentTo(len(content)))
+	g.Expect(fi.Name()).To(Equal("app.bin"))
+
+	f, err := fs.Open("/builds/1/app.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.Name()).To(Equal("/builds/1/app.bin"))
+	g.Expect(f.Close()).To(Succeed())
+
+	// ordinary files are read as usual
+	g.Expect(afero.WriteFile(source, "/plain.txt", []byte("plain"), 0644)).To(Succeed())
+	data, err = afero.ReadFile(fs, "/plain.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("plain"))
+}
+
+func TestDedupFsWithMultipartUpload(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.MinPartSize = 4
+	source := NewFs("mybucket", mem).WithMultipartUpload(4)
+	fs := NewDedupFs(source, "/.cas")
+
+	content := strings.Repeat("artefact ", 10)
+	f, err := fs.Create("/builds/1/app.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+	for i := 0; i < 10; i++ {
+		_, err = f.WriteString("artefact ")
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(f.Close()).To(Succeed())
+
+	fi, err := source.Stat("/builds/1/app.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Size()).To(Equal(casPointerSize))
+
+	data, err := afero.ReadFile(fs, "/builds/1/app.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(content))
+}
+
+func TestDedupFsAppend(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	source := NewFs("mybucket", mem)
+	fs := NewDedupFs(source, "/.cas")
+
+	appendString := func(name, s string) {
+		f, err := fs.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		g.Expect(err).NotTo(HaveOccurred())
+		_, err = f.WriteString(s)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(f.Close()).To(Succeed())
+	}
+
+	// the content is appended to, rather than the pointer
+	appendString("/greeting.txt", "hello ")
+	appendString("/greeting.txt", "world")
+	data, err := afero.ReadFile(fs, "/greeting.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("hello world"))
+
+	// a large ordinary file is read, not copied within S3
+	large := strings.Repeat("x", minPartSize)
+	g.Expect(afero.WriteFile(source, "/large.txt", []byte(large), 0644)).To(Succeed())
+	appendString("/large.txt", "yz")
+	g.Expect(source.Stats().Copy).To(BeZero())
+
+	fi, err := source.Stat("/large.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Size()).To(Equal(casPointerSize))
+	data, err = afero.ReadFile(fs, "/large.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(large + "yz"))
+}
+
+func TestDedupFsReturnsWriteBuffer(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewDedupFs(NewFs("mybucket", s3fake.New()), "/.cas")
+
+	f, err := fs.Create("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString("content")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the buffer that held the content is reset when it is returned to the pool
+	buf := f.(*dedupFile).writeBuf
+	g.Expect(f.Close()).To(Succeed())
+	g.Expect(buf.Len()).To(BeZero())
+
+	data, err := afero.ReadFile(fs, "/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("content"))
+}
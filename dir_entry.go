@@ -0,0 +1,54 @@
+package s3
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// DirEntry implements fs.DirEntry for an entry found by File.ReadDir. The
+// name and type are known from the listing; the full file info, including
+// the S3 metadata that is only available via HeadObject, is fetched the
+// first time Info is called.
+type DirEntry struct {
+	s3Fs   Fs
+	listed FileInfo
+
+	once sync.Once
+	info fs.FileInfo
+	err  error
+}
+
+var _ fs.DirEntry = &DirEntry{}
+
+// Name provides the base name of the file or directory.
+func (e *DirEntry) Name() string { return e.listed.Name() }
+
+// IsDir reports whether the entry is a directory.
+func (e *DirEntry) IsDir() bool { return e.listed.IsDir() }
+
+// Type provides the type bits of the entry, i.e. fs.ModeDir or zero.
+func (e *DirEntry) Type() fs.FileMode { return e.listed.Mode().Type() }
+
+// Path provides the full path of the entry within the S3 file system.
+func (e *DirEntry) Path() string { return e.listed.Path() }
+
+// Listed provides the file info obtained from the listing, without sending
+// any request. Its size and modification time are known, but for files, the
+// content type and any attributes stored in metadata are not.
+func (e *DirEntry) Listed() FileInfo { return e.listed }
+
+// Info gets the full file info, as for Fs.LstatIfPossible, so a symbolic
+// link is described rather than its target. This is done only once; later
+// calls return the same result. Directories are fully described by the
+// listing, so no request is sent for them. If the file has been deleted since
+// the directory was read, the error matches fs.ErrNotExist.
+func (e *DirEntry) Info() (fs.FileInfo, error) {
+	e.once.Do(func() {
+		if e.listed.IsDir() {
+			e.info = e.listed
+			return
+		}
+		e.info, e.err = e.s3Fs.lstat(e.listed.Path())
+	})
+	return e.info, e.err
+}
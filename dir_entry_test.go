@@ -0,0 +1,97 @@
+package s3
+
+import (
+	"io"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestReadDirLazyInfo(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem).AddMimeTypes(map[string]string{"txt": "text/plain"})
+	g.Expect(afero.WriteFile(fs, "/d/a.txt", []byte("hello"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/d/sub/b.txt", []byte("world"), 0644)).To(Succeed())
+
+	af, err := fs.Open("/d")
+	g.Expect(err).NotTo(HaveOccurred())
+	f := af.(*File)
+
+	heads := mem.Heads
+	entries, err := f.ReadDir(-1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entries).To(HaveLen(2))
+	g.Expect(mem.Heads).To(Equal(heads))
+
+	byName := map[string]*DirEntry{}
+	for _, e := range entries {
+		byName[e.Name()] = e.(*DirEntry)
+	}
+
+	file := byName["a.txt"]
+	g.Expect(file.IsDir()).To(BeFalse())
+	g.Expect(file.Type().IsRegular()).To(BeTrue())
+	g.Expect(file.Listed().Size()).To(Equal(int64(5)))
+
+	fi, err := file.Info()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Size()).To(Equal(int64(5)))
+	g.Expect(fi.Sys().(*ObjectInfo).ContentType).To(Equal("text/plain"))
+	g.Expect(mem.Heads).To(Equal(heads + 1))
+
+	_, err = file.Info()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mem.Heads).To(Equal(heads + 1))
+
+	dir := byName["sub"]
+	g.Expect(dir.IsDir()).To(BeTrue())
+	fi, err = dir.Info()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.IsDir()).To(BeTrue())
+	g.Expect(mem.Heads).To(Equal(heads + 1))
+}
+
+func TestReaddirPaging(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	g.Expect(fs.Mkdir("/d", 0755)).To(Succeed())
+	for _, name := range []string{"/d/1", "/d/2", "/d/3", "/d/4", "/d/5/x"} {
+		g.Expect(afero.WriteFile(fs, name, []byte(name), 0644)).To(Succeed())
+	}
+
+	f, err := fs.Open("/d")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var names []string
+	for {
+		page, err := f.Readdirnames(2)
+		if err == io.EOF {
+			g.Expect(page).To(BeEmpty())
+			break
+		}
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(len(page)).To(BeNumerically("<=", 2))
+		names = append(names, page...)
+	}
+	g.Expect(names).To(ConsistOf("1", "2", "3", "4", "5"))
+
+	_, err = f.Readdir(2)
+	g.Expect(err).To(Equal(io.EOF))
+	rest, err := f.Readdir(-1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rest).To(BeEmpty())
+
+	f, err = fs.Open("/d")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.Readdir(3)
+	g.Expect(err).NotTo(HaveOccurred())
+	rest, err = f.Readdir(0)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rest).To(HaveLen(2))
+}
@@ -0,0 +1,210 @@
+package s3
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskCache holds local copies of objects, identified by their key and ETag,
+// so that unchanged objects need not be downloaded again. The total size is
+// limited; the least recently used copies are deleted first. It is shared by
+// every copy of the Fs that created it.
+//
+// All the methods do nothing when the cache is nil.
+type diskCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element
+	lru      *list.List
+}
+
+type diskCacheEntry struct {
+	name string
+	size int64
+}
+
+const diskCacheSuffix = ".s3cache"
+
+// newDiskCache creates a cache in a directory, including any copies
+// already there from earlier use.
+func newDiskCache(dir string, maxBytes int64) *diskCache {
+	c := &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	infos, _ := ioutil.ReadDir(dir)
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().After(infos[j].ModTime())
+	})
+	for _, fi := range infos {
+		if fi.Mode().IsRegular() && strings.HasSuffix(fi.Name(), diskCacheSuffix) {
+			c.entries[fi.Name()] = c.lru.PushBack(&diskCacheEntry{name: fi.Name(), size: fi.Size()})
+			c.size += fi.Size()
+		}
+	}
+	c.evict()
+	return c
+}
+
+// cacheFileName gets the name of the local copy of a version of an object.
+func cacheFileName(bucket, key, etag string) string {
+	h := sha256.Sum256([]byte(bucket + "\x00" + key + "\x00" + etag))
+	return hex.EncodeToString(h[:]) + diskCacheSuffix
+}
+
+// open opens the local copy of an object, if there is one.
+func (c *diskCache) open(bucket, key, etag string) (*os.File, bool) {
+	if c == nil || etag == "" {
+		return nil, false
+	}
+
+	name := cacheFileName(bucket, key, etag)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, exists := c.entries[name]
+	if !exists {
+		return nil, false
+	}
+
+	file, err := os.Open(filepath.Join(c.dir, name))
+	if err != nil {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	now := time.Now()
+	os.Chtimes(file.Name(), now, now) // so that the order survives restarts
+	return file, true
+}
+
+// writer starts making a local copy of an object. The copy is only added to
+// the cache by commit; until then, it is a temporary file.
+func (c *diskCache) writer(bucket, key, etag string) *diskCacheWriter {
+	if c == nil || etag == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		lgr("disk cache %s > %+v\n", c.dir, err)
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		lgr("disk cache %s > %+v\n", c.dir, err)
+		return nil
+	}
+
+	return &diskCacheWriter{cache: c, tmp: tmp, name: cacheFileName(bucket, key, etag)}
+}
+
+func (c *diskCache) add(name string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, exists := c.entries[name]; exists {
+		c.size -= el.Value.(*diskCacheEntry).size
+		c.lru.Remove(el)
+	}
+
+	c.entries[name] = c.lru.PushFront(&diskCacheEntry{name: name, size: size})
+	c.size += size
+	c.evict()
+}
+
+func (c *diskCache) evict() {
+	for c.size > c.maxBytes && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *diskCache) remove(el *list.Element) {
+	entry := el.Value.(*diskCacheEntry)
+	c.lru.Remove(el)
+	delete(c.entries, entry.name)
+	c.size -= entry.size
+	os.Remove(filepath.Join(c.dir, entry.name))
+}
+
+// diskCacheWriter makes a local copy of an object as it is downloaded.
+type diskCacheWriter struct {
+	cache *diskCache
+	tmp   *os.File
+	name  string
+	size  int64
+	err   error
+}
+
+func (w *diskCacheWriter) Write(p []byte) (int, error) {
+	if w.err == nil {
+		var n int
+		n, w.err = w.tmp.Write(p)
+		w.size += int64(n)
+	}
+	// a failure to cache doesn't stop the download
+	return len(p), nil
+}
+
+// commit adds the copy to the cache, provided it is complete.
+func (w *diskCacheWriter) commit(expectedSize int64) {
+	err := w.tmp.Close()
+	if w.err == nil && err == nil && (expectedSize < 0 || w.size == expectedSize) && w.size <= w.cache.maxBytes {
+		if os.Rename(w.tmp.Name(), filepath.Join(w.cache.dir, w.name)) == nil {
+			w.cache.add(w.name, w.size)
+			return
+		}
+	}
+	os.Remove(w.tmp.Name())
+}
+
+// discard abandons an incomplete copy.
+func (w *diskCacheWriter) discard() {
+	w.tmp.Close()
+	os.Remove(w.tmp.Name())
+}
+
+// cachingReader copies a download to the disk cache. The copy is committed
+// when the whole object has been read, or discarded if it is closed sooner.
+type cachingReader struct {
+	io.ReadCloser
+	writer       *diskCacheWriter
+	expectedSize int64
+	done         bool
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && !r.done {
+		r.writer.Write(p[:n])
+	}
+	if err == io.EOF && !r.done {
+		r.done = true
+		r.writer.commit(r.expectedSize)
+	}
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	if !r.done {
+		r.done = true
+		r.writer.discard()
+	}
+	return r.ReadCloser.Close()
+}
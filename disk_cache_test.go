@@ -0,0 +1,104 @@
+package s3
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDiskCacheReadThrough(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "diskcache")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	c := newDiskCache(dir, 100)
+	_, ok := c.open("b", "a.txt", `"e1"`)
+	g.Expect(ok).To(BeFalse())
+
+	r := &cachingReader{ReadCloser: ioutil.NopCloser(strings.NewReader("hello")), writer: c.writer("b", "a.txt", `"e1"`), expectedSize: 5}
+	b, err := ioutil.ReadAll(r)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("hello"))
+	g.Expect(r.Close()).NotTo(HaveOccurred())
+
+	file, ok := c.open("b", "a.txt", `"e1"`)
+	g.Expect(ok).To(BeTrue())
+	b, err = ioutil.ReadAll(file)
+	file.Close()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("hello"))
+
+	// a different ETag is a different version
+	_, ok = c.open("b", "a.txt", `"e2"`)
+	g.Expect(ok).To(BeFalse())
+
+	// the cache is reloaded from the directory
+	c = newDiskCache(dir, 100)
+	file, ok = c.open("b", "a.txt", `"e1"`)
+	g.Expect(ok).To(BeTrue())
+	file.Close()
+	g.Expect(c.size).To(Equal(int64(5)))
+}
+
+func TestDiskCacheIncompleteReads(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "diskcache")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	c := newDiskCache(dir, 100)
+
+	r := &cachingReader{ReadCloser: ioutil.NopCloser(strings.NewReader("hello")), writer: c.writer("b", "a.txt", `"e1"`), expectedSize: 5}
+	r.Read(make([]byte, 2))
+	r.Close()
+	_, ok := c.open("b", "a.txt", `"e1"`)
+	g.Expect(ok).To(BeFalse())
+
+	r = &cachingReader{ReadCloser: ioutil.NopCloser(strings.NewReader("hello")), writer: c.writer("b", "a.txt", `"e1"`), expectedSize: 6}
+	ioutil.ReadAll(r)
+	r.Close()
+	_, ok = c.open("b", "a.txt", `"e1"`)
+	g.Expect(ok).To(BeFalse())
+
+	infos, _ := ioutil.ReadDir(dir)
+	g.Expect(infos).To(BeEmpty())
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "diskcache")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	c := newDiskCache(dir, 10)
+	put := func(key string) {
+		r := &cachingReader{ReadCloser: ioutil.NopCloser(strings.NewReader("abcd")), writer: c.writer("b", key, `"e"`), expectedSize: -1}
+		ioutil.ReadAll(r)
+	}
+
+	put("a")
+	put("b")
+	file, ok := c.open("b", "a", `"e"`)
+	g.Expect(ok).To(BeTrue())
+	file.Close()
+
+	put("c")
+	_, ok = c.open("b", "b", `"e"`)
+	g.Expect(ok).To(BeFalse())
+	for _, key := range []string{"a", "c"} {
+		file, ok = c.open("b", key, `"e"`)
+		g.Expect(ok).To(BeTrue())
+		file.Close()
+	}
+	g.Expect(c.size).To(Equal(int64(8)))
+
+	infos, _ := ioutil.ReadDir(dir)
+	g.Expect(infos).To(HaveLen(2))
+}
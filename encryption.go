@@ -0,0 +1,41 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithKMSEncryption sets a new instance of the file system to encrypt every
+// file it writes or renames using SSE-KMS, with the given KMS key ID or ARN.
+// A blank key ID means the AWS managed key (aws/s3), or the bucket's default
+// KMS key if it has one.
+//
+// The encryption context of the files can be set by path using
+// WithWriteRules; see WriteRule.EncryptionContext.
+func (fs Fs) WithKMSEncryption(keyID string) *Fs {
+	fs.writeOpts.kms = true
+	fs.writeOpts.kmsKeyID = optionalString(keyID)
+	return &fs
+}
+
+// encryptionContext encodes an encryption context as S3 expects it: the
+// base64 encoding of a JSON object. It is nil if the context is empty.
+func encryptionContext(context map[string]string) *string {
+	if len(context) == 0 {
+		return nil
+	}
+	js, _ := json.Marshal(context) // a map of strings always encodes
+	encoded := base64.StdEncoding.EncodeToString(js)
+	return &encoded
+}
+
+// sse gets the server-side encryption settings for an object written. An
+// encryption context implies SSE-KMS; otherwise the defaults are given.
+func (o writeOptions) sse(defaultSSE, defaultKeyID *string) (sse, keyID, context *string) {
+	if !o.kms && o.kmsContext == nil {
+		return defaultSSE, defaultKeyID, nil
+	}
+	return optionalString(s3.ServerSideEncryptionAwsKms), o.kmsKeyID, o.kmsContext
+}
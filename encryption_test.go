@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestWithKMSEncryption(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &recordingBucket{Bucket: s3fake.New()}
+	fs := NewFs("mybucket", mem).
+		WithKMSEncryption("my-key").
+		WithWriteRules(
+			WriteRule{Path: "/tenants/acme", EncryptionContext: map[string]string{"tenant": "acme"}},
+			WriteRule{Path: "/tenants/zeta", EncryptionContext: map[string]string{"tenant": "zeta"}},
+		)
+
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("a"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/tenants/acme/b.txt", []byte("b"), 0644)).To(Succeed())
+	g.Expect(fs.Rename("/tenants/acme/b.txt", "/tenants/zeta/b.txt")).To(Succeed())
+	g.Expect(NewFs("mybucket", mem).Rename("/a.txt", "/c.txt")).To(Succeed())
+
+	g.Expect(mem.puts).To(HaveLen(2))
+	g.Expect(aws.StringValue(mem.puts[0].ServerSideEncryption)).To(Equal(s3.ServerSideEncryptionAwsKms))
+	g.Expect(aws.StringValue(mem.puts[0].SSEKMSKeyId)).To(Equal("my-key"))
+	g.Expect(mem.puts[0].SSEKMSEncryptionContext).To(BeNil())
+	g.Expect(aws.StringValue(mem.puts[1].SSEKMSKeyId)).To(Equal("my-key"))
+	g.Expect(decodeContext(mem.puts[1].SSEKMSEncryptionContext)).To(Equal(`{"tenant":"acme"}`))
+
+	g.Expect(mem.copies).To(HaveLen(2))
+	g.Expect(aws.StringValue(mem.copies[0].ServerSideEncryption)).To(Equal(s3.ServerSideEncryptionAwsKms))
+	g.Expect(decodeContext(mem.copies[0].SSEKMSEncryptionContext)).To(Equal(`{"tenant":"zeta"}`))
+	g.Expect(aws.StringValue(mem.copies[1].ServerSideEncryption)).To(Equal(s3.ServerSideEncryptionAes256))
+	g.Expect(mem.copies[1].SSEKMSEncryptionContext).To(BeNil())
+}
+
+func TestEncryptionContextImpliesKMS(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &recordingBucket{Bucket: s3fake.New()}
+	fs := NewFs("mybucket", mem).
+		WithWriteRules(WriteRule{Path: "/tenants/acme", EncryptionContext: map[string]string{"tenant": "acme"}})
+
+	g.Expect(afero.WriteFile(fs, "/tenants/acme/a.txt", []byte("a"), 0644)).To(Succeed())
+
+	g.Expect(mem.puts).To(HaveLen(1))
+	g.Expect(aws.StringValue(mem.puts[0].ServerSideEncryption)).To(Equal(s3.ServerSideEncryptionAwsKms))
+	g.Expect(mem.puts[0].SSEKMSKeyId).To(BeNil())
+	g.Expect(decodeContext(mem.puts[0].SSEKMSEncryptionContext)).To(Equal(`{"tenant":"acme"}`))
+}
+
+func decodeContext(encoded *string) string {
+	js, _ := base64.StdEncoding.DecodeString(aws.StringValue(encoded))
+	return string(js)
+}
@@ -0,0 +1,131 @@
+package s3
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// InvalidKeyError is the error used when a file name maps to an S3 object key
+// that S3 would reject or mishandle. Within the file system, it is wrapped in
+// an *os.PathError.
+type InvalidKeyError struct {
+	Key    string
+	Reason string
+}
+
+func (e *InvalidKeyError) Error() string {
+	return "invalid S3 key " + strconv.Quote(e.Key) + ": " + e.Reason
+}
+
+// Errors for S3 conditions. Within the file system, these are wrapped in an
+// *os.PathError (or *os.LinkError for Rename) in place of the AWS error, so
+// callers can test for them using errors.Is. ErrObjectNotFound and
+// ErrAccessDenied are the same as os.ErrNotExist and os.ErrPermission, so
+// os.IsNotExist and os.IsPermission also work. ErrBucketNotFound matches
+// os.ErrNotExist too, but only via errors.Is. ErrCircuitOpen is used instead
+// of sending requests while the circuit breaker is open (see
+// Fs.WithCircuitBreaker). ErrQuotaExceeded is used by QuotaFs instead of
+// writing more than its limit. ErrChecksumMismatch is used when a file read
+// with verification (see Fs.WithVerifiedReads) does not match its checksum.
+// ErrUnreachable is matched by the error from Fs.Ping when S3 could not be
+// reached at all or did not respond in time. ErrUnsupportedOperation is used
+// for operations that the bucket does not support, e.g. writes to an S3
+// Object Lambda access point.
+var (
+	ErrObjectNotFound             = os.ErrNotExist
+	ErrAccessDenied               = os.ErrPermission
+	ErrBucketNotFound       error = &conditionError{msg: "bucket does not exist", is: os.ErrNotExist}
+	ErrObjectArchived       error = &conditionError{msg: "object is archived and must be restored before it can be read"}
+	ErrPreconditionFailed   error = &conditionError{msg: "precondition failed"}
+	ErrNotModified          error = &conditionError{msg: "not modified"}
+	ErrCircuitOpen          error = &conditionError{msg: "S3 is unavailable: circuit breaker is open"}
+	ErrQuotaExceeded        error = &conditionError{msg: "quota exceeded"}
+	ErrChecksumMismatch     error = &conditionError{msg: "content does not match checksum"}
+	ErrUnreachable          error = &conditionError{msg: "S3 is unreachable"}
+	ErrUnsupportedOperation error = &conditionError{msg: "operation is not supported by this bucket or access point"}
+)
+
+// conditionError is an S3 condition that may also match a more general error.
+type conditionError struct {
+	msg string
+	is  error
+}
+
+func (e *conditionError) Error() string { return e.msg }
+
+// Is allows errors.Is to match the more general error.
+func (e *conditionError) Is(target error) bool { return e.is != nil && target == e.is }
+
+// pathError wraps an error with the operation and file name that caused it,
+// in the same way as the os package. Errors that are already an *os.PathError
+// or *os.LinkError are returned unchanged, as is nil. S3 errors for known
+// conditions are replaced by the corresponding Err... value.
+func pathError(op, name string, err error) error {
+	switch err.(type) {
+	case nil, *os.PathError, *os.LinkError:
+		return err
+	}
+	return &os.PathError{Op: op, Path: name, Err: conditionOf(err)}
+}
+
+// conditionOf replaces S3 errors for known conditions with the corresponding
+// Err... value. Other errors are returned unchanged. The error codes are
+// checked first because they are more specific than the status codes, but
+// responses to HEAD requests have no body so only the status code is known.
+func conditionOf(err error) error {
+	if ae, ok := err.(awserr.Error); ok {
+		switch ae.Code() {
+		case s3.ErrCodeNoSuchBucket:
+			return ErrBucketNotFound
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return ErrObjectNotFound
+		case "AccessDenied", "Forbidden":
+			return ErrAccessDenied
+		case s3.ErrCodeInvalidObjectState:
+			return ErrObjectArchived
+		case "PreconditionFailed":
+			return ErrPreconditionFailed
+		case "NotModified":
+			return ErrNotModified
+		case "NotImplemented", "MethodNotAllowed":
+			return ErrUnsupportedOperation
+		}
+	}
+
+	if re, ok := err.(awserr.RequestFailure); ok {
+		switch re.StatusCode() {
+		case 304:
+			return ErrNotModified
+		case 403:
+			return ErrAccessDenied
+		case 404:
+			return ErrObjectNotFound
+		case 405, 501:
+			return ErrUnsupportedOperation
+		case 412:
+			return ErrPreconditionFailed
+		}
+	}
+
+	return err
+}
+
+// isNotFound tests whether an error from S3 means that the object does not exist.
+func isNotFound(err error) bool {
+	return conditionOf(err) == ErrObjectNotFound
+}
+
+// isExpected tests whether an error is a normal outcome rather than a
+// failure: the file doesn't exist, or it has not been modified.
+func isExpected(err error) bool {
+	if os.IsNotExist(err) {
+		return true
+	}
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return err == ErrNotModified
+}
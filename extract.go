@@ -0,0 +1,225 @@
+package s3
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errArchivePath = errors.New("archive entry is outside the directory")
+
+// ExtractOptions controls how Extract writes the files in an archive.
+type ExtractOptions struct {
+	// Concurrency is the number of files uploaded at once. The default is 1.
+	// Each file being uploaded is held in memory.
+	Concurrency int
+	// ModTime stores the modification time of each file in the archive as
+	// the object's mtime metadata, as for WithModTimeMetadata.
+	ModTime bool
+}
+
+// Extract reads an archive, in the given format, and writes each file in it
+// to the directory dir. The content type of each file is found from its
+// extension, using the types given to AddMimeTypes or else those known to
+// the mime package. Directories in the archive are made using Mkdir; other
+// entries, such as symbolic links, are ignored. An entry whose name would
+// be outside the directory stops the extraction with an error.
+//
+// Tar archives are read as a stream. Zip archives cannot be, so these are
+// first copied to a temporary local file, unless r is an *os.File.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Extract(r io.Reader, dir string, format ArchiveFormat, opts ExtractOptions) error {
+	start := fs.begin("Extract", dir)
+	n, err := fs.extract(r, dir, format, opts)
+	err = pathError("extract", dir, err)
+	fs.logOp("Extract", dir, start, err, "count", n)
+	return err
+}
+
+// extractJob is one file to be uploaded.
+type extractJob struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+func (fs Fs) extract(r io.Reader, dir string, format ArchiveFormat, opts ExtractOptions) (int, error) {
+	var mu sync.Mutex
+	var firstErr error
+	count := 0
+
+	jobs := make(chan extractJob)
+	var wg sync.WaitGroup
+	n := opts.Concurrency
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				err := fs.extractFile(job, opts)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				} else if err == nil {
+					count++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	// each entry is read in turn and then uploaded by a worker
+	entry := func(name string, isDir bool, modTime time.Time, body io.Reader) error {
+		if failed() {
+			return errStopExtract
+		}
+		base := path.Clean(PathSeparator + dir)
+		target := path.Join(base, name)
+		if target != base && !strings.HasPrefix(target, addTrailingSlash(base)) {
+			return errArchivePath
+		}
+		if isDir {
+			return fs.Mkdir(target, 0755)
+		}
+
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		jobs <- extractJob{name: target, data: data, modTime: modTime}
+		return nil
+	}
+
+	var err error
+	switch format {
+	case Tar:
+		err = extractTar(r, entry)
+	case TarGzip:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(r); err == nil {
+			err = extractTar(gz, entry)
+		}
+	case Zip:
+		err = extractZip(r, entry)
+	default:
+		err = errArchiveFormat
+	}
+
+	close(jobs)
+	wg.Wait()
+	if err == errStopExtract {
+		err = nil
+	}
+	if err == nil {
+		err = firstErr
+	}
+	return count, err
+}
+
+var errStopExtract = errors.New("stop extracting")
+
+func (fs Fs) extractFile(job extractJob, opts ExtractOptions) error {
+	f := NewFile(fs.bucket, job.name, fs.s3API, fs)
+	f.created = true
+	if f.lookupContentType() == nil {
+		f.writeOpts.contentType = optionalString(mime.TypeByExtension(path.Ext(job.name)))
+	}
+	if opts.ModTime {
+		f.writeOpts.modTime = true
+		f.opened = job.modTime
+	}
+
+	if _, err := f.Write(job.data); err != nil {
+		f.writeBuf = nil
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type extractEntry func(name string, isDir bool, modTime time.Time, body io.Reader) error
+
+func extractTar(r io.Reader, entry extractEntry) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			err = entry(hdr.Name, false, hdr.ModTime, tr)
+		case tar.TypeDir:
+			err = entry(hdr.Name, true, hdr.ModTime, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(r io.Reader, entry extractEntry) error {
+	file, ok := r.(*os.File)
+	if !ok {
+		tmp, err := ioutil.TempFile("", "afero-s3-*.zip")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, r); err != nil {
+			return err
+		}
+		file = tmp
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			err = entry(zf.Name, true, zf.Modified, nil)
+		case mode.IsRegular():
+			var rc io.ReadCloser
+			if rc, err = zf.Open(); err == nil {
+				err = entry(zf.Name, false, zf.Modified, rc)
+				rc.Close()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
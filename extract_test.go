@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestExtractRoundTrip(t *testing.T) {
+	g := NewGomegaWithT(t)
+	src := archiveTestFs(g)
+
+	for _, format := range []ArchiveFormat{Tar, TarGzip, Zip} {
+		buf := &bytes.Buffer{}
+		g.Expect(src.Archive("/d", buf, format)).To(Succeed())
+
+		fs := NewFs("mybucket", s3fake.New())
+		g.Expect(fs.Extract(buf, "/x", format, ExtractOptions{Concurrency: 2})).To(Succeed())
+
+		list, err := fs.ListObjects("/x", -1, true)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(list.Paths()).To(Equal([]string{"/x/a.txt", "/x/sub/b.txt"}))
+
+		data, err := afero.ReadFile(fs, "/x/sub/b.txt")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(Equal("world"))
+	}
+}
+
+func TestExtractTar(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mtime := time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC)
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	g.Expect(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "empty/", Mode: 0755})).To(Succeed())
+	g.Expect(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "page.html", Size: 2, Mode: 0644, ModTime: mtime})).To(Succeed())
+	_, err := tw.Write([]byte("hi"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tw.Close()).To(Succeed())
+
+	fs := NewFs("mybucket", s3fake.New())
+	g.Expect(fs.Extract(bytes.NewReader(buf.Bytes()), "/site", Tar, ExtractOptions{ModTime: true})).To(Succeed())
+
+	fi, err := fs.Stat("/site/page.html")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.ModTime().Equal(mtime)).To(BeTrue())
+	g.Expect(fi.Sys().(*ObjectInfo).ContentType).To(HavePrefix("text/html"))
+
+	fi, err = fs.Stat("/site/empty")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.IsDir()).To(BeTrue())
+
+	// entries outside the directory are rejected
+	buf.Reset()
+	tw = tar.NewWriter(buf)
+	g.Expect(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "../evil.txt", Size: 0, Mode: 0644})).To(Succeed())
+	g.Expect(tw.Close()).To(Succeed())
+	err = fs.Extract(buf, "/site", Tar, ExtractOptions{})
+	g.Expect(err).To(MatchError(ContainSubstring(errArchivePath.Error())))
+	_, err = fs.Stat("/evil.txt")
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,206 @@
+package s3
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Replica is a copy of the bucket, such as one kept up to date by S3
+// Cross-Region Replication, that reads can fail over to. The client is
+// usually configured for the replica's region.
+type Replica struct {
+	Bucket string
+	Client S3APISubset
+}
+
+// replicaCoolDown is how long an endpoint that failed is avoided.
+const replicaCoolDown = 30 * time.Second
+
+// WithReplicas sets the replicas that reads fail over to, in a new instance
+// of the file system. Objects are read (using GetObject, HeadObject and
+// GetObjectAttributes) from the bucket given to NewFs or from a replica,
+// whichever is healthy and has been fastest to respond recently. When a read
+// fails because S3 is unavailable, or does not respond within the timeout
+// given, the next is tried; the one that failed is then avoided for 30
+// seconds. Zero means no timeout, other than any set for the file system.
+//
+// Writes and listings always use the bucket given to NewFs. Replication is
+// asynchronous, so a replica may return an older version of an object, or
+// none; a missing object is not treated as a failure.
+//
+// The health of the replicas is shared with the file systems derived from
+// this one. No replicas disables failover.
+func (fs Fs) WithReplicas(timeout time.Duration, replicas ...Replica) *Fs {
+	if len(replicas) == 0 {
+		fs.replicas = nil
+	} else {
+		fs.replicas = newReplicaSet(timeout, replicas)
+	}
+	fs.s3API = fs.layeredAPI()
+	return &fs
+}
+
+// replicaSet tracks the health of the primary bucket and its replicas.
+// It is shared by every copy of the Fs that created it.
+type replicaSet struct {
+	timeout   time.Duration
+	mu        sync.Mutex
+	endpoints []*replicaEndpoint // the primary first
+	now       func() time.Time
+}
+
+type replicaEndpoint struct {
+	index     int     // zero for the primary
+	replica   Replica // the primary has a blank bucket and no client
+	latency   time.Duration
+	downUntil time.Time
+}
+
+func newReplicaSet(timeout time.Duration, replicas []Replica) *replicaSet {
+	set := &replicaSet{timeout: timeout, now: time.Now}
+	set.endpoints = append(set.endpoints, &replicaEndpoint{})
+	for i, r := range replicas {
+		set.endpoints = append(set.endpoints, &replicaEndpoint{index: i + 1, replica: r})
+	}
+	return set
+}
+
+// order lists the endpoints in the order they should be tried: the healthy
+// ones that have responded, fastest first, then the other healthy ones, then
+// the unhealthy ones, those that will recover soonest first.
+func (s *replicaSet) order() []replicaEndpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	ordered := make([]replicaEndpoint, len(s.endpoints))
+	for i, ep := range s.endpoints {
+		ordered[i] = *ep
+	}
+	rank := func(ep replicaEndpoint) int {
+		switch {
+		case ep.downUntil.After(now):
+			return 2
+		case ep.latency == 0:
+			return 1
+		}
+		return 0
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, rj := rank(ordered[i]), rank(ordered[j])
+		switch {
+		case ri != rj:
+			return ri < rj
+		case ri == 0:
+			return ordered[i].latency < ordered[j].latency
+		case ri == 2:
+			return ordered[i].downUntil.Before(ordered[j].downUntil)
+		}
+		return false
+	})
+	return ordered
+}
+
+// record notes the outcome of a request to an endpoint. The latency is a
+// moving average, so that one slow response does not outweigh the others.
+func (s *replicaSet) record(index int, elapsed time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ep := s.endpoints[index]
+	switch {
+	case failed:
+		ep.downUntil = s.now().Add(replicaCoolDown)
+	case ep.latency == 0:
+		ep.downUntil = time.Time{}
+		ep.latency = elapsed
+	default:
+		ep.downUntil = time.Time{}
+		ep.latency = (3*ep.latency + elapsed) / 4
+	}
+}
+
+// failoverAPI sends reads to the primary bucket or its replicas.
+type failoverAPI struct {
+	S3APISubset // the primary
+	replicas    *replicaSet
+	logger      Logger
+}
+
+// try sends a read to each endpoint in turn until one succeeds or fails for
+// a reason other than an outage. The context of the successful attempt is
+// not cancelled until the returned function is called, because a response
+// body may still be being read.
+func (f *failoverAPI) try(ctx aws.Context, op string, primary *string, send func(ctx aws.Context, api S3APISubset, bucket *string) error) (context.CancelFunc, error) {
+	var err error
+	for _, ep := range f.replicas.order() {
+		api, bucket := f.S3APISubset, primary
+		if ep.index > 0 {
+			api, bucket = ep.replica.Client, aws.String(ep.replica.Bucket)
+		}
+
+		attemptCtx, cancel := context.WithCancel(ctx)
+		var timer *time.Timer
+		if f.replicas.timeout > 0 {
+			timer = time.AfterFunc(f.replicas.timeout, cancel)
+		}
+
+		began := time.Now()
+		err = send(attemptCtx, api, bucket)
+		timedOut := timer != nil && !timer.Stop()
+		failed := err != nil && (timedOut || isOutage(attemptCtx, err))
+		f.replicas.record(ep.index, time.Since(began), failed)
+
+		if !failed || ctx.Err() != nil {
+			return cancel, err
+		}
+
+		cancel()
+		lgr("%s failover from %s > %+v\n", op, aws.StringValue(bucket), err)
+		logTo(f.logger, LevelWarn, "failover", "op", op, "bucket", aws.StringValue(bucket), "error", err)
+	}
+	return func() {}, err
+}
+
+func (f *failoverAPI) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (output *s3.GetObjectOutput, err error) {
+	cancel, err := f.try(ctx, "GetObject", input.Bucket, func(ctx aws.Context, api S3APISubset, bucket *string) (e error) {
+		in := *input
+		in.Bucket = bucket
+		output, e = api.GetObjectWithContext(ctx, &in, opts...)
+		return e
+	})
+	if err != nil {
+		cancel()
+		return output, err
+	}
+	output.Body = cancelOnClose{ReadCloser: output.Body, cancel: cancel}
+	return output, nil
+}
+
+func (f *failoverAPI) GetObjectAttributesWithContext(ctx aws.Context, input *s3.GetObjectAttributesInput, opts ...request.Option) (output *s3.GetObjectAttributesOutput, err error) {
+	cancel, err := f.try(ctx, "GetObjectAttributes", input.Bucket, func(ctx aws.Context, api S3APISubset, bucket *string) (e error) {
+		in := *input
+		in.Bucket = bucket
+		output, e = api.GetObjectAttributesWithContext(ctx, &in, opts...)
+		return e
+	})
+	cancel()
+	return output, err
+}
+
+func (f *failoverAPI) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (output *s3.HeadObjectOutput, err error) {
+	cancel, err := f.try(ctx, "HeadObject", input.Bucket, func(ctx aws.Context, api S3APISubset, bucket *string) (e error) {
+		in := *input
+		in.Bucket = bucket
+		output, e = api.HeadObjectWithContext(ctx, &in, opts...)
+		return e
+	})
+	cancel()
+	return output, err
+}
@@ -0,0 +1,107 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+// unavailableBucket fails reads as if S3 were unavailable, or responds slowly.
+type unavailableBucket struct {
+	*s3fake.Bucket
+	down  bool
+	delay time.Duration
+	reads int
+}
+
+func (b *unavailableBucket) read(ctx aws.Context) error {
+	b.reads++
+	if b.down {
+		return awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service Unavailable", nil), 503, "")
+	}
+	select {
+	case <-time.After(b.delay):
+		return nil
+	case <-ctx.Done():
+		return awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+	}
+}
+
+func (b *unavailableBucket) GetObjectWithContext(ctx aws.Context, req *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if err := b.read(ctx); err != nil {
+		return nil, err
+	}
+	return b.Bucket.GetObjectWithContext(ctx, req, opts...)
+}
+
+func (b *unavailableBucket) HeadObjectWithContext(ctx aws.Context, req *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	if err := b.read(ctx); err != nil {
+		return nil, err
+	}
+	return b.Bucket.HeadObjectWithContext(ctx, req, opts...)
+}
+
+func TestWithReplicas(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	primary := &unavailableBucket{Bucket: s3fake.New()}
+	replica := &unavailableBucket{Bucket: s3fake.New()}
+	for _, b := range []*unavailableBucket{primary, replica} {
+		g.Expect(afero.WriteFile(NewFs("bucket", b.Bucket), "/a.txt", []byte("hello"), 0644)).To(Succeed())
+	}
+
+	fs := NewFs("primary", primary).WithReplicas(50*time.Millisecond, Replica{Bucket: "replica", Client: replica})
+
+	// the primary is used while it is healthy
+	data, err := afero.ReadFile(fs, "/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("hello"))
+	g.Expect(replica.reads).To(Equal(0))
+
+	// reads fail over to the replica, which is then preferred
+	primary.down = true
+	primary.reads = 0
+	data, err = afero.ReadFile(fs, "/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("hello"))
+	g.Expect(primary.reads).To(Equal(1))
+
+	_, err = fs.Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(primary.reads).To(Equal(1))
+
+	// a slow replica times out, so the primary is tried again
+	primary.down = false
+	replica.delay = time.Second
+	_, err = fs.Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(primary.reads).To(Equal(2))
+
+	// a missing file is not a failure
+	replica.reads = 0
+	_, err = fs.Stat("/missing.txt")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(replica.reads).To(Equal(0))
+}
+
+func TestReplicaSetOrder(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	set := newReplicaSet(0, []Replica{{Bucket: "b"}, {Bucket: "c"}, {Bucket: "d"}})
+	set.record(0, 30*time.Millisecond, false)
+	set.record(2, 10*time.Millisecond, false)
+	set.record(3, 0, true)
+
+	var order []int
+	for _, ep := range set.order() {
+		order = append(order, ep.index)
+	}
+	g.Expect(order).To(Equal([]int{2, 0, 1, 3}))
+}
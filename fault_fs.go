@@ -0,0 +1,280 @@
+package s3
+
+import (
+	"math/rand"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/spf13/afero"
+)
+
+// Fault describes a fault to be injected by FaultFs. A fault applies to the
+// operations that match its Op and Path.
+//
+// The operation names are those used in *os.PathError: "create", "mkdir",
+// "mkdirall", "open", "remove", "removeall", "rename", "stat", "chmod" and
+// "chtimes" for the file system, plus "read", "write", "readdir" and
+// "close" for files.
+type Fault struct {
+	// Op is the name of the operation affected. Blank matches every operation.
+	Op string
+	// Path is a pattern, as used by path.Match, for the names of the files
+	// affected. Blank matches every file.
+	Path string
+	// Rate is the fraction of the matching operations that are affected,
+	// chosen at random. Zero means all of them.
+	Rate float64
+	// Delay is added before the operation.
+	Delay time.Duration
+	// Err is returned instead of doing the operation, wrapped in an
+	// *os.PathError. For example, ThrottlingError or os.ErrPermission.
+	Err error
+	// MaxRead limits the number of bytes returned by each Read (but not
+	// ReadAt), which simulates a slow connection. Zero means no limit.
+	MaxRead int
+}
+
+// ThrottlingError returns the error that S3 uses when requests are being
+// throttled (503 SlowDown), for use in a Fault.
+func ThrottlingError() error {
+	return awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), 503, "")
+}
+
+// FaultFs is a file system that injects faults, such as added latency,
+// errors and short reads, into the operations of another file system. This
+// is intended for testing how applications cope when S3 is degraded.
+type FaultFs struct {
+	source afero.Fs
+	faults []Fault
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+var _ afero.Fs = (*FaultFs)(nil)
+
+// NewFaultFs creates a file system that injects faults into the operations
+// of another file system, which would usually be an *Fs. For each operation,
+// the first matching fault applies.
+func NewFaultFs(source afero.Fs, faults ...Fault) *FaultFs {
+	return &FaultFs{
+		source: source,
+		faults: faults,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// fault finds the fault, if any, for an operation.
+func (ffs *FaultFs) fault(op, name string) *Fault {
+	for i, f := range ffs.faults {
+		if f.Op != "" && f.Op != op {
+			continue
+		}
+		if f.Path != "" {
+			if matched, _ := path.Match(f.Path, name); !matched {
+				continue
+			}
+		}
+		if f.Rate > 0 && f.Rate < 1 && !ffs.chance(f.Rate) {
+			continue
+		}
+		return &ffs.faults[i]
+	}
+	return nil
+}
+
+func (ffs *FaultFs) chance(rate float64) bool {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	return ffs.rnd.Float64() < rate
+}
+
+// inject applies the fault, if any, for an operation, returning its error.
+func (ffs *FaultFs) inject(op, name string) (*Fault, error) {
+	f := ffs.fault(op, name)
+	if f == nil {
+		return nil, nil
+	}
+
+	if f.Delay > 0 {
+		time.Sleep(f.Delay)
+	}
+
+	if f.Err != nil {
+		lgr("FaultFs %s %q > %+v\n", op, name, f.Err)
+		return f, pathError(op, name, f.Err)
+	}
+	return f, nil
+}
+
+// Name returns the name of the source file system.
+func (ffs *FaultFs) Name() string { return "Fault/" + ffs.source.Name() }
+
+// Create a file.
+func (ffs *FaultFs) Create(name string) (afero.File, error) {
+	if _, err := ffs.inject("create", name); err != nil {
+		return nil, err
+	}
+	return ffs.wrap(ffs.source.Create(name))
+}
+
+// Mkdir makes a directory.
+func (ffs *FaultFs) Mkdir(name string, perm os.FileMode) error {
+	if _, err := ffs.inject("mkdir", name); err != nil {
+		return err
+	}
+	return ffs.source.Mkdir(name, perm)
+}
+
+// MkdirAll creates a directory and all parent directories if necessary.
+func (ffs *FaultFs) MkdirAll(path string, perm os.FileMode) error {
+	if _, err := ffs.inject("mkdirall", path); err != nil {
+		return err
+	}
+	return ffs.source.MkdirAll(path, perm)
+}
+
+// Open a file for reading.
+func (ffs *FaultFs) Open(name string) (afero.File, error) {
+	if _, err := ffs.inject("open", name); err != nil {
+		return nil, err
+	}
+	return ffs.wrap(ffs.source.Open(name))
+}
+
+// OpenFile opens a file.
+func (ffs *FaultFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if _, err := ffs.inject("open", name); err != nil {
+		return nil, err
+	}
+	return ffs.wrap(ffs.source.OpenFile(name, flag, perm))
+}
+
+// Remove a file.
+func (ffs *FaultFs) Remove(name string) error {
+	if _, err := ffs.inject("remove", name); err != nil {
+		return err
+	}
+	return ffs.source.Remove(name)
+}
+
+// RemoveAll removes a path and any children it contains.
+func (ffs *FaultFs) RemoveAll(path string) error {
+	if _, err := ffs.inject("removeall", path); err != nil {
+		return err
+	}
+	return ffs.source.RemoveAll(path)
+}
+
+// Rename a file. Faults are matched against the old name.
+func (ffs *FaultFs) Rename(oldname, newname string) error {
+	if fault, err := ffs.inject("rename", oldname); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: conditionOf(fault.Err)}
+	}
+	return ffs.source.Rename(oldname, newname)
+}
+
+// Stat returns a FileInfo describing the named file.
+func (ffs *FaultFs) Stat(name string) (os.FileInfo, error) {
+	if _, err := ffs.inject("stat", name); err != nil {
+		return nil, err
+	}
+	return ffs.source.Stat(name)
+}
+
+// Chmod changes the mode of a file.
+func (ffs *FaultFs) Chmod(name string, mode os.FileMode) error {
+	if _, err := ffs.inject("chmod", name); err != nil {
+		return err
+	}
+	return ffs.source.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of a file.
+func (ffs *FaultFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if _, err := ffs.inject("chtimes", name); err != nil {
+		return err
+	}
+	return ffs.source.Chtimes(name, atime, mtime)
+}
+
+func (ffs *FaultFs) wrap(file afero.File, err error) (afero.File, error) {
+	if err != nil {
+		return file, err
+	}
+	return &faultFile{File: file, ffs: ffs}, nil
+}
+
+// faultFile injects faults into the operations of a file.
+type faultFile struct {
+	afero.File
+	ffs *FaultFs
+}
+
+func (f *faultFile) Read(p []byte) (int, error) {
+	fault, err := f.ffs.inject("read", f.Name())
+	if err != nil {
+		return 0, err
+	}
+	if fault != nil && fault.MaxRead > 0 && len(p) > fault.MaxRead {
+		p = p[:fault.MaxRead]
+	}
+	return f.File.Read(p)
+}
+
+func (f *faultFile) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := f.ffs.inject("read", f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.ReadAt(p, off)
+}
+
+func (f *faultFile) Write(p []byte) (int, error) {
+	if _, err := f.ffs.inject("write", f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.Write(p)
+}
+
+func (f *faultFile) WriteAt(p []byte, off int64) (int, error) {
+	if _, err := f.ffs.inject("write", f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func (f *faultFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *faultFile) Readdir(n int) ([]os.FileInfo, error) {
+	if _, err := f.ffs.inject("readdir", f.Name()); err != nil {
+		return nil, err
+	}
+	return f.File.Readdir(n)
+}
+
+func (f *faultFile) Readdirnames(n int) ([]string, error) {
+	if _, err := f.ffs.inject("readdir", f.Name()); err != nil {
+		return nil, err
+	}
+	return f.File.Readdirnames(n)
+}
+
+// Close closes the file. When a fault is injected, the source file is still
+// closed but, for S3 files, whatever was written is discarded instead of
+// being uploaded.
+func (f *faultFile) Close() error {
+	_, err := f.ffs.inject("close", f.Name())
+	if err != nil {
+		if file, ok := f.File.(*File); ok {
+			file.writeBuf = nil
+		}
+		f.File.Close()
+		return err
+	}
+	return f.File.Close()
+}
@@ -0,0 +1,75 @@
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+func TestFaultFsErrors(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	source := afero.NewMemMapFs()
+	g.Expect(afero.WriteFile(source, "/a/b.txt", []byte("hello world"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(source, "/a/c.txt", []byte("hello world"), 0644)).To(Succeed())
+
+	throttled := ThrottlingError()
+	fs := NewFaultFs(source,
+		Fault{Op: "stat", Path: "/a/b.*", Err: throttled},
+		Fault{Op: "rename", Err: os.ErrPermission},
+	)
+
+	_, err := fs.Stat("/a/b.txt")
+	g.Expect(err).To(Equal(&os.PathError{Op: "stat", Path: "/a/b.txt", Err: throttled}))
+
+	_, err = fs.Stat("/a/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = fs.Rename("/a/c.txt", "/a/d.txt")
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+}
+
+func TestFaultFsShortReads(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	source := afero.NewMemMapFs()
+	g.Expect(afero.WriteFile(source, "/a/b.txt", []byte("hello world"), 0644)).To(Succeed())
+
+	fs := NewFaultFs(source, Fault{Op: "read", MaxRead: 3, Delay: time.Millisecond})
+
+	f, err := fs.Open("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	p := make([]byte, 100)
+	n, err := f.Read(p)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(p[:n])).To(Equal("hel"))
+
+	rest, err := ioutil.ReadAll(f)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(rest)).To(Equal("lo world"))
+}
+
+func TestFaultFsWriteFailure(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}, missing: true}
+	fs := NewFaultFs(NewFs("mybucket", stub), Fault{Op: "close", Err: ErrAccessDenied})
+
+	f, err := fs.Create("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	stub.putKey = nil
+
+	_, err = f.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = f.Close()
+	g.Expect(errors.Is(err, os.ErrPermission)).To(BeTrue())
+	g.Expect(stub.putKey).To(BeNil())
+}
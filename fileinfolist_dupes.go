@@ -0,0 +1,95 @@
+package s3
+
+// DuplicatesByETag returns groups of the files in the list that have the same content, i.e. the
+// same ETag and size. Each group has at least two files, in the order they are in the list; the
+// groups are in the order of their first files. Directories and files whose ETags are unknown
+// are not included.
+//
+// Files with the same content may have different ETags, so not every duplicate is found. The
+// ETag of a file uploaded in parts depends on the part size, and the ETag of a file encrypted
+// using SSE-KMS or SSE-C is unique to it. Files with the same ETag and size can be assumed to
+// have the same content.
+// The original list is not modified.
+func (list FileInfoList) DuplicatesByETag() []FileInfoList {
+	type identity struct {
+		etag string
+		size int64
+	}
+
+	index := make(map[identity]int)
+	var groups []FileInfoList
+	for _, fi := range list {
+		if fi.IsDir() || fi.ETag() == "" {
+			continue
+		}
+		id := identity{etag: fi.ETag(), size: fi.Size()}
+		i, seen := index[id]
+		if !seen {
+			i = len(groups)
+			index[id] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], fi)
+	}
+
+	duplicates := make([]FileInfoList, 0)
+	for _, group := range groups {
+		if len(group) > 1 {
+			duplicates = append(duplicates, group)
+		}
+	}
+	return duplicates
+}
+
+// DedupPlan lists, for each group of files with the same content, the file to keep and the
+// redundant copies, which could be removed or replaced, e.g. by symbolic links. It is made by
+// FileInfoList.DedupPlan.
+type DedupPlan []DedupAction
+
+// DedupAction is the plan for one group of files with the same content.
+type DedupAction struct {
+	Keep   FileInfo
+	Remove FileInfoList
+}
+
+// DedupPlan plans the removal of the redundant copies found by DuplicatesByETag. The keep
+// function chooses which file of each group to keep, by its index in the group, e.g. the one
+// with the shortest path; if keep is nil, the first file of each group is kept, so sorting the
+// list first, e.g. using SortByModTime, chooses which.
+// The original list is not modified.
+func (list FileInfoList) DedupPlan(keep func(group FileInfoList) int) DedupPlan {
+	groups := list.DuplicatesByETag()
+	plan := make(DedupPlan, len(groups))
+	for i, group := range groups {
+		k := 0
+		if keep != nil {
+			k = keep(group)
+		}
+		plan[i].Keep = group[k]
+		plan[i].Remove = MakeFileInfoList(0, len(group)-1)
+		plan[i].Remove = append(plan[i].Remove, group[:k]...)
+		plan[i].Remove = append(plan[i].Remove, group[k+1:]...)
+	}
+	return plan
+}
+
+// Removals gets all the redundant files in the plan.
+func (plan DedupPlan) Removals() FileInfoList {
+	removals := MakeFileInfoList(0, 0)
+	for _, action := range plan {
+		removals = append(removals, action.Remove...)
+	}
+	return removals
+}
+
+// Saving gets the total size of the redundant files in the plan, i.e. the storage that
+// removing them would save.
+func (plan DedupPlan) Saving() int64 {
+	var total int64
+	for _, action := range plan {
+		for _, fi := range action.Remove {
+			total += fi.Size()
+		}
+	}
+	return total
+}
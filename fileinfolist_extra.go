@@ -1,6 +1,10 @@
 package s3
 
-import "os"
+import (
+	"os"
+	"path"
+	"strings"
+)
 
 // ToSlice adapts the list to the equivalent slice of the base type.
 func (list FileInfoList) ToStdSlice() []os.FileInfo {
@@ -43,6 +47,79 @@ func (list FileInfoList) SortByDeepestFirst() FileInfoList {
 	})
 }
 
+// SortBySize alters the ordering of the list to be by size, smallest first.
+// This uses a stable sort algorithm.
+func (list FileInfoList) SortBySize() FileInfoList {
+	return list.StableSortBy(func(i, j FileInfo) bool {
+		return i.Size() < j.Size()
+	})
+}
+
+// SortByLargestFirst alters the ordering of the list to be by size, largest first.
+// This uses a stable sort algorithm.
+func (list FileInfoList) SortByLargestFirst() FileInfoList {
+	return list.StableSortBy(func(i, j FileInfo) bool {
+		return i.Size() > j.Size()
+	})
+}
+
+// SortByModTime alters the ordering of the list to be by modification time, oldest first.
+// This uses a stable sort algorithm.
+func (list FileInfoList) SortByModTime() FileInfoList {
+	return list.StableSortBy(func(i, j FileInfo) bool {
+		return i.ModTime().Before(j.ModTime())
+	})
+}
+
+// SortByNewestFirst alters the ordering of the list to be by modification time, newest first.
+// This uses a stable sort algorithm.
+func (list FileInfoList) SortByNewestFirst() FileInfoList {
+	return list.StableSortBy(func(i, j FileInfo) bool {
+		return i.ModTime().After(j.ModTime())
+	})
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// FilterByGlob returns a new list of the elements whose paths match a pattern, as for path.Match
+// and Fs.Glob, e.g. "/reports/2024/*.csv". Wildcards do not match '/', so each element of the
+// pattern matches one element of the path. A pattern without a leading '/' is treated as if it
+// had one. A malformed pattern matches nothing.
+// The original list is not modified.
+func (list FileInfoList) FilterByGlob(pattern string) FileInfoList {
+	pattern = PathSeparator + strings.TrimPrefix(pattern, PathSeparator)
+	return list.Filter(func(fi FileInfo) bool {
+		ok, _ := path.Match(pattern, PathSeparator+strings.TrimPrefix(fi.Path(), PathSeparator))
+		return ok
+	})
+}
+
+// FilterBySuffix returns a new list of the elements whose names end with any of the suffixes,
+// e.g. ".csv", ".tsv". Suffixes are case-sensitive.
+// The original list is not modified.
+func (list FileInfoList) FilterBySuffix(suffixes ...string) FileInfoList {
+	return list.Filter(func(fi FileInfo) bool {
+		for _, suffix := range suffixes {
+			if NameHasSuffix(suffix)(fi) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Diff compares this list, as the older, with a newer list, matching the elements by their paths.
+// Elements differ if their sizes differ or, when both ETags are known, their ETags differ;
+// otherwise, if either ETag is unknown, if their modification times differ. The added and
+// changed elements are from the newer list, in its order; the removed ones are in this list's
+// order. See also Manifest.Diff, which matches files by their paths relative to a directory.
+// Neither list is modified.
+func (list FileInfoList) Diff(newer FileInfoList) ManifestDiff {
+	return diffFiles(list, FileInfo.Path, newer, FileInfo.Path)
+}
+
 //-------------------------------------------------------------------------------------------------
 
 // Names gets a list of file names in the same order as this list.
@@ -0,0 +1,131 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var t0 = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFileInfoListSortBySizeAndModTime(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	list := NewFileInfoList(
+		NewFileInfo("/a", 20, t0.Add(time.Hour)),
+		NewFileInfo("/b", 10, t0.Add(3*time.Hour)),
+		NewFileInfo("/c", 30, t0),
+		NewFileInfo("/d", 10, t0.Add(2*time.Hour)),
+	)
+
+	g.Expect(list.Clone().SortBySize().Paths()).To(Equal([]string{"/b", "/d", "/a", "/c"}))
+	g.Expect(list.Clone().SortByLargestFirst().Paths()).To(Equal([]string{"/c", "/a", "/b", "/d"}))
+	g.Expect(list.Clone().SortByModTime().Paths()).To(Equal([]string{"/c", "/a", "/d", "/b"}))
+	g.Expect(list.Clone().SortByNewestFirst().Paths()).To(Equal([]string{"/b", "/d", "/a", "/c"}))
+}
+
+func TestFileInfoListFilterByGlobAndSuffix(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	list := NewFileInfoList(
+		NewFileInfo("/reports/a.csv", 1, t0),
+		NewFileInfo("/reports/b.tsv", 1, t0),
+		NewFileInfo("/reports/2024/c.csv", 1, t0),
+		NewFileInfo("/d.csv", 1, t0),
+		NewDirectoryInfo("/reports/2024"),
+	)
+
+	g.Expect(list.FilterByGlob("/reports/*.csv").Paths()).To(Equal([]string{"/reports/a.csv"}))
+	g.Expect(list.FilterByGlob("reports/*/*.csv").Paths()).To(Equal([]string{"/reports/2024/c.csv"}))
+	g.Expect(list.FilterByGlob("/reports/*").Paths()).To(Equal([]string{"/reports/a.csv", "/reports/b.tsv", "/reports/2024"}))
+	g.Expect(list.FilterByGlob("/[").Paths()).To(BeEmpty())
+
+	g.Expect(list.FilterBySuffix(".csv").Paths()).To(Equal([]string{"/reports/a.csv", "/reports/2024/c.csv", "/d.csv"}))
+	g.Expect(list.FilterBySuffix(".csv", ".tsv").Paths()).To(HaveLen(4))
+	g.Expect(list.FilterBySuffix().Paths()).To(BeEmpty())
+}
+
+func TestFileInfoListLargestOldestNewestN(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	list := NewFileInfoList(
+		NewFileInfo("/a", 20, t0.Add(time.Hour)),
+		NewDirectoryInfo("/dir"),
+		NewFileInfo("/b", 10, t0.Add(3*time.Hour)),
+		NewFileInfo("/c", 30, t0),
+		NewFileInfo("/d", 10, t0.Add(2*time.Hour)),
+		NewFileInfo("/e", 5, t0.Add(3*time.Hour)),
+	)
+
+	g.Expect(list.LargestN(2).Paths()).To(Equal([]string{"/c", "/a"}))
+	g.Expect(list.LargestN(4).Paths()).To(Equal([]string{"/c", "/a", "/b", "/d"}))
+	g.Expect(list.LargestN(10).Paths()).To(Equal([]string{"/c", "/a", "/b", "/d", "/e"}))
+	g.Expect(list.LargestN(0)).To(BeEmpty())
+
+	g.Expect(list.OldestN(2).Paths()).To(Equal([]string{"/c", "/a"}))
+	g.Expect(list.NewestN(3).Paths()).To(Equal([]string{"/b", "/e", "/d"}))
+
+	g.Expect(list.Paths()).To(Equal([]string{"/a", "/dir", "/b", "/c", "/d", "/e"}))
+}
+
+func TestFileInfoListDiff(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	older := NewFileInfoList(
+		NewFileInfo("/a", 1, t0),
+		NewFileInfo("/b", 1, t0),
+		NewFileInfo("/c", 1, t0).withObjectInfo(ObjectInfo{ETag: `"c1"`}),
+		NewFileInfo("/d", 1, t0),
+	)
+	newer := NewFileInfoList(
+		NewFileInfo("/e", 1, t0),
+		NewFileInfo("/d", 1, t0.Add(time.Second)),
+		NewFileInfo("/c", 1, t0.Add(time.Second)).withObjectInfo(ObjectInfo{ETag: `"c1"`}),
+		NewFileInfo("/a", 2, t0),
+	)
+
+	diff := older.Diff(newer)
+	g.Expect(diff.Added.Paths()).To(Equal([]string{"/e"}))
+	g.Expect(diff.Removed.Paths()).To(Equal([]string{"/b"}))
+	g.Expect(diff.Changed.Paths()).To(Equal([]string{"/d", "/a"}))
+	g.Expect(older.Diff(older).IsEmpty()).To(BeTrue())
+}
+
+func TestFileInfoListDuplicatesByETag(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	file := func(name string, size int64, etag string) FileInfo {
+		return NewFileInfo(name, size, t0).withObjectInfo(ObjectInfo{ETag: etag})
+	}
+	list := NewFileInfoList(
+		file("/a/1.jpg", 10, `"x"`),
+		file("/a/2.jpg", 20, `"y"`),
+		file("/b/1.jpg", 10, `"x"`),
+		file("/b/2.jpg", 20, `"z"`),
+		file("/c/1.jpg", 10, `"x"`),
+		file("/c/2.jpg", 30, `"y"`),
+		file("/c/3.jpg", 20, `"z"`),
+		file("/c/4.jpg", 5, ""),
+		file("/c/5.jpg", 5, ""),
+		NewDirectoryInfo("/d"),
+		NewDirectoryInfo("/e"),
+	)
+
+	groups := list.DuplicatesByETag()
+	g.Expect(groups).To(HaveLen(2))
+	g.Expect(groups[0].Paths()).To(Equal([]string{"/a/1.jpg", "/b/1.jpg", "/c/1.jpg"}))
+	g.Expect(groups[1].Paths()).To(Equal([]string{"/b/2.jpg", "/c/3.jpg"}))
+
+	plan := list.DedupPlan(nil)
+	g.Expect(plan).To(HaveLen(2))
+	g.Expect(plan[0].Keep.Path()).To(Equal("/a/1.jpg"))
+	g.Expect(plan[0].Remove.Paths()).To(Equal([]string{"/b/1.jpg", "/c/1.jpg"}))
+	g.Expect(plan.Removals().Paths()).To(Equal([]string{"/b/1.jpg", "/c/1.jpg", "/c/3.jpg"}))
+	g.Expect(plan.Saving()).To(Equal(int64(40)))
+
+	plan = list.DedupPlan(func(group FileInfoList) int { return len(group) - 1 })
+	g.Expect(plan[0].Keep.Path()).To(Equal("/c/1.jpg"))
+	g.Expect(plan[0].Remove.Paths()).To(Equal([]string{"/a/1.jpg", "/b/1.jpg"}))
+	g.Expect(plan[1].Keep.Path()).To(Equal("/c/3.jpg"))
+}
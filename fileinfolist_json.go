@@ -0,0 +1,56 @@
+package s3
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// fileInfoJSON is the JSON form of a FileInfo. Its fields must not be
+// renamed, because listings are persisted, e.g. as manifests.
+type fileInfoJSON struct {
+	Path    string     `json:"path"`
+	Size    int64      `json:"size"`
+	ModTime *time.Time `json:"modTime,omitempty"`
+	IsDir   bool       `json:"isDir,omitempty"`
+	ETag    string     `json:"etag,omitempty"`
+}
+
+// MarshalJSON encodes the list as a JSON array of objects holding the path,
+// size, modification time, ETag and whether each is a directory. Other
+// attributes, such as the content type and mode, are not included.
+func (list FileInfoList) MarshalJSON() ([]byte, error) {
+	wire := make([]fileInfoJSON, len(list))
+	for i, fi := range list {
+		wire[i] = fileInfoJSON{Path: fi.Path(), Size: fi.Size(), IsDir: fi.IsDir(), ETag: fi.ETag()}
+		if !fi.ModTime().IsZero() {
+			modTime := fi.ModTime()
+			wire[i].ModTime = &modTime
+		}
+	}
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON decodes a list encoded by MarshalJSON, replacing the
+// contents of the list.
+func (list *FileInfoList) UnmarshalJSON(data []byte) error {
+	var wire []fileInfoJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	result := MakeFileInfoList(0, len(wire))
+	for _, w := range wire {
+		var fi FileInfo
+		if w.IsDir {
+			fi = NewDirectoryInfo(w.Path)
+		} else {
+			fi = NewFileInfo(w.Path, w.Size, time.Time{}).withObjectInfo(ObjectInfo{ETag: w.ETag, Uid: -1, Gid: -1})
+		}
+		if w.ModTime != nil {
+			fi.modTime = *w.ModTime
+		}
+		result = append(result, fi)
+	}
+	*list = result
+	return nil
+}
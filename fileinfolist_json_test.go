@@ -0,0 +1,34 @@
+package s3
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFileInfoListJSON(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	list := NewFileInfoList(
+		NewDirectoryInfo("/a"),
+		NewFileInfo("/a/b.txt", 11, t0).withObjectInfo(ObjectInfo{ETag: `"abc"`}),
+	)
+
+	js, err := json.Marshal(list)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(js)).To(Equal(`[{"path":"/a","size":0,"isDir":true},` +
+		`{"path":"/a/b.txt","size":11,"modTime":"2020-01-01T00:00:00Z","etag":"\"abc\""}]`))
+
+	var decoded FileInfoList
+	g.Expect(json.Unmarshal(js, &decoded)).To(Succeed())
+	g.Expect(decoded).To(HaveLen(2))
+	g.Expect(decoded[0].Path()).To(Equal("/a"))
+	g.Expect(decoded[0].IsDir()).To(BeTrue())
+	g.Expect(decoded[1].Path()).To(Equal("/a/b.txt"))
+	g.Expect(decoded[1].Name()).To(Equal("b.txt"))
+	g.Expect(decoded[1].Size()).To(Equal(int64(11)))
+	g.Expect(decoded[1].ModTime().Equal(t0)).To(BeTrue())
+	g.Expect(decoded[1].ETag()).To(Equal(`"abc"`))
+	g.Expect(decoded[1].IsDir()).To(BeFalse())
+}
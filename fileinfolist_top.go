@@ -0,0 +1,101 @@
+package s3
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// LargestN returns a new list of the n largest files in the list, largest first. Directories are
+// not included. Files of equal size are in the order they are in the list.
+//
+// This takes O(m log n) time for a list of m elements, so it is much quicker than sorting a
+// large listing to take the first few.
+// The original list is not modified.
+func (list FileInfoList) LargestN(n int) FileInfoList {
+	return list.topN(n, func(i, j FileInfo) bool {
+		return i.Size() > j.Size()
+	})
+}
+
+// OldestN returns a new list of the n least recently modified files in the list, oldest first.
+// Directories are not included. Files modified at the same time are in the order they are in
+// the list. Like LargestN, this does not sort the whole list.
+// The original list is not modified.
+func (list FileInfoList) OldestN(n int) FileInfoList {
+	return list.topN(n, func(i, j FileInfo) bool {
+		return i.ModTime().Before(j.ModTime())
+	})
+}
+
+// NewestN returns a new list of the n most recently modified files in the list, newest first.
+// Directories are not included. Files modified at the same time are in the order they are in
+// the list. Like LargestN, this does not sort the whole list.
+// The original list is not modified.
+func (list FileInfoList) NewestN(n int) FileInfoList {
+	return list.topN(n, func(i, j FileInfo) bool {
+		return i.ModTime().After(j.ModTime())
+	})
+}
+
+// topN selects the first n files in the order given by before, using a heap
+// that holds the best n found so far, with the worst of them at the top.
+func (list FileInfoList) topN(n int, before func(i, j FileInfo) bool) FileInfoList {
+	if n <= 0 {
+		return MakeFileInfoList(0, 0)
+	}
+
+	h := &rankedHeap{before: before}
+	for i, fi := range list {
+		if fi.IsDir() {
+			continue
+		}
+		r := ranked{FileInfo: fi, index: i}
+		switch {
+		case len(h.items) < n:
+			heap.Push(h, r)
+		case h.better(r, h.items[0]):
+			h.items[0] = r
+			heap.Fix(h, 0)
+		}
+	}
+
+	sort.Slice(h.items, func(i, j int) bool { return h.better(h.items[i], h.items[j]) })
+	result := MakeFileInfoList(len(h.items), len(h.items))
+	for i, r := range h.items {
+		result[i] = r.FileInfo
+	}
+	return result
+}
+
+// ranked is a file and its position in the list, which breaks ties.
+type ranked struct {
+	FileInfo
+	index int
+}
+
+// rankedHeap implements heap.Interface with the worst item at the top.
+type rankedHeap struct {
+	items  []ranked
+	before func(i, j FileInfo) bool
+}
+
+func (h *rankedHeap) better(a, b ranked) bool {
+	switch {
+	case h.before(a.FileInfo, b.FileInfo):
+		return true
+	case h.before(b.FileInfo, a.FileInfo):
+		return false
+	}
+	return a.index < b.index
+}
+
+func (h *rankedHeap) Len() int           { return len(h.items) }
+func (h *rankedHeap) Less(i, j int) bool { return h.better(h.items[j], h.items[i]) }
+func (h *rankedHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *rankedHeap) Push(x interface{}) { h.items = append(h.items, x.(ranked)) }
+
+func (h *rankedHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
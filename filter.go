@@ -0,0 +1,65 @@
+package s3
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Filter is a predicate that selects the entries in a listing; see
+// ListOptions.Filters and ListObjects. The size and time filters select
+// every directory, because S3 has neither for directories.
+type Filter func(FileInfo) bool
+
+// NameHasSuffix selects the entries whose names end with a suffix, e.g. ".jpg".
+func NameHasSuffix(suffix string) Filter {
+	return func(fi FileInfo) bool {
+		return strings.HasSuffix(fi.Name(), suffix)
+	}
+}
+
+// NameMatches selects the entries whose names match a pattern, as for
+// path.Match, e.g. "error-*.json". A malformed pattern matches nothing.
+func NameMatches(pattern string) Filter {
+	return func(fi FileInfo) bool {
+		ok, _ := path.Match(pattern, fi.Name())
+		return ok
+	}
+}
+
+// PathMatches selects the entries whose paths, e.g. "/logs/2024/01/a.json",
+// match a regular expression.
+func PathMatches(re *regexp.Regexp) Filter {
+	return func(fi FileInfo) bool {
+		return re.MatchString(fi.Path())
+	}
+}
+
+// MinSize selects the files of at least the given size in bytes.
+func MinSize(bytes int64) Filter {
+	return func(fi FileInfo) bool {
+		return fi.IsDir() || fi.Size() >= bytes
+	}
+}
+
+// MaxSize selects the files of at most the given size in bytes.
+func MaxSize(bytes int64) Filter {
+	return func(fi FileInfo) bool {
+		return fi.IsDir() || fi.Size() <= bytes
+	}
+}
+
+// ModifiedAfter selects the files last modified after the given time.
+func ModifiedAfter(t time.Time) Filter {
+	return func(fi FileInfo) bool {
+		return fi.IsDir() || fi.ModTime().After(t)
+	}
+}
+
+// ModifiedBefore selects the files last modified before the given time.
+func ModifiedBefore(t time.Time) Filter {
+	return func(fi FileInfo) bool {
+		return fi.IsDir() || fi.ModTime().Before(t)
+	}
+}
@@ -0,0 +1,41 @@
+package s3
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestListFilters(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"/d/a.json", "/d/error-1.json", "/d/error-2.txt", "/d/sub/error-3.json"} {
+		mem.Now = func() time.Time { return t0.Add(time.Duration(i) * time.Hour) }
+		g.Expect(afero.WriteFile(fs, name, []byte(strings.Repeat("x", 10*(i+1))), 0644)).To(Succeed())
+	}
+
+	list, err := fs.ListObjects("/d", -1, true, NameMatches("error-*"), NameHasSuffix(".json"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(ConsistOf("/d/error-1.json", "/d/sub/error-3.json"))
+
+	list, err = fs.ListObjects("/d", -1, true, MinSize(20), MaxSize(30))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(ConsistOf("/d/error-1.json", "/d/error-2.txt"))
+
+	list, err = fs.ListObjects("/d", -1, true, ModifiedAfter(t0.Add(90*time.Minute)), ModifiedBefore(t0.Add(3*time.Hour)))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(ConsistOf("/d/error-2.txt"))
+
+	list, err = fs.NewLister("/d", ListOptions{Filters: []Filter{PathMatches(regexp.MustCompile(`/(sub|a\.)`)), MinSize(1)}}).List()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(ConsistOf("/d/a.json", "/d/sub"))
+}
@@ -0,0 +1,111 @@
+package s3
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Glob returns the names of all files and directories matching pattern, as
+// for afero.Glob and filepath.Glob, or nil if there are none. The only
+// possible error is path.ErrBadPattern, apart from errors from S3.
+//
+// Unlike afero.Glob, which reads every directory that might match, this
+// finds the leading elements of the pattern that have no wildcards and
+// lists only that directory. If only the last element has wildcards, as in
+// "logs/2024/error-*.json", the directory is listed using the delimiter "/";
+// otherwise, as in "logs/2024/*/error-*.json", everything below it is listed
+// without a delimiter. The rest of the pattern is matched using path.Match.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	start := fs.begin("Glob", pattern)
+	matches, err := fs.glob(pattern)
+	if err != nil {
+		err = pathError("glob", pattern, err)
+		fs.logOp("Glob", pattern, start, err)
+		return nil, err
+	}
+
+	fs.logOp("Glob", pattern, start, nil, "count", len(matches))
+	return matches, nil
+}
+
+func (fs Fs) glob(pattern string) ([]string, error) {
+	if !hasGlobMeta(pattern) {
+		if _, err := fs.lstat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	elements := strings.Split(pattern, PathSeparator)
+	i := 0
+	for !hasGlobMeta(elements[i]) {
+		i++
+	}
+	base := strings.Join(elements[:i], PathSeparator)
+	if base == "" && i > 0 {
+		base = PathSeparator // the pattern is absolute
+	}
+	dir := base
+	if dir == "" {
+		dir = PathSeparator
+	}
+
+	opts := ListOptions{Recursive: i < len(elements)-1}
+	it := fs.NewLister(dir, opts).Iterator()
+
+	// the name of each entry relative to the directory is found from its key;
+	// directories are implied by the files within them
+	prefix := fs.key(dir)
+	if prefix != "" {
+		prefix = addTrailingSlash(prefix)
+	}
+	depth := len(elements) - i
+
+	candidates := make(map[string]struct{})
+	for it.Next() {
+		key := fs.key(it.FileInfo().Path())
+		if len(key) <= len(prefix) {
+			continue // the marker of the directory itself
+		}
+
+		rel := key[len(prefix):]
+		for rel != "." {
+			if strings.Count(rel, PathSeparator)+1 == depth {
+				candidates[rel] = struct{}{}
+				break
+			}
+			rel = path.Dir(rel)
+		}
+	}
+	if err := it.Err(); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var matches []string
+	for rel := range candidates {
+		name := rel
+		if base != "" {
+			name = addTrailingSlash(base) + rel
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
@@ -0,0 +1,59 @@
+package s3
+
+import (
+	"path"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestGlob(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	for _, name := range []string{
+		"/logs/2024/01/error-1.json",
+		"/logs/2024/01/info-1.json",
+		"/logs/2024/02/error-2.json",
+		"/logs/2024/02/x/error-3.json",
+		"/logs/2024/error-0.json",
+		"/logs/2023/01/error-9.json",
+		"/top.txt",
+	} {
+		g.Expect(afero.WriteFile(fs, name, []byte("x"), 0644)).To(Succeed())
+	}
+
+	cases := map[string][]string{
+		"/logs/2024/*/error-*.json": {"/logs/2024/01/error-1.json", "/logs/2024/02/error-2.json"},
+		"logs/2024/*/error-*.json":  {"logs/2024/01/error-1.json", "logs/2024/02/error-2.json"},
+		"/logs/2024/*":              {"/logs/2024/01", "/logs/2024/02", "/logs/2024/error-0.json"},
+		"/logs/*/01":                {"/logs/2023/01", "/logs/2024/01"},
+		"/*.txt":                    {"/top.txt"},
+		"*":                         {"logs", "top.txt"},
+		"/top.txt":                  {"/top.txt"},
+		"/nothing/*":                nil,
+		"/nothing.txt":              nil,
+	}
+	for pattern, expected := range cases {
+		matches, err := fs.Glob(pattern)
+		g.Expect(err).NotTo(HaveOccurred(), pattern)
+		g.Expect(matches).To(Equal(expected), pattern)
+
+		if expected != nil {
+			aferoMatches, err := afero.Glob(fs, pattern)
+			g.Expect(err).NotTo(HaveOccurred(), pattern)
+			g.Expect(matches).To(Equal(aferoMatches), pattern)
+		}
+	}
+
+	lists := mem.Lists
+	_, err := fs.Glob("/logs/2024/*/error-*.json")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mem.Lists - lists).To(Equal(1))
+
+	_, err = fs.Glob("/logs/[")
+	g.Expect(err).To(Equal(path.ErrBadPattern))
+}
@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var (
+	errUnsupportedHeader = errors.New("the header cannot be set")
+	errHeadersSent       = errors.New("the headers have already been sent")
+)
+
+// objectHeaders holds the standard HTTP headers that are stored with an
+// object and returned when it is read.
+type objectHeaders struct {
+	cacheControl       *string
+	contentDisposition *string
+	contentEncoding    *string
+	contentLanguage    *string
+}
+
+// field gets the field for a header, or nil if it is not supported.
+func (h *objectHeaders) field(name string) **string {
+	switch http.CanonicalHeaderKey(name) {
+	case "Cache-Control":
+		return &h.cacheControl
+	case "Content-Disposition":
+		return &h.contentDisposition
+	case "Content-Encoding":
+		return &h.contentEncoding
+	case "Content-Language":
+		return &h.contentLanguage
+	}
+	return nil
+}
+
+func (h objectHeaders) applyToPut(input *s3.PutObjectInput) {
+	input.CacheControl = h.cacheControl
+	input.ContentDisposition = h.contentDisposition
+	input.ContentEncoding = h.contentEncoding
+	input.ContentLanguage = h.contentLanguage
+}
+
+func (h objectHeaders) applyToCreateMultipart(input *s3.CreateMultipartUploadInput) {
+	input.CacheControl = h.cacheControl
+	input.ContentDisposition = h.contentDisposition
+	input.ContentEncoding = h.contentEncoding
+	input.ContentLanguage = h.contentLanguage
+}
+
+// SetHeader sets one of the HTTP headers stored with the file when it is
+// written: Cache-Control, Content-Disposition, Content-Encoding or
+// Content-Language. Unlike the With methods, this changes the file itself,
+// so that headers computed while the file is being written can be set
+// before it is closed. They override any set by Fs.WithWriteRules. A blank
+// value removes the header.
+//
+// It fails if the header is not one of these, or if a multipart upload of
+// the file has already begun (see Fs.WithMultipartUpload).
+func (f *File) SetHeader(name, value string) error {
+	field := f.writeOpts.headers.field(name)
+	if field == nil {
+		return pathError("setheader", f.name, errUnsupportedHeader)
+	}
+	if f.multipart != nil {
+		return pathError("setheader", f.name, errHeadersSent)
+	}
+	*field = optionalString(value)
+	return nil
+}
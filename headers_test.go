@@ -0,0 +1,43 @@
+package s3
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+)
+
+func TestSetHeader(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &recordingBucket{Bucket: s3fake.New()}
+	fs := NewFs("mybucket", mem).
+		WithWriteRules(WriteRule{Path: "/static", CacheControl: "max-age=3600"})
+
+	af, err := fs.Create("/static/a.csv")
+	g.Expect(err).NotTo(HaveOccurred())
+	f := af.(*File)
+	_, err = f.WriteString("a,b,c\n")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.SetHeader("content-disposition", `attachment; filename="a.csv"`)).To(Succeed())
+	g.Expect(f.SetHeader("Content-Language", "en")).To(Succeed())
+	g.Expect(f.SetHeader("Cache-Control", "no-store")).To(Succeed())
+
+	err = f.SetHeader("X-Custom", "x")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.(*os.PathError).Err).To(Equal(errUnsupportedHeader))
+	g.Expect(f.Close()).To(Succeed())
+
+	af, err = fs.Create("/static/b.css")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(af.Close()).To(Succeed())
+
+	g.Expect(mem.puts).To(HaveLen(2))
+	g.Expect(aws.StringValue(mem.puts[0].ContentDisposition)).To(Equal(`attachment; filename="a.csv"`))
+	g.Expect(aws.StringValue(mem.puts[0].ContentLanguage)).To(Equal("en"))
+	g.Expect(aws.StringValue(mem.puts[0].CacheControl)).To(Equal("no-store"))
+	g.Expect(mem.puts[0].ContentEncoding).To(BeNil())
+	g.Expect(aws.StringValue(mem.puts[1].CacheControl)).To(Equal("max-age=3600"))
+}
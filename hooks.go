@@ -0,0 +1,233 @@
+package s3
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Request describes an S3 request, as seen by a Hook.
+type Request struct {
+	// Context is the context of the request. Before may replace it.
+	Context aws.Context
+	// Op is the name of the S3 operation, e.g. "HeadObject".
+	Op string
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Key is the object key, or the prefix for ListObjectsV2, or the
+	// destination key for CopyObject.
+	Key string
+	// Input is the S3 input, e.g. *s3.HeadObjectInput. Before may alter it.
+	Input interface{}
+	// Output is the S3 output, e.g. *s3.HeadObjectOutput. If Before sets
+	// Output (which must have the correct type) or Err, the request is not
+	// sent; this allows hooks to serve requests from a cache.
+	Output interface{}
+	// Err is the error, if any.
+	Err error
+	// Start is the time when the request was started.
+	Start time.Time
+	// Duration is the time taken by the request, including any retries.
+	// It is not known until After.
+	Duration time.Duration
+}
+
+// Hook is called before and after every S3 request made by the file system.
+// Hooks can be used for metrics, auditing, altering requests, caching and so
+// on. When there are several hooks, Before is called in the order they were
+// added, and After in the reverse order.
+type Hook interface {
+	Before(r *Request)
+	After(r *Request)
+}
+
+// HookFuncs is a Hook made from a pair of functions, either of which may be nil.
+type HookFuncs struct {
+	BeforeFunc func(r *Request)
+	AfterFunc  func(r *Request)
+}
+
+// Before calls BeforeFunc, if it is not nil.
+func (h HookFuncs) Before(r *Request) {
+	if h.BeforeFunc != nil {
+		h.BeforeFunc(r)
+	}
+}
+
+// After calls AfterFunc, if it is not nil.
+func (h HookFuncs) After(r *Request) {
+	if h.AfterFunc != nil {
+		h.AfterFunc(r)
+	}
+}
+
+// hookingAPI calls hooks around the requests to the S3 API it wraps.
+type hookingAPI struct {
+	S3APISubset
+	hooks []Hook
+}
+
+func (h *hookingAPI) call(ctx aws.Context, op string, bucket, key *string, input interface{}, send func(ctx aws.Context) (interface{}, error)) (interface{}, error) {
+	r := &Request{
+		Context: ctx,
+		Op:      op,
+		Bucket:  aws.StringValue(bucket),
+		Key:     aws.StringValue(key),
+		Input:   input,
+		Start:   time.Now(),
+	}
+
+	for _, hook := range h.hooks {
+		hook.Before(r)
+	}
+
+	if r.Output == nil && r.Err == nil {
+		r.Output, r.Err = send(r.Context)
+	}
+
+	r.Duration = time.Since(r.Start)
+
+	for i := len(h.hooks) - 1; i >= 0; i-- {
+		h.hooks[i].After(r)
+	}
+
+	return r.Output, r.Err
+}
+
+func (h *hookingAPI) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	output, err := h.call(ctx, "AbortMultipartUpload", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.AbortMultipartUploadWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.AbortMultipartUploadOutput)
+	return out, err
+}
+
+func (h *hookingAPI) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	output, err := h.call(ctx, "CompleteMultipartUpload", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.CompleteMultipartUploadWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.CompleteMultipartUploadOutput)
+	return out, err
+}
+
+func (h *hookingAPI) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	output, err := h.call(ctx, "CopyObject", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.CopyObjectWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.CopyObjectOutput)
+	return out, err
+}
+
+func (h *hookingAPI) CreateBucketWithContext(ctx aws.Context, input *s3.CreateBucketInput, opts ...request.Option) (*s3.CreateBucketOutput, error) {
+	output, err := h.call(ctx, "CreateBucket", input.Bucket, nil, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.CreateBucketWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.CreateBucketOutput)
+	return out, err
+}
+
+func (h *hookingAPI) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	output, err := h.call(ctx, "CreateMultipartUpload", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.CreateMultipartUploadWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.CreateMultipartUploadOutput)
+	return out, err
+}
+
+func (h *hookingAPI) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	output, err := h.call(ctx, "DeleteObject", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.DeleteObjectWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.DeleteObjectOutput)
+	return out, err
+}
+
+func (h *hookingAPI) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	output, err := h.call(ctx, "GetObject", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.GetObjectWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.GetObjectOutput)
+	return out, err
+}
+
+func (h *hookingAPI) GetObjectAttributesWithContext(ctx aws.Context, input *s3.GetObjectAttributesInput, opts ...request.Option) (*s3.GetObjectAttributesOutput, error) {
+	output, err := h.call(ctx, "GetObjectAttributes", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.GetObjectAttributesWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.GetObjectAttributesOutput)
+	return out, err
+}
+
+func (h *hookingAPI) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	output, err := h.call(ctx, "HeadObject", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.HeadObjectWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.HeadObjectOutput)
+	return out, err
+}
+
+func (h *hookingAPI) ListMultipartUploadsWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, opts ...request.Option) (*s3.ListMultipartUploadsOutput, error) {
+	output, err := h.call(ctx, "ListMultipartUploads", input.Bucket, input.Prefix, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.ListMultipartUploadsWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.ListMultipartUploadsOutput)
+	return out, err
+}
+
+func (h *hookingAPI) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	output, err := h.call(ctx, "ListObjectsV2", input.Bucket, input.Prefix, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.ListObjectsV2WithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.ListObjectsV2Output)
+	return out, err
+}
+
+func (h *hookingAPI) ListObjectVersionsWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, opts ...request.Option) (*s3.ListObjectVersionsOutput, error) {
+	output, err := h.call(ctx, "ListObjectVersions", input.Bucket, input.Prefix, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.ListObjectVersionsWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.ListObjectVersionsOutput)
+	return out, err
+}
+
+func (h *hookingAPI) ListPartsWithContext(ctx aws.Context, input *s3.ListPartsInput, opts ...request.Option) (*s3.ListPartsOutput, error) {
+	output, err := h.call(ctx, "ListParts", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.ListPartsWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.ListPartsOutput)
+	return out, err
+}
+
+func (h *hookingAPI) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	output, err := h.call(ctx, "PutObject", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.PutObjectWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.PutObjectOutput)
+	return out, err
+}
+
+func (h *hookingAPI) SelectObjectContentWithContext(ctx aws.Context, input *s3.SelectObjectContentInput, opts ...request.Option) (*s3.SelectObjectContentOutput, error) {
+	output, err := h.call(ctx, "SelectObjectContent", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.SelectObjectContentWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.SelectObjectContentOutput)
+	return out, err
+}
+
+func (h *hookingAPI) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	output, err := h.call(ctx, "UploadPart", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.UploadPartWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.UploadPartOutput)
+	return out, err
+}
+
+func (h *hookingAPI) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	output, err := h.call(ctx, "UploadPartCopy", input.Bucket, input.Key, input, func(ctx aws.Context) (interface{}, error) {
+		return h.S3APISubset.UploadPartCopyWithContext(ctx, input, opts...)
+	})
+	out, _ := output.(*s3.UploadPartCopyOutput)
+	return out, err
+}
@@ -0,0 +1,33 @@
+package s3
+
+import (
+	"net/http"
+)
+
+// httpFileSystem adapts an Fs to http.FileSystem.
+type httpFileSystem struct {
+	fs Fs
+}
+
+// HTTPFileSystem adapts the file system for use with http.FileServer, for
+// example
+//
+//	http.Handle("/media/", http.StripPrefix("/media", http.FileServer(fs.HTTPFileSystem())))
+//
+// Files are downloaded as they are read, so the request for a range of a
+// file, e.g. by a media player, only downloads the part that is needed: a
+// Seek followed by Read sends a ranged GET request to S3, starting at the
+// offset. The sizes of files are found when they are opened, so Seek
+// relative to the end doesn't need another request.
+func (fs Fs) HTTPFileSystem() http.FileSystem {
+	return httpFileSystem{fs: fs}
+}
+
+// Open opens a file or directory. The name is always an absolute path.
+func (h httpFileSystem) Open(name string) (http.File, error) {
+	f, err := h.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.(*File), nil
+}
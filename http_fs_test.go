@@ -0,0 +1,39 @@
+package s3
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestHTTPFileSystemRange(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	g.Expect(afero.WriteFile(fs, "/media/a.txt", []byte("0123456789"), 0644)).To(Succeed())
+
+	handler := http.FileServer(fs.HTTPFileSystem())
+
+	req := httptest.NewRequest("GET", "/media/a.txt", nil)
+	req.Header.Set("Range", "bytes=4-6")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	g.Expect(w.Code).To(Equal(http.StatusPartialContent))
+	g.Expect(w.Header().Get("Content-Range")).To(Equal("bytes 4-6/10"))
+	body, _ := ioutil.ReadAll(w.Body)
+	g.Expect(string(body)).To(Equal("456"))
+	g.Expect(mem.Ranges).To(ContainElement("bytes=4-"))
+	g.Expect(mem.Ranges).NotTo(ContainElement(""))
+
+	req = httptest.NewRequest("GET", "/media/b.txt", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	g.Expect(w.Code).To(Equal(http.StatusNotFound))
+}
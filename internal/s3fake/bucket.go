@@ -0,0 +1,466 @@
+// Package s3fake provides an in-memory fake of an S3 bucket, for testing.
+package s3fake
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Bucket is an in-memory fake of a single S3 bucket, for tests that need
+// objects to be written and read back. It implements the subset of the S3
+// API used by the file system. Apart from counting them, it does not record
+// the requests.
+type Bucket struct {
+	mu      sync.Mutex
+	objects map[string]object
+
+	// Now gets the LastModified time of objects as they are written.
+	Now func() time.Time
+
+	// Versioning keeps every version of each object, as in a bucket with
+	// versioning enabled.
+	Versioning  bool
+	versions    map[string][]version // oldest first
+	nextVersion int
+
+	// MinPartSize is the smallest size of every part of a multipart upload
+	// but the last; S3 requires 5 MiB, which is the default.
+	MinPartSize int
+	uploads     map[string]*upload // by upload ID
+	nextUpload  int
+
+	Gets   int      // the number of GetObject and SelectObjectContent requests
+	Heads  int      // the number of HeadObject requests
+	Lists  int      // the number of ListObjectsV2 and ListObjectVersions requests
+	Puts   int      // the number of PutObject requests
+	Parts  int      // the number of UploadPart and UploadPartCopy requests
+	Ranges []string // the Range of each GetObject request, or blank
+}
+
+// version is one version of an object in a versioned bucket.
+type version struct {
+	object
+	deleteMarker bool
+}
+
+type object struct {
+	versionId    string
+	data         []byte
+	metadata     map[string]*string
+	contentType  *string
+	lastModified time.Time
+	etag         string
+	checksums    s3.Checksum // as given when the object was put
+	expires      *time.Time
+}
+
+// New creates an empty bucket.
+func New() *Bucket {
+	return &Bucket{
+		objects:     make(map[string]object),
+		uploads:     make(map[string]*upload),
+		Now:         func() time.Time { return time.Now().Truncate(time.Second) },
+		MinPartSize: 5 << 20,
+	}
+}
+
+func notFound() error {
+	return awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, "")
+}
+
+func noSuchKey() error {
+	return awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil), 404, "")
+}
+
+func (m *Bucket) put(key string, data []byte, metadata map[string]*string, contentType *string) object {
+	obj := object{
+		data:         data,
+		metadata:     metadata,
+		contentType:  contentType,
+		lastModified: m.Now(),
+		etag:         fmt.Sprintf(`"%x"`, md5.Sum(data)),
+	}
+	if m.Versioning {
+		m.nextVersion++
+		obj.versionId = fmt.Sprintf("v%d", m.nextVersion)
+		m.addVersion(key, version{object: obj})
+	}
+	m.objects[key] = obj
+	return obj
+}
+
+func (m *Bucket) addVersion(key string, v version) {
+	if m.versions == nil {
+		m.versions = make(map[string][]version)
+	}
+	m.versions[key] = append(m.versions[key], v)
+}
+
+// findVersion gets a version of an object, which may be a delete marker.
+func (m *Bucket) findVersion(key, versionId string) (version, bool) {
+	for _, v := range m.versions[key] {
+		if v.versionId == versionId {
+			return v, true
+		}
+	}
+	return version{}, false
+}
+
+func noSuchVersion() error {
+	return awserr.NewRequestFailure(awserr.New("NoSuchVersion", "The specified version does not exist.", nil), 404, "")
+}
+
+// CreateBucketWithContext fails because the bucket already exists.
+func (m *Bucket) CreateBucketWithContext(ctx aws.Context, req *s3.CreateBucketInput, opts ...request.Option) (*s3.CreateBucketOutput, error) {
+	return nil, awserr.NewRequestFailure(awserr.New(s3.ErrCodeBucketAlreadyOwnedByYou, "Your previous request to create the named bucket succeeded and you already own it.", nil), 409, "")
+}
+
+func (m *Bucket) CopyObjectWithContext(ctx aws.Context, req *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source := aws.StringValue(req.CopySource)
+	source = source[strings.IndexByte(source, '/')+1:]
+	obj, exists := m.objects[source]
+	if !exists {
+		return nil, noSuchKey()
+	}
+
+	metadata, contentType, expires := obj.metadata, obj.contentType, obj.expires
+	if aws.StringValue(req.MetadataDirective) == s3.MetadataDirectiveReplace {
+		metadata, contentType, expires = req.Metadata, req.ContentType, req.Expires
+	}
+	copied := m.put(aws.StringValue(req.Key), obj.data, metadata, contentType)
+	copied.checksums = obj.checksums
+	copied.expires = expires
+	m.objects[aws.StringValue(req.Key)] = copied
+	return &s3.CopyObjectOutput{
+		CopyObjectResult: &s3.CopyObjectResult{ETag: aws.String(copied.etag)},
+		VersionId:        optionalString(copied.versionId),
+	}, nil
+}
+
+func (m *Bucket) DeleteObjectWithContext(ctx aws.Context, req *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := aws.StringValue(req.Key)
+	if !m.Versioning {
+		delete(m.objects, key)
+		return &s3.DeleteObjectOutput{}, nil
+	}
+
+	if req.VersionId == nil {
+		// a delete marker hides the object
+		m.nextVersion++
+		marker := version{object: object{versionId: fmt.Sprintf("v%d", m.nextVersion), lastModified: m.Now()}, deleteMarker: true}
+		m.addVersion(key, marker)
+		delete(m.objects, key)
+		return &s3.DeleteObjectOutput{DeleteMarker: aws.Bool(true), VersionId: aws.String(marker.versionId)}, nil
+	}
+
+	// deleting a version permanently removes it, revealing the one before
+	versions := m.versions[key]
+	var deleted version
+	for i, v := range versions {
+		if v.versionId == *req.VersionId {
+			deleted = v
+			versions = append(versions[:i:i], versions[i+1:]...)
+			break
+		}
+	}
+	m.versions[key] = versions
+	delete(m.objects, key)
+	if n := len(versions); n > 0 && !versions[n-1].deleteMarker {
+		m.objects[key] = versions[n-1].object
+	}
+	return &s3.DeleteObjectOutput{DeleteMarker: aws.Bool(deleted.deleteMarker), VersionId: req.VersionId}, nil
+}
+
+func (m *Bucket) GetObjectWithContext(ctx aws.Context, req *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Gets++
+	obj, exists := m.objects[aws.StringValue(req.Key)]
+	if req.VersionId != nil {
+		v, found := m.findVersion(aws.StringValue(req.Key), *req.VersionId)
+		if !found || v.deleteMarker {
+			return nil, noSuchVersion()
+		}
+		obj, exists = v.object, true
+	}
+	if !exists {
+		return nil, noSuchKey()
+	}
+	if (req.IfNoneMatch != nil && *req.IfNoneMatch == obj.etag) ||
+		(req.IfNoneMatch == nil && req.IfModifiedSince != nil && !obj.lastModified.After(*req.IfModifiedSince)) {
+		return nil, awserr.NewRequestFailure(awserr.New("NotModified", "Not Modified", nil), 304, "")
+	}
+
+	data, contentRange := obj.data, (*string)(nil)
+	if req.Range != nil {
+		first, last := 0, -1
+		fmt.Sscanf(*req.Range, "bytes=%d-%d", &first, &last)
+		if first >= len(data) {
+			return nil, awserr.NewRequestFailure(awserr.New("InvalidRange", "The requested range is not satisfiable", nil), 416, "")
+		}
+		if last < 0 || last >= len(data) {
+			last = len(data) - 1
+		}
+		data = data[first : last+1]
+		contentRange = aws.String(fmt.Sprintf("bytes %d-%d/%d", first, last, len(obj.data)))
+	}
+	m.Ranges = append(m.Ranges, aws.StringValue(req.Range))
+
+	out := &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentRange:  contentRange,
+		ContentType:   obj.contentType,
+		LastModified:  aws.Time(obj.lastModified),
+		ETag:          aws.String(obj.etag),
+		Metadata:      obj.metadata,
+		VersionId:     optionalString(obj.versionId),
+	}
+	if aws.StringValue(req.ChecksumMode) == s3.ChecksumModeEnabled && req.Range == nil {
+		out.ChecksumCRC32 = obj.checksums.ChecksumCRC32
+		out.ChecksumCRC32C = obj.checksums.ChecksumCRC32C
+		out.ChecksumSHA1 = obj.checksums.ChecksumSHA1
+		out.ChecksumSHA256 = obj.checksums.ChecksumSHA256
+	}
+	return out, nil
+}
+
+func (m *Bucket) GetObjectAttributesWithContext(ctx aws.Context, req *s3.GetObjectAttributesInput, opts ...request.Option) (*s3.GetObjectAttributesOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, exists := m.objects[aws.StringValue(req.Key)]
+	if !exists {
+		return nil, noSuchKey()
+	}
+	return &s3.GetObjectAttributesOutput{
+		ObjectSize:   aws.Int64(int64(len(obj.data))),
+		LastModified: aws.Time(obj.lastModified),
+		ETag:         aws.String(obj.etag),
+	}, nil
+}
+
+func (m *Bucket) HeadObjectWithContext(ctx aws.Context, req *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Heads++
+	obj, exists := m.objects[aws.StringValue(req.Key)]
+	if !exists {
+		return nil, notFound()
+	}
+	out := &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(obj.data))),
+		ContentType:   obj.contentType,
+		LastModified:  aws.Time(obj.lastModified),
+		ETag:          aws.String(obj.etag),
+		Metadata:      obj.metadata,
+		VersionId:     optionalString(obj.versionId),
+	}
+	if obj.expires != nil {
+		out.Expires = aws.String(obj.expires.UTC().Format(http.TimeFormat))
+	}
+	if aws.StringValue(req.ChecksumMode) == s3.ChecksumModeEnabled {
+		out.ChecksumCRC32 = obj.checksums.ChecksumCRC32
+		out.ChecksumCRC32C = obj.checksums.ChecksumCRC32C
+		out.ChecksumSHA1 = obj.checksums.ChecksumSHA1
+		out.ChecksumSHA256 = obj.checksums.ChecksumSHA256
+	}
+	return out, nil
+}
+
+func (m *Bucket) ListObjectsV2WithContext(ctx aws.Context, req *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Lists++
+	prefix := aws.StringValue(req.Prefix)
+	delimiter := aws.StringValue(req.Delimiter)
+	after := aws.StringValue(req.StartAfter)
+	if req.ContinuationToken != nil {
+		after = *req.ContinuationToken
+	}
+	maxKeys := int(aws.Int64Value(req.MaxKeys))
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	keys := make([]string, 0, len(m.objects))
+	for k := range m.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	output := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	count := 0
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) || k <= after {
+			continue
+		}
+
+		cp := ""
+		if i := strings.Index(k[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			cp = k[:len(prefix)+i+len(delimiter)]
+		}
+
+		if count == maxKeys {
+			output.IsTruncated = aws.Bool(true)
+			output.NextContinuationToken = aws.String(after)
+			break
+		}
+		count++
+		after = k
+
+		if cp != "" {
+			// skip the rest of the keys with this prefix
+			after = cp + "\xff"
+			output.CommonPrefixes = append(output.CommonPrefixes, &s3.CommonPrefix{Prefix: aws.String(cp)})
+			continue
+		}
+
+		obj := m.objects[k]
+		output.Contents = append(output.Contents, &s3.Object{
+			Key:          aws.String(k),
+			Size:         aws.Int64(int64(len(obj.data))),
+			LastModified: aws.Time(obj.lastModified),
+			ETag:         aws.String(obj.etag),
+		})
+	}
+	output.KeyCount = aws.Int64(int64(count))
+	return output, nil
+}
+
+func (m *Bucket) PutObjectWithContext(ctx aws.Context, req *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	var data []byte
+	if req.Body != nil {
+		var err error
+		if data, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Puts++
+	key := aws.StringValue(req.Key)
+	if _, exists := m.objects[key]; exists && headers(opts).Get("If-None-Match") == "*" {
+		return nil, awserr.NewRequestFailure(awserr.New("PreconditionFailed", "At least one of the pre-conditions you specified did not hold", nil), 412, "")
+	}
+	obj := m.put(key, data, req.Metadata, req.ContentType)
+	obj.checksums = s3.Checksum{
+		ChecksumCRC32:  req.ChecksumCRC32,
+		ChecksumCRC32C: req.ChecksumCRC32C,
+		ChecksumSHA1:   req.ChecksumSHA1,
+		ChecksumSHA256: req.ChecksumSHA256,
+	}
+	obj.expires = req.Expires
+	m.objects[key] = obj
+	return &s3.PutObjectOutput{ETag: aws.String(obj.etag), VersionId: optionalString(obj.versionId)}, nil
+}
+
+func (m *Bucket) ListObjectVersionsWithContext(ctx aws.Context, req *s3.ListObjectVersionsInput, opts ...request.Option) (*s3.ListObjectVersionsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Lists++
+
+	var keys []string
+	for key := range m.versions {
+		if strings.HasPrefix(key, aws.StringValue(req.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	maxKeys := int(aws.Int64Value(req.MaxKeys))
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	keyMarker, versionMarker := aws.StringValue(req.KeyMarker), aws.StringValue(req.VersionIdMarker)
+	skipping := keyMarker != ""
+
+	out := &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(false)}
+	count := 0
+	for _, key := range keys {
+		versions := m.versions[key]
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			if skipping {
+				if key < keyMarker || (key == keyMarker && versionMarker == "") {
+					continue
+				}
+				if key == keyMarker {
+					if v.versionId == versionMarker {
+						skipping = false
+					}
+					continue
+				}
+				skipping = false
+			}
+
+			if count == maxKeys {
+				out.IsTruncated = aws.Bool(true)
+				return out, nil
+			}
+			count++
+			out.NextKeyMarker, out.NextVersionIdMarker = aws.String(key), aws.String(v.versionId)
+
+			latest := i == len(versions)-1
+			if v.deleteMarker {
+				out.DeleteMarkers = append(out.DeleteMarkers, &s3.DeleteMarkerEntry{
+					Key:          aws.String(key),
+					VersionId:    aws.String(v.versionId),
+					IsLatest:     aws.Bool(latest),
+					LastModified: aws.Time(v.lastModified),
+				})
+			} else {
+				out.Versions = append(out.Versions, &s3.ObjectVersion{
+					Key:          aws.String(key),
+					VersionId:    aws.String(v.versionId),
+					IsLatest:     aws.Bool(latest),
+					LastModified: aws.Time(v.lastModified),
+					ETag:         aws.String(v.etag),
+					Size:         aws.Int64(int64(len(v.data))),
+				})
+			}
+		}
+	}
+	out.NextKeyMarker, out.NextVersionIdMarker = nil, nil
+	return out, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// headers gets the HTTP headers set by request options, such as those made
+// by request.WithSetRequestHeaders.
+func headers(opts []request.Option) http.Header {
+	r := &request.Request{HTTPRequest: &http.Request{Header: http.Header{}}}
+	r.ApplyOptions(opts...)
+	r.Handlers.Build.Run(r)
+	return r.HTTPRequest.Header
+}
@@ -0,0 +1,308 @@
+package s3fake
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// upload is an incomplete multipart upload.
+type upload struct {
+	key         string
+	id          string
+	initiated   time.Time
+	metadata    map[string]*string
+	contentType *string
+	checksum    *string // the additional checksum algorithm, if any
+	parts       map[int64]part
+}
+
+type part struct {
+	data         []byte
+	etag         string
+	lastModified time.Time
+	checksums    partChecksums
+}
+
+// partChecksums are the additional checksums of a part, as sent by the
+// client; they are not verified.
+type partChecksums struct {
+	crc32, crc32c, sha1, sha256 *string
+}
+
+// get gets the checksum for an algorithm.
+func (c partChecksums) get(algorithm string) *string {
+	switch algorithm {
+	case s3.ChecksumAlgorithmCrc32:
+		return c.crc32
+	case s3.ChecksumAlgorithmCrc32c:
+		return c.crc32c
+	case s3.ChecksumAlgorithmSha1:
+		return c.sha1
+	case s3.ChecksumAlgorithmSha256:
+		return c.sha256
+	}
+	return nil
+}
+
+func noSuchUpload() error {
+	return awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchUpload, "The specified upload does not exist.", nil), 404, "")
+}
+
+func badRequest(code, message string) error {
+	return awserr.NewRequestFailure(awserr.New(code, message, nil), 400, "")
+}
+
+// findUpload gets an upload, which must be for the given key.
+func (m *Bucket) findUpload(key, uploadId *string) (*upload, error) {
+	u, exists := m.uploads[aws.StringValue(uploadId)]
+	if !exists || u.key != aws.StringValue(key) {
+		return nil, noSuchUpload()
+	}
+	return u, nil
+}
+
+func (m *Bucket) addPart(u *upload, number int64, data []byte, checksums partChecksums) part {
+	m.Parts++
+	p := part{data: data, etag: fmt.Sprintf(`"%x"`, md5.Sum(data)), lastModified: m.Now(), checksums: checksums}
+	u.parts[number] = p
+	return p
+}
+
+func (m *Bucket) CreateMultipartUploadWithContext(ctx aws.Context, req *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextUpload++
+	u := &upload{
+		key:         aws.StringValue(req.Key),
+		id:          fmt.Sprintf("upload%d", m.nextUpload),
+		initiated:   m.Now(),
+		metadata:    req.Metadata,
+		contentType: req.ContentType,
+		checksum:    req.ChecksumAlgorithm,
+		parts:       make(map[int64]part),
+	}
+	m.uploads[u.id] = u
+	return &s3.CreateMultipartUploadOutput{Bucket: req.Bucket, Key: req.Key, UploadId: aws.String(u.id)}, nil
+}
+
+func (m *Bucket) UploadPartWithContext(ctx aws.Context, req *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	var data []byte
+	if req.Body != nil {
+		var err error
+		if data, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.findUpload(req.Key, req.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	checksums := partChecksums{req.ChecksumCRC32, req.ChecksumCRC32C, req.ChecksumSHA1, req.ChecksumSHA256}
+	p := m.addPart(u, aws.Int64Value(req.PartNumber), data, checksums)
+	return &s3.UploadPartOutput{ETag: aws.String(p.etag)}, nil
+}
+
+func (m *Bucket) UploadPartCopyWithContext(ctx aws.Context, req *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.findUpload(req.Key, req.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	source := aws.StringValue(req.CopySource)
+	source = source[strings.IndexByte(source, '/')+1:]
+	obj, exists := m.objects[source]
+	if !exists {
+		return nil, noSuchKey()
+	}
+	if req.CopySourceIfMatch != nil && *req.CopySourceIfMatch != obj.etag {
+		return nil, awserr.NewRequestFailure(awserr.New("PreconditionFailed", "At least one of the pre-conditions you specified did not hold", nil), 412, "")
+	}
+
+	data := obj.data
+	if req.CopySourceRange != nil {
+		first, last := 0, -1
+		fmt.Sscanf(*req.CopySourceRange, "bytes=%d-%d", &first, &last)
+		if first > last || last >= len(data) {
+			return nil, badRequest("InvalidArgument", "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+		}
+		data = data[first : last+1]
+	}
+	p := m.addPart(u, aws.Int64Value(req.PartNumber), data, partChecksums{})
+	return &s3.UploadPartCopyOutput{
+		CopyPartResult: &s3.CopyPartResult{ETag: aws.String(p.etag), LastModified: aws.Time(p.lastModified)},
+	}, nil
+}
+
+func (m *Bucket) CompleteMultipartUploadWithContext(ctx aws.Context, req *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.findUpload(req.Key, req.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []*s3.CompletedPart
+	if req.MultipartUpload != nil {
+		completed = req.MultipartUpload.Parts
+	}
+	if len(completed) == 0 {
+		return nil, badRequest("MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.")
+	}
+
+	var data []byte
+	hashes := md5.New()
+	previous := int64(0)
+	for i, c := range completed {
+		number := aws.Int64Value(c.PartNumber)
+		p, exists := u.parts[number]
+		if !exists || p.etag != aws.StringValue(c.ETag) {
+			return nil, badRequest("InvalidPart", "One or more of the specified parts could not be found.")
+		}
+		if algorithm := aws.StringValue(u.checksum); algorithm != "" {
+			sent := partChecksums{c.ChecksumCRC32, c.ChecksumCRC32C, c.ChecksumSHA1, c.ChecksumSHA256}.get(algorithm)
+			if aws.StringValue(sent) != aws.StringValue(p.checksums.get(algorithm)) {
+				return nil, badRequest("InvalidPart", "One or more of the specified parts could not be found.")
+			}
+		}
+		if number <= previous {
+			return nil, badRequest("InvalidPartOrder", "The list of parts was not in ascending order.")
+		}
+		if i < len(completed)-1 && len(p.data) < m.MinPartSize {
+			return nil, badRequest("EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.")
+		}
+		previous = number
+		data = append(data, p.data...)
+		sum := md5.Sum(p.data)
+		hashes.Write(sum[:])
+	}
+
+	delete(m.uploads, u.id)
+	obj := m.put(u.key, data, u.metadata, u.contentType)
+	obj.etag = fmt.Sprintf(`"%x-%d"`, hashes.Sum(nil), len(completed))
+	m.objects[u.key] = obj
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket:    req.Bucket,
+		Key:       req.Key,
+		ETag:      aws.String(obj.etag),
+		VersionId: optionalString(obj.versionId),
+	}, nil
+}
+
+func (m *Bucket) AbortMultipartUploadWithContext(ctx aws.Context, req *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.findUpload(req.Key, req.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	delete(m.uploads, u.id)
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (m *Bucket) ListMultipartUploadsWithContext(ctx aws.Context, req *s3.ListMultipartUploadsInput, opts ...request.Option) (*s3.ListMultipartUploadsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Lists++
+	var uploads []*upload
+	for _, u := range m.uploads {
+		if strings.HasPrefix(u.key, aws.StringValue(req.Prefix)) {
+			uploads = append(uploads, u)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].key != uploads[j].key {
+			return uploads[i].key < uploads[j].key
+		}
+		return uploads[i].id < uploads[j].id
+	})
+
+	maxUploads := int(aws.Int64Value(req.MaxUploads))
+	if maxUploads <= 0 {
+		maxUploads = 1000
+	}
+	keyMarker, uploadMarker := aws.StringValue(req.KeyMarker), aws.StringValue(req.UploadIdMarker)
+
+	out := &s3.ListMultipartUploadsOutput{IsTruncated: aws.Bool(false)}
+	for _, u := range uploads {
+		if keyMarker != "" && (u.key < keyMarker || (u.key == keyMarker && (uploadMarker == "" || u.id <= uploadMarker))) {
+			continue
+		}
+		if len(out.Uploads) == maxUploads {
+			out.IsTruncated = aws.Bool(true)
+			return out, nil
+		}
+		out.NextKeyMarker, out.NextUploadIdMarker = aws.String(u.key), aws.String(u.id)
+		out.Uploads = append(out.Uploads, &s3.MultipartUpload{
+			Key:       aws.String(u.key),
+			UploadId:  aws.String(u.id),
+			Initiated: aws.Time(u.initiated),
+		})
+	}
+	out.NextKeyMarker, out.NextUploadIdMarker = nil, nil
+	return out, nil
+}
+
+func (m *Bucket) ListPartsWithContext(ctx aws.Context, req *s3.ListPartsInput, opts ...request.Option) (*s3.ListPartsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.findUpload(req.Key, req.UploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int64, 0, len(u.parts))
+	for n := range u.parts {
+		numbers = append(numbers, n)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	maxParts := int(aws.Int64Value(req.MaxParts))
+	if maxParts <= 0 {
+		maxParts = 1000
+	}
+
+	out := &s3.ListPartsOutput{Key: req.Key, UploadId: req.UploadId, ChecksumAlgorithm: u.checksum, IsTruncated: aws.Bool(false)}
+	for _, n := range numbers {
+		if n <= aws.Int64Value(req.PartNumberMarker) {
+			continue
+		}
+		if len(out.Parts) == maxParts {
+			out.IsTruncated = aws.Bool(true)
+			return out, nil
+		}
+		p := u.parts[n]
+		out.NextPartNumberMarker = aws.Int64(n)
+		out.Parts = append(out.Parts, &s3.Part{
+			PartNumber:     aws.Int64(n),
+			ETag:           aws.String(p.etag),
+			Size:           aws.Int64(int64(len(p.data))),
+			LastModified:   aws.Time(p.lastModified),
+			ChecksumCRC32:  p.checksums.crc32,
+			ChecksumCRC32C: p.checksums.crc32c,
+			ChecksumSHA1:   p.checksums.sha1,
+			ChecksumSHA256: p.checksums.sha256,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package s3fake
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// SelectObjectContentWithContext supports only the query that selects
+// everything, "SELECT * FROM S3Object", which returns the content unchanged
+// as a single records event. Other queries fail.
+func (m *Bucket) SelectObjectContentWithContext(ctx aws.Context, req *s3.SelectObjectContentInput, opts ...request.Option) (*s3.SelectObjectContentOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Gets++
+	obj, exists := m.objects[aws.StringValue(req.Key)]
+	if !exists {
+		return nil, noSuchKey()
+	}
+
+	if !strings.EqualFold(strings.Join(strings.Fields(aws.StringValue(req.Expression)), " "), "SELECT * FROM S3Object") {
+		return nil, awserr.NewRequestFailure(awserr.New("UnsupportedSqlOperation", "The fake supports only SELECT * FROM S3Object.", nil), 400, "")
+	}
+
+	events := make(chan s3.SelectObjectContentEventStreamEvent, 3)
+	data := append([]byte(nil), obj.data...)
+	events <- &s3.RecordsEvent{Payload: data}
+	events <- &s3.StatsEvent{Details: &s3.Stats{
+		BytesScanned:   aws.Int64(int64(len(data))),
+		BytesProcessed: aws.Int64(int64(len(data))),
+		BytesReturned:  aws.Int64(int64(len(data))),
+	}}
+	events <- &s3.EndEvent{}
+	close(events)
+
+	stream := s3.NewSelectObjectContentEventStream(func(es *s3.SelectObjectContentEventStream) {
+		es.Reader = eventReader{events: events}
+		es.StreamCloser = es.Reader
+	})
+	return &s3.SelectObjectContentOutput{EventStream: stream}, nil
+}
+
+// eventReader delivers events that are already known.
+type eventReader struct {
+	events chan s3.SelectObjectContentEventStreamEvent
+}
+
+func (r eventReader) Events() <-chan s3.SelectObjectContentEventStreamEvent { return r.events }
+func (r eventReader) Close() error                                          { return nil }
+func (r eventReader) Err() error                                            { return nil }
@@ -0,0 +1,220 @@
+package s3
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var errInventoryBucket = errors.New("inventory is of another bucket")
+
+// Inventory reads the reports produced by S3 Inventory for the bucket of a
+// file system. For buckets with many millions of objects, these are much
+// quicker and cheaper to read than a listing, albeit up to a day or a week
+// out of date, so they suit offline analyses and planning a sync.
+//
+// Only the CSV format is supported; the ORC and Parquet formats are not.
+type Inventory struct {
+	s3Fs     Fs // the file system whose bucket the inventory is of
+	reports  Fs
+	manifest string
+}
+
+// inventoryManifest is the part of an S3 Inventory manifest.json file that
+// is needed to read the report.
+type inventoryManifest struct {
+	SourceBucket string `json:"sourceBucket"`
+	FileFormat   string `json:"fileFormat"`
+	FileSchema   string `json:"fileSchema"`
+	Files        []struct {
+		Key string `json:"key"`
+	} `json:"files"`
+}
+
+// NewInventory creates a reader for an S3 Inventory report of this file
+// system's bucket. The report is found via its manifest, e.g.
+// "/inventory/mybucket/daily/2024-01-01T01-00Z/manifest.json", in the
+// file system (usually of another bucket) that holds the reports. Nothing
+// is read until Objects or List is used.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) NewInventory(reports *Fs, manifest string) *Inventory {
+	return &Inventory{s3Fs: fs, reports: *reports, manifest: manifest}
+}
+
+// Objects gets an iterator over the files in the inventory with a given
+// prefix, including those in all subdirectories, in the same way as
+// Fs.Objects. Each data file of the report is read only when it is needed.
+// The files are in the order of the report, which is not necessarily sorted.
+// On a versioned bucket, only the latest versions are included, and not
+// delete markers. Directory markers are not included.
+func (inv *Inventory) Objects(prefix string) *ObjectIterator {
+	r := &inventoryReader{inv: inv, prefix: addTrailingSlash(inv.s3Fs.key(prefix))}
+	return &ObjectIterator{prefix: prefix, hasMore: true, pages: r.next}
+}
+
+// List lists all the files in the inventory with a given prefix, in the
+// same way as Fs.ListObjects.
+func (inv *Inventory) List(prefix string) (FileInfoList, error) {
+	fis := make(FileInfoList, 0)
+	it := inv.Objects(prefix)
+	for it.Next() {
+		fis = append(fis, it.FileInfo())
+	}
+	return fis, it.Err()
+}
+
+// inventoryReader reads the data files of a report one at a time.
+type inventoryReader struct {
+	inv      *Inventory
+	prefix   string // the key prefix
+	manifest *inventoryManifest
+	columns  map[string]int
+	files    []string
+}
+
+func (r *inventoryReader) next() (FileInfoList, bool, error) {
+	if r.manifest == nil {
+		if err := r.readManifest(); err != nil {
+			return nil, false, pathError("inventory", r.inv.manifest, err)
+		}
+	}
+	if len(r.files) == 0 {
+		return nil, false, nil
+	}
+
+	key := r.files[0]
+	r.files = r.files[1:]
+	fis, err := r.readFile(key)
+	if err != nil {
+		return nil, false, pathError("inventory", r.inv.reports.pathOf(key), err)
+	}
+	return fis, len(r.files) > 0, nil
+}
+
+func (r *inventoryReader) readManifest() error {
+	f, err := r.inv.reports.Open(r.inv.manifest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	m := &inventoryManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return err
+	}
+	if m.FileFormat != "CSV" {
+		return fmt.Errorf("unsupported inventory format %q", m.FileFormat)
+	}
+	if m.SourceBucket != r.inv.s3Fs.bucket {
+		return errInventoryBucket
+	}
+
+	r.columns = make(map[string]int)
+	for i, column := range strings.Split(m.FileSchema, ",") {
+		r.columns[strings.TrimSpace(column)] = i
+	}
+	if _, ok := r.columns["Key"]; !ok {
+		return errors.New("inventory has no Key column")
+	}
+
+	for _, file := range m.Files {
+		r.files = append(r.files, file.Key)
+	}
+	r.manifest = m
+	return nil
+}
+
+// readFile reads one gzipped CSV data file, whose key is given in full
+// because it is in the manifest.
+func (r *inventoryReader) readFile(key string) (FileInfoList, error) {
+	fs := r.inv.reports
+	ctx, start := fs.beginWithContext(fs.ctx, "Inventory", fs.pathOf(key))
+	ctx, cancel := withTimeout(ctx, fs.timeouts.transfer)
+	defer cancel()
+
+	output, err := fs.s3API.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		fs.logOp("Inventory", fs.pathOf(key), start, err)
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	fis, err := r.parse(output.Body)
+	fs.logOp("Inventory", fs.pathOf(key), start, err, "count", len(fis))
+	return fis, err
+}
+
+func (r *inventoryReader) parse(body io.Reader) (FileInfoList, error) {
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	source := r.inv.s3Fs
+	cr := csv.NewReader(gz)
+	cr.FieldsPerRecord = -1
+
+	fis := make(FileInfoList, 0)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return fis, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := r.columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+
+		// the keys are URL-encoded
+		key, err := url.QueryUnescape(field("Key"))
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(key, r.prefix) || hasTrailingSlash(key) ||
+			field("IsLatest") == "false" || field("IsDeleteMarker") == "true" {
+			continue
+		}
+
+		size, _ := strconv.ParseInt(field("Size"), 10, 64)
+		modTime, _ := time.Parse(time.RFC3339, field("LastModifiedDate"))
+		oi := ObjectInfo{
+			StorageClass: field("StorageClass"),
+			VersionId:    field("VersionId"),
+			Uid:          -1,
+			Gid:          -1,
+		}
+		if etag := field("ETag"); etag != "" {
+			oi.ETag = `"` + etag + `"`
+		}
+
+		fi := NewFileInfo(source.pathOf(key), size, modTime).withObjectInfo(oi)
+		fis = append(fis, source.applyDefaultPerm(fi))
+	}
+}
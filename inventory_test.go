@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func gzipped(g *WithT, s string) []byte {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err := w.Write([]byte(s))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(w.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+func TestInventory(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	reportsBucket := s3fake.New()
+	reports := NewFs("reports", reportsBucket)
+
+	manifest := `{
+		"sourceBucket": "mybucket",
+		"destinationBucket": "arn:aws:s3:::reports",
+		"fileFormat": "CSV",
+		"fileSchema": "Bucket, Key, Size, LastModifiedDate, ETag, StorageClass, IsLatest, IsDeleteMarker",
+		"files": [
+			{"key": "inv/data/1.csv.gz", "size": 100, "MD5checksum": "x"},
+			{"key": "inv/data/2.csv.gz", "size": 100, "MD5checksum": "x"}
+		]
+	}`
+	g.Expect(afero.WriteFile(reports, "/inv/manifest.json", []byte(manifest), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(reports, "/inv/data/1.csv.gz", gzipped(g,
+		`"mybucket","d/a.txt","10","2024-01-01T10:00:00.000Z","abc","STANDARD","true","false"
+"mybucket","d/my+file%20b.txt","20","2024-01-02T10:00:00.000Z","def","GLACIER","true","false"
+"mybucket","d/sub/","0","2024-01-02T10:00:00.000Z","ghi","STANDARD","true","false"
+`), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(reports, "/inv/data/2.csv.gz", gzipped(g,
+		`"mybucket","d/sub/c.txt","30","2024-01-03T10:00:00.000Z","jkl","STANDARD","true","false"
+"mybucket","d/sub/gone.txt","0","2024-01-03T10:00:00.000Z","","","true","true"
+"mybucket","d/sub/c.txt","25","2024-01-01T10:00:00.000Z","mno","STANDARD","false","false"
+"mybucket","e/d.txt","40","2024-01-04T10:00:00.000Z","pqr","STANDARD","true","false"
+`), 0644)).To(Succeed())
+	gets := reportsBucket.Gets
+
+	inv := fs.NewInventory(reports, "/inv/manifest.json")
+
+	it := inv.Objects("/d")
+	g.Expect(it.Next()).To(BeTrue())
+	g.Expect(reportsBucket.Gets - gets).To(Equal(2)) // the manifest and the first file
+	fi := it.FileInfo()
+	g.Expect(fi.Path()).To(Equal("/d/a.txt"))
+	g.Expect(fi.Size()).To(BeEquivalentTo(10))
+	g.Expect(fi.ModTime()).To(Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)))
+	g.Expect(fi.ETag()).To(Equal(`"abc"`))
+
+	list, err := inv.List("/d")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(Equal([]string{"/d/a.txt", "/d/my file b.txt", "/d/sub/c.txt"}))
+	g.Expect(list[1].Sys().(*ObjectInfo).StorageClass).To(Equal("GLACIER"))
+	g.Expect(list[2].Size()).To(BeEquivalentTo(30))
+
+	list, err = fs.WithKeyPrefix("e").NewInventory(reports, "/inv/manifest.json").List("/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(Equal([]string{"/d.txt"}))
+
+	_, err = NewFs("other", s3fake.New()).NewInventory(reports, "/inv/manifest.json").List("/")
+	g.Expect(err).To(MatchError(ContainSubstring(errInventoryBucket.Error())))
+}
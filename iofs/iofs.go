@@ -0,0 +1,252 @@
+//go:build go1.17
+// +build go1.17
+
+// Package iofs adapts an S3 file system to the io/fs interfaces, so that it
+// can be used with html/template, fs.WalkDir, http.FS and the like.
+//
+// Unlike the generic afero.IOFS wrapper, directories are read using a single
+// listing and Glob lists only the directory named by the pattern's leading
+// non-wildcard elements, rather than reading every directory in turn.
+package iofs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	s3 "github.com/rickb777/afero-s3"
+)
+
+// FS implements fs.FS, fs.StatFS, fs.ReadDirFS, fs.ReadFileFS and fs.GlobFS
+// using an S3 file system. Names are relative to the root of the S3 file
+// system (including its key prefix, if any), as required by io/fs.
+type FS struct {
+	fs *s3.Fs
+}
+
+var (
+	_ fs.StatFS     = FS{}
+	_ fs.ReadDirFS  = FS{}
+	_ fs.ReadFileFS = FS{}
+	_ fs.GlobFS     = FS{}
+)
+
+// New creates an io/fs adapter for an S3 file system.
+func New(s3Fs *s3.Fs) FS {
+	return FS{fs: s3Fs}
+}
+
+// s3Name converts a name from io/fs to an S3 file system name.
+func s3Name(name string) string {
+	if name == "." {
+		return "/"
+	}
+	return "/" + name
+}
+
+// fsError converts an error from the S3 file system to one that uses the
+// io/fs name.
+func fsError(op, name string, err error) error {
+	if pe, ok := err.(*os.PathError); ok {
+		return &fs.PathError{Op: op, Path: name, Err: pe.Err}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// Open opens a file or directory.
+func (f FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	af, err := f.fs.Open(s3Name(name))
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	file := af.(*s3.File)
+
+	fi, err := f.Stat(name)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		file.Close()
+		return &dir{fsys: f, name: name, info: fi}, nil
+	}
+	return &regularFile{file: file, name: name, info: fi}, nil
+}
+
+// Stat gets the file info for a file or directory.
+func (f FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	fi, err := f.fs.Stat(s3Name(name))
+	if err != nil {
+		return nil, fsError("stat", name, err)
+	}
+	return fi, nil
+}
+
+// ReadFile reads the whole of a file.
+func (f FS) ReadFile(name string) ([]byte, error) {
+	file, err := f.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, ok := file.(*dir); ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	return ioutil.ReadAll(file)
+}
+
+// ReadDir reads a directory, returning its entries sorted by name. This
+// uses one listing (or more for very large directories) without any further
+// requests for the file info.
+func (f FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	af, err := f.fs.Open(s3Name(name))
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	defer af.Close()
+
+	fi, err := af.Stat()
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	infos, err := af.(*s3.File).ReaddirAll()
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Glob finds the names of files and directories that match a pattern, as
+// for fs.Glob. This uses s3.Fs.Glob, which lists only the directory named by
+// the leading elements of the pattern that have no wildcards.
+func (f FS) Glob(pattern string) ([]string, error) {
+	matches, err := f.fs.Glob(pattern)
+	if err == path.ErrBadPattern {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fsError("glob", pattern, err)
+	}
+	return matches, nil
+}
+
+var (
+	errIsDir  = errors.New("is a directory")
+	errNotDir = errors.New("not a directory")
+)
+
+//-------------------------------------------------------------------------------------------------
+
+// regularFile is an open file.
+type regularFile struct {
+	file *s3.File
+	name string
+	info fs.FileInfo
+}
+
+func (f *regularFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *regularFile) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	if err != nil && err != io.EOF {
+		err = fsError("read", f.name, err)
+	}
+	return n, err
+}
+
+func (f *regularFile) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.file.Seek(offset, whence)
+	if err != nil {
+		err = fsError("seek", f.name, err)
+	}
+	return n, err
+}
+
+func (f *regularFile) Close() error {
+	if err := f.file.Close(); err != nil {
+		return fsError("close", f.name, err)
+	}
+	return nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// dir is an open directory. Its entries are listed when first needed.
+type dir struct {
+	fsys    FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	listed  bool
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// ReadDir reads the directory entries, as for fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
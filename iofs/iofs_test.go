@@ -0,0 +1,142 @@
+//go:build go1.17
+// +build go1.17
+
+package iofs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/onsi/gomega"
+	s3 "github.com/rickb777/afero-s3"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func testFS(g *WithT) FS {
+	mem := s3fake.New()
+	s3Fs := s3.NewFs("mybucket", mem)
+	for _, name := range []string{"/a/x.txt", "/a/y.txt", "/a/z.md", "/a/b/bar.txt", "/c/baz.txt", "/top.txt"} {
+		g.Expect(afero.WriteFile(s3Fs, name, []byte("hello "+name), 0644)).To(Succeed())
+	}
+	return New(s3Fs)
+}
+
+func TestOpenAndRead(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fsys := testFS(g)
+
+	b, err := fs.ReadFile(fsys, "a/x.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("hello /a/x.txt"))
+
+	fi, err := fs.Stat(fsys, "a/b")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.IsDir()).To(BeTrue())
+
+	_, err = fsys.Open("a/nope.txt")
+	g.Expect(errors.Is(err, fs.ErrNotExist)).To(BeTrue())
+
+	_, err = fsys.Open("/a/x.txt")
+	g.Expect(errors.Is(err, fs.ErrInvalid)).To(BeTrue())
+
+	_, err = fsys.ReadFile("a")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestReadDir(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fsys := testFS(g)
+
+	entries, err := fs.ReadDir(fsys, "a")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names(entries)).To(Equal([]string{"b", "x.txt", "y.txt", "z.md"}))
+	g.Expect(entries[0].IsDir()).To(BeTrue())
+
+	f, err := fsys.Open("a")
+	g.Expect(err).NotTo(HaveOccurred())
+	d := f.(fs.ReadDirFile)
+	page, err := d.ReadDir(3)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names(page)).To(Equal([]string{"b", "x.txt", "y.txt"}))
+	page, err = d.ReadDir(3)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names(page)).To(Equal([]string{"z.md"}))
+	_, err = d.ReadDir(3)
+	g.Expect(err).To(Equal(io.EOF))
+	g.Expect(f.Close()).To(Succeed())
+
+	_, err = fs.ReadDir(fsys, "top.txt")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestGlob(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fsys := testFS(g)
+
+	matches, err := fs.Glob(fsys, "a/*.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(matches).To(Equal([]string{"a/x.txt", "a/y.txt"}))
+
+	matches, err = fs.Glob(fsys, "*/b*")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(matches).To(Equal([]string{"a/b", "c/baz.txt"}))
+
+	matches, err = fs.Glob(fsys, "*/*/*.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(matches).To(Equal([]string{"a/b/bar.txt"}))
+
+	matches, err = fs.Glob(fsys, "top.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(matches).To(Equal([]string{"top.txt"}))
+
+	matches, err = fs.Glob(fsys, "nope/*")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(matches).To(BeEmpty())
+
+	_, err = fs.Glob(fsys, "a/[")
+	g.Expect(err).To(Equal(path.ErrBadPattern))
+}
+
+func TestWalkDir(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fsys := testFS(g)
+
+	var visited []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		visited = append(visited, p)
+		return err
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(visited).To(Equal([]string{".", "a", "a/b", "a/b/bar.txt", "a/x.txt", "a/y.txt", "a/z.md", "c", "c/baz.txt", "top.txt"}))
+}
+
+func names(entries []fs.DirEntry) []string {
+	var ss []string
+	for _, e := range entries {
+		ss = append(ss, e.Name())
+	}
+	return ss
+}
+
+func TestConformance(t *testing.T) {
+	g := NewGomegaWithT(t)
+	fsys := testFS(g)
+
+	err := fstest.TestFS(fsys, "a/x.txt", "a/y.txt", "a/z.md", "a/b/bar.txt", "c/baz.txt", "top.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the key prefix is the root, so the other files are not visible
+	mem := s3fake.New()
+	s3Fs := s3.NewFs("mybucket", mem)
+	g.Expect(afero.WriteFile(s3Fs, "/other.txt", []byte("x"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(s3Fs, "/pre/d/e.txt", []byte("y"), 0644)).To(Succeed())
+	g.Expect(s3Fs.MkdirAll("/pre/empty", 0755)).To(Succeed())
+
+	err = fstest.TestFS(New(s3Fs.WithKeyPrefix("pre")), "d/e.txt", "empty")
+	g.Expect(err).NotTo(HaveOccurred())
+}
@@ -3,44 +3,177 @@ package s3
 import (
 	"math"
 	"path"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/rickb777/collection"
 )
 
-// File represents a file in S3.
-// It is not safe to share File objects between goroutines.
+// ListOptions controls what a Lister lists. The zero value lists the files
+// and subdirectories in a directory, 1000 at a time, without any limit.
+type ListOptions struct {
+	// Recursive lists everything below the directory, including the contents
+	// of its subdirectories. Otherwise, only the directory's own entries are
+	// listed, using the delimiter "/".
+	Recursive bool
+	// FilesOnly omits directories.
+	FilesOnly bool
+	// DirsOnly omits files.
+	DirsOnly bool
+	// Suffix, if not blank, omits entries whose names do not end with it,
+	// e.g. ".jpg".
+	Suffix string
+	// Filters omit the entries for which any of them returns false. They are
+	// applied as each page of the listing arrives.
+	Filters []Filter
+	// PageSize is the number of keys requested in each ListObjectsV2 request.
+	// S3 returns no more than 1000, which is the default.
+	PageSize int
+	// MaxResults limits the number of entries listed. Zero means no limit.
+	MaxResults int
+	// StartAfter, if not blank, is the name of a file or directory (which
+	// needn't exist); only the entries that come after it in lexicographic
+	// order of their keys are listed. This allows an earlier scan to be
+	// resumed from the last name it processed.
+	StartAfter string
+	// IncludeOwner requests the owner of each object, which is then available
+	// via ObjectInfo.
+	IncludeOwner bool
+}
+
+// Lister lists the contents of a directory in S3, as configured by its
+// ListOptions. It is not safe to share Lister objects between goroutines.
 type Lister struct {
-	bucket    string
-	name      string
-	delimiter *string
-	s3Fs      Fs
-	s3API     S3APISubset
-	ctx       aws.Context
+	bucket string
+	name   string
+	opts   ListOptions
+	s3Fs   Fs
+	s3API  S3APISubset
+	ctx    aws.Context
+}
+
+// NewLister creates a lister for the contents of a directory. No request is
+// sent until List or Iterator is used.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) NewLister(name string, opts ListOptions) *Lister {
+	l := fs.newLister(name, opts)
+	return &l
+}
+
+func (fs Fs) newLister(name string, opts ListOptions) Lister {
+	return Lister{
+		bucket: fs.bucket,
+		name:   name,
+		opts:   opts,
+		s3Fs:   fs,
+		s3API:  fs.s3API,
+		ctx:    fs.ctx,
+	}
+}
+
+// List lists the directory, returning up to ListOptions.MaxResults entries.
+func (f *Lister) List() (FileInfoList, error) {
+	var start operation
+	f.ctx, start = f.s3Fs.beginWithContext(f.s3Fs.ctx, "List", f.name)
+	fis, _, _, err := f.listObjects(f.opts.MaxResults, f.opts.FilesOnly, nil)
+	err = pathError("list", f.name, err)
+	f.s3Fs.logOp("List", f.name, start, err, "count", len(fis))
+	return fis, err
+}
+
+// Iterator gets an iterator over the directory, which fetches each page of
+// the listing only when it is needed. ListOptions.MaxResults is ignored.
+func (f *Lister) Iterator() *ObjectIterator {
+	return &ObjectIterator{lister: *f, prefix: f.name, hasMore: true}
+}
+
+// Page lists one page of the directory, i.e. the result of one
+// ListObjectsV2 request of up to ListOptions.PageSize keys, starting from a
+// continuation token; this is blank for the first page. It returns the
+// token for the next page, which is blank after the last page. Because the
+// options may filter out some of the keys, a page can have fewer entries
+// than requested, even none, without being the last.
+//
+// The tokens are those of S3, so they can be given to the clients of a
+// stateless web API, for example, and later used with a new Lister that has
+// the same options.
+func (f *Lister) Page(token string) (FileInfoList, string, error) {
+	var start operation
+	f.ctx, start = f.s3Fs.beginWithContext(f.s3Fs.ctx, "ListPage", f.name)
+	fis, next, hasMore, err := f.doListObjects(f.pageSize(), false, optionalString(token))
+	if err != nil {
+		err = pathError("list", f.name, err)
+		f.s3Fs.logOp("ListPage", f.name, start, err)
+		return nil, "", err
+	}
+
+	f.s3Fs.logOp("ListPage", f.name, start, nil, "count", len(fis))
+	if !hasMore {
+		return fis, "", nil
+	}
+	return fis, aws.StringValue(next), nil
+}
+
+func (f *Lister) delimiter() *string {
+	if f.opts.Recursive {
+		return nil // include sub-objects
+	}
+	return aws.String(PathSeparator)
+}
+
+func (f *Lister) pageSize() int {
+	if f.opts.PageSize > 0 && f.opts.PageSize < maxObjectsPerRequest {
+		return f.opts.PageSize
+	}
+	return maxObjectsPerRequest
+}
+
+// wanted tests whether an entry passes the filters in the options.
+func (f *Lister) wanted(fi FileInfo) bool {
+	if f.opts.DirsOnly && !fi.IsDir() {
+		return false
+	}
+	if !strings.HasSuffix(fi.Name(), f.opts.Suffix) {
+		return false
+	}
+	for _, filter := range f.opts.Filters {
+		if !filter(fi) {
+			return false
+		}
+	}
+	return true
 }
 
 func (f *Lister) doListObjects(n int, filesOnly bool, continuationToken *string) (FileInfoList, *string, bool, error) {
-	// ListObjects treats leading slashes as part of the directory name
-	// It also needs a trailing slash to list contents of a directory.
+	// ListObjects needs a trailing slash to list contents of a directory.
 	// If n > 1000, AWS returns only the first 1000 keys.
-	prefix := trimLeadingSlash(f.name) + PathSeparator
+	prefix := addTrailingSlash(f.s3Fs.key(f.name))
+	base := trimTrailingSlash(f.s3Fs.pathOf(prefix))
 	input := &s3.ListObjectsV2Input{
 		ContinuationToken: continuationToken,
 		Bucket:            aws.String(f.bucket),
 		Prefix:            aws.String(prefix),
-		Delimiter:         f.delimiter,
+		Delimiter:         f.delimiter(),
 		MaxKeys:           aws.Int64(int64(n)),
+		FetchOwner:        aws.Bool(f.opts.IncludeOwner),
+	}
+	if f.opts.StartAfter != "" {
+		input.StartAfter = aws.String(f.s3Fs.key(f.opts.StartAfter))
 	}
-	output, err := f.s3API.ListObjectsV2WithContext(f.ctx, input)
+	ctx, cancel := withTimeout(f.ctx, f.s3Fs.timeouts.list)
+	output, err := f.s3API.ListObjectsV2WithContext(ctx, input)
+	cancel()
 
 	if err != nil {
 		return nil, nil, false, err
 	}
 
 	fis := make(FileInfoList, 0)
+	filesOnly = filesOnly || f.opts.FilesOnly
 	for _, subfolder := range output.CommonPrefixes {
-		fis = append(fis, NewDirectoryInfo(PathSeparator+*subfolder.Prefix))
+		fis = append(fis, NewDirectoryInfo(f.s3Fs.pathOf(*subfolder.Prefix)))
 	}
 
 	var dirs collection.StringSet
@@ -49,20 +182,21 @@ func (f *Lister) doListObjects(n int, filesOnly bool, continuationToken *string)
 	}
 
 	for _, fileObject := range output.Contents {
-		p := PathSeparator + *fileObject.Key
+		p := f.s3Fs.pathOf(*fileObject.Key)
 		if hasTrailingSlash(*fileObject.Key) {
 			// S3 includes <name>/ in the Contents listing for <name>
 			if !filesOnly {
 				dir := NewDirectoryInfo(p)
 				fis = append(fis, dir)
 				parent := trimTrailingSlash(dir.parent)
-				for len(parent) > len(f.name) {
+				for len(parent) > len(base) {
 					dirs.Add(parent)
 					parent = trimTrailingSlash(path.Dir(parent))
 				}
 			}
 		} else {
-			fis = append(fis, NewFileInfo(p, *fileObject.Size, *fileObject.LastModified))
+			fi := NewFileInfo(p, *fileObject.Size, *fileObject.LastModified)
+			fis = append(fis, fi.withObjectInfo(objectInfoFromListing(fileObject)))
 		}
 	}
 
@@ -72,20 +206,38 @@ func (f *Lister) doListObjects(n int, filesOnly bool, continuationToken *string)
 		}
 	}
 
-	return fis, output.NextContinuationToken, *output.IsTruncated, nil
+	wanted := fis[:0]
+	for _, fi := range fis {
+		fi = f.s3Fs.applyDefaultPerm(fi)
+		if fi.IsDir() {
+			f.s3Fs.dirCache.put(f.s3Fs.key(fi.Path()), fi)
+		}
+		if f.wanted(fi) {
+			wanted = append(wanted, fi)
+		}
+	}
+
+	return wanted, output.NextContinuationToken, *output.IsTruncated, nil
 }
 
 // ListObjects lists all objects in the bucket starting with the lister's name.
 func (f *Lister) ListObjects(max int, filesOnly bool) (FileInfoList, error) {
+	fileInfos, _, _, err := f.listObjects(max, filesOnly, nil)
+	return fileInfos, err
+}
+
+// listObjects lists up to max objects, starting from a continuation token
+// if one is given. It returns the continuation token for the rest of the
+// objects, and whether there are any.
+func (f *Lister) listObjects(max int, filesOnly bool, continuationToken *string) (FileInfoList, *string, bool, error) {
 	if max <= 0 {
 		max = math.MaxInt64
 	}
 
 	hasMore := true
-	var continuationToken *string
 	fileInfos := make(FileInfoList, 0)
-	for hasMore {
-		n := maxObjectsPerRequest
+	for hasMore && max > 0 {
+		n := f.pageSize()
 		if n > max {
 			n = max
 		}
@@ -96,12 +248,12 @@ func (f *Lister) ListObjects(max int, filesOnly bool) (FileInfoList, error) {
 		fileInfos = append(fileInfos, infos...)
 
 		if err != nil {
-			return nil, err
+			return nil, nil, false, err
 		}
 
 		max -= len(infos)
 	}
-	return fileInfos, nil
+	return fileInfos, continuationToken, hasMore, nil
 }
 
 // maxObjectsPerRequest is the upper limit of objects returned per request to ListObjectsV2WithContext
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestLister(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	for _, name := range []string{"/p/a.jpg", "/p/b.png", "/p/c.jpg", "/p/x/d.jpg", "/p/y/e.txt"} {
+		g.Expect(afero.WriteFile(fs, name, []byte("x"), 0644)).To(Succeed())
+	}
+
+	list, err := fs.NewLister("/p", ListOptions{}).List()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(ConsistOf("/p/a.jpg", "/p/b.png", "/p/c.jpg", "/p/x", "/p/y"))
+
+	list, err = fs.NewLister("/p", ListOptions{DirsOnly: true}).List()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(ConsistOf("/p/x", "/p/y"))
+
+	list, err = fs.NewLister("/p", ListOptions{Recursive: true, FilesOnly: true, Suffix: ".jpg"}).List()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(ConsistOf("/p/a.jpg", "/p/c.jpg", "/p/x/d.jpg"))
+
+	lists := mem.Lists
+	list, err = fs.NewLister("/p", ListOptions{Recursive: true, PageSize: 2, MaxResults: 3}).List()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list).To(HaveLen(3))
+	g.Expect(mem.Lists - lists).To(Equal(2))
+
+	lists = mem.Lists
+	it := fs.NewLister("/p", ListOptions{Recursive: true, PageSize: 2}).Iterator()
+	n := 0
+	for it.Next() {
+		n++
+	}
+	g.Expect(it.Err()).NotTo(HaveOccurred())
+	g.Expect(n).To(Equal(5))
+	g.Expect(mem.Lists - lists).To(Equal(3))
+}
+
+func TestListerStartAfter(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New()).WithKeyPrefix("batch")
+	for _, name := range []string{"/in/2024-01-01.csv", "/in/2024-01-02.csv", "/in/2024-01-03.csv", "/in/2024-01-04.csv"} {
+		g.Expect(afero.WriteFile(fs, name, []byte("x"), 0644)).To(Succeed())
+	}
+
+	list, err := fs.NewLister("/in", ListOptions{StartAfter: "/in/2024-01-02.csv"}).List()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(Equal([]string{"/in/2024-01-03.csv", "/in/2024-01-04.csv"}))
+
+	var names []string
+	it := fs.NewLister("/in", ListOptions{StartAfter: "/in/2024-01-01.csv", PageSize: 1}).Iterator()
+	for it.Next() {
+		names = append(names, it.FileInfo().Name())
+	}
+	g.Expect(it.Err()).NotTo(HaveOccurred())
+	g.Expect(names).To(Equal([]string{"2024-01-02.csv", "2024-01-03.csv", "2024-01-04.csv"}))
+}
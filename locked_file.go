@@ -0,0 +1,144 @@
+package s3
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// LockedFile wraps a file so that it can be shared between goroutines, e.g.
+// by HTTP handlers. Each method holds a mutex, so the calls are serialised.
+//
+// As for os.File, ReadAt does not alter the offset used by Read and Seek, so
+// that concurrent ReadAt calls don't interfere with each other, nor with a
+// sequential reader. For a File, each ReadAt that is not at the current
+// offset re-opens the download, so it is cheaper to read sequentially where
+// possible.
+type LockedFile struct {
+	mu   sync.Mutex
+	file afero.File
+}
+
+var _ afero.File = (*LockedFile)(nil)
+
+// NewLockedFile wraps a file, such as one opened by Fs.Open, so that it is
+// safe for concurrent use.
+func NewLockedFile(file afero.File) *LockedFile {
+	return &LockedFile{file: file}
+}
+
+// Unwrap gets the file that is wrapped, e.g. to use the methods of File
+// that afero.File lacks. It should not be used concurrently.
+func (l *LockedFile) Unwrap() afero.File {
+	return l.file
+}
+
+// Close closes the file.
+func (l *LockedFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Read reads from the file at the current offset.
+func (l *LockedFile) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Read(p)
+}
+
+// ReadAt reads len(p) bytes from the file at an offset, without altering the
+// current offset. It returns a non-nil error when fewer bytes are read; at
+// the end of the file, that error is io.EOF.
+func (l *LockedFile) ReadAt(p []byte, off int64) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.file.(*File)
+	if !ok {
+		return l.file.ReadAt(p, off)
+	}
+
+	offset := f.offset
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if _, seekErr := f.Seek(offset, io.SeekStart); seekErr != nil && err == nil {
+		err = seekErr
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read or Write.
+func (l *LockedFile) Seek(offset int64, whence int) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Seek(offset, whence)
+}
+
+// Write writes to the file.
+func (l *LockedFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Write(p)
+}
+
+// WriteAt writes to the file at an offset.
+func (l *LockedFile) WriteAt(p []byte, off int64) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.WriteAt(p, off)
+}
+
+// Name gets the name of the file.
+func (l *LockedFile) Name() string {
+	return l.file.Name()
+}
+
+// Readdir reads the contents of a directory.
+func (l *LockedFile) Readdir(count int) ([]os.FileInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Readdir(count)
+}
+
+// Readdirnames reads the names of the contents of a directory.
+func (l *LockedFile) Readdirnames(n int) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Readdirnames(n)
+}
+
+// Stat gets the file info.
+func (l *LockedFile) Stat() (os.FileInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Stat()
+}
+
+// Sync commits the file to storage.
+func (l *LockedFile) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Sync()
+}
+
+// Truncate changes the size of the file.
+func (l *LockedFile) Truncate(size int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Truncate(size)
+}
+
+// WriteString writes a string to the file.
+func (l *LockedFile) WriteString(s string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.WriteString(s)
+}
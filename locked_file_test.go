@@ -0,0 +1,60 @@
+package s3
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestLockedFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	content := strings.Repeat("0123456789", 100)
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte(content), 0644)).To(Succeed())
+
+	f, err := fs.Open("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	lf := NewLockedFile(f)
+
+	// ReadAt does not disturb a sequential reader
+	head := make([]byte, 5)
+	_, err = io.ReadFull(lf, head)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := make([]byte, 3)
+			if _, err := lf.ReadAt(p, int64(i*100+7)); err == nil {
+				results[i] = string(p)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, r := range results {
+		g.Expect(r).To(Equal("789"))
+	}
+
+	rest, err := ioutil.ReadAll(lf)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(head) + string(rest)).To(Equal(content))
+
+	// reading past the end
+	p := make([]byte, 10)
+	n, err := lf.ReadAt(p, 995)
+	g.Expect(n).To(Equal(5))
+	g.Expect(err).To(Equal(io.EOF))
+
+	g.Expect(lf.Unwrap()).To(BeIdenticalTo(f))
+	g.Expect(lf.Close()).To(Succeed())
+}
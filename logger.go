@@ -0,0 +1,91 @@
+package s3
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LogLevel is the importance of a log message. The values are the same as
+// those of log/slog, so they can be converted directly to slog.Level.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = -4
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4
+	LevelError LogLevel = 8
+)
+
+// Logger receives structured log messages from a file system. The keyvals
+// are alternating keys and values, as used by log/slog. The keys include
+// "bucket", "key", "op", "latency" and "error".
+//
+// For example, to log using log/slog:
+//
+//	s3.LoggerFunc(func(level s3.LogLevel, msg string, keyvals ...interface{}) {
+//		slog.Log(context.Background(), slog.Level(level), msg, keyvals...)
+//	})
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// LoggerFunc is a Logger made from a function.
+type LoggerFunc func(level LogLevel, msg string, keyvals ...interface{})
+
+// Log calls the function.
+func (fn LoggerFunc) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	fn(level, msg, keyvals...)
+}
+
+// logOp logs the outcome of an operation on a file, both to the Fs logger,
+// if any, and to the package logger set by SetLogger. Failures are logged as
+// errors except when the file doesn't exist or has not been modified, which
+// is normal. The operation's span, if any, is ended.
+func (fs Fs) logOp(op, name string, o operation, err error, keyvals ...interface{}) {
+	o.end(err, keyvals...)
+
+	level := LevelDebug
+	if err != nil && !isExpected(err) {
+		level = LevelError
+	}
+
+	fields := []interface{}{"bucket", fs.bucket, "key", fs.key(name), "op", op, "latency", time.Since(o.start)}
+	fields = append(fields, keyvals...)
+	if err != nil {
+		fields = append(fields, "error", err)
+	}
+	fs.log(level, op, fields...)
+
+	extra := new(strings.Builder)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fmt.Fprintf(extra, " %v=%v", keyvals[i], keyvals[i+1])
+	}
+	if err != nil {
+		lgr("%s %s %q%s > %+v\n", op, fs.bucket, name, extra, err)
+	} else {
+		lgr("%s %s %q%s\n", op, fs.bucket, name, extra)
+	}
+}
+
+// log sends a message to the Fs logger, if any.
+func (fs Fs) log(level LogLevel, msg string, keyvals ...interface{}) {
+	logTo(fs.logger, level, msg, keyvals...)
+}
+
+func logTo(logger Logger, level LogLevel, msg string, keyvals ...interface{}) {
+	if logger != nil {
+		logger.Log(level, msg, keyvals...)
+	}
+}
+
+// SetLogger sets a debug logger for observing S3 accesses. This is
+// compatible with 'log.Printf'. The default value is a no-op function.
+// It is shared by every file system in the process.
+//
+// Deprecated: use Fs.WithLogger instead.
+func SetLogger(fn func(format string, v ...interface{})) {
+	lgr = fn
+}
+
+var lgr = func(format string, v ...interface{}) {}
@@ -0,0 +1,183 @@
+package s3
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Manifest lists the files below a directory, with their sizes, ETags and
+// modification times, e.g. to record what was published so that it can be
+// verified later. It can be saved and loaded as JSON, in which the paths
+// are relative to the directory, so that manifests of different
+// directories, buckets or file systems can be compared using Diff.
+type Manifest struct {
+	Dir   string
+	Files FileInfoList // sorted by path
+}
+
+// manifestEntry is the JSON form of one file in a Manifest.
+type manifestEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ETag    string    `json:"etag,omitempty"`
+	ModTime time.Time `json:"mtime"`
+}
+
+type manifestJSON struct {
+	Dir   string          `json:"dir"`
+	Files []manifestEntry `json:"files"`
+}
+
+// Manifest lists the files in a directory and all its subdirectories.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Manifest(dir string) (*Manifest, error) {
+	m := &Manifest{Dir: dir, Files: make(FileInfoList, 0)}
+	it := fs.Objects(dir)
+	for it.Next() {
+		m.Files = append(m.Files, it.FileInfo())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	m.sort()
+	return m, nil
+}
+
+// NewManifest makes a manifest of the files in a directory of any file
+// system, e.g. a local directory that is to be compared with S3. The ETags
+// are only known for an *Fs.
+func NewManifest(fs afero.Fs, dir string) (*Manifest, error) {
+	if s3Fs, ok := fs.(*Fs); ok {
+		return s3Fs.Manifest(dir)
+	}
+
+	m := &Manifest{Dir: dir, Files: make(FileInfoList, 0)}
+	err := afero.Walk(fs, dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		m.Files = append(m.Files, NewFileInfo(name, info.Size(), info.ModTime()))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.sort()
+	return m, nil
+}
+
+func (m *Manifest) sort() {
+	sort.SliceStable(m.Files, func(i, j int) bool { return m.Files[i].Path() < m.Files[j].Path() })
+}
+
+// rel gets the path of a file relative to the directory.
+func (m *Manifest) rel(fi FileInfo) string {
+	return strings.TrimPrefix(fi.Path(), addTrailingSlash(path.Clean(PathSeparator+m.Dir)))
+}
+
+// MarshalJSON writes the manifest as JSON.
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	v := manifestJSON{Dir: m.Dir, Files: make([]manifestEntry, len(m.Files))}
+	for i, fi := range m.Files {
+		v.Files[i] = manifestEntry{Path: m.rel(fi), Size: fi.Size(), ETag: fi.ETag(), ModTime: fi.ModTime()}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON reads a manifest written by MarshalJSON.
+func (m *Manifest) UnmarshalJSON(data []byte) error {
+	var v manifestJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	m.Dir = v.Dir
+	m.Files = make(FileInfoList, len(v.Files))
+	for i, e := range v.Files {
+		fi := NewFileInfo(path.Join(PathSeparator, v.Dir, e.Path), e.Size, e.ModTime)
+		m.Files[i] = fi.withObjectInfo(ObjectInfo{ETag: e.ETag, Uid: -1, Gid: -1})
+	}
+	m.sort()
+	return nil
+}
+
+// ManifestDiff is the difference between two manifests, found by Diff, or
+// between two lists of files, found by FileInfoList.Diff.
+type ManifestDiff struct {
+	Added   FileInfoList // in the new manifest only
+	Removed FileInfoList // in the old manifest only
+	Changed FileInfoList // as in the new manifest
+}
+
+// IsEmpty tests whether there are no differences.
+func (d ManifestDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares an old manifest with a new one, matching the files by their
+// paths relative to the directories. Files differ if their sizes differ or,
+// when both ETags are known, their ETags differ; otherwise, if either ETag
+// is unknown, if their modification times differ.
+func (m *Manifest) Diff(newer *Manifest) ManifestDiff {
+	return diffFiles(m.Files, m.rel, newer.Files, newer.rel)
+}
+
+// diffFiles compares two lists of files, matching them by the keys given.
+func diffFiles(older FileInfoList, oldKey func(FileInfo) string, newer FileInfoList, newKey func(FileInfo) string) ManifestDiff {
+	old := make(map[string]FileInfo, len(older))
+	for _, fi := range older {
+		old[oldKey(fi)] = fi
+	}
+
+	diff := ManifestDiff{Added: make(FileInfoList, 0), Removed: make(FileInfoList, 0), Changed: make(FileInfoList, 0)}
+	for _, fi := range newer {
+		key := newKey(fi)
+		was, existed := old[key]
+		delete(old, key)
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, fi)
+		case fileChanged(was, fi):
+			diff.Changed = append(diff.Changed, fi)
+		}
+	}
+	for _, fi := range older {
+		if _, removed := old[oldKey(fi)]; removed {
+			diff.Removed = append(diff.Removed, fi)
+		}
+	}
+	return diff
+}
+
+func fileChanged(a, b FileInfo) bool {
+	if a.Size() != b.Size() {
+		return true
+	}
+	if a.ETag() != "" && b.ETag() != "" {
+		return a.ETag() != b.ETag()
+	}
+	return !a.ModTime().Equal(b.ModTime())
+}
+
+// Diff compares a directory of this file system with a directory of another,
+// which may be the same file system, by making a manifest of each.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Diff(dir string, other afero.Fs, otherDir string) (ManifestDiff, error) {
+	m, err := fs.Manifest(dir)
+	if err != nil {
+		return ManifestDiff{}, err
+	}
+	n, err := NewManifest(other, otherDir)
+	if err != nil {
+		return ManifestDiff{}, err
+	}
+	return m.Diff(n), nil
+}
@@ -0,0 +1,58 @@
+package s3
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestManifestDiff(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	for name, content := range map[string]string{
+		"/staging/a.txt":     "a",
+		"/staging/b.txt":     "b",
+		"/staging/sub/c.txt": "c",
+		"/live/a.txt":        "a",
+		"/live/b.txt":        "old",
+		"/live/gone.txt":     "gone",
+	} {
+		g.Expect(afero.WriteFile(fs, name, []byte(content), 0644)).To(Succeed())
+	}
+
+	live, err := fs.Manifest("/live")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(live.Files.Paths()).To(Equal([]string{"/live/a.txt", "/live/b.txt", "/live/gone.txt"}))
+
+	// the manifest survives being saved
+	data, err := json.Marshal(live)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring(`"path":"a.txt"`))
+	loaded := &Manifest{}
+	g.Expect(json.Unmarshal(data, loaded)).To(Succeed())
+	g.Expect(loaded.Files.Paths()).To(Equal(live.Files.Paths()))
+	g.Expect(loaded.Files[0].ETag()).To(Equal(live.Files[0].ETag()))
+
+	staging, err := fs.Manifest("/staging")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	diff := loaded.Diff(staging)
+	g.Expect(diff.Added.Paths()).To(Equal([]string{"/staging/sub/c.txt"}))
+	g.Expect(diff.Removed.Paths()).To(Equal([]string{"/live/gone.txt"}))
+	g.Expect(diff.Changed.Paths()).To(Equal([]string{"/staging/b.txt"}))
+	g.Expect(diff.IsEmpty()).To(BeFalse())
+
+	g.Expect(staging.Diff(staging).IsEmpty()).To(BeTrue())
+
+	// against another file system, by size and time
+	local := afero.NewMemMapFs()
+	g.Expect(afero.WriteFile(local, "/x/a.txt", []byte("aa"), 0644)).To(Succeed())
+	diff, err = fs.Diff("/live", local, "/x")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(diff.Changed.Paths()).To(Equal([]string{"/x/a.txt"}))
+	g.Expect(diff.Removed).To(HaveLen(2))
+}
@@ -0,0 +1,247 @@
+package s3
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// User metadata keys, as used by s3fs-fuse. S3 sends these as x-amz-meta-* headers.
+const (
+	metadataKeyMtime = "mtime"
+	metadataKeyMode  = "mode"
+	metadataKeyUid   = "uid"
+	metadataKeyGid   = "gid"
+)
+
+// Unix file type and permission bits, as stored in the mode metadata by s3fs-fuse.
+const (
+	unixTypeMask    = 0170000
+	unixTypeDir     = 0040000
+	unixTypeFile    = 0100000
+	unixTypeSymlink = 0120000
+	unixSetuid      = 04000
+	unixSetgid      = 02000
+	unixSticky      = 01000
+)
+
+// metadataValue looks up a user metadata value. The keys in HeadObject and
+// GetObject responses are canonicalised like HTTP headers, so the lookup
+// ignores case.
+func metadataValue(metadata map[string]*string, key string) (string, bool) {
+	for k, v := range metadata {
+		if v != nil && strings.EqualFold(k, key) {
+			return *v, true
+		}
+	}
+	return "", false
+}
+
+// setMetadataValue stores a user metadata value, replacing any existing
+// value regardless of the case of its key.
+func setMetadataValue(metadata map[string]*string, key, value string) {
+	for k := range metadata {
+		if strings.EqualFold(k, key) {
+			delete(metadata, k)
+		}
+	}
+	metadata[key] = aws.String(value)
+}
+
+// metadataTime parses a timestamp in user metadata. Like s3fs-fuse, this is
+// the number of seconds since the Unix epoch, optionally with a fractional part.
+func metadataTime(metadata map[string]*string, key string) (time.Time, bool) {
+	v, ok := metadataValue(metadata, key)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	secs, nanos := v, ""
+	if dot := strings.IndexByte(v, '.'); dot >= 0 {
+		secs, nanos = v[:dot], v[dot+1:]
+	}
+
+	s, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var ns int64
+	if nanos != "" {
+		if len(nanos) > 9 {
+			nanos = nanos[:9]
+		}
+		ns, err = strconv.ParseInt(nanos+strings.Repeat("0", 9-len(nanos)), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+	}
+
+	return time.Unix(s, ns), true
+}
+
+// formatMetadataTime is the inverse of metadataTime.
+func formatMetadataTime(t time.Time) string {
+	if t.Nanosecond() == 0 {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return strconv.FormatInt(t.Unix(), 10) + "." + strconv.FormatInt(int64(t.Nanosecond())+1e9, 10)[1:]
+}
+
+// metadataMode parses the Unix mode in user metadata. Like s3fs-fuse, this is
+// the decimal value of st_mode, including the file type bits. Only the
+// permission bits are returned.
+func metadataMode(metadata map[string]*string, key string) (os.FileMode, bool) {
+	v, ok := metadataValue(metadata, key)
+	if !ok {
+		return 0, false
+	}
+
+	m, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	mode := os.FileMode(m) & os.ModePerm
+	if m&unixSetuid != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&unixSetgid != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&unixSticky != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, true
+}
+
+// metadataIsSymlink tests whether the Unix mode in user metadata has the
+// file type bits of a symbolic link.
+func metadataIsSymlink(metadata map[string]*string, key string) bool {
+	v, ok := metadataValue(metadata, key)
+	if !ok {
+		return false
+	}
+
+	m, err := strconv.ParseUint(v, 10, 32)
+	return err == nil && m&unixTypeMask == unixTypeSymlink
+}
+
+// formatMetadataMode is the inverse of metadataMode.
+func formatMetadataMode(mode os.FileMode, dir bool) string {
+	m := uint64(mode & os.ModePerm)
+	if mode&os.ModeSetuid != 0 {
+		m |= unixSetuid
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= unixSetgid
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= unixSticky
+	}
+	if dir {
+		m |= unixTypeDir
+	} else {
+		m |= unixTypeFile
+	}
+	return strconv.FormatUint(m, 10)
+}
+
+// metadataID parses a numeric user or group ID in user metadata.
+func metadataID(metadata map[string]*string, key string) (int, bool) {
+	v, ok := metadataValue(metadata, key)
+	if !ok {
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int(id), true
+}
+
+// updateFileOrDirMetadata is like updateMetadata but, when there is no file
+// with the given name, it tries the marker object of a directory instead.
+func (fs Fs) updateFileOrDirMetadata(op, name string, update func(metadata map[string]*string, dir bool)) error {
+	err := fs.updateMetadata(op, name, func(metadata map[string]*string) {
+		update(metadata, hasTrailingSlash(name))
+	})
+
+	if os.IsNotExist(err) && !hasTrailingSlash(name) {
+		err = fs.updateMetadata(op, addTrailingSlash(name), func(metadata map[string]*string) {
+			update(metadata, true)
+		})
+	}
+
+	return err
+}
+
+// updateMetadata alters the user metadata of an existing object. S3 objects
+// cannot be modified, so this copies the object onto itself, replacing its
+// metadata. The other headers are preserved; they would otherwise be lost.
+func (fs Fs) updateMetadata(op, name string, update func(metadata map[string]*string)) error {
+	if err := fs.checkName(op, name); err != nil {
+		return err
+	}
+
+	start := fs.begin(op, name)
+
+	key := fs.key(name)
+	ctx, cancel := withTimeout(fs.ctx, fs.timeouts.head)
+	head, err := fs.s3API.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	cancel()
+	if err != nil {
+		err = pathError(op, name, err)
+		fs.logOp(op, name, start, err)
+		return err
+	}
+
+	metadata := head.Metadata
+	if metadata == nil {
+		metadata = make(map[string]*string)
+	}
+	update(metadata)
+
+	input := &s3.CopyObjectInput{
+		Bucket:                  aws.String(fs.bucket),
+		CopySource:              aws.String(copySource(fs.bucket, key)),
+		Key:                     aws.String(key),
+		MetadataDirective:       aws.String(s3.MetadataDirectiveReplace),
+		Metadata:                metadata,
+		CacheControl:            head.CacheControl,
+		ContentDisposition:      head.ContentDisposition,
+		ContentEncoding:         head.ContentEncoding,
+		ContentLanguage:         head.ContentLanguage,
+		ContentType:             head.ContentType,
+		ServerSideEncryption:    head.ServerSideEncryption,
+		SSEKMSKeyId:             head.SSEKMSKeyId,
+		StorageClass:            head.StorageClass,
+		WebsiteRedirectLocation: head.WebsiteRedirectLocation,
+	}
+	if expires, err := http.ParseTime(aws.StringValue(head.Expires)); err == nil {
+		input.Expires = &expires
+	}
+	fs.writeOptionsFor(name).applyToCopy(input)
+
+	ctx, cancel = withTimeout(fs.ctx, fs.timeouts.transfer)
+	_, err = fs.s3API.CopyObjectWithContext(ctx, input)
+	cancel()
+	fs.forget(name)
+	if err != nil {
+		err = pathError(op, name, err)
+		fs.logOp(op, name, start, err)
+		return err
+	}
+
+	fs.logOp(op, name, start, nil)
+	return nil
+}
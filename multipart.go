@@ -0,0 +1,362 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var errResumeToken = errors.New("the upload does not have the parts of the resume token")
+
+// UploadedPart is one part of a multipart upload that has been uploaded.
+// Checksum is its additional checksum, if any (see Fs.WithChecksum).
+type UploadedPart struct {
+	Number   int64  `json:"number"`
+	ETag     string `json:"etag"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// ResumeToken records the progress of a multipart upload, so that it can be
+// resumed after being interrupted (see File.ResumeToken and Fs.Resume). It
+// can be stored as JSON, e.g. in a local file, so that an upload can be
+// resumed by another process.
+type ResumeToken struct {
+	UploadId          string         `json:"uploadId"`
+	ChecksumAlgorithm string         `json:"checksumAlgorithm,omitempty"`
+	Parts             []UploadedPart `json:"parts"`
+}
+
+// Size gets the number of bytes uploaded so far. This is where writing
+// resumes, so the data to be written to the resumed file starts at this
+// offset.
+func (t ResumeToken) Size() int64 {
+	var size int64
+	for _, p := range t.Parts {
+		size += p.Size
+	}
+	return size
+}
+
+// nextPart gets the number of the next part to upload.
+func (t ResumeToken) nextPart() int64 {
+	if len(t.Parts) == 0 {
+		return 1
+	}
+	return t.Parts[len(t.Parts)-1].Number + 1
+}
+
+// MultipartUpload is a multipart upload that has been started but neither
+// completed nor aborted, as listed by ListMultipartUploads. S3 charges for
+// the storage of its parts until it is aborted.
+type MultipartUpload struct {
+	Path      string
+	UploadId  string
+	Initiated time.Time
+}
+
+// ListMultipartUploads lists the incomplete multipart uploads of objects with
+// a given prefix, in the order of their keys. As for ListVersions, the prefix
+// is a path that selects every key that starts with it.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) ListMultipartUploads(prefix string) ([]MultipartUpload, error) {
+	ctx, start := fs.beginWithContext(fs.ctx, "ListMultipartUploads", prefix)
+	uploads, err := fs.listMultipartUploads(ctx, fs.key(prefix))
+	if err != nil {
+		err = pathError("list", prefix, err)
+		fs.logOp("ListMultipartUploads", prefix, start, err)
+		return nil, err
+	}
+
+	fs.logOp("ListMultipartUploads", prefix, start, nil, "count", len(uploads))
+	return uploads, nil
+}
+
+// AbortMultipartUploads aborts the incomplete multipart uploads of objects
+// with a given prefix that were started more than the given duration ago,
+// deleting their parts. It returns the number aborted. A zero duration
+// aborts every one, including any that are in progress.
+//
+// A bucket lifecycle rule (AbortIncompleteMultipartUpload) does the same
+// thing without needing to be run, but this is useful where there is none.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) AbortMultipartUploads(prefix string, olderThan time.Duration) (int, error) {
+	ctx, start := fs.beginWithContext(fs.ctx, "AbortMultipartUploads", prefix)
+	uploads, err := fs.listMultipartUploads(ctx, fs.key(prefix))
+	if err != nil {
+		err = pathError("abort", prefix, err)
+		fs.logOp("AbortMultipartUploads", prefix, start, err)
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	aborted := 0
+	for _, u := range uploads {
+		if olderThan > 0 && !u.Initiated.Before(cutoff) {
+			continue
+		}
+		if err = fs.abortMultipartUpload(ctx, u.Path, u.UploadId); err != nil && !isNoSuchUpload(err) {
+			err = pathError("abort", u.Path, err)
+			fs.logOp("AbortMultipartUploads", prefix, start, err, "count", aborted)
+			return aborted, err
+		}
+		aborted++
+	}
+
+	fs.logOp("AbortMultipartUploads", prefix, start, nil, "count", aborted)
+	return aborted, nil
+}
+
+// BeginMultipart starts a multipart upload of a file, returning its upload
+// ID. This, with WritePart, CompleteMultipart and AbortMultipart, allows the
+// parts to be generated and uploaded independently, e.g. in parallel or on
+// different machines, while applying the key mapping, content types and
+// write options of the file system as usual.
+//
+// Most callers should use WithMultipartUpload instead, which uploads files
+// in parts as they are written.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) BeginMultipart(name string) (string, error) {
+	if err := fs.checkName("begin", name); err != nil {
+		return "", err
+	}
+
+	f := NewFile(fs.bucket, name, fs.s3API, fs)
+	if err := f.createMultipartUpload(); err != nil {
+		return "", err
+	}
+	return f.multipart.UploadId, nil
+}
+
+// WritePart uploads one part of a multipart upload started by BeginMultipart.
+// Parts are numbered from 1 to 10000 and may be uploaded in any order;
+// uploading a part again replaces it. S3 requires parts of at least 5 MiB,
+// apart from the last. The result is needed to complete the upload.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) WritePart(name, uploadId string, number int64, data []byte) (UploadedPart, error) {
+	f := NewFile(fs.bucket, name, fs.s3API, fs)
+	f.multipart = &ResumeToken{UploadId: uploadId, ChecksumAlgorithm: fs.writeOpts.checksum}
+	if err := f.uploadPart(number, data, -1); err != nil {
+		return UploadedPart{}, err
+	}
+	return f.multipart.Parts[0], nil
+}
+
+// CompleteMultipart completes a multipart upload started by BeginMultipart,
+// combining the parts into the file. The parts are those returned by
+// WritePart, in any order.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) CompleteMultipart(name, uploadId string, parts []UploadedPart) error {
+	parts = append([]UploadedPart(nil), parts...)
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+
+	f := NewFile(fs.bucket, name, fs.s3API, fs)
+	f.created = true
+	f.multipart = &ResumeToken{UploadId: uploadId, ChecksumAlgorithm: fs.writeOpts.checksum, Parts: parts}
+	f.writeBuf = getWriteBuffer()
+	return f.finaliseMultipart()
+}
+
+// AbortMultipart aborts a multipart upload started by BeginMultipart,
+// deleting the parts that have been uploaded.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) AbortMultipart(name, uploadId string) error {
+	ctx, start := fs.beginWithContext(fs.ctx, "AbortMultipart", name)
+	err := pathError("abort", name, fs.abortMultipartUpload(ctx, name, uploadId))
+	fs.logOp("AbortMultipart", name, start, err, "upload", uploadId)
+	return err
+}
+
+// listMultipartUploads lists the incomplete multipart uploads of the objects
+// whose keys start with a prefix, paging through the listing.
+func (fs Fs) listMultipartUploads(ctx aws.Context, prefix string) ([]MultipartUpload, error) {
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	var uploads []MultipartUpload
+	for {
+		ctx, cancel := withTimeout(ctx, fs.timeouts.list)
+		output, err := fs.s3API.ListMultipartUploadsWithContext(ctx, input)
+		cancel()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, u := range output.Uploads {
+			uploads = append(uploads, MultipartUpload{
+				Path:      fs.pathOf(aws.StringValue(u.Key)),
+				UploadId:  aws.StringValue(u.UploadId),
+				Initiated: aws.TimeValue(u.Initiated),
+			})
+		}
+
+		if !aws.BoolValue(output.IsTruncated) {
+			return uploads, nil
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.UploadIdMarker = output.NextUploadIdMarker
+	}
+}
+
+func (fs Fs) createMultipartUpload(ctx aws.Context, input *s3.CreateMultipartUploadInput) (string, error) {
+	ctx, cancel := withTimeout(ctx, fs.timeouts.head)
+	defer cancel()
+
+	output, err := fs.s3API.CreateMultipartUploadWithContext(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.UploadId), nil
+}
+
+// newUploadPartInput makes the input to upload one part of a multipart upload,
+// with its Content-MD5 and additional checksum as required by the options.
+func (fs Fs) newUploadPartInput(name, uploadId string, number int64, data []byte, opts writeOptions) (*s3.UploadPartInput, error) {
+	input := &s3.UploadPartInput{
+		Bucket:        aws.String(fs.bucket),
+		Key:           aws.String(fs.key(name)),
+		UploadId:      aws.String(uploadId),
+		PartNumber:    aws.Int64(number),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	}
+	if !opts.noContentMD5 {
+		hash := md5.Sum(data)
+		input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(hash[:]))
+	}
+	if opts.checksum != "" {
+		sum, err := checksumOf(opts.checksum, data)
+		if err != nil {
+			return nil, err
+		}
+		checksumFields{&input.ChecksumCRC32, &input.ChecksumCRC32C, &input.ChecksumSHA1, &input.ChecksumSHA256}.set(opts.checksum, sum)
+	}
+	return input, nil
+}
+
+// uploadPart uploads one part, as made by newUploadPartInput.
+func (fs Fs) uploadPart(ctx aws.Context, input *s3.UploadPartInput) (UploadedPart, error) {
+	ctx, cancel := withTimeout(ctx, fs.timeouts.transfer)
+	defer cancel()
+
+	output, err := fs.s3API.UploadPartWithContext(ctx, input)
+	if err != nil {
+		return UploadedPart{}, err
+	}
+
+	part := UploadedPart{
+		Number: aws.Int64Value(input.PartNumber),
+		ETag:   aws.StringValue(output.ETag),
+		Size:   aws.Int64Value(input.ContentLength),
+	}
+	for _, sum := range []*string{input.ChecksumCRC32, input.ChecksumCRC32C, input.ChecksumSHA1, input.ChecksumSHA256} {
+		if sum != nil {
+			part.Checksum = *sum
+		}
+	}
+	return part, nil
+}
+
+// completeMultipartUpload combines the parts into the object.
+func (fs Fs) completeMultipartUpload(ctx aws.Context, name string, token ResumeToken, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	parts := make([]*s3.CompletedPart, len(token.Parts))
+	for i, p := range token.Parts {
+		parts[i] = &s3.CompletedPart{PartNumber: aws.Int64(p.Number), ETag: aws.String(p.ETag)}
+		if p.Checksum != "" {
+			c := parts[i]
+			checksumFields{&c.ChecksumCRC32, &c.ChecksumCRC32C, &c.ChecksumSHA1, &c.ChecksumSHA256}.set(token.ChecksumAlgorithm, p.Checksum)
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, fs.timeouts.transfer)
+	defer cancel()
+
+	return fs.s3API.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(fs.bucket),
+		Key:             aws.String(fs.key(name)),
+		UploadId:        aws.String(token.UploadId),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	}, opts...)
+}
+
+// listParts lists the parts of a multipart upload that have been uploaded,
+// paging through the listing. Their checksums are those for the upload's
+// additional checksum algorithm, if any.
+func (fs Fs) listParts(ctx aws.Context, name, uploadId string) ([]UploadedPart, error) {
+	input := &s3.ListPartsInput{
+		Bucket:   aws.String(fs.bucket),
+		Key:      aws.String(fs.key(name)),
+		UploadId: aws.String(uploadId),
+	}
+
+	var parts []UploadedPart
+	for {
+		ctx, cancel := withTimeout(ctx, fs.timeouts.list)
+		output, err := fs.s3API.ListPartsWithContext(ctx, input)
+		cancel()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range output.Parts {
+			part := UploadedPart{
+				Number: aws.Int64Value(p.PartNumber),
+				ETag:   aws.StringValue(p.ETag),
+				Size:   aws.Int64Value(p.Size),
+			}
+			switch aws.StringValue(output.ChecksumAlgorithm) {
+			case s3.ChecksumAlgorithmCrc32:
+				part.Checksum = aws.StringValue(p.ChecksumCRC32)
+			case s3.ChecksumAlgorithmCrc32c:
+				part.Checksum = aws.StringValue(p.ChecksumCRC32C)
+			case s3.ChecksumAlgorithmSha1:
+				part.Checksum = aws.StringValue(p.ChecksumSHA1)
+			case s3.ChecksumAlgorithmSha256:
+				part.Checksum = aws.StringValue(p.ChecksumSHA256)
+			}
+			parts = append(parts, part)
+		}
+
+		if !aws.BoolValue(output.IsTruncated) {
+			return parts, nil
+		}
+		input.PartNumberMarker = output.NextPartNumberMarker
+	}
+}
+
+func (fs Fs) abortMultipartUpload(ctx aws.Context, name, uploadId string) error {
+	ctx, cancel := withTimeout(ctx, fs.timeouts.head)
+	defer cancel()
+
+	_, err := fs.s3API.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(fs.bucket),
+		Key:      aws.String(fs.key(name)),
+		UploadId: aws.String(uploadId),
+	})
+	return err
+}
+
+// isNoSuchUpload tests whether an error from S3 means that a multipart upload
+// has already been completed or aborted.
+func isNoSuchUpload(err error) bool {
+	ae, ok := err.(awserr.Error)
+	return ok && ae.Code() == s3.ErrCodeNoSuchUpload
+}
@@ -0,0 +1,340 @@
+package s3
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestAbortMultipartUploads(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+
+	now := time.Now()
+	for _, u := range []struct {
+		key string
+		age time.Duration
+	}{
+		{"d/a.bin", 48 * time.Hour},
+		{"d/b.bin", time.Minute},
+		{"e/c.bin", 48 * time.Hour},
+	} {
+		mem.Now = func() time.Time { return now.Add(-u.age) }
+		_, err := mem.CreateMultipartUploadWithContext(nil, &s3.CreateMultipartUploadInput{Bucket: aws.String("mybucket"), Key: aws.String(u.key)})
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	uploads, err := fs.ListMultipartUploads("/d/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(uploads).To(HaveLen(2))
+	g.Expect(uploads[0].Path).To(Equal("/d/a.bin"))
+	g.Expect(uploads[0].UploadId).NotTo(BeEmpty())
+	g.Expect(uploads[0].Initiated).To(BeTemporally("~", now.Add(-48*time.Hour), time.Second))
+	g.Expect(uploads[1].Path).To(Equal("/d/b.bin"))
+
+	n, err := fs.AbortMultipartUploads("/", 24*time.Hour)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(n).To(Equal(2))
+
+	uploads, err = fs.ListMultipartUploads("/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(uploads).To(HaveLen(1))
+	g.Expect(uploads[0].Path).To(Equal("/d/b.bin"))
+
+	n, err = fs.AbortMultipartUploads("/d/", 0)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(n).To(Equal(1))
+	g.Expect(fs.ListMultipartUploads("/")).To(BeEmpty())
+}
+
+// failingPartBucket fails to upload one of the parts of every multipart upload.
+type failingPartBucket struct {
+	*s3fake.Bucket
+	fail int64
+}
+
+func (b *failingPartBucket) UploadPartWithContext(ctx aws.Context, req *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	if aws.Int64Value(req.PartNumber) == b.fail {
+		return nil, errors.New("connection reset by peer")
+	}
+	return b.Bucket.UploadPartWithContext(ctx, req, opts...)
+}
+
+func TestMultipartUpload(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.MinPartSize = 4
+	fs := NewFs("mybucket", mem).WithMultipartUpload(4)
+
+	// a small file is written using PutObject
+	g.Expect(afero.WriteFile(fs, "/small.txt", []byte("abc"), 0644)).To(Succeed())
+	g.Expect(mem.Puts).To(Equal(1))
+	g.Expect(mem.Parts).To(Equal(0))
+
+	f, err := fs.Create("/big.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, s := range []string{"aa", "aabb", "bbccccd"} {
+		_, err = f.WriteString(s)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(mem.Parts).To(Equal(3))
+	g.Expect(f.(*File).ResumeToken().Size()).To(Equal(int64(12)))
+	g.Expect(f.Close()).To(Succeed())
+	g.Expect(mem.Parts).To(Equal(4))
+	g.Expect(mem.Puts).To(Equal(1))
+	g.Expect(f.(*File).ETag()).To(HaveSuffix(`-4"`))
+
+	data, err := afero.ReadFile(fs, "/big.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("aaaabbbbccccd"))
+}
+
+func TestResume(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.MinPartSize = 4
+	failing := &failingPartBucket{Bucket: mem, fail: 3}
+	fs := NewFs("mybucket", failing).WithMultipartUpload(4)
+
+	content := "aaaabbbbccccdd"
+	f, err := fs.Create("/big.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString(content)
+	g.Expect(err).To(HaveOccurred())
+
+	// the token can be stored and used by another process
+	b, err := json.Marshal(f.(*File).ResumeToken())
+	g.Expect(err).NotTo(HaveOccurred())
+	var token ResumeToken
+	g.Expect(json.Unmarshal(b, &token)).To(Succeed())
+	g.Expect(token.Parts).To(HaveLen(2))
+	g.Expect(token.Size()).To(Equal(int64(8)))
+
+	failing.fail = 0
+	resumed, err := fs.Resume("/big.txt", token)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = resumed.WriteString(content[token.Size():])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resumed.Close()).To(Succeed())
+
+	data, err := afero.ReadFile(fs, "/big.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(content))
+	g.Expect(fs.ListMultipartUploads("/")).To(BeEmpty())
+
+	// the upload has been completed, so it cannot be resumed again
+	_, err = fs.Resume("/big.txt", token)
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+
+	// the parts must match
+	f, err = fs.Create("/other.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString(strings.Repeat("x", 8))
+	g.Expect(err).NotTo(HaveOccurred())
+	token = f.(*File).ResumeToken()
+	token.Parts[0].ETag = `"bogus"`
+	_, err = fs.Resume("/other.txt", token)
+	g.Expect(errors.Is(err, errResumeToken)).To(BeTrue())
+}
+
+func TestResumeWithChecksum(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.MinPartSize = 4
+	fs := NewFs("mybucket", mem).WithMultipartUpload(4).WithChecksum(s3.ChecksumAlgorithmSha256)
+
+	content := "aaaabbbbcc"
+	f, err := fs.Create("/big.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString(content[:8])
+	g.Expect(err).NotTo(HaveOccurred())
+	token := f.(*File).ResumeToken()
+	g.Expect(token.Parts).To(HaveLen(2))
+	g.Expect(token.Parts[0].Checksum).NotTo(BeEmpty())
+
+	// a wrong checksum is detected
+	bogus := token
+	bogus.Parts = []UploadedPart{token.Parts[0], token.Parts[1]}
+	bogus.Parts[1].Checksum = "bogus"
+	_, err = fs.Resume("/big.txt", bogus)
+	g.Expect(errors.Is(err, errResumeToken)).To(BeTrue())
+
+	// missing checksums are found from S3, which needs them to complete the upload
+	for i := range token.Parts {
+		token.Parts[i].Checksum = ""
+	}
+	resumed, err := fs.Resume("/big.txt", token)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resumed.ResumeToken().Parts[0].Checksum).NotTo(BeEmpty())
+	_, err = resumed.WriteString(content[8:])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resumed.Close()).To(Succeed())
+
+	data, err := afero.ReadFile(fs, "/big.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(content))
+}
+
+func TestLowLevelMultipart(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.MinPartSize = 4
+	fs := NewFs("mybucket", mem).WithChecksum(s3.ChecksumAlgorithmSha256)
+
+	uploadId, err := fs.BeginMultipart("/big.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	chunks := []string{"aaaa", "bbbb", "cc"}
+	parts := make([]UploadedPart, len(chunks))
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			parts[i], errs[i] = fs.WritePart("/big.txt", uploadId, int64(i+1), []byte(chunks[i]))
+		}(i)
+	}
+	wg.Wait()
+	g.Expect(errs).To(Equal(make([]error, len(chunks))))
+	g.Expect(parts[2].Checksum).NotTo(BeEmpty())
+
+	parts[0], parts[2] = parts[2], parts[0]
+	g.Expect(fs.CompleteMultipart("/big.txt", uploadId, parts)).To(Succeed())
+
+	data, err := afero.ReadFile(fs, "/big.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("aaaabbbbcc"))
+
+	uploadId, err = fs.BeginMultipart("/abandoned.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = fs.WritePart("/abandoned.txt", uploadId, 1, []byte("aaaa"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fs.ListMultipartUploads("/")).To(HaveLen(1))
+	g.Expect(fs.AbortMultipart("/abandoned.txt", uploadId)).To(Succeed())
+	g.Expect(fs.ListMultipartUploads("/")).To(BeEmpty())
+	g.Expect(afero.Exists(fs, "/abandoned.txt")).To(BeFalse())
+}
+
+func TestAppend(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+
+	appendString := func(name, s string, flag int) error {
+		f, err := fs.OpenFile(name, os.O_WRONLY|os.O_APPEND|flag, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err = f.WriteString(s); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	// the file must exist unless it is created
+	g.Expect(os.IsNotExist(appendString("/small.txt", "abc", 0))).To(BeTrue())
+	g.Expect(appendString("/small.txt", "abc", os.O_CREATE)).To(Succeed())
+	g.Expect(appendString("/small.txt", "def", os.O_CREATE)).To(Succeed())
+	data, err := afero.ReadFile(fs, "/small.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("abcdef"))
+
+	// a large file is copied within S3 rather than downloaded
+	large := strings.Repeat("x", minPartSize)
+	g.Expect(afero.WriteFile(fs, "/large.txt", []byte(large), 0644)).To(Succeed())
+	gets, puts := mem.Gets, mem.Puts
+	g.Expect(appendString("/large.txt", "yz", 0)).To(Succeed())
+	g.Expect(mem.Gets).To(Equal(gets))
+	g.Expect(mem.Puts).To(Equal(puts))
+	g.Expect(fs.Stats().Copy).To(Equal(int64(1)))
+
+	data, err = afero.ReadFile(fs, "/large.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(len(data)).To(Equal(minPartSize + 2))
+	g.Expect(string(data[minPartSize-1:])).To(Equal("xyz"))
+	g.Expect(fs.ListMultipartUploads("/")).To(BeEmpty())
+}
+
+func TestAppendBeyondCopyPartLimit(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	defer func(max int64) { maxCopyPartSize = max }(maxCopyPartSize)
+	maxCopyPartSize = 2 << 20
+
+	mem := s3fake.New()
+	mem.MinPartSize = 4
+	fs := NewFs("mybucket", mem)
+
+	large := make([]byte, minPartSize)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	g.Expect(afero.WriteFile(fs, "/large.bin", large, 0644)).To(Succeed())
+
+	f, err := fs.OpenFile("/large.bin", os.O_WRONLY|os.O_APPEND, 0644)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString("yz")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.Close()).To(Succeed())
+
+	// the object is copied in three ranges, none larger than the limit
+	g.Expect(fs.Stats().Copy).To(Equal(int64(3)))
+
+	data, err := afero.ReadFile(fs, "/large.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(Equal(append(large, 'y', 'z')))
+}
+
+func TestAppendReusesWriteBuffer(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("abc"), 0644)).To(Succeed())
+
+	file := NewFile(fs.bucket, "/a.txt", fs.s3API, *fs)
+	file.WriteString("")
+	buf := file.writeBuf
+	g.Expect(file.openForAppend(true)).To(Succeed())
+	g.Expect(file.writeBuf).To(BeIdenticalTo(buf))
+	g.Expect(file.writeBuf.String()).To(Equal("abc"))
+	g.Expect(file.Close()).To(Succeed())
+}
+
+func TestAppendToSymlink(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	large := strings.Repeat("x", minPartSize)
+	g.Expect(afero.WriteFile(fs, "/large.txt", []byte(large), 0644)).To(Succeed())
+	g.Expect(fs.SymlinkIfPossible("/large.txt", "/link")).To(Succeed())
+
+	// the link object is rewritten, as when it is opened for writing, and
+	// its target is unchanged
+	f, err := fs.OpenFile("/link", os.O_WRONLY|os.O_APPEND, 0644)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.Close()).To(Succeed())
+	g.Expect(fs.Stats().Copy).To(BeZero())
+
+	data, err := afero.ReadFile(fs, "/large.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal(large))
+}
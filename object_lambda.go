@@ -0,0 +1,86 @@
+package s3
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// isObjectLambdaARN tests whether a bucket name is the ARN of an S3 Object
+// Lambda access point, e.g.
+// "arn:aws:s3-object-lambda:eu-west-2:123456789012:accesspoint/my-olap".
+func isObjectLambdaARN(bucket string) bool {
+	a, err := arn.Parse(bucket)
+	return err == nil && a.Service == "s3-object-lambda" && strings.HasPrefix(a.Resource, "accesspoint")
+}
+
+// objectLambdaAPI allows only the requests that an S3 Object Lambda access
+// point supports: GetObject, HeadObject and ListObjectsV2. The others fail
+// with ErrUnsupportedOperation without being sent.
+//
+// The file system uses it when the bucket given to NewFs is the ARN of an
+// Object Lambda access point. The SDK sends the requests to the access
+// point's endpoint, where a Lambda function transforms the objects as they
+// are read, so the file system is then read-only.
+type objectLambdaAPI struct {
+	S3APISubset
+}
+
+func (objectLambdaAPI) AbortMultipartUploadWithContext(aws.Context, *s3.AbortMultipartUploadInput, ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) CompleteMultipartUploadWithContext(aws.Context, *s3.CompleteMultipartUploadInput, ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) CopyObjectWithContext(aws.Context, *s3.CopyObjectInput, ...request.Option) (*s3.CopyObjectOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) CreateBucketWithContext(aws.Context, *s3.CreateBucketInput, ...request.Option) (*s3.CreateBucketOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) CreateMultipartUploadWithContext(aws.Context, *s3.CreateMultipartUploadInput, ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) DeleteObjectWithContext(aws.Context, *s3.DeleteObjectInput, ...request.Option) (*s3.DeleteObjectOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) GetObjectAttributesWithContext(aws.Context, *s3.GetObjectAttributesInput, ...request.Option) (*s3.GetObjectAttributesOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) ListMultipartUploadsWithContext(aws.Context, *s3.ListMultipartUploadsInput, ...request.Option) (*s3.ListMultipartUploadsOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) ListObjectVersionsWithContext(aws.Context, *s3.ListObjectVersionsInput, ...request.Option) (*s3.ListObjectVersionsOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) ListPartsWithContext(aws.Context, *s3.ListPartsInput, ...request.Option) (*s3.ListPartsOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) PutObjectWithContext(aws.Context, *s3.PutObjectInput, ...request.Option) (*s3.PutObjectOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) SelectObjectContentWithContext(aws.Context, *s3.SelectObjectContentInput, ...request.Option) (*s3.SelectObjectContentOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) UploadPartWithContext(aws.Context, *s3.UploadPartInput, ...request.Option) (*s3.UploadPartOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
+
+func (objectLambdaAPI) UploadPartCopyWithContext(aws.Context, *s3.UploadPartCopyInput, ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	return nil, ErrUnsupportedOperation
+}
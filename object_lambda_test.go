@@ -0,0 +1,50 @@
+package s3
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+const testObjectLambdaARN = "arn:aws:s3-object-lambda:eu-west-2:123456789012:accesspoint/my-olap"
+
+func TestIsObjectLambdaARN(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(isObjectLambdaARN(testObjectLambdaARN)).To(BeTrue())
+	g.Expect(isObjectLambdaARN("arn:aws:s3:eu-west-2:123456789012:accesspoint/my-ap")).To(BeFalse())
+	g.Expect(isObjectLambdaARN("mybucket")).To(BeFalse())
+}
+
+func TestObjectLambdaAccessPoint(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	g.Expect(afero.WriteFile(NewFs("mybucket", mem), "/a/b.txt", []byte("hello"), 0644)).To(Succeed())
+
+	fs := NewFs(testObjectLambdaARN, mem)
+
+	data, err := afero.ReadFile(fs, "/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("hello"))
+
+	names, err := afero.ReadDir(fs, "/a")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names).To(HaveLen(1))
+
+	puts := mem.Puts
+	err = afero.WriteFile(fs, "/c.txt", []byte("c"), 0644)
+	g.Expect(errors.Is(err, ErrUnsupportedOperation)).To(BeTrue())
+	g.Expect(mem.Puts).To(Equal(puts))
+
+	err = fs.Rename("/a/b.txt", "/a/c.txt")
+	g.Expect(errors.Is(err, ErrUnsupportedOperation)).To(BeTrue())
+
+	// the same error is used when S3 rejects an operation
+	err = pathError("write", "/c.txt", awserr.NewRequestFailure(awserr.New("MethodNotAllowed", "The specified method is not allowed against this resource.", nil), 405, ""))
+	g.Expect(errors.Is(err, ErrUnsupportedOperation)).To(BeTrue())
+}
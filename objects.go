@@ -0,0 +1,123 @@
+package s3
+
+import "errors"
+
+// StopListing can be returned by the function given to ListObjectsFunc to
+// stop listing early. ListObjectsFunc then returns nil.
+var StopListing = errors.New("stop listing")
+
+// ObjectIterator pages through a listing of the files in S3, fetching each
+// page only when it is needed, so that any number of files can be processed
+// without holding them all in memory. It is used like bufio.Scanner:
+//
+//	it := fs.Objects("/logs")
+//	for it.Next() {
+//		fi := it.FileInfo()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// An ObjectIterator is not safe for use by more than one goroutine.
+type ObjectIterator struct {
+	lister  Lister
+	prefix  string
+	page    FileInfoList
+	current FileInfo
+	token   *string
+	hasMore bool
+	err     error
+
+	// pages, if not nil, gets each page instead of the lister
+	pages func() (FileInfoList, bool, error)
+}
+
+// Objects gets an iterator over the files in the bucket with a given prefix,
+// including those in all subdirectories, in the same order as ListObjects.
+// Directory markers are not included. No request is sent until Next is
+// first called.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Objects(prefix string) *ObjectIterator {
+	return fs.NewLister(prefix, ListOptions{Recursive: true, FilesOnly: true, IncludeOwner: true}).Iterator()
+}
+
+// ListObjectsPage lists one page of the files in the bucket with a given
+// prefix, including those in all subdirectories, in the same order as
+// ListObjects. The token is blank for the first page; the token returned is
+// then given to get each subsequent page, until it is blank. The page has up
+// to n files; if n is zero or more than 1000, the page size is 1000.
+// Directory markers are not included, so a page may have fewer than n files.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) ListObjectsPage(prefix, token string, n int) (FileInfoList, string, error) {
+	opts := ListOptions{Recursive: true, FilesOnly: true, IncludeOwner: true, PageSize: n}
+	return fs.NewLister(prefix, opts).Page(token)
+}
+
+// Next advances to the next file, which is then available via FileInfo. It
+// returns false when there are no more files or when an error occurs.
+func (it *ObjectIterator) Next() bool {
+	for len(it.page) == 0 {
+		if !it.hasMore || it.err != nil {
+			return false
+		}
+		if it.pages != nil {
+			it.page, it.hasMore, it.err = it.pages()
+		} else {
+			it.fetch()
+		}
+	}
+
+	it.current = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+func (it *ObjectIterator) fetch() {
+	fs := it.lister.s3Fs
+	var start operation
+	it.lister.ctx, start = fs.beginWithContext(fs.ctx, "Objects", it.prefix)
+
+	page, token, hasMore, err := it.lister.doListObjects(it.lister.pageSize(), false, it.token)
+	if err != nil {
+		it.err = pathError("list", it.prefix, err)
+		fs.logOp("Objects", it.prefix, start, it.err)
+		return
+	}
+
+	fs.logOp("Objects", it.prefix, start, nil, "count", len(page))
+	it.page, it.token, it.hasMore = page, token, hasMore
+}
+
+// FileInfo gets the current file.
+func (it *ObjectIterator) FileInfo() FileInfo {
+	return it.current
+}
+
+// Err gets the error, if any, that stopped the iteration.
+func (it *ObjectIterator) Err() error {
+	return it.err
+}
+
+// ListObjectsFunc calls a function for each of the files in the bucket with
+// a given prefix, including those in all subdirectories, in the same order as
+// ListObjects. The function is called as each page of the listing arrives,
+// so the files needn't all be held in memory. If the function returns an
+// error, listing stops and the error is returned, except that StopListing
+// stops listing without error, e.g. when the file being sought has been found.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) ListObjectsFunc(prefix string, fn func(FileInfo) error) error {
+	it := fs.Objects(prefix)
+	for it.Next() {
+		if err := fn(it.FileInfo()); err != nil {
+			if err == StopListing {
+				return nil
+			}
+			return err
+		}
+	}
+	return it.Err()
+}
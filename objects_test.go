@@ -0,0 +1,116 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestObjects(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	g.Expect(fs.Mkdir("/logs", 0755)).To(Succeed())
+	for i := 0; i < 2500; i++ {
+		name := fmt.Sprintf("/logs/%02d/%04d.log", i%10, i)
+		g.Expect(afero.WriteFile(fs, name, []byte("x"), 0644)).To(Succeed())
+	}
+	g.Expect(afero.WriteFile(fs, "/other.txt", []byte("x"), 0644)).To(Succeed())
+
+	it := fs.Objects("/logs")
+	g.Expect(mem.Lists).To(Equal(0))
+
+	g.Expect(it.Next()).To(BeTrue())
+	g.Expect(it.FileInfo().Path()).To(Equal("/logs/00/0000.log"))
+	g.Expect(mem.Lists).To(Equal(1))
+
+	count := 1
+	for it.Next() {
+		g.Expect(it.FileInfo().IsDir()).To(BeFalse())
+		count++
+	}
+	g.Expect(it.Err()).NotTo(HaveOccurred())
+	g.Expect(count).To(Equal(2500))
+	g.Expect(mem.Lists).To(Equal(3))
+	g.Expect(it.Next()).To(BeFalse())
+
+	it = fs.Objects("/nothing")
+	g.Expect(it.Next()).To(BeFalse())
+	g.Expect(it.Err()).NotTo(HaveOccurred())
+}
+
+func TestObjectsError(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", &s3stub{failure: awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, "")})
+	it := fs.Objects("/logs")
+	g.Expect(it.Next()).To(BeFalse())
+	g.Expect(os.IsPermission(it.Err())).To(BeTrue())
+}
+
+func TestListObjectsFunc(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	for i := 0; i < 1500; i++ {
+		g.Expect(afero.WriteFile(fs, fmt.Sprintf("/data/%04d.csv", i), []byte("x"), 0644)).To(Succeed())
+	}
+
+	var found string
+	err := fs.ListObjectsFunc("/data", func(fi FileInfo) error {
+		if fi.Name() == "0042.csv" {
+			found = fi.Path()
+			return StopListing
+		}
+		return nil
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(found).To(Equal("/data/0042.csv"))
+	g.Expect(mem.Lists).To(Equal(1))
+
+	count := 0
+	err = fs.ListObjectsFunc("/data", func(fi FileInfo) error {
+		count++
+		return nil
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(count).To(Equal(1500))
+
+	failure := errors.New("failed")
+	err = fs.ListObjectsFunc("/data", func(fi FileInfo) error {
+		return failure
+	})
+	g.Expect(err).To(Equal(failure))
+}
+
+func TestListObjectsPage(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	for i := 0; i < 5; i++ {
+		g.Expect(afero.WriteFile(fs, fmt.Sprintf("/data/%d.csv", i), []byte("x"), 0644)).To(Succeed())
+	}
+
+	var names []string
+	token := ""
+	for pages := 1; ; pages++ {
+		list, next, err := fs.ListObjectsPage("/data", token, 2)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(len(list)).To(BeNumerically("<=", 2))
+		names = append(names, list.Names()...)
+		if next == "" {
+			g.Expect(pages).To(Equal(3))
+			break
+		}
+		token = next
+	}
+	g.Expect(names).To(Equal([]string{"0.csv", "1.csv", "2.csv", "3.csv", "4.csv"}))
+}
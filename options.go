@@ -0,0 +1,101 @@
+package s3
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// writeOptions holds the settings that are applied to every object written,
+// whether by PutObject (on closing a File) or by CopyObject (e.g. Rename).
+// An Fs holds the defaults; each File starts with a copy of these.
+type writeOptions struct {
+	acl             *string
+	lockMode        *string
+	lockRetainUntil *time.Time
+	legalHold       *string
+	modTime         bool // store the time the file was opened as its mtime metadata
+	contentType     *string
+	checksum        string // the additional checksum algorithm, if any
+	noContentMD5    bool
+	expires         *time.Time
+	expiresAfter    time.Duration // used if expires is nil
+	redirect        *string
+	kms             bool    // use SSE-KMS
+	kmsKeyID        *string // blank for the default key
+	kmsContext      *string // the encoded encryption context
+	headers         objectHeaders
+}
+
+func (o writeOptions) applyToPut(input *s3.PutObjectInput) {
+	input.ACL = o.acl
+	input.ObjectLockMode = o.lockMode
+	input.ObjectLockRetainUntilDate = o.lockRetainUntil
+	input.ObjectLockLegalHoldStatus = o.legalHold
+	input.Expires = o.expiresAt()
+	input.WebsiteRedirectLocation = o.redirect
+	input.ServerSideEncryption, input.SSEKMSKeyId, input.SSEKMSEncryptionContext = o.sse(nil, nil)
+	o.headers.applyToPut(input)
+	if o.contentType != nil {
+		input.ContentType = o.contentType
+	}
+}
+
+func (o writeOptions) applyToCreateMultipart(input *s3.CreateMultipartUploadInput) {
+	input.ACL = o.acl
+	input.ObjectLockMode = o.lockMode
+	input.ObjectLockRetainUntilDate = o.lockRetainUntil
+	input.ObjectLockLegalHoldStatus = o.legalHold
+	input.ChecksumAlgorithm = optionalString(o.checksum)
+	input.Expires = o.expiresAt()
+	input.WebsiteRedirectLocation = o.redirect
+	input.ServerSideEncryption, input.SSEKMSKeyId, input.SSEKMSEncryptionContext = o.sse(nil, nil)
+	o.headers.applyToCreateMultipart(input)
+	if o.contentType != nil {
+		input.ContentType = o.contentType
+	}
+}
+
+func (o writeOptions) applyToCopy(input *s3.CopyObjectInput) {
+	input.ACL = o.acl
+	input.ObjectLockMode = o.lockMode
+	input.ObjectLockRetainUntilDate = o.lockRetainUntil
+	input.ObjectLockLegalHoldStatus = o.legalHold
+	input.ChecksumAlgorithm = optionalString(o.checksum)
+	input.ServerSideEncryption, input.SSEKMSKeyId, input.SSEKMSEncryptionContext = o.sse(input.ServerSideEncryption, input.SSEKMSKeyId)
+}
+
+// expiresAt gets the value of the Expires header for an object written now.
+func (o writeOptions) expiresAt() *time.Time {
+	if o.expires == nil && o.expiresAfter != 0 {
+		t := time.Now().Add(o.expiresAfter)
+		return &t
+	}
+	return o.expires
+}
+
+func (o *writeOptions) setObjectLock(mode string, retainUntil time.Time) {
+	if mode == "" {
+		o.lockMode = nil
+		o.lockRetainUntil = nil
+	} else {
+		o.lockMode = &mode
+		o.lockRetainUntil = &retainUntil
+	}
+}
+
+func (o *writeOptions) setLegalHold(on bool) {
+	if on {
+		o.legalHold = optionalString(s3.ObjectLockLegalHoldStatusOn)
+	} else {
+		o.legalHold = nil
+	}
+}
+
+// optionalString returns nil for a blank string, or a pointer to it otherwise.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
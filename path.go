@@ -1,5 +1,16 @@
 package s3
 
+import (
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
 func hasTrailingSlash(s string) bool {
 	return len(s) > 0 && s[len(s)-1] == '/'
 }
@@ -37,3 +48,101 @@ func depth(s string) int {
 	}
 	return d
 }
+
+// copySource forms the CopySource value that refers to a key in a bucket.
+// This is the URL-encoded bucket and key separated by a slash.
+func copySource(bucket, key string) string {
+	u := url.URL{Path: bucket + PathSeparator + key}
+	return u.EscapedPath()
+}
+
+// key maps a file path to the S3 object key that holds it. This is the only
+// place where this mapping happens, so that every operation addresses a given
+// file identically. The path is cleaned and has no leading slash, so "a/b",
+// "/a/b" and "/a/./b" are all the same file. A trailing slash is kept because
+// it is significant, e.g. for directory marker objects. The key prefix of
+// the file system, if any, is prepended.
+//
+// When sanitising is enabled (see KeyValidation), problem characters are
+// replaced here too.
+func (fs Fs) key(name string) string {
+	if fs.keyValidation == KeySanitize {
+		name = sanitizeName(name)
+	}
+	k := trimLeadingSlash(path.Clean(PathSeparator + name))
+	if k != "" && hasTrailingSlash(name) {
+		k += PathSeparator
+	}
+	return fs.keyPrefix + k
+}
+
+// pathOf is the inverse of key; it maps an S3 object key to the file path,
+// which always has a leading slash.
+func (fs Fs) pathOf(key string) string {
+	return PathSeparator + strings.TrimPrefix(key, fs.keyPrefix)
+}
+
+// normaliseKeyPrefix cleans a key prefix so that it is either blank or has
+// a trailing slash but no leading slash.
+func normaliseKeyPrefix(prefix string) string {
+	return addTrailingSlash(trimLeadingSlash(path.Clean(PathSeparator + prefix)))
+}
+
+// KeyValidation determines how file names are checked before being used as
+// S3 object keys.
+type KeyValidation int
+
+const (
+	// KeyDefault rejects keys that are longer than MaxKeyLength, that are not
+	// valid UTF-8, or that contain control characters.
+	KeyDefault KeyValidation = iota
+
+	// KeyStrict is like KeyDefault but also rejects backslashes, which some
+	// S3-compatible providers (and tools) treat as path separators.
+	KeyStrict
+
+	// KeySanitize replaces control characters and invalid UTF-8 with '_',
+	// and backslashes with '/', instead of rejecting them. Keys that are
+	// too long are still rejected.
+	KeySanitize
+)
+
+// MaxKeyLength is the maximum length of an S3 object key, in bytes.
+const MaxKeyLength = 1024
+
+// checkName validates the key for a file name. If it is not acceptable, the
+// error is an *os.PathError wrapping an *InvalidKeyError.
+func (fs Fs) checkName(op, name string) error {
+	key := fs.key(name)
+	reason := ""
+
+	switch {
+	case len(key) > MaxKeyLength:
+		reason = "longer than " + strconv.Itoa(MaxKeyLength) + " bytes"
+	case !utf8.ValidString(key):
+		reason = "not valid UTF-8"
+	case strings.IndexFunc(key, unicode.IsControl) >= 0:
+		reason = "contains a control character"
+	case fs.keyValidation == KeyStrict && strings.IndexByte(key, '\\') >= 0:
+		reason = "contains a backslash"
+	default:
+		return nil
+	}
+
+	err := &os.PathError{Op: op, Path: name, Err: &InvalidKeyError{Key: key, Reason: reason}}
+	fs.logOp(op, name, operation{start: time.Now()}, err)
+	return err
+}
+
+// sanitizeName replaces the characters that checkName would reject.
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\\':
+			return '/'
+		case r == utf8.RuneError, unicode.IsControl(r):
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,74 @@
+package s3
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// unreachableError is a failure to reach S3, wrapping the cause so that it
+// can still be found using errors.As.
+type unreachableError struct {
+	err error
+}
+
+func (e *unreachableError) Error() string { return ErrUnreachable.Error() + ": " + e.err.Error() }
+
+// Is allows errors.Is to match ErrUnreachable.
+func (e *unreachableError) Is(target error) bool { return target == ErrUnreachable }
+
+// Unwrap gets the cause.
+func (e *unreachableError) Unwrap() error { return e.err }
+
+// Ping checks that the bucket exists and can be listed, using the cheapest
+// request that needs the same permissions as reading a directory: a listing
+// of at most one object. It returns nil if all is well. Otherwise, the error
+// matches (using errors.Is) ErrBucketNotFound, ErrAccessDenied or
+// ErrUnreachable if the cause is one of these, or ErrCircuitOpen if the
+// circuit breaker is open. This suits checking the configuration at startup
+// and in health endpoints.
+//
+// The context given is used instead of the one set by WithContext.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Ping(ctx context.Context) error {
+	ctx, start := fs.beginWithContext(ctx, "Ping", "/")
+	ctx, cancel := withTimeout(ctx, fs.timeouts.list)
+	defer cancel()
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(fs.bucket),
+		MaxKeys: aws.Int64(1),
+	}
+	if fs.keyPrefix != "" {
+		input.Prefix = aws.String(fs.keyPrefix)
+	}
+
+	_, err := fs.s3API.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		if isUnreachable(err) {
+			err = &unreachableError{err: err}
+		}
+		err = pathError("ping", "/", err)
+	}
+	fs.logOp("Ping", "/", start, err)
+	return err
+}
+
+// isUnreachable tests whether a request failed without any response from S3.
+func isUnreachable(err error) bool {
+	if _, ok := err.(awserr.RequestFailure); ok {
+		return false
+	}
+	if ae, ok := err.(awserr.Error); ok {
+		switch ae.Code() {
+		case request.ErrCodeRequestError, request.ErrCodeResponseTimeout, request.CanceledErrorCode:
+			return true
+		}
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
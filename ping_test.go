@@ -0,0 +1,47 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+)
+
+// failingListBucket fails every listing with an error.
+type failingListBucket struct {
+	*s3fake.Bucket
+	err error
+}
+
+func (b failingListBucket) ListObjectsV2WithContext(ctx aws.Context, req *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	return nil, b.err
+}
+
+func TestPing(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(NewFs("mybucket", s3fake.New()).Ping(context.Background())).To(Succeed())
+
+	cases := map[error]awserr.Error{
+		ErrBucketNotFound: awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist", nil), 404, ""),
+		ErrAccessDenied:   awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, ""),
+		ErrUnreachable:    awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("dial tcp: connection refused")),
+	}
+	for expected, cause := range cases {
+		fs := NewFs("mybucket", failingListBucket{Bucket: s3fake.New(), err: cause})
+		err := fs.Ping(context.Background())
+		g.Expect(errors.Is(err, expected)).To(BeTrue(), err.Error())
+	}
+
+	// the cause of a network failure is kept
+	fs := NewFs("mybucket", failingListBucket{Bucket: s3fake.New(), err: cases[ErrUnreachable]})
+	var ae awserr.Error
+	g.Expect(errors.As(fs.Ping(context.Background()), &ae)).To(BeTrue())
+	g.Expect(ae.Code()).To(Equal(request.ErrCodeRequestError))
+}
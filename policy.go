@@ -0,0 +1,324 @@
+package s3
+
+import (
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Access is a class of operations, for a Rule. Values can be combined, e.g.
+// ReadAccess|ListAccess.
+type Access uint8
+
+const (
+	// ReadAccess allows files to be opened for reading and to be stat'd.
+	ReadAccess Access = 1 << iota
+	// WriteAccess allows files to be created and written, directories to be
+	// made, and the mode and times of files to be changed.
+	WriteAccess
+	// DeleteAccess allows files and directories to be removed.
+	DeleteAccess
+	// ListAccess allows directories to be read and to be stat'd.
+	ListAccess
+)
+
+// Rule allows or denies a class of operations for the files that match a
+// pattern; see PolicyFs.
+type Rule struct {
+	// Access is the class of operations affected.
+	Access Access
+	// Path is a pattern, as used by path.Match, for the names of the files
+	// affected. It also affects everything below the directories it matches,
+	// so "/public" affects "/public/a/b.txt".
+	Path string
+	// Deny denies the operations. Otherwise, they are allowed.
+	Deny bool
+}
+
+// matches tests whether a rule applies to an operation.
+func (r Rule) matches(access Access, name string) bool {
+	return r.Access&access != 0 && pathMatches(r.Path, name)
+}
+
+// pathMatches tests whether a name, or any directory above it, matches a
+// pattern, as used by path.Match.
+func pathMatches(pattern, name string) bool {
+	for name = path.Clean(PathSeparator + name); ; name = path.Dir(name) {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+		if name == PathSeparator {
+			return false
+		}
+	}
+}
+
+// PolicyFs is a file system that restricts the operations allowed on
+// another file system, according to a list of rules, so that it can be
+// handed to semi-trusted code. For example, the rules
+//
+//	Rule{Access: ReadAccess | ListAccess, Path: "/public"}
+//	Rule{Access: WriteAccess, Path: "/uploads"}
+//	Rule{Access: WriteAccess, Path: "/uploads/*.exe", Deny: true}
+//
+// allow reads below /public and writes below /uploads, except of .exe files.
+//
+// An operation is denied if any rule that matches it denies it; otherwise it
+// is allowed only if a rule that matches it allows it. Denied operations
+// fail with an error that matches os.ErrPermission.
+type PolicyFs struct {
+	source afero.Fs
+	rules  []Rule
+}
+
+var _ afero.Fs = (*PolicyFs)(nil)
+
+// NewPolicyFs creates a file system that restricts the operations on another
+// file system, which would usually be an *Fs, according to a list of rules.
+func NewPolicyFs(source afero.Fs, rules ...Rule) *PolicyFs {
+	return &PolicyFs{source: source, rules: rules}
+}
+
+// Allowed tests whether the rules allow a class of operations on a file.
+func (pfs *PolicyFs) Allowed(access Access, name string) bool {
+	allowed := false
+	for _, r := range pfs.rules {
+		if r.matches(access, name) {
+			if r.Deny {
+				return false
+			}
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// check tests whether the rules allow any of a class of operations on a file.
+func (pfs *PolicyFs) check(op, name string, access ...Access) error {
+	for _, a := range access {
+		if pfs.Allowed(a, name) {
+			return nil
+		}
+	}
+	lgr("PolicyFs %s %q denied\n", op, name)
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
+
+// Name returns the name of the source file system.
+func (pfs *PolicyFs) Name() string { return "Policy/" + pfs.source.Name() }
+
+// Create a file.
+func (pfs *PolicyFs) Create(name string) (afero.File, error) {
+	if err := pfs.check("create", name, WriteAccess); err != nil {
+		return nil, err
+	}
+	file, err := pfs.source.Create(name)
+	return pfs.wrap(file, err, true)
+}
+
+// Mkdir makes a directory.
+func (pfs *PolicyFs) Mkdir(name string, perm os.FileMode) error {
+	if err := pfs.check("mkdir", name, WriteAccess); err != nil {
+		return err
+	}
+	return pfs.source.Mkdir(name, perm)
+}
+
+// MkdirAll creates a directory and all parent directories if necessary.
+func (pfs *PolicyFs) MkdirAll(path string, perm os.FileMode) error {
+	if err := pfs.check("mkdirall", path, WriteAccess); err != nil {
+		return err
+	}
+	return pfs.source.MkdirAll(path, perm)
+}
+
+// Open a file for reading. Directories can be opened for listing.
+func (pfs *PolicyFs) Open(name string) (afero.File, error) {
+	if err := pfs.check("open", name, ReadAccess, ListAccess); err != nil {
+		return nil, err
+	}
+	file, err := pfs.source.Open(name)
+	if err == nil && !pfs.Allowed(ReadAccess, name) {
+		err = pfs.requireDir(file, name)
+	}
+	return pfs.wrap(file, err, false)
+}
+
+// OpenFile opens a file, which needs write access unless it is opened only
+// for reading.
+func (pfs *PolicyFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	writable := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0
+	if writable {
+		if err := pfs.check("open", name, WriteAccess); err != nil {
+			return nil, err
+		}
+	}
+	readable := flag&os.O_WRONLY == 0
+	if readable {
+		if err := pfs.check("open", name, ReadAccess, ListAccess); err != nil {
+			return nil, err
+		}
+	}
+	file, err := pfs.source.OpenFile(name, flag, perm)
+	if err == nil && readable && !pfs.Allowed(ReadAccess, name) {
+		err = pfs.requireDir(file, name)
+	}
+	return pfs.wrap(file, err, writable)
+}
+
+// requireDir fails, closing the file, unless it is a directory. Without read
+// access, list access allows only directories to be opened.
+func (pfs *PolicyFs) requireDir(file afero.File, name string) error {
+	fi, err := file.Stat()
+	if err == nil && !fi.IsDir() {
+		lgr("PolicyFs open %q denied\n", name)
+		err = &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	if err != nil {
+		file.Close()
+	}
+	return err
+}
+
+// Remove a file.
+func (pfs *PolicyFs) Remove(name string) error {
+	if err := pfs.check("remove", name, DeleteAccess); err != nil {
+		return err
+	}
+	return pfs.source.Remove(name)
+}
+
+// RemoveAll removes a path and any children it contains. If a rule denies
+// deleting any of the children, nothing is removed and it fails with an
+// error that matches os.ErrPermission.
+func (pfs *PolicyFs) RemoveAll(path string) error {
+	if err := pfs.check("removeall", path, DeleteAccess); err != nil {
+		return err
+	}
+	if pfs.deniesAny(DeleteAccess) {
+		err := afero.Walk(pfs.source, path, func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return pfs.check("removeall", name, DeleteAccess)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return pfs.source.RemoveAll(path)
+}
+
+// deniesAny tests whether any rule denies a class of operations, in which
+// case the rules may forbid operations below a path that they allow on it.
+func (pfs *PolicyFs) deniesAny(access Access) bool {
+	for _, r := range pfs.rules {
+		if r.Deny && r.Access&access != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Rename a file, which needs delete access to the old name and write access
+// to the new name.
+func (pfs *PolicyFs) Rename(oldname, newname string) error {
+	if !pfs.Allowed(DeleteAccess, oldname) || !pfs.Allowed(WriteAccess, newname) {
+		lgr("PolicyFs rename %q %q denied\n", oldname, newname)
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	return pfs.source.Rename(oldname, newname)
+}
+
+// Stat returns a FileInfo describing the named file.
+func (pfs *PolicyFs) Stat(name string) (os.FileInfo, error) {
+	if err := pfs.check("stat", name, ReadAccess, ListAccess); err != nil {
+		return nil, err
+	}
+	return pfs.source.Stat(name)
+}
+
+// Chmod changes the mode of a file.
+func (pfs *PolicyFs) Chmod(name string, mode os.FileMode) error {
+	if err := pfs.check("chmod", name, WriteAccess); err != nil {
+		return err
+	}
+	return pfs.source.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of a file.
+func (pfs *PolicyFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := pfs.check("chtimes", name, WriteAccess); err != nil {
+		return err
+	}
+	return pfs.source.Chtimes(name, atime, mtime)
+}
+
+func (pfs *PolicyFs) wrap(file afero.File, err error, writable bool) (afero.File, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &policyFile{File: file, pfs: pfs, writable: writable}, nil
+}
+
+// policyFile checks the rules for listing a directory, which may have been
+// opened with only read access, and prevents writing to a file unless it was
+// opened for writing, which needs write access.
+type policyFile struct {
+	afero.File
+	pfs      *PolicyFs
+	writable bool
+}
+
+// checkWritable fails unless the file was opened for writing.
+func (f *policyFile) checkWritable(op string) error {
+	if f.writable {
+		return nil
+	}
+	lgr("PolicyFs %s %q denied\n", op, f.Name())
+	return &os.PathError{Op: op, Path: f.Name(), Err: os.ErrPermission}
+}
+
+func (f *policyFile) Write(p []byte) (int, error) {
+	if err := f.checkWritable("write"); err != nil {
+		return 0, err
+	}
+	return f.File.Write(p)
+}
+
+func (f *policyFile) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.checkWritable("write"); err != nil {
+		return 0, err
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func (f *policyFile) WriteString(s string) (int, error) {
+	if err := f.checkWritable("write"); err != nil {
+		return 0, err
+	}
+	return f.File.WriteString(s)
+}
+
+func (f *policyFile) Truncate(size int64) error {
+	if err := f.checkWritable("truncate"); err != nil {
+		return err
+	}
+	return f.File.Truncate(size)
+}
+
+func (f *policyFile) Readdir(n int) ([]os.FileInfo, error) {
+	if err := f.pfs.check("readdir", f.Name(), ListAccess); err != nil {
+		return nil, err
+	}
+	return f.File.Readdir(n)
+}
+
+func (f *policyFile) Readdirnames(n int) ([]string, error) {
+	if err := f.pfs.check("readdir", f.Name(), ListAccess); err != nil {
+		return nil, err
+	}
+	return f.File.Readdirnames(n)
+}
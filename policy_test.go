@@ -0,0 +1,130 @@
+package s3
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+func TestPolicyFs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	source := afero.NewMemMapFs()
+	g.Expect(afero.WriteFile(source, "/public/a.txt", []byte("a"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(source, "/private/b.txt", []byte("b"), 0644)).To(Succeed())
+	g.Expect(source.MkdirAll("/uploads", 0755)).To(Succeed())
+
+	fs := NewPolicyFs(source,
+		Rule{Access: ReadAccess | ListAccess, Path: "/public"},
+		Rule{Access: WriteAccess | DeleteAccess, Path: "/uploads"},
+		Rule{Access: WriteAccess, Path: "/uploads/*.exe", Deny: true},
+	)
+
+	data, err := afero.ReadFile(fs, "/public/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("a"))
+
+	names, err := afero.ReadDir(fs, "/public")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names).To(HaveLen(1))
+
+	_, err = afero.ReadFile(fs, "/private/b.txt")
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+	g.Expect(err).To(Equal(&os.PathError{Op: "open", Path: "/private/b.txt", Err: os.ErrPermission}))
+
+	err = afero.WriteFile(fs, "/public/c.txt", []byte("c"), 0644)
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+
+	g.Expect(afero.WriteFile(fs, "/uploads/c.txt", []byte("c"), 0644)).To(Succeed())
+	err = afero.WriteFile(fs, "/uploads/c.exe", []byte("c"), 0644)
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+
+	// uploads can be written but not read or listed
+	_, err = afero.ReadFile(fs, "/uploads/c.txt")
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+
+	err = fs.Rename("/uploads/c.txt", "/public/c.txt")
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+
+	g.Expect(fs.Rename("/uploads/c.txt", "/uploads/d.txt")).To(Succeed())
+	g.Expect(fs.Remove("/uploads/d.txt")).To(Succeed())
+	g.Expect(os.IsPermission(fs.Remove("/public/a.txt"))).To(BeTrue())
+}
+
+func TestPolicyFsReadOnlyFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	source := afero.NewMemMapFs()
+	g.Expect(afero.WriteFile(source, "/public/a.txt", []byte("a"), 0644)).To(Succeed())
+
+	fs := NewPolicyFs(source, Rule{Access: ReadAccess | ListAccess, Path: "/public"})
+
+	for _, open := range []func() (afero.File, error){
+		func() (afero.File, error) { return fs.Open("/public/a.txt") },
+		func() (afero.File, error) { return fs.OpenFile("/public/a.txt", os.O_RDONLY, 0) },
+	} {
+		f, err := open()
+		g.Expect(err).NotTo(HaveOccurred())
+
+		_, err = f.Write([]byte("pwned"))
+		g.Expect(err).To(Equal(&os.PathError{Op: "write", Path: "/public/a.txt", Err: os.ErrPermission}))
+		_, err = f.WriteAt([]byte("pwned"), 0)
+		g.Expect(os.IsPermission(err)).To(BeTrue())
+		_, err = f.WriteString("pwned")
+		g.Expect(os.IsPermission(err)).To(BeTrue())
+		g.Expect(os.IsPermission(f.Truncate(0))).To(BeTrue())
+		g.Expect(f.Close()).To(Succeed())
+	}
+
+	data, err := afero.ReadFile(source, "/public/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("a"))
+}
+
+func TestPolicyFsRemoveAllWithDeniedChild(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	source := afero.NewMemMapFs()
+	g.Expect(afero.WriteFile(source, "/uploads/a.txt", []byte("a"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(source, "/uploads/keep/x.txt", []byte("x"), 0644)).To(Succeed())
+
+	fs := NewPolicyFs(source,
+		Rule{Access: DeleteAccess, Path: "/uploads"},
+		Rule{Access: DeleteAccess, Path: "/uploads/keep", Deny: true},
+	)
+
+	g.Expect(os.IsPermission(fs.Remove("/uploads/keep/x.txt"))).To(BeTrue())
+	err := fs.RemoveAll("/uploads")
+	g.Expect(err).To(Equal(&os.PathError{Op: "removeall", Path: "/uploads/keep", Err: os.ErrPermission}))
+
+	for _, name := range []string{"/uploads/a.txt", "/uploads/keep/x.txt"} {
+		_, err = source.Stat(name)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	g.Expect(fs.RemoveAll("/uploads/a.txt")).To(Succeed())
+	g.Expect(fs.RemoveAll("/uploads/missing")).To(Succeed())
+	_, err = source.Stat("/uploads/a.txt")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+}
+
+func TestPolicyFsListOnly(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	source := afero.NewMemMapFs()
+	g.Expect(afero.WriteFile(source, "/public/secret.txt", []byte("secret"), 0644)).To(Succeed())
+
+	fs := NewPolicyFs(source, Rule{Access: ListAccess, Path: "/public"})
+
+	names, err := afero.ReadDir(fs, "/public")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(names).To(HaveLen(1))
+
+	_, err = afero.ReadFile(fs, "/public/secret.txt")
+	g.Expect(err).To(Equal(&os.PathError{Op: "open", Path: "/public/secret.txt", Err: os.ErrPermission}))
+
+	_, err = fs.OpenFile("/public/secret.txt", os.O_RDONLY, 0)
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+}
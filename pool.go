@@ -0,0 +1,31 @@
+package s3
+
+import (
+	"bytes"
+	"sync"
+)
+
+// writeBuffers holds the write buffers of closed files for reuse, which saves
+// allocating and growing a new buffer for every file written.
+var writeBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer is the capacity above which write buffers are not reused,
+// so that the pool does not hold on to the memory used by a few large files.
+const maxPooledBuffer = 16 << 20
+
+// getWriteBuffer gets an empty write buffer.
+func getWriteBuffer() *bytes.Buffer {
+	return writeBuffers.Get().(*bytes.Buffer)
+}
+
+// putWriteBuffer returns a write buffer to the pool. Its content must no
+// longer be in use.
+func putWriteBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	writeBuffers.Put(buf)
+}
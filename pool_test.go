@@ -0,0 +1,31 @@
+package s3
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestWriteBufferPool(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	buf := getWriteBuffer()
+	buf.WriteString("hello")
+	putWriteBuffer(buf)
+	g.Expect(buf.Len()).To(BeZero())
+
+	large := bytes.NewBuffer(make([]byte, 0, maxPooledBuffer+1))
+	large.WriteString("world")
+	putWriteBuffer(large)
+	g.Expect(large.Len()).To(Equal(5)) // not reset, because it was not pooled
+
+	// the buffers are reused, without affecting the content of the files
+	fs := NewFs("mybucket", s3fake.New())
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("aaaaaa"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/b.txt", []byte("bb"), 0644)).To(Succeed())
+	g.Expect(afero.ReadFile(fs, "/a.txt")).To(Equal([]byte("aaaaaa")))
+	g.Expect(afero.ReadFile(fs, "/b.txt")).To(Equal([]byte("bb")))
+}
@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// presigner is the part of the S3 client API needed for presigned URLs. It is
+// implemented by *s3.S3, but not by S3APISubset, so that other implementations
+// of S3APISubset need not provide it.
+type presigner interface {
+	GetObjectRequest(*s3.GetObjectInput) (*request.Request, *s3.GetObjectOutput)
+	PutObjectRequest(*s3.PutObjectInput) (*request.Request, *s3.PutObjectOutput)
+}
+
+var errNoPresigner = errors.New("the S3 client cannot presign requests")
+
+// PresignGet creates a URL that can be used to download a file, e.g. by a web
+// browser, without any further authentication until the expiry duration has
+// passed. The key prefix, if any, is applied to the name.
+//
+// This requires the S3 client given to NewFs to be an *s3.S3 (or otherwise to
+// provide GetObjectRequest). No request is sent to S3, so the file need not
+// exist.
+func (fs Fs) PresignGet(name string, expiry time.Duration) (string, error) {
+	if err := fs.checkName("presign", name); err != nil {
+		return "", err
+	}
+
+	start := fs.begin("PresignGet", name)
+
+	client, ok := fs.client.(presigner)
+	if !ok {
+		err := pathError("presign", name, errNoPresigner)
+		fs.logOp("PresignGet", name, start, err)
+		return "", err
+	}
+
+	req, _ := client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.key(name)),
+	})
+
+	url, err := req.Presign(expiry)
+	if err != nil {
+		err = pathError("presign", name, err)
+		fs.logOp("PresignGet", name, start, err)
+		return "", err
+	}
+
+	fs.logOp("PresignGet", name, start, nil, "expiry", expiry)
+	return url, nil
+}
+
+// PresignPut creates a URL that can be used to upload a file, e.g. by a web
+// browser, without any further authentication until the expiry duration has
+// passed. The key prefix, if any, is applied to the name. The content type is
+// set from the mime types of the file system and the ACL, Object Lock and
+// Expires settings are applied, as for files written using Create. Further headers
+// can be given, such as "Content-Type" or "x-amz-meta-...".
+//
+// The upload must be made using the PUT method and must include the headers
+// returned, which are those that were signed other than Host.
+//
+// Like PresignGet, this requires the S3 client given to NewFs to be an *s3.S3.
+func (fs Fs) PresignPut(name string, expiry time.Duration, headers map[string]string) (string, http.Header, error) {
+	if err := fs.checkName("presign", name); err != nil {
+		return "", nil, err
+	}
+
+	start := fs.begin("PresignPut", name)
+
+	client, ok := fs.client.(presigner)
+	if !ok {
+		err := pathError("presign", name, errNoPresigner)
+		fs.logOp("PresignPut", name, start, err)
+		return "", nil, err
+	}
+
+	file := File{name: name, s3Fs: fs}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(fs.bucket),
+		Key:         aws.String(fs.key(name)),
+		ContentType: file.lookupContentType(),
+	}
+	fs.writeOptionsFor(name).applyToPut(input)
+
+	req, _ := client.PutObjectRequest(input)
+	for k, v := range headers {
+		req.HTTPRequest.Header.Set(k, v)
+	}
+
+	url, signed, err := req.PresignRequest(expiry)
+	if err != nil {
+		err = pathError("presign", name, err)
+		fs.logOp("PresignPut", name, start, err)
+		return "", nil, err
+	}
+
+	// the signer uses lower-case names, so these are canonicalised
+	required := make(http.Header)
+	for k, vs := range signed {
+		if !strings.EqualFold(k, "Host") {
+			for _, v := range vs {
+				required.Add(k, v)
+			}
+		}
+	}
+
+	fs.logOp("PresignPut", name, start, nil, "expiry", expiry)
+	return url, required, nil
+}
+
+// URL gets the public URL of a file, which does not include any
+// authentication. This is only useful if the file can be read publicly, e.g.
+// because it has the public-read ACL or the bucket policy allows it. The key
+// prefix, if any, is applied to the name.
+//
+// The URL follows the configuration of the S3 client given to NewFs, which
+// must be an *s3.S3 (or otherwise provide GetObjectRequest), including its
+// endpoint and whether it uses path-style or virtual-hosted-style addressing.
+func (fs Fs) URL(name string) (string, error) {
+	if err := fs.checkName("url", name); err != nil {
+		return "", err
+	}
+
+	client, ok := fs.client.(presigner)
+	if !ok {
+		return "", pathError("url", name, errNoPresigner)
+	}
+
+	req, _ := client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.key(name)),
+	})
+	if err := req.Build(); err != nil {
+		return "", pathError("url", name, err)
+	}
+
+	u := *req.HTTPRequest.URL
+	u.RawQuery = ""
+	return u.String(), nil
+}
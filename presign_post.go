@@ -0,0 +1,175 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// PostConditions limits what can be uploaded using a presigned POST.
+type PostConditions struct {
+	// ContentType is the content type the upload must have. If it ends with
+	// "/", e.g. "image/", it is a prefix that the content type must start with.
+	// If blank, the content type is not restricted.
+	ContentType string
+	// MinSize and MaxSize limit the size of the upload, in bytes. If MaxSize
+	// is zero, the size is not limited.
+	MinSize, MaxSize int64
+}
+
+// PresignedPost holds what a web browser needs to upload a file directly to
+// S3 using an HTML form. The form must use the POST method with multipart
+// encoding, include each of the fields, plus a "Content-Type" field if the
+// content type was restricted, and have the file as its last field.
+type PresignedPost struct {
+	URL    string
+	Fields map[string]string
+}
+
+// PresignPost creates a policy for uploading files using an HTML form, e.g.
+// from a web browser, without any further authentication until the expiry
+// duration has passed. The key prefix, if any, is applied to the name.
+//
+// If the name ends with "/", it is a directory and any file can be uploaded
+// into it; the key field is then set so that the name of the file uploaded
+// is used (S3 replaces "${filename}" with it), but it can be changed to any
+// other key in the directory. Otherwise, only the named file can be uploaded.
+// The ACL set by WithACL, if any, is required.
+//
+// This requires the S3 client given to NewFs to be an *s3.S3. No request is
+// sent to S3.
+func (fs Fs) PresignPost(name string, expiry time.Duration, conditions PostConditions) (*PresignedPost, error) {
+	dir := hasTrailingSlash(name)
+	if err := fs.checkName("presign", name); err != nil {
+		return nil, err
+	}
+
+	start := fs.begin("PresignPost", name)
+
+	post, err := fs.presignPost(name, dir, expiry, conditions)
+	if err != nil {
+		err = pathError("presign", name, err)
+		fs.logOp("PresignPost", name, start, err)
+		return nil, err
+	}
+
+	fs.logOp("PresignPost", name, start, nil, "expiry", expiry)
+	return post, nil
+}
+
+func (fs Fs) presignPost(name string, dir bool, expiry time.Duration, c PostConditions) (*PresignedPost, error) {
+	client, ok := fs.client.(*s3.S3)
+	if !ok {
+		return nil, errNoPresigner
+	}
+
+	creds, err := client.Config.Credentials.GetWithContext(fs.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, _ := client.HeadBucketRequest(&s3.HeadBucketInput{Bucket: aws.String(fs.bucket)})
+	if err := req.Build(); err != nil {
+		return nil, err
+	}
+	bucketURL := *req.HTTPRequest.URL
+	bucketURL.RawQuery = ""
+
+	region := client.SigningRegion
+	if region == "" {
+		region = aws.StringValue(client.Config.Region)
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	credential := strings.Join([]string{creds.AccessKeyID, date, region, "s3", "aws4_request"}, "/")
+
+	fields := map[string]string{
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       now.Format("20060102T150405Z"),
+	}
+	policy := []interface{}{
+		map[string]string{"bucket": fs.bucket},
+	}
+
+	key := fs.key(name)
+	if dir {
+		key = strings.TrimSuffix(key, "/") + "/"
+		if key == "/" {
+			key = ""
+		}
+		fields["key"] = key + "${filename}"
+		policy = append(policy, []string{"starts-with", "$key", key})
+	} else {
+		fields["key"] = key
+		policy = append(policy, map[string]string{"key": key})
+	}
+
+	if fs.writeOpts.acl != nil {
+		fields["acl"] = *fs.writeOpts.acl
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != "key" {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		policy = append(policy, map[string]string{k: fields[k]})
+	}
+
+	if strings.HasSuffix(c.ContentType, "/") {
+		policy = append(policy, []string{"starts-with", "$Content-Type", c.ContentType})
+	} else if c.ContentType != "" {
+		policy = append(policy, map[string]string{"Content-Type": c.ContentType})
+	}
+
+	if c.MaxSize > 0 {
+		if c.MinSize > c.MaxSize {
+			return nil, errors.New("the minimum size is more than the maximum size")
+		}
+		policy = append(policy, []interface{}{"content-length-range", c.MinSize, c.MaxSize})
+	}
+
+	doc, err := json.Marshal(map[string]interface{}{
+		"expiration": now.Add(expiry).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": policy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(doc)
+	fields["policy"] = encoded
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(signingKey(creds.SecretAccessKey, date, region), encoded))
+
+	return &PresignedPost{URL: bucketURL.String(), Fields: fields}, nil
+}
+
+// signingKey derives the AWS Signature Version 4 key for S3 on a given date.
+func signingKey(secret, date, region string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, "s3")
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
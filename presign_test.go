@@ -0,0 +1,123 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+)
+
+func testClient(g *WithT) *s3.S3 {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-2"),
+		Credentials: credentials.NewStaticCredentials("AKIDEXAMPLE", "secret", ""),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return s3.New(sess)
+}
+
+func TestPresignGet(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", testClient(g)).WithKeyPrefix("pre")
+
+	s, err := fs.PresignGet("/a/b.txt", 15*time.Minute)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	u, err := url.Parse(s)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u.Host).To(Equal("mybucket.s3.eu-west-2.amazonaws.com"))
+	g.Expect(u.Path).To(Equal("/pre/a/b.txt"))
+	g.Expect(u.Query().Get("X-Amz-Expires")).To(Equal("900"))
+	g.Expect(u.Query().Get("X-Amz-Signature")).NotTo(BeEmpty())
+}
+
+func TestPresignPut(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", testClient(g)).WithACL(s3.ObjectCannedACLPublicRead).
+		AddMimeTypes(map[string]string{"txt": "text/plain"})
+
+	s, headers, err := fs.PresignPut("/a/b.txt", time.Hour, map[string]string{"x-amz-meta-owner": "me"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	u, err := url.Parse(s)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u.Path).To(Equal("/a/b.txt"))
+	g.Expect(u.Query().Get("X-Amz-Expires")).To(Equal("3600"))
+	g.Expect(u.Query().Get("X-Amz-SignedHeaders")).To(ContainSubstring("content-type"))
+	g.Expect(headers.Get("Content-Type")).To(Equal("text/plain"))
+	g.Expect(headers.Get("Host")).To(BeEmpty())
+}
+
+func TestPresignNeedsClient(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", &s3stub{})
+	_, err := fs.PresignGet("/a/b.txt", time.Hour)
+	g.Expect(errors.Is(err, errNoPresigner)).To(BeTrue())
+}
+
+func TestPresignPost(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", testClient(g)).WithKeyPrefix("pre").WithACL(s3.ObjectCannedACLPrivate)
+
+	post, err := fs.PresignPost("/uploads/", time.Hour, PostConditions{ContentType: "image/", MaxSize: 1 << 20})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(post.URL).To(Equal("https://mybucket.s3.eu-west-2.amazonaws.com/"))
+	g.Expect(post.Fields).To(HaveKeyWithValue("key", "pre/uploads/${filename}"))
+	g.Expect(post.Fields).To(HaveKeyWithValue("acl", "private"))
+	g.Expect(post.Fields).To(HaveKeyWithValue("x-amz-algorithm", "AWS4-HMAC-SHA256"))
+	g.Expect(post.Fields["x-amz-credential"]).To(HavePrefix("AKIDEXAMPLE/"))
+	g.Expect(post.Fields["x-amz-credential"]).To(HaveSuffix("/eu-west-2/s3/aws4_request"))
+	g.Expect(post.Fields["x-amz-signature"]).To(MatchRegexp("^[0-9a-f]{64}$"))
+
+	doc, err := base64.StdEncoding.DecodeString(post.Fields["policy"])
+	g.Expect(err).NotTo(HaveOccurred())
+	var policy struct {
+		Expiration string
+		Conditions []interface{}
+	}
+	g.Expect(json.Unmarshal(doc, &policy)).To(Succeed())
+	g.Expect(policy.Conditions).To(ContainElement(map[string]interface{}{"bucket": "mybucket"}))
+	g.Expect(policy.Conditions).To(ContainElement([]interface{}{"starts-with", "$key", "pre/uploads/"}))
+	g.Expect(policy.Conditions).To(ContainElement([]interface{}{"starts-with", "$Content-Type", "image/"}))
+	g.Expect(policy.Conditions).To(ContainElement([]interface{}{"content-length-range", 0.0, float64(1 << 20)}))
+	g.Expect(policy.Conditions).To(ContainElement(map[string]interface{}{"acl": "private"}))
+
+	post, err = fs.PresignPost("/a/b.png", time.Hour, PostConditions{ContentType: "image/png"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(post.Fields).To(HaveKeyWithValue("key", "pre/a/b.png"))
+}
+
+func TestURL(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	client := testClient(g)
+	fs := NewFs("mybucket", client).WithKeyPrefix("pre")
+
+	u, err := fs.URL("/a/b c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u).To(Equal("https://mybucket.s3.eu-west-2.amazonaws.com/pre/a/b%20c.txt"))
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String("https://minio.example.com"),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.AnonymousCredentials,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	u, err = NewFs("mybucket", s3.New(sess)).URL("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(u).To(Equal("https://minio.example.com/mybucket/a/b.txt"))
+}
@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"io"
+)
+
+// ProgressFunc is called repeatedly while a file is uploaded or downloaded,
+// with the number of bytes transferred so far and the total size. The total
+// is -1 if it is not known.
+//
+// For uploads, the AWS SDK may read the data more than once, e.g. to sign the
+// request and again to send it, or when a request is retried. The number of
+// bytes transferred then starts again from zero, or from the end of the
+// previous part of a multipart upload. The total size of a multipart upload
+// is not known until its last part.
+type ProgressFunc func(name string, transferred, total int64)
+
+// progressReader calls a ProgressFunc as data is read from a download.
+type progressReader struct {
+	io.ReadCloser
+	name        string
+	transferred int64
+	total       int64
+	progress    ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.progress(r.name, r.transferred, r.total)
+	}
+	return n, err
+}
+
+// progressReadSeeker calls a ProgressFunc as data is read for an upload.
+type progressReadSeeker struct {
+	io.ReadSeeker
+	name     string
+	base     int64 // the number of bytes sent in earlier parts of a multipart upload
+	position int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (r *progressReadSeeker) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	if n > 0 {
+		r.position += int64(n)
+		r.progress(r.name, r.base+r.position, r.total)
+	}
+	return n, err
+}
+
+func (r *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		r.position = pos
+	}
+	return pos, err
+}
@@ -0,0 +1,272 @@
+package s3
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// QuotaFs is a file system that limits the total size of the files below a
+// directory of another file system, e.g. the directory of one tenant of a
+// multi-tenant storage service. Writes that would exceed the limit fail
+// with ErrQuotaExceeded, wrapped in an *os.PathError, and the file is left
+// unchanged. Files outside the directory are not limited.
+//
+// The usage is found using Fs.Usage when the QuotaFs is created and is then
+// tracked as files are written, removed and renamed through the QuotaFs.
+// Changes made by other clients are not seen until Recount is used.
+type QuotaFs struct {
+	source *Fs
+	prefix string
+	limit  int64
+
+	mu   sync.Mutex
+	used int64
+}
+
+var _ afero.Fs = (*QuotaFs)(nil)
+
+// NewQuotaFs creates a file system that limits the total size of the files
+// below a directory to a given number of bytes. The usage is found by
+// listing the directory.
+func NewQuotaFs(source *Fs, prefix string, limit int64) (*QuotaFs, error) {
+	qfs := &QuotaFs{source: source, prefix: prefix, limit: limit}
+	if err := qfs.Recount(); err != nil {
+		return nil, err
+	}
+	return qfs, nil
+}
+
+// Recount finds the usage again by listing the directory, e.g. to allow for
+// files written by other clients.
+func (qfs *QuotaFs) Recount() error {
+	usage, err := qfs.source.Usage(qfs.prefix)
+	if err != nil {
+		return err
+	}
+	qfs.mu.Lock()
+	qfs.used = usage.Bytes
+	qfs.mu.Unlock()
+	return nil
+}
+
+// Used returns the number of bytes used by the files below the directory.
+func (qfs *QuotaFs) Used() int64 {
+	qfs.mu.Lock()
+	defer qfs.mu.Unlock()
+	return qfs.used
+}
+
+// Limit returns the maximum number of bytes allowed below the directory.
+func (qfs *QuotaFs) Limit() int64 { return qfs.limit }
+
+// limited tests whether a file is below the directory.
+func (qfs *QuotaFs) limited(name string) bool {
+	base := addTrailingSlash(qfs.source.key(qfs.prefix))
+	return strings.HasPrefix(qfs.source.key(name), base)
+}
+
+// allows tests whether the usage can change by delta bytes.
+func (qfs *QuotaFs) allows(delta int64) bool {
+	qfs.mu.Lock()
+	defer qfs.mu.Unlock()
+	return delta <= 0 || qfs.used+delta <= qfs.limit
+}
+
+// reserve changes the usage by delta bytes, if allowed.
+func (qfs *QuotaFs) reserve(delta int64) bool {
+	qfs.mu.Lock()
+	defer qfs.mu.Unlock()
+	if delta > 0 && qfs.used+delta > qfs.limit {
+		return false
+	}
+	qfs.used += delta
+	return true
+}
+
+func (qfs *QuotaFs) release(delta int64) {
+	qfs.reserve(-delta)
+}
+
+// sizeOf gets the size of a file that is below the directory, or zero.
+func (qfs *QuotaFs) sizeOf(name string) int64 {
+	if !qfs.limited(name) {
+		return 0
+	}
+	fi, err := qfs.source.lstat(name)
+	if err != nil || fi.IsDir() {
+		return 0
+	}
+	return fi.Size()
+}
+
+// Name returns the name of the source file system.
+func (qfs *QuotaFs) Name() string { return "Quota/" + qfs.source.Name() }
+
+// Create a file.
+func (qfs *QuotaFs) Create(name string) (afero.File, error) {
+	return qfs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir makes a directory.
+func (qfs *QuotaFs) Mkdir(name string, perm os.FileMode) error {
+	return qfs.source.Mkdir(name, perm)
+}
+
+// MkdirAll creates a directory and all parent directories if necessary.
+func (qfs *QuotaFs) MkdirAll(path string, perm os.FileMode) error {
+	return qfs.source.MkdirAll(path, perm)
+}
+
+// Open a file for reading.
+func (qfs *QuotaFs) Open(name string) (afero.File, error) {
+	return qfs.source.Open(name)
+}
+
+// OpenFile opens a file. Files below the directory that are opened for
+// writing count towards the quota when they are closed; each write fails
+// if the file would then exceed it.
+func (qfs *QuotaFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	const writing = os.O_WRONLY | os.O_RDWR | os.O_CREATE
+	if flag&writing == 0 || !qfs.limited(name) {
+		return qfs.source.OpenFile(name, flag, perm)
+	}
+
+	previous := qfs.sizeOf(name)
+	file, err := qfs.source.OpenFile(name, flag, perm)
+	if err != nil {
+		return file, err
+	}
+	return &quotaFile{File: file.(*File), qfs: qfs, previous: previous}, nil
+}
+
+// Remove a file.
+func (qfs *QuotaFs) Remove(name string) error {
+	size := qfs.sizeOf(name)
+	if err := qfs.source.Remove(name); err != nil {
+		return err
+	}
+	qfs.release(size)
+	return nil
+}
+
+// RemoveAll removes a path and any children it contains.
+func (qfs *QuotaFs) RemoveAll(path string) error {
+	var size int64
+	if qfs.limited(path) || qfs.limited(addTrailingSlash(path)) {
+		usage, err := qfs.source.Usage(path)
+		if err != nil {
+			return err
+		}
+		size = usage.Bytes + qfs.sizeOf(path)
+	} else if strings.HasPrefix(qfs.source.key(qfs.prefix), addTrailingSlash(qfs.source.key(path))) {
+		// the whole directory is being removed
+		size = qfs.Used()
+	}
+
+	if err := qfs.source.RemoveAll(path); err != nil {
+		return err
+	}
+	qfs.release(size)
+	return nil
+}
+
+// Rename a file. Moving a file into the directory fails if it would exceed
+// the quota.
+func (qfs *QuotaFs) Rename(oldname, newname string) error {
+	var delta int64
+	if qfs.limited(oldname) || qfs.limited(newname) {
+		size, err := qfs.source.lstat(oldname)
+		if err != nil {
+			return err
+		}
+		if qfs.limited(newname) {
+			delta += size.Size() - qfs.sizeOf(newname)
+		}
+		if qfs.limited(oldname) {
+			delta -= size.Size()
+		}
+	}
+
+	if !qfs.reserve(delta) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: ErrQuotaExceeded}
+	}
+	if err := qfs.source.Rename(oldname, newname); err != nil {
+		qfs.release(delta)
+		return err
+	}
+	return nil
+}
+
+// Stat returns a FileInfo describing the named file.
+func (qfs *QuotaFs) Stat(name string) (os.FileInfo, error) {
+	return qfs.source.Stat(name)
+}
+
+// Chmod changes the mode of a file.
+func (qfs *QuotaFs) Chmod(name string, mode os.FileMode) error {
+	return qfs.source.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of a file.
+func (qfs *QuotaFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return qfs.source.Chtimes(name, atime, mtime)
+}
+
+// quotaFile checks the quota as a file is written.
+type quotaFile struct {
+	*File
+	qfs      *QuotaFs
+	previous int64 // the size of the file before it was opened
+}
+
+// check tests whether n more bytes can be written.
+func (f *quotaFile) check(n int) error {
+	size := int64(n) + f.bytesWritten()
+	if !f.qfs.allows(size - f.previous) {
+		return pathError("write", f.Name(), ErrQuotaExceeded)
+	}
+	return nil
+}
+
+func (f *quotaFile) Write(p []byte) (int, error) {
+	if err := f.check(len(p)); err != nil {
+		return 0, err
+	}
+	return f.File.Write(p)
+}
+
+func (f *quotaFile) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.check(len(p)); err != nil {
+		return 0, err
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func (f *quotaFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// Close closes the file, which is written if the quota allows. Otherwise,
+// whatever was written is discarded.
+func (f *quotaFile) Close() error {
+	if f.writeBuf == nil {
+		return f.File.Close()
+	}
+
+	delta := f.bytesWritten() - f.previous
+	if !f.qfs.reserve(delta) {
+		f.discardWrites()
+		f.File.Close()
+		return pathError("close", f.Name(), ErrQuotaExceeded)
+	}
+
+	if err := f.File.Close(); err != nil {
+		f.qfs.release(delta)
+		return err
+	}
+	return nil
+}
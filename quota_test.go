@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestQuotaFs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	source := NewFs("mybucket", s3fake.New())
+	g.Expect(afero.WriteFile(source, "/t1/a.txt", []byte(strings.Repeat("a", 40)), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(source, "/t2/b.txt", []byte(strings.Repeat("b", 500)), 0644)).To(Succeed())
+
+	fs, err := NewQuotaFs(source, "/t1", 100)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fs.Used()).To(BeEquivalentTo(40))
+
+	// within the quota
+	g.Expect(afero.WriteFile(fs, "/t1/c.txt", []byte(strings.Repeat("c", 50)), 0644)).To(Succeed())
+	g.Expect(fs.Used()).To(BeEquivalentTo(90))
+
+	// a write that would exceed the quota fails
+	err = afero.WriteFile(fs, "/t1/d.txt", []byte(strings.Repeat("d", 20)), 0644)
+	g.Expect(errors.Is(err, ErrQuotaExceeded)).To(BeTrue())
+	g.Expect(fs.Used()).To(BeEquivalentTo(90))
+
+	// replacing a file only counts the difference
+	g.Expect(afero.WriteFile(fs, "/t1/a.txt", []byte(strings.Repeat("a", 50)), 0644)).To(Succeed())
+	g.Expect(fs.Used()).To(BeEquivalentTo(100))
+
+	// other directories are not limited
+	g.Expect(afero.WriteFile(fs, "/t2/e.txt", []byte(strings.Repeat("e", 200)), 0644)).To(Succeed())
+
+	// moving a file in counts against the quota
+	err = fs.Rename("/t2/e.txt", "/t1/e.txt")
+	g.Expect(errors.Is(err, ErrQuotaExceeded)).To(BeTrue())
+
+	g.Expect(fs.Remove("/t1/c.txt")).To(Succeed())
+	g.Expect(fs.Used()).To(BeEquivalentTo(50))
+
+	g.Expect(fs.Rename("/t1/a.txt", "/t2/a.txt")).To(Succeed())
+	g.Expect(fs.Used()).To(BeEquivalentTo(0))
+
+	g.Expect(afero.WriteFile(source, "/t1/f.txt", []byte("ffff"), 0644)).To(Succeed())
+	g.Expect(fs.Recount()).To(Succeed())
+	g.Expect(fs.Used()).To(BeEquivalentTo(4))
+
+	g.Expect(fs.RemoveAll("/t1")).To(Succeed())
+	g.Expect(fs.Used()).To(BeEquivalentTo(0))
+}
+
+func TestQuotaFsWithMultipartUpload(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.MinPartSize = 4
+	source := NewFs("mybucket", mem).WithMultipartUpload(4)
+	fs, err := NewQuotaFs(source, "/t1", 30)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the parts already uploaded count towards the quota
+	f, err := fs.Create("/t1/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	for i := 0; i < 3; i++ {
+		_, err = f.WriteString(strings.Repeat("a", 10))
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	_, err = f.WriteString(strings.Repeat("a", 10))
+	g.Expect(errors.Is(err, ErrQuotaExceeded)).To(BeTrue())
+	g.Expect(f.Close()).To(Succeed())
+	g.Expect(fs.Used()).To(BeEquivalentTo(30))
+
+	fi, err := source.Stat("/t1/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Size()).To(BeEquivalentTo(30))
+
+	// a file that exceeds the quota when it is closed is discarded
+	g.Expect(fs.Remove("/t1/a.txt")).To(Succeed())
+	b, err := fs.Create("/t1/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	c, err := fs.Create("/t1/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, f := range []afero.File{b, c} {
+		_, err = f.WriteString(strings.Repeat("b", 20))
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(b.Close()).To(Succeed())
+	err = c.Close()
+	g.Expect(errors.Is(err, ErrQuotaExceeded)).To(BeTrue())
+	g.Expect(fs.Used()).To(BeEquivalentTo(20))
+
+	_, err = source.Stat("/t1/c.txt")
+	g.Expect(err).To(HaveOccurred())
+	uploads, err := source.ListMultipartUploads("/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(uploads).To(BeEmpty())
+}
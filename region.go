@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+var errNoRegionDetection = errors.New("the S3 client cannot be rebuilt for another region")
+
+// WithDetectedRegion finds the region of the bucket and, if it differs from
+// the region of the S3 client given to NewFs, returns a new instance of the
+// file system using a client for the bucket's region. Otherwise, every request
+// would fail with a redirect. The new client has the same configuration as
+// the old one, including its credentials and endpoint, apart from the region.
+//
+// This sends one HEAD request, which needs no permissions because the region
+// is taken from the response headers, so it is best done once at startup. The
+// S3 client given to NewFs must be an *s3.S3.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) WithDetectedRegion(ctx context.Context) (*Fs, error) {
+	client, ok := fs.client.(*s3.S3)
+	if !ok {
+		return nil, errNoRegionDetection
+	}
+
+	ctx, start := fs.beginWithContext(ctx, "DetectRegion", "/")
+	region, err := s3manager.GetBucketRegionWithClient(ctx, client, fs.bucket)
+	if err != nil {
+		err = pathError("region", "/", err)
+		fs.logOp("DetectRegion", "/", start, err)
+		return nil, err
+	}
+
+	fs.logOp("DetectRegion", "/", start, nil, "region", region, "configured", aws.StringValue(client.Config.Region))
+	if region == aws.StringValue(client.Config.Region) {
+		return &fs, nil
+	}
+
+	sess, err := session.NewSession(client.Config.Copy(&aws.Config{Region: aws.String(region)}))
+	if err != nil {
+		return nil, err
+	}
+	fs.client = s3.New(sess)
+	fs.s3API = fs.layeredAPI()
+	return &fs, nil
+}
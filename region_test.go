@@ -0,0 +1,45 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+)
+
+func TestWithDetectedRegion(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amz-Bucket-Region", "eu-west-2")
+		w.WriteHeader(http.StatusMovedPermanently)
+	}))
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("AKIDEXAMPLE", "secret", ""),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	fs, err := NewFs("mybucket", s3.New(sess)).WithDetectedRegion(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	client := fs.client.(*s3.S3)
+	g.Expect(aws.StringValue(client.Config.Region)).To(Equal("eu-west-2"))
+	g.Expect(aws.StringValue(client.Config.Endpoint)).To(Equal(server.URL))
+	g.Expect(client.Config.Credentials).To(BeIdenticalTo(s3.New(sess).Config.Credentials))
+
+	// other clients cannot be rebuilt
+	_, err = NewFs("mybucket", s3fake.New()).WithDetectedRegion(context.Background())
+	g.Expect(err).To(Equal(errNoRegionDetection))
+}
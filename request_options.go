@@ -0,0 +1,124 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WithRequestOptions adds options that are applied to every S3 request, in a
+// new instance of the file system. These can, for example, add headers (see
+// request.WithSetRequestHeaders), change the signing, or add handlers to
+// observe or alter the requests. They are applied before any options given
+// for the operation, using ContextWithRequestOptions.
+func (fs Fs) WithRequestOptions(opts ...request.Option) *Fs {
+	fs.requestOptions = append(fs.requestOptions[:len(fs.requestOptions):len(fs.requestOptions)], opts...)
+	fs.s3API = fs.layeredAPI()
+	return &fs
+}
+
+type requestOptionsKey struct{}
+
+// ContextWithRequestOptions adds options for the S3 requests made using a
+// context, e.g. a file system made by WithContext, or an operation given a
+// context of its own, such as Ping. They are applied after any set using
+// WithRequestOptions.
+func ContextWithRequestOptions(ctx context.Context, opts ...request.Option) context.Context {
+	existing := requestOptionsFrom(ctx)
+	return context.WithValue(ctx, requestOptionsKey{}, append(existing[:len(existing):len(existing)], opts...))
+}
+
+func requestOptionsFrom(ctx context.Context) []request.Option {
+	opts, _ := ctx.Value(requestOptionsKey{}).([]request.Option)
+	return opts
+}
+
+// optionsAPI adds the options for the file system and the context to the
+// requests made using the S3 API it wraps.
+type optionsAPI struct {
+	S3APISubset
+	opts []request.Option
+}
+
+// with gets the options for a request: those of the file system, then those
+// of the context, then those given.
+func (o *optionsAPI) with(ctx aws.Context, opts []request.Option) []request.Option {
+	fromContext := requestOptionsFrom(ctx)
+	if len(o.opts) == 0 && len(fromContext) == 0 {
+		return opts
+	}
+	all := make([]request.Option, 0, len(o.opts)+len(fromContext)+len(opts))
+	all = append(all, o.opts...)
+	all = append(all, fromContext...)
+	return append(all, opts...)
+}
+
+func (o *optionsAPI) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	return o.S3APISubset.AbortMultipartUploadWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	return o.S3APISubset.CompleteMultipartUploadWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	return o.S3APISubset.CopyObjectWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) CreateBucketWithContext(ctx aws.Context, input *s3.CreateBucketInput, opts ...request.Option) (*s3.CreateBucketOutput, error) {
+	return o.S3APISubset.CreateBucketWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	return o.S3APISubset.CreateMultipartUploadWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	return o.S3APISubset.DeleteObjectWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	return o.S3APISubset.GetObjectWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) GetObjectAttributesWithContext(ctx aws.Context, input *s3.GetObjectAttributesInput, opts ...request.Option) (*s3.GetObjectAttributesOutput, error) {
+	return o.S3APISubset.GetObjectAttributesWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	return o.S3APISubset.HeadObjectWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) ListMultipartUploadsWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, opts ...request.Option) (*s3.ListMultipartUploadsOutput, error) {
+	return o.S3APISubset.ListMultipartUploadsWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) ListObjectVersionsWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, opts ...request.Option) (*s3.ListObjectVersionsOutput, error) {
+	return o.S3APISubset.ListObjectVersionsWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	return o.S3APISubset.ListObjectsV2WithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) ListPartsWithContext(ctx aws.Context, input *s3.ListPartsInput, opts ...request.Option) (*s3.ListPartsOutput, error) {
+	return o.S3APISubset.ListPartsWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	return o.S3APISubset.PutObjectWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) SelectObjectContentWithContext(ctx aws.Context, input *s3.SelectObjectContentInput, opts ...request.Option) (*s3.SelectObjectContentOutput, error) {
+	return o.S3APISubset.SelectObjectContentWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	return o.S3APISubset.UploadPartWithContext(ctx, input, o.with(ctx, opts)...)
+}
+
+func (o *optionsAPI) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	return o.S3APISubset.UploadPartCopyWithContext(ctx, input, o.with(ctx, opts)...)
+}
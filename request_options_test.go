@@ -0,0 +1,57 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+// headerBucket records the headers that the request options of each
+// HeadObject request would set.
+type headerBucket struct {
+	*s3fake.Bucket
+	headers []http.Header
+}
+
+func (b *headerBucket) HeadObjectWithContext(ctx aws.Context, req *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	r := &request.Request{HTTPRequest: &http.Request{Header: http.Header{}}}
+	r.ApplyOptions(opts...)
+	b.headers = append(b.headers, r.HTTPRequest.Header)
+	return b.Bucket.HeadObjectWithContext(ctx, req, opts...)
+}
+
+func TestWithRequestOptions(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &headerBucket{Bucket: s3fake.New()}
+	fs := NewFs("mybucket", mem).WithRequestOptions(request.WithSetRequestHeaders(map[string]string{"X-Tenant": "acme"}))
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("a"), 0644)).To(Succeed())
+
+	_, err := fs.Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	ctx := ContextWithRequestOptions(context.Background(), request.WithSetRequestHeaders(map[string]string{"X-Trace": "123"}))
+	_, err = fs.WithContext(ctx).Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the options for the context follow those for the file system
+	ctx = ContextWithRequestOptions(ctx, request.WithSetRequestHeaders(map[string]string{"X-Tenant": "other"}))
+	_, err = fs.WithContext(ctx).Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = NewFs("mybucket", mem).Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(mem.headers).To(HaveLen(4))
+	g.Expect(mem.headers[0]).To(Equal(http.Header{"X-Tenant": {"acme"}}))
+	g.Expect(mem.headers[1]).To(Equal(http.Header{"X-Tenant": {"acme"}, "X-Trace": {"123"}}))
+	g.Expect(mem.headers[2]).To(Equal(http.Header{"X-Tenant": {"other"}, "X-Trace": {"123"}}))
+	g.Expect(mem.headers[3]).To(BeEmpty())
+}
@@ -0,0 +1,288 @@
+package s3
+
+import (
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// RetryPolicy controls how failed S3 requests are retried. This is done by
+// the Fs, independently of any retryer configured in the AWS client.
+//
+// Requests are retried when S3 is throttling them (503 SlowDown), when it
+// has an internal error (500 and similar) and when the connection fails,
+// e.g. because it was reset. The delay before each retry doubles each time,
+// starting from BaseDelay (or ThrottleDelay when throttled) and limited by
+// MaxDelay. Other errors are returned immediately.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt. Zero disables retrying.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry.
+	BaseDelay time.Duration
+	// ThrottleDelay is the delay before the first retry when S3 is throttling
+	// requests. This is usually longer than BaseDelay so that the request rate
+	// drops enough for S3 to recover. If zero, BaseDelay is used.
+	ThrottleDelay time.Duration
+	// MaxDelay is the upper limit for any delay. If zero, there is no limit.
+	MaxDelay time.Duration
+	// Jitter randomises each delay to between half and all of its value, so
+	// that many clients don't retry in lock-step.
+	Jitter bool
+}
+
+// DefaultRetryPolicy is a reasonable policy for most uses.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:    4,
+	BaseDelay:     100 * time.Millisecond,
+	ThrottleDelay: 500 * time.Millisecond,
+	MaxDelay:      20 * time.Second,
+	Jitter:        true,
+}
+
+// delay gets the delay before a retry; attempt is zero for the first retry.
+func (p RetryPolicy) delay(attempt int, throttled bool) time.Duration {
+	d := p.BaseDelay
+	if throttled && p.ThrottleDelay > 0 {
+		d = p.ThrottleDelay
+	}
+
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter && d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// isThrottled tests whether an error from S3 means that requests are being throttled.
+func isThrottled(err error) bool {
+	if ae, ok := err.(awserr.Error); ok && ae.Code() == "SlowDown" {
+		return true
+	}
+	if re, ok := err.(awserr.RequestFailure); ok && (re.StatusCode() == 429 || re.StatusCode() == 503) {
+		return true
+	}
+	return request.IsErrorThrottle(err)
+}
+
+// isRetryable tests whether a failed request might succeed if it is repeated.
+func isRetryable(err error) bool {
+	if isThrottled(err) {
+		return true
+	}
+	if ae, ok := err.(awserr.Error); ok && ae.Code() == "InternalError" {
+		return true
+	}
+	if re, ok := err.(awserr.RequestFailure); ok && re.StatusCode() >= 500 && re.StatusCode() != 501 {
+		return true
+	}
+	// this covers connection resets and timeouts
+	return request.IsErrorRetryable(err)
+}
+
+// retryingAPI retries the failed requests of the S3 API it wraps.
+type retryingAPI struct {
+	S3APISubset
+	policy RetryPolicy
+	logger Logger
+}
+
+func (r *retryingAPI) retry(ctx aws.Context, op string, do func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := do()
+		if err == nil || attempt >= r.policy.MaxRetries || !isRetryable(err) {
+			return err
+		}
+
+		delay := r.policy.delay(attempt, isThrottled(err))
+		lgr("%s retry %d after %v > %+v\n", op, attempt+1, delay, err)
+		logTo(r.logger, LevelWarn, "retry", "op", op, "attempt", attempt+1, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// rewind returns a function that puts a request body back where it started,
+// so that it can be sent again.
+func rewind(body io.ReadSeeker) func() error {
+	if body == nil {
+		return func() error { return nil }
+	}
+	start, err := body.Seek(0, io.SeekCurrent)
+	return func() error {
+		if err != nil {
+			return err
+		}
+		_, e2 := body.Seek(start, io.SeekStart)
+		return e2
+	}
+}
+
+func (r *retryingAPI) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (output *s3.AbortMultipartUploadOutput, err error) {
+	err = r.retry(ctx, "AbortMultipartUpload", func() (e error) {
+		output, e = r.S3APISubset.AbortMultipartUploadWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (output *s3.CompleteMultipartUploadOutput, err error) {
+	err = r.retry(ctx, "CompleteMultipartUpload", func() (e error) {
+		output, e = r.S3APISubset.CompleteMultipartUploadWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (output *s3.CopyObjectOutput, err error) {
+	err = r.retry(ctx, "CopyObject", func() (e error) {
+		output, e = r.S3APISubset.CopyObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) CreateBucketWithContext(ctx aws.Context, input *s3.CreateBucketInput, opts ...request.Option) (output *s3.CreateBucketOutput, err error) {
+	err = r.retry(ctx, "CreateBucket", func() (e error) {
+		output, e = r.S3APISubset.CreateBucketWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (output *s3.CreateMultipartUploadOutput, err error) {
+	err = r.retry(ctx, "CreateMultipartUpload", func() (e error) {
+		output, e = r.S3APISubset.CreateMultipartUploadWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (output *s3.DeleteObjectOutput, err error) {
+	err = r.retry(ctx, "DeleteObject", func() (e error) {
+		output, e = r.S3APISubset.DeleteObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (output *s3.GetObjectOutput, err error) {
+	err = r.retry(ctx, "GetObject", func() (e error) {
+		output, e = r.S3APISubset.GetObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) GetObjectAttributesWithContext(ctx aws.Context, input *s3.GetObjectAttributesInput, opts ...request.Option) (output *s3.GetObjectAttributesOutput, err error) {
+	err = r.retry(ctx, "GetObjectAttributes", func() (e error) {
+		output, e = r.S3APISubset.GetObjectAttributesWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (output *s3.HeadObjectOutput, err error) {
+	err = r.retry(ctx, "HeadObject", func() (e error) {
+		output, e = r.S3APISubset.HeadObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) ListMultipartUploadsWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, opts ...request.Option) (output *s3.ListMultipartUploadsOutput, err error) {
+	err = r.retry(ctx, "ListMultipartUploads", func() (e error) {
+		output, e = r.S3APISubset.ListMultipartUploadsWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (output *s3.ListObjectsV2Output, err error) {
+	err = r.retry(ctx, "ListObjectsV2", func() (e error) {
+		output, e = r.S3APISubset.ListObjectsV2WithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) ListObjectVersionsWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, opts ...request.Option) (output *s3.ListObjectVersionsOutput, err error) {
+	err = r.retry(ctx, "ListObjectVersions", func() (e error) {
+		output, e = r.S3APISubset.ListObjectVersionsWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) ListPartsWithContext(ctx aws.Context, input *s3.ListPartsInput, opts ...request.Option) (output *s3.ListPartsOutput, err error) {
+	err = r.retry(ctx, "ListParts", func() (e error) {
+		output, e = r.S3APISubset.ListPartsWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (output *s3.PutObjectOutput, err error) {
+	reset := rewind(input.Body)
+	first := true
+	err = r.retry(ctx, "PutObject", func() (e error) {
+		if !first {
+			if e = reset(); e != nil {
+				return e
+			}
+		}
+		first = false
+		output, e = r.S3APISubset.PutObjectWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) SelectObjectContentWithContext(ctx aws.Context, input *s3.SelectObjectContentInput, opts ...request.Option) (output *s3.SelectObjectContentOutput, err error) {
+	err = r.retry(ctx, "SelectObjectContent", func() (e error) {
+		output, e = r.S3APISubset.SelectObjectContentWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (output *s3.UploadPartOutput, err error) {
+	reset := rewind(input.Body)
+	first := true
+	err = r.retry(ctx, "UploadPart", func() (e error) {
+		if !first {
+			if e = reset(); e != nil {
+				return e
+			}
+		}
+		first = false
+		output, e = r.S3APISubset.UploadPartWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
+
+func (r *retryingAPI) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (output *s3.UploadPartCopyOutput, err error) {
+	err = r.retry(ctx, "UploadPartCopy", func() (e error) {
+		output, e = r.S3APISubset.UploadPartCopyWithContext(ctx, input, opts...)
+		return e
+	})
+	return output, err
+}
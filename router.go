@@ -0,0 +1,334 @@
+package s3
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/afero/mem"
+)
+
+// RouterFs is a file system that presents other file systems, typically Fs
+// instances for different buckets, as a single tree. Each is mounted at a
+// directory, so that, for example, "/images/a.jpg" is "/a.jpg" in the file
+// system mounted at "/images". Names that are not below any mount point go
+// to the root file system, if there is one.
+//
+// Listing a directory that contains mount points includes them, so that
+// "/" lists "images" and "backups" as well as the contents of the root file
+// system. Directories on the way to a mount point always exist.
+//
+// Files cannot be renamed between file systems; this fails with an error
+// that matches syscall.EXDEV, as for os.Rename. Removing a directory with
+// RemoveAll does not remove the mount points below it.
+type RouterFs struct {
+	root   afero.Fs
+	mounts []mountPoint // longest first
+}
+
+type mountPoint struct {
+	dir string // clean, with a leading slash
+	fs  afero.Fs
+}
+
+var _ afero.Fs = (*RouterFs)(nil)
+
+// NewRouterFs creates a file system that routes names below the mount points
+// to other file systems, and other names to the root file system, which may
+// be nil.
+func NewRouterFs(root afero.Fs) *RouterFs {
+	return &RouterFs{root: root}
+}
+
+// Mount adds a file system at a directory, replacing any already there. It
+// returns the RouterFs, so that calls can be chained. It should not be used
+// concurrently with other methods.
+func (rfs *RouterFs) Mount(dir string, fs afero.Fs) *RouterFs {
+	dir = path.Clean(PathSeparator + dir)
+	for i, m := range rfs.mounts {
+		if m.dir == dir {
+			rfs.mounts[i].fs = fs
+			return rfs
+		}
+	}
+	rfs.mounts = append(rfs.mounts, mountPoint{dir: dir, fs: fs})
+	sort.SliceStable(rfs.mounts, func(i, j int) bool { return len(rfs.mounts[i].dir) > len(rfs.mounts[j].dir) })
+	return rfs
+}
+
+// route finds the file system for a name, the name within it and its mount
+// point, which is blank for the root file system. The file system is nil if
+// there is no root file system and the name is not below a mount point.
+func (rfs *RouterFs) route(name string) (afero.Fs, string, string) {
+	name = path.Clean(PathSeparator + name)
+	for _, m := range rfs.mounts {
+		if name == m.dir {
+			return m.fs, PathSeparator, m.dir
+		}
+		if m.dir == PathSeparator {
+			return m.fs, name, m.dir
+		}
+		if strings.HasPrefix(name, m.dir+PathSeparator) {
+			return m.fs, name[len(m.dir):], m.dir
+		}
+	}
+	return rfs.root, name, ""
+}
+
+// mountsIn lists the names of the entries of a directory that lead to mount
+// points below it, i.e. the mount points themselves or their ancestors.
+func (rfs *RouterFs) mountsIn(dir string) []string {
+	base := addTrailingSlash(path.Clean(PathSeparator + dir))
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range rfs.mounts {
+		if !strings.HasPrefix(m.dir, base) || m.dir == PathSeparator {
+			continue
+		}
+		name := strings.TrimPrefix(m.dir, base)
+		if i := strings.Index(name, PathSeparator); i >= 0 {
+			name = name[:i]
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isMountDir tests whether a name is a mount point or leads to one.
+func (rfs *RouterFs) isMountDir(name string) bool {
+	name = path.Clean(PathSeparator + name)
+	for _, m := range rfs.mounts {
+		if m.dir == name || strings.HasPrefix(m.dir, addTrailingSlash(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMountPoint tests whether a name is a mount point.
+func (rfs *RouterFs) isMountPoint(name string) bool {
+	_, _, mount := rfs.route(name)
+	return mount == path.Clean(PathSeparator+name)
+}
+
+// routed applies an operation to the file system for a name, giving it the
+// name within that file system. Path errors report the name as given.
+func (rfs *RouterFs) routed(op, name string, do func(fs afero.Fs, name string) error) error {
+	fs, inner, _ := rfs.route(name)
+	if fs == nil {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return renamePathError(do(fs, inner), name)
+}
+
+// renamePathError replaces the name in a path error.
+func renamePathError(err error, name string) error {
+	if pe, ok := err.(*os.PathError); ok {
+		return &os.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
+
+// Name returns the name of the file system.
+func (rfs *RouterFs) Name() string { return "Router" }
+
+// Create a file.
+func (rfs *RouterFs) Create(name string) (afero.File, error) {
+	return rfs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir makes a directory.
+func (rfs *RouterFs) Mkdir(name string, perm os.FileMode) error {
+	return rfs.routed("mkdir", name, func(fs afero.Fs, name string) error {
+		return fs.Mkdir(name, perm)
+	})
+}
+
+// MkdirAll creates a directory and all parent directories if necessary.
+func (rfs *RouterFs) MkdirAll(path string, perm os.FileMode) error {
+	return rfs.routed("mkdirall", path, func(fs afero.Fs, name string) error {
+		return fs.MkdirAll(name, perm)
+	})
+}
+
+// Open a file for reading. Directories can be opened for listing.
+func (rfs *RouterFs) Open(name string) (afero.File, error) {
+	return rfs.open(name, os.O_RDONLY, func(fs afero.Fs, name string) (afero.File, error) {
+		return fs.Open(name)
+	})
+}
+
+// OpenFile opens a file using the given flags and the given mode.
+func (rfs *RouterFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return rfs.open(name, flag, func(fs afero.Fs, name string) (afero.File, error) {
+		return fs.OpenFile(name, flag, perm)
+	})
+}
+
+func (rfs *RouterFs) open(name string, flag int, open func(fs afero.Fs, name string) (afero.File, error)) (afero.File, error) {
+	var file afero.File
+	err := rfs.routed("open", name, func(fs afero.Fs, name string) (err error) {
+		file, err = open(fs, name)
+		return err
+	})
+
+	if err != nil {
+		if os.IsNotExist(err) && flag&(os.O_WRONLY|os.O_RDWR) == 0 && rfs.isMountDir(name) {
+			// the directory leads to a mount point but doesn't exist in its own right
+			dir := mem.NewFileHandle(mem.CreateDir(name))
+			return &routerFile{File: dir, name: name, rfs: rfs, synthetic: true}, nil
+		}
+		return nil, err
+	}
+	return &routerFile{File: file, name: name, rfs: rfs}, nil
+}
+
+// Remove a file.
+func (rfs *RouterFs) Remove(name string) error {
+	return rfs.routed("remove", name, func(fs afero.Fs, name string) error {
+		return fs.Remove(name)
+	})
+}
+
+// RemoveAll removes a path and any children it contains, apart from any
+// mount points.
+func (rfs *RouterFs) RemoveAll(path string) error {
+	return rfs.routed("removeall", path, func(fs afero.Fs, name string) error {
+		return fs.RemoveAll(name)
+	})
+}
+
+// Rename a file within one of the file systems.
+func (rfs *RouterFs) Rename(oldname, newname string) error {
+	oldFs, oldInner, oldMount := rfs.route(oldname)
+	_, newInner, newMount := rfs.route(newname)
+	if oldFs == nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+	if oldMount != newMount {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EXDEV}
+	}
+	err := oldFs.Rename(oldInner, newInner)
+	if le, ok := err.(*os.LinkError); ok {
+		err = &os.LinkError{Op: le.Op, Old: oldname, New: newname, Err: le.Err}
+	}
+	return err
+}
+
+// Stat returns a FileInfo describing the named file.
+func (rfs *RouterFs) Stat(name string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := rfs.routed("stat", name, func(fs afero.Fs, name string) (err error) {
+		fi, err = fs.Stat(name)
+		return err
+	})
+
+	if os.IsNotExist(err) && rfs.isMountDir(name) || err == nil && fi.IsDir() && rfs.isMountPoint(name) {
+		// the root of the mounted file system has no name of its own
+		return NewDirectoryInfo(path.Clean(PathSeparator + name)), nil
+	}
+	return fi, err
+}
+
+// Chmod changes the mode of a file.
+func (rfs *RouterFs) Chmod(name string, mode os.FileMode) error {
+	return rfs.routed("chmod", name, func(fs afero.Fs, name string) error {
+		return fs.Chmod(name, mode)
+	})
+}
+
+// Chtimes changes the access and modification times of a file.
+func (rfs *RouterFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return rfs.routed("chtimes", name, func(fs afero.Fs, name string) error {
+		return fs.Chtimes(name, atime, mtime)
+	})
+}
+
+// routerFile is a file opened via a RouterFs, which has the name used to
+// open it. The listing of a directory includes the mount points in it.
+type routerFile struct {
+	afero.File
+	name      string
+	rfs       *RouterFs
+	synthetic bool          // the directory exists only because it leads to a mount point
+	entries   []os.FileInfo // the merged listing, once read
+	listed    bool
+}
+
+func (f *routerFile) Name() string { return f.name }
+
+func (f *routerFile) Stat() (os.FileInfo, error) {
+	if f.synthetic {
+		return NewDirectoryInfo(path.Clean(PathSeparator + f.name)), nil
+	}
+	return f.File.Stat()
+}
+
+func (f *routerFile) Readdir(n int) ([]os.FileInfo, error) {
+	mounts := f.rfs.mountsIn(f.name)
+	if len(mounts) == 0 {
+		return f.File.Readdir(n)
+	}
+
+	if !f.listed {
+		if err := f.list(mounts); err != nil {
+			return nil, err
+		}
+	}
+
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+// list merges the listing of the directory with the mount points in it,
+// which take precedence over entries of the same name.
+func (f *routerFile) list(mounts []string) error {
+	listed, err := f.File.Readdir(-1)
+	if err != nil {
+		return renamePathError(err, f.name)
+	}
+
+	dir := addTrailingSlash(path.Clean(PathSeparator + f.name))
+	isMount := make(map[string]bool)
+	for _, name := range mounts {
+		isMount[name] = true
+		f.entries = append(f.entries, NewDirectoryInfo(dir+name))
+	}
+	for _, fi := range listed {
+		if !isMount[fi.Name()] {
+			f.entries = append(f.entries, fi)
+		}
+	}
+	sort.Slice(f.entries, func(i, j int) bool { return f.entries[i].Name() < f.entries[j].Name() })
+	f.listed = true
+	return nil
+}
+
+func (f *routerFile) Readdirnames(n int) ([]string, error) {
+	entries, err := f.Readdir(n)
+	names := make([]string, len(entries))
+	for i, fi := range entries {
+		names[i] = fi.Name()
+	}
+	return names, err
+}
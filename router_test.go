@@ -0,0 +1,85 @@
+package s3
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"syscall"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestRouterFs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	root := afero.NewMemMapFs()
+	images := NewFs("images", s3fake.New())
+	backups := NewFs("backups", s3fake.New())
+	rfs := NewRouterFs(root).Mount("/images", images).Mount("/data/backups", backups)
+
+	g.Expect(afero.WriteFile(rfs, "/readme.txt", []byte("root"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(rfs, "/images/a.jpg", []byte("image"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(rfs, "/data/backups/b.tar", []byte("backup"), 0644)).To(Succeed())
+
+	// each file goes to its own file system
+	g.Expect(afero.Exists(root, "/readme.txt")).To(BeTrue())
+	g.Expect(afero.Exists(images, "/a.jpg")).To(BeTrue())
+	g.Expect(afero.Exists(backups, "/b.tar")).To(BeTrue())
+
+	data, err := afero.ReadFile(rfs, "/images/a.jpg")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("image"))
+
+	// the listings include the mount points
+	g.Expect(readDirNames(g, rfs, "/")).To(Equal([]string{"data", "images", "readme.txt"}))
+	g.Expect(readDirNames(g, rfs, "/data")).To(Equal([]string{"backups"}))
+	g.Expect(readDirNames(g, rfs, "/data/backups")).To(Equal([]string{"b.tar"}))
+
+	fi, err := rfs.Stat("/data")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.IsDir()).To(BeTrue())
+
+	fi, err = rfs.Stat("/images")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.IsDir()).To(BeTrue())
+	g.Expect(fi.Name()).To(Equal("images"))
+
+	var walked []string
+	g.Expect(afero.Walk(rfs, "/", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			walked = append(walked, path)
+		}
+		return err
+	})).To(Succeed())
+	sort.Strings(walked)
+	g.Expect(walked).To(Equal([]string{"/data/backups/b.tar", "/images/a.jpg", "/readme.txt"}))
+
+	// files can be renamed only within a file system
+	g.Expect(rfs.Rename("/images/a.jpg", "/images/c.jpg")).To(Succeed())
+	err = rfs.Rename("/images/c.jpg", "/c.jpg")
+	g.Expect(errors.Is(err, syscall.EXDEV)).To(BeTrue())
+
+	// errors report the names as given
+	_, err = rfs.Open("/images/missing.jpg")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(err.(*os.PathError).Path).To(Equal("/images/missing.jpg"))
+
+	// without a root file system, only the mount points exist
+	rfs = NewRouterFs(nil).Mount("/images", images)
+	g.Expect(readDirNames(g, rfs, "/")).To(Equal([]string{"images"}))
+	_, err = rfs.Create("/readme.txt")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+}
+
+func readDirNames(g *WithT, fs afero.Fs, dir string) []string {
+	f, err := fs.Open(dir)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	g.Expect(err).NotTo(HaveOccurred())
+	sort.Strings(names)
+	return names
+}
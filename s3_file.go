@@ -4,16 +4,28 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
+var (
+	errNegativeOffset = errors.New("negative offset")
+	errWhence         = errors.New("invalid whence")
+)
+
 // File represents a file in S3.
-// It is not safe to share File objects between goroutines.
+// It is not safe to share File objects between goroutines, unless they are
+// wrapped using NewLockedFile.
 type File struct {
 	bucket string
 	name   string
@@ -25,24 +37,41 @@ type File struct {
 	closed     bool
 	readCloser io.ReadCloser
 	writeBuf   *bytes.Buffer
+	multipart  *ResumeToken // the multipart upload in progress, if any
+	etag       string
+	versionId  string
+	info       os.FileInfo // as found by Open, if known
 
 	// readdir state
 	readdirContinuationToken *string
 	readdirNotTruncated      bool
 
-	ctx aws.Context
+	ctx       aws.Context
+	writeOpts writeOptions
+	limiter   *rateLimiter
+	verify    bool
+	opened    time.Time
+	exclusive bool // opened with O_EXCL
+	created   bool // opened with O_CREATE
+
+	// conditions for reading
+	ifNoneMatch     *string
+	ifModifiedSince *time.Time
 }
 
 // NewFile initializes an File object.
 func NewFile(bucket, name string, s3API S3APISubset, s3Fs Fs) *File {
 	return &File{
-		bucket: bucket,
-		name:   name,
-		s3API:  s3API,
-		s3Fs:   s3Fs,
-		offset: 0,
-		closed: false,
-		ctx:    s3Fs.ctx,
+		bucket:    bucket,
+		name:      name,
+		s3API:     s3API,
+		s3Fs:      s3Fs,
+		offset:    0,
+		closed:    false,
+		ctx:       s3Fs.ctx,
+		writeOpts: s3Fs.writeOptionsFor(name),
+		verify:    s3Fs.verifyReads,
+		opened:    time.Now(),
 	}
 }
 
@@ -52,41 +81,194 @@ func (f File) WithContext(ctx aws.Context) *File {
 	return &f
 }
 
+// WithBandwidthLimit limits the rate at which a new instance of the file is
+// uploaded or downloaded, in bytes per second. This applies as well as any
+// limit set by Fs.WithBandwidthLimit. Zero means there is no limit.
+func (f File) WithBandwidthLimit(bytesPerSecond int64) *File {
+	f.limiter = nil
+	if bytesPerSecond > 0 {
+		f.limiter = newRateLimiter(bytesPerSecond)
+	}
+	return &f
+}
+
+// WithVerify sets whether a new instance of the file is verified as it is
+// read, overriding the default set by Fs.WithVerifiedReads.
+func (f File) WithVerify(on bool) *File {
+	f.verify = on
+	return &f
+}
+
+// WithIfNoneMatch makes a new instance of the file that is only read if its
+// ETag differs from the one given, which would usually have been obtained
+// using ETag when the file was read previously. Otherwise, Read fails with
+// ErrNotModified (wrapped in an *os.PathError), without downloading anything.
+// This allows cached copies to be revalidated cheaply. A blank etag removes
+// the condition.
+func (f File) WithIfNoneMatch(etag string) *File {
+	f.ifNoneMatch = optionalString(etag)
+	return &f
+}
+
+// WithIfModifiedSince makes a new instance of the file that is only read if
+// it has been modified since the time given. Otherwise, Read fails with
+// ErrNotModified (wrapped in an *os.PathError). A zero time removes the
+// condition. If WithIfNoneMatch is also used, S3 ignores this condition
+// when the ETag differs.
+func (f File) WithIfModifiedSince(t time.Time) *File {
+	f.ifModifiedSince = nil
+	if !t.IsZero() {
+		f.ifModifiedSince = &t
+	}
+	return &f
+}
+
+// conditional tests whether the file is only to be read if it has changed.
+func (f *File) conditional() bool {
+	return f.ifNoneMatch != nil || f.ifModifiedSince != nil
+}
+
+// limiters gets the rate limiters for transferring the file, if any.
+func (f *File) limiters() []*rateLimiter {
+	if f.limiter == nil && f.s3Fs.limiter == nil {
+		return nil
+	}
+	return []*rateLimiter{f.limiter, f.s3Fs.limiter}
+}
+
+// WithACL sets the canned ACL in a new instance of the file, overriding the
+// default set by Fs.WithACL. It is applied when the file is written on Close.
+func (f File) WithACL(acl string) *File {
+	f.writeOpts.acl = optionalString(acl)
+	return &f
+}
+
+// WithObjectLock sets the Object Lock retention in a new instance of the file,
+// overriding the default set by Fs.WithObjectLock.
+func (f File) WithObjectLock(mode string, retainUntil time.Time) *File {
+	f.writeOpts.setObjectLock(mode, retainUntil)
+	return &f
+}
+
+// WithLegalHold sets the Object Lock legal hold status in a new instance of
+// the file, overriding the default set by Fs.WithLegalHold.
+func (f File) WithLegalHold(on bool) *File {
+	f.writeOpts.setLegalHold(on)
+	return &f
+}
+
+// WithContentMD5 sets whether a new instance of the file sends the MD5 of its
+// content, overriding the default set by Fs.WithContentMD5.
+func (f File) WithContentMD5(on bool) *File {
+	f.writeOpts.noContentMD5 = !on
+	return &f
+}
+
+// WithChecksum sets the additional checksum algorithm in a new instance of
+// the file, overriding the default set by Fs.WithChecksum.
+func (f File) WithChecksum(algorithm string) *File {
+	f.writeOpts.checksum = algorithm
+	return &f
+}
+
+// ResumeToken gets the progress of the multipart upload of the file, so that
+// it can be resumed using Fs.Resume if it is interrupted. The UploadId is
+// blank if no multipart upload has been started (see Fs.WithMultipartUpload).
+func (f *File) ResumeToken() ResumeToken {
+	if f.multipart == nil {
+		return ResumeToken{}
+	}
+	token := *f.multipart
+	token.Parts = append([]UploadedPart(nil), token.Parts...)
+	return token
+}
+
 // Name returns the filename, i.e. S3 path without the bucket name.
 func (f *File) Name() string { return f.name }
 
+// ETag returns the entity tag of the S3 object, exactly as provided by S3
+// (including its quotes). This is known after the file was opened using
+// Fs.Open, after it has been read, or after it has been written and closed;
+// otherwise it is blank.
+func (f *File) ETag() string { return f.etag }
+
+// VersionID returns the version ID of the S3 object, in a bucket that has
+// versioning enabled. Like ETag, this is known after the file was opened
+// using Fs.Open, after it has been read, or after it has been written and
+// closed, so callers can record exactly which version they produced. It is
+// blank if the bucket has never had versioning enabled.
+func (f *File) VersionID() string { return f.versionId }
+
 // Readdir reads the contents of the directory associated with file and
 // returns a slice of up to n FileInfo values, as would be returned
-// by ListObjects, in directory order. Subsequent calls on the same file will yield further FileInfos.
+// by ListObjects, in directory order. Subsequent calls on the same file will
+// yield further FileInfos, using the continuation token from the previous
+// listing.
 //
 // If n > 0, Readdir returns at most n FileInfo structures. In this case, if
 // Readdir returns an empty slice, it will return a non-nil error
 // explaining why. At the end of a directory, the error is io.EOF.
 //
-// If n <= 0, Readdir returns all the FileInfo from the directory in
+// If n <= 0, Readdir returns all the remaining FileInfo from the directory in
 // a single slice. In this case, if Readdir succeeds (reads all
 // the way to the end of the directory), it returns the slice and a
 // nil error. If it encounters an error before the end of the
 // directory, Readdir returns the FileInfo read until that point
 // and a non-nil error.
 func (f *File) Readdir(n int) ([]os.FileInfo, error) {
-	lister := f.lister(aws.String(PathSeparator))
-	list, err := lister.ListObjects(n, true)
+	if f.readdirNotTruncated {
+		if n > 0 {
+			return nil, io.EOF
+		}
+		return []os.FileInfo{}, nil
+	}
+
+	lister := f.lister()
+	var start operation
+	lister.ctx, start = f.s3Fs.beginWithContext(f.ctx, "Readdir", f.name)
+	list, token, hasMore, err := lister.listObjects(n, true, f.readdirContinuationToken)
 	if err != nil {
+		err = pathError("readdir", f.name, err)
+		f.s3Fs.logOp("Readdir", f.name, start, err)
 		return nil, err
 	}
 
+	f.readdirContinuationToken = token
+	f.readdirNotTruncated = !hasMore
+	f.s3Fs.logOp("Readdir", f.name, start, nil, "count", len(list))
+
+	if n > 0 && len(list) == 0 {
+		return nil, io.EOF
+	}
 	return list.ToStdSlice(), nil
 }
 
+// ReadDir reads the contents of the directory associated with the file, in
+// the same way as Readdir, but returns directory entries instead. The name
+// and type of each entry are known immediately; its full file info is only
+// fetched if DirEntry.Info is called.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	infos, err := f.Readdir(n)
+	entries := make([]fs.DirEntry, len(infos))
+	for i, fi := range infos {
+		entries[i] = &DirEntry{s3Fs: f.s3Fs, listed: fi.(FileInfo)}
+	}
+	return entries, err
+}
+
 // ReaddirAll provides list of file info.
 func (f *File) ReaddirAll() ([]os.FileInfo, error) {
-	lister := f.lister(aws.String(PathSeparator))
+	lister := f.lister()
+	var start operation
+	lister.ctx, start = f.s3Fs.beginWithContext(f.ctx, "Readdir", f.name)
 	list, err := lister.ListObjects(-1, true)
 	if err != nil {
+		err = pathError("readdir", f.name, err)
+		f.s3Fs.logOp("Readdir", f.name, start, err)
 		return nil, err
 	}
 
+	f.s3Fs.logOp("Readdir", f.name, start, nil, "count", len(list))
 	return list.ToStdSlice(), nil
 }
 
@@ -111,15 +293,11 @@ func (f *File) Readdirnames(n int) ([]string, error) {
 	return names, err
 }
 
-func (f *File) lister(delimiter *string) Lister {
-	return Lister{
-		bucket:    f.bucket,
-		name:      f.name,
-		delimiter: delimiter,
-		s3Fs:      f.s3Fs,
-		s3API:     f.s3API,
-		ctx:       f.ctx,
-	}
+func (f *File) lister() Lister {
+	lister := f.s3Fs.newLister(f.name, ListOptions{IncludeOwner: true})
+	lister.s3API = f.s3API
+	lister.ctx = f.ctx
+	return lister
 }
 
 // Stat returns the FileInfo structure describing file.
@@ -158,12 +336,14 @@ func (f *File) Close() error {
 
 	if f.writeBuf != nil {
 		err = f.finaliseWrite()
+		putWriteBuffer(f.writeBuf)
 		f.writeBuf = nil
+		f.info = nil
 	}
 
 	f.closed = true
 	f.offset = 0
-	return err
+	return pathError("close", f.name, err)
 }
 
 // Read reads up to len(b) bytes from the File.
@@ -178,52 +358,107 @@ func (f *File) Read(p []byte) (int, error) {
 		return 0, nil
 	}
 
+	if f.s3Fs.blockCache != nil {
+		return f.readBlocks(p)
+	}
+
+	if f.readCloser == nil && !f.conditional() && f.readFromDiskCache() {
+		if _, err := f.readCloser.(io.Seeker).Seek(f.offset, io.SeekStart); err != nil {
+			return 0, pathError("read", f.name, err)
+		}
+	}
+
 	if f.readCloser == nil {
-		output, err := f.s3API.GetObjectWithContext(f.ctx, &s3.GetObjectInput{
-			Bucket: aws.String(f.bucket),
-			Key:    aws.String(f.name),
-		})
+		ctx, start := f.s3Fs.beginWithContext(f.ctx, "Read", f.name)
+		ctx, cancel := withTimeout(ctx, f.s3Fs.timeouts.transfer)
+		input := &s3.GetObjectInput{
+			Bucket:          aws.String(f.bucket),
+			Key:             aws.String(f.s3Fs.key(f.name)),
+			IfNoneMatch:     f.ifNoneMatch,
+			IfModifiedSince: f.ifModifiedSince,
+		}
+		if f.offset > 0 {
+			// only download the rest of the file
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", f.offset))
+		} else if f.verify {
+			input.ChecksumMode = aws.String(s3.ChecksumModeEnabled)
+		}
+		output, err := f.s3API.GetObjectWithContext(ctx, input)
+		if isInvalidRange(err) {
+			// the offset is at or beyond the end of the file
+			cancel()
+			f.s3Fs.logOp("Read", f.name, start, nil, "offset", f.offset, "size", 0)
+			return 0, io.EOF
+		}
 		if err != nil {
+			cancel()
+			err = pathError("read", f.name, err)
+			f.s3Fs.logOp("Read", f.name, start, err)
 			return 0, err
 		}
+		f.s3Fs.logOp("Read", f.name, start, nil, "size", aws.Int64Value(output.ContentLength))
 
-		f.readCloser = output.Body
-
-		err = f.skipBytes(f.offset)
-		if err != nil {
-			return 0, err
+		body := output.Body
+		if f.verify && f.offset == 0 {
+			body = newVerifyingReader(output)
+		}
+		var w *diskCacheWriter
+		if f.offset == 0 {
+			// the disk cache only holds whole files
+			w = f.s3Fs.diskCache.writer(f.bucket, f.s3Fs.key(f.name), aws.StringValue(output.ETag))
+		}
+		if w != nil {
+			expectedSize := int64(-1)
+			if output.ContentLength != nil {
+				expectedSize = *output.ContentLength
+			}
+			body = &cachingReader{ReadCloser: body, writer: w, expectedSize: expectedSize}
+		}
+		if f.s3Fs.progress != nil {
+			total := aws.Int64Value(output.ContentLength)
+			if output.ContentLength == nil {
+				total = -1
+			}
+			body = &progressReader{ReadCloser: body, name: f.name, total: total, progress: f.s3Fs.progress}
+		}
+		if limiters := f.limiters(); limiters != nil {
+			body = throttledReader{ReadCloser: body, limiters: limiters}
 		}
+		f.readCloser = cancelOnClose{ReadCloser: body, cancel: cancel}
+		f.etag = aws.StringValue(output.ETag)
+		f.versionId = aws.StringValue(output.VersionId)
 	}
 
 	n, err := f.readCloser.Read(p)
 	f.offset += int64(n)
+	if err != nil && err != io.EOF {
+		// like os.File, io.EOF is returned as it is
+		err = pathError("read", f.name, err)
+	}
 	return n, err
 }
 
+// readFromDiskCache opens the cached copy of the file, if the disk cache
+// has one that matches its ETag.
+func (f *File) readFromDiskCache() bool {
+	start := operation{start: time.Now()}
+	file, ok := f.s3Fs.diskCache.open(f.bucket, f.s3Fs.key(f.name), f.etag)
+	if !ok {
+		return false
+	}
+	f.readCloser = file
+	f.s3Fs.logOp("Read", f.name, start, nil, "cached", true)
+	return true
+}
+
 func (f *File) skipBytes(toSkip int64) error {
 	if f.readCloser == nil {
 		return nil
 	}
 
-	if toSkip > 1024 {
-		junk := make([]byte, 1024)
-		for ; toSkip > 1024; toSkip -= 1024 {
-			_, err := f.readCloser.Read(junk)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	if toSkip > 0 {
-		junk := make([]byte, toSkip)
-		_, err := f.readCloser.Read(junk)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	// io.Discard reads into a pooled buffer, so this doesn't allocate one
+	_, err := io.CopyN(io.Discard, f.readCloser, toSkip)
+	return err
 }
 
 // ReadAt reads len(p) bytes from the file starting at byte offset off.
@@ -247,6 +482,10 @@ func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
 func (f *File) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
 	case 0:
+		if offset < 0 {
+			return 0, pathError("seek", f.name, errNegativeOffset)
+		}
+
 		if f.readCloser != nil {
 			// already reading so force the file to re-open on next read
 			err := f.readCloser.Close()
@@ -263,19 +502,38 @@ func (f *File) Seek(offset int64, whence int) (int64, error) {
 		f.offset = offset
 
 	case 1:
-		err := f.skipBytes(offset)
-		if err != nil {
-			return 0, err
+		// skipping forwards is cheaper than re-opening, unless the end of the
+		// file is reached
+		if offset < 0 || f.skipBytes(offset) != nil {
+			return f.Seek(f.offset+offset, 0)
 		}
 		f.offset += offset
 
 	case 2:
-		// can probably do this if we had GetObjectOutput (ContentLength)
-		panic("TODO: whence == 2 seek")
+		size, err := f.size()
+		if err != nil {
+			return 0, err
+		}
+		return f.Seek(size+offset, 0)
+
+	default:
+		return 0, pathError("seek", f.name, errWhence)
 	}
 	return f.offset, nil
 }
 
+// size gets the size of the file, as found by Open if possible.
+func (f *File) size() (int64, error) {
+	if f.info == nil {
+		fi, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		f.info = fi
+	}
+	return f.info.Size(), nil
+}
+
 // Write writes len(b) bytes to the File.
 // It returns the number of bytes written and an error, if any.
 // Write returns a non-nil error when n != len(b).
@@ -289,10 +547,242 @@ func (f *File) Write(p []byte) (int, error) {
 	//}
 
 	if f.writeBuf == nil {
-		f.writeBuf = &bytes.Buffer{}
+		f.writeBuf = getWriteBuffer()
 	}
 
-	return f.writeBuf.Write(p)
+	n, _ := f.writeBuf.Write(p)
+	if partSize := f.s3Fs.partSize; partSize > 0 && int64(f.writeBuf.Len()) >= partSize {
+		if err := f.uploadParts(int(partSize)); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// bytesWritten gets the size of the data written so far, including any parts
+// of a multipart upload that have already been uploaded.
+func (f *File) bytesWritten() int64 {
+	var size int64
+	if f.writeBuf != nil {
+		size = int64(f.writeBuf.Len())
+	}
+	if f.multipart != nil {
+		size += f.multipart.Size()
+	}
+	return size
+}
+
+// discardWrites abandons the data written so far, aborting any multipart
+// upload, so that closing the file leaves the object unchanged.
+func (f *File) discardWrites() {
+	if f.multipart != nil {
+		f.s3Fs.abortMultipartUpload(f.ctx, f.name, f.multipart.UploadId)
+		f.multipart = nil
+	}
+	if f.writeBuf != nil {
+		putWriteBuffer(f.writeBuf)
+		f.writeBuf = nil
+	}
+}
+
+// minPartSize is the smallest part of a multipart upload, apart from the
+// last, that S3 accepts.
+const minPartSize = 5 << 20
+
+// maxCopyPartSize is the largest part that S3 will copy from an existing
+// object. It is a variable so that tests can use small objects.
+var maxCopyPartSize int64 = 5 << 30
+
+// openForAppend prepares to append to the existing content of the file, if
+// any. S3 objects cannot be altered, so the object is rewritten on Close.
+// Objects of at least 5 MiB are copied within S3 as the first part of a
+// multipart upload, so that only the new data is uploaded; smaller ones
+// are read into the write buffer and uploaded again. Objects larger than
+// 5 GiB, the most S3 copies in one part, are copied as several parts.
+func (f *File) openForAppend(create bool) error {
+	// the object itself is rewritten, even if it is a symbolic link
+	fi, err := f.s3Fs.lstat(f.name)
+	if os.IsNotExist(err) && create {
+		return nil // there is nothing to append to
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return syscall.EISDIR
+	}
+
+	if f.writeBuf == nil {
+		f.writeBuf = getWriteBuffer()
+	}
+
+	if fi.Size() < minPartSize {
+		return f.readAll()
+	}
+
+	if err := f.createMultipartUpload(); err != nil {
+		return err
+	}
+
+	etag := ""
+	if info, ok := fi.(FileInfo); ok {
+		etag = info.ETag()
+	}
+
+	// S3 copies at most maxCopyPartSize bytes in one part, so larger objects
+	// are copied as ranges of roughly equal size
+	size := fi.Size()
+	count := (size + maxCopyPartSize - 1) / maxCopyPartSize
+	parts := make([]UploadedPart, 0, count)
+	for i := int64(0); i < count; i++ {
+		first, last := size*i/count, size*(i+1)/count-1
+		input := &s3.UploadPartCopyInput{
+			Bucket:     aws.String(f.bucket),
+			Key:        aws.String(f.s3Fs.key(f.name)),
+			UploadId:   aws.String(f.multipart.UploadId),
+			PartNumber: aws.Int64(i + 1),
+			CopySource: aws.String(copySource(f.bucket, f.s3Fs.key(f.name))),
+			// the object must not change before it is copied
+			CopySourceIfMatch: optionalString(etag),
+		}
+		if count > 1 {
+			input.CopySourceRange = aws.String(fmt.Sprintf("bytes=%d-%d", first, last))
+		}
+
+		ctx, start := f.s3Fs.beginWithContext(f.ctx, "CopyPart", f.name)
+		ctx, cancel := withTimeout(ctx, f.s3Fs.timeouts.transfer)
+		output, err := f.s3API.UploadPartCopyWithContext(ctx, input)
+		cancel()
+		f.s3Fs.logOp("CopyPart", f.name, start, err, "part", i+1, "size", last-first+1)
+		if err != nil {
+			f.s3Fs.abortMultipartUpload(f.ctx, f.name, f.multipart.UploadId)
+			f.multipart = nil
+			return err
+		}
+		parts = append(parts, UploadedPart{Number: i + 1, ETag: aws.StringValue(output.CopyPartResult.ETag), Size: last - first + 1})
+	}
+
+	f.multipart.Parts = parts
+	return nil
+}
+
+// readAll reads the existing content of the file into the write buffer.
+func (f *File) readAll() error {
+	_, err := f.writeBuf.ReadFrom(f)
+	if f.readCloser != nil {
+		f.readCloser.Close()
+		f.readCloser = nil
+	}
+	f.offset = 0
+	return err
+}
+
+// uploadParts uploads each whole part in the write buffer, starting a
+// multipart upload if need be. Each part is only removed from the buffer
+// once it has been uploaded.
+func (f *File) uploadParts(partSize int) error {
+	if f.multipart == nil {
+		if err := f.createMultipartUpload(); err != nil {
+			return err
+		}
+	}
+
+	for f.writeBuf.Len() >= partSize {
+		if err := f.uploadPart(f.multipart.nextPart(), f.writeBuf.Bytes()[:partSize], -1); err != nil {
+			return err
+		}
+		f.writeBuf.Next(partSize)
+	}
+	return nil
+}
+
+func (f *File) createMultipartUpload() error {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(f.bucket),
+		Key:         aws.String(f.s3Fs.key(f.name)),
+		ContentType: f.lookupContentType(),
+	}
+	f.writeOpts.applyToCreateMultipart(input)
+	if f.writeOpts.modTime {
+		input.Metadata = map[string]*string{}
+		setMetadataValue(input.Metadata, metadataKeyMtime, formatMetadataTime(f.opened))
+	}
+
+	ctx, start := f.s3Fs.beginWithContext(f.ctx, "CreateMultipartUpload", f.name)
+	uploadId, err := f.s3Fs.createMultipartUpload(ctx, input)
+	if err != nil {
+		err = pathError("write", f.name, err)
+		f.s3Fs.logOp("CreateMultipartUpload", f.name, start, err)
+		return err
+	}
+
+	f.s3Fs.logOp("CreateMultipartUpload", f.name, start, nil, "upload", uploadId)
+	f.multipart = &ResumeToken{UploadId: uploadId, ChecksumAlgorithm: f.writeOpts.checksum}
+	return nil
+}
+
+// uploadPart uploads a part of a multipart upload. The total size of the
+// file is -1 unless this is the last part.
+func (f *File) uploadPart(number int64, data []byte, total int64) error {
+	writeOpts := f.writeOpts
+	writeOpts.checksum = f.multipart.ChecksumAlgorithm
+	input, err := f.s3Fs.newUploadPartInput(f.name, f.multipart.UploadId, number, data, writeOpts)
+	if err != nil {
+		return pathError("write", f.name, err)
+	}
+	if f.s3Fs.progress != nil {
+		input.Body = &progressReadSeeker{ReadSeeker: input.Body, name: f.name, base: f.multipart.Size(), total: total, progress: f.s3Fs.progress}
+	}
+	if limiters := f.limiters(); limiters != nil {
+		input.Body = throttledReadSeeker{ReadSeeker: input.Body, limiters: limiters}
+	}
+
+	ctx, start := f.s3Fs.beginWithContext(f.ctx, "WritePart", f.name)
+	part, err := f.s3Fs.uploadPart(ctx, input)
+	if err != nil {
+		err = pathError("write", f.name, err)
+		f.s3Fs.logOp("WritePart", f.name, start, err, "part", number, "size", len(data))
+		return err
+	}
+
+	f.s3Fs.logOp("WritePart", f.name, start, nil, "part", number, "size", len(data))
+	f.multipart.Parts = append(f.multipart.Parts, part)
+	return nil
+}
+
+// finaliseMultipart uploads the rest of the write buffer as the last part of
+// the multipart upload, then completes it.
+func (f *File) finaliseMultipart() error {
+	if f.writeBuf.Len() > 0 || len(f.multipart.Parts) == 0 {
+		total := f.multipart.Size() + int64(f.writeBuf.Len())
+		if err := f.uploadPart(f.multipart.nextPart(), f.writeBuf.Bytes(), total); err != nil {
+			return err
+		}
+		f.writeBuf.Reset()
+	}
+
+	var opts []request.Option
+	if f.exclusive && f.s3Fs.conditionalCreate {
+		opts = append(opts, request.WithSetRequestHeaders(map[string]string{"If-None-Match": "*"}))
+	}
+
+	size := f.multipart.Size()
+	ctx, start := f.s3Fs.beginWithContext(f.ctx, "Write", f.name)
+	output, err := f.s3Fs.completeMultipartUpload(ctx, f.name, *f.multipart, opts...)
+	f.s3Fs.forget(f.name)
+	if err != nil {
+		if f.exclusive && conditionOf(err) == ErrPreconditionFailed {
+			err = os.ErrExist
+		}
+		err = pathError("write", f.name, err)
+		f.s3Fs.logOp("Write", f.name, start, err, "size", size, "parts", len(f.multipart.Parts))
+		return err
+	}
+	f.s3Fs.logOp("Write", f.name, start, nil, "size", size, "parts", len(f.multipart.Parts), "version", aws.StringValue(output.VersionId))
+
+	f.multipart = nil
+	f.written(size, aws.StringValue(output.ETag), aws.StringValue(output.VersionId))
+	return pathError("write", f.name, f.s3Fs.waitUntilVisible(f.ctx, f.name, f.etag))
 }
 
 // finaliseWrite upload the write buffer contents to the S3 object. It is not possible
@@ -303,34 +793,94 @@ func (f *File) finaliseWrite() error {
 		// mimic os.File's write after close behavior
 		panic("write after close")
 	}
+	if f.multipart != nil {
+		return f.finaliseMultipart()
+	}
 	if f.offset != 0 {
 		panic("TODO: non-offset == 0 write")
 	}
 
 	buf := f.writeBuf.Bytes()
-	hasher := md5.New()
-	_, err := hasher.Write(buf)
-	if err != nil {
-		return err
+
+	var readSeeker io.ReadSeeker = bytes.NewReader(buf)
+	if f.s3Fs.progress != nil {
+		readSeeker = &progressReadSeeker{ReadSeeker: readSeeker, name: f.name, total: int64(len(buf)), progress: f.s3Fs.progress}
+	}
+	if limiters := f.limiters(); limiters != nil {
+		readSeeker = throttledReadSeeker{ReadSeeker: readSeeker, limiters: limiters}
 	}
-	hashBytes := hasher.Sum(nil)
-	hashB64 := base64.StdEncoding.EncodeToString(hashBytes)
-	//fmt.Printf("%x\n", hashBytes)
-	//fmt.Println(hashB64)
 
-	readSeeker := bytes.NewReader(buf)
-	if _, err := f.s3API.PutObjectWithContext(f.ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(f.bucket),
-		Key:         aws.String(f.name),
-		Body:        readSeeker,
-		ContentType: f.lookupContentType(),
-		ContentMD5:  aws.String(hashB64),
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(f.bucket),
+		Key:           aws.String(f.s3Fs.key(f.name)),
+		Body:          readSeeker,
+		ContentLength: aws.Int64(int64(len(buf))),
+		ContentType:   f.lookupContentType(),
 		//ServerSideEncryption: aws.String("AES256"),
-	}); err != nil {
+	}
+	if !f.writeOpts.noContentMD5 {
+		hash := md5.Sum(buf)
+		input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(hash[:]))
+	}
+	f.writeOpts.applyToPut(input)
+	if f.writeOpts.checksum != "" {
+		if err := setPutChecksum(input, f.writeOpts.checksum, buf); err != nil {
+			return pathError("write", f.name, err)
+		}
+	}
+	if f.writeOpts.modTime {
+		input.Metadata = map[string]*string{}
+		setMetadataValue(input.Metadata, metadataKeyMtime, formatMetadataTime(f.opened))
+	}
+
+	var opts []request.Option
+	if f.exclusive && f.s3Fs.conditionalCreate {
+		opts = append(opts, request.WithSetRequestHeaders(map[string]string{"If-None-Match": "*"}))
+	}
+
+	ctx, start := f.s3Fs.beginWithContext(f.ctx, "Write", f.name)
+	ctx, cancel := withTimeout(ctx, f.s3Fs.timeouts.transfer)
+	output, err := f.s3API.PutObjectWithContext(ctx, input, opts...)
+	cancel()
+	f.s3Fs.forget(f.name)
+	if err != nil {
+		if f.exclusive && conditionOf(err) == ErrPreconditionFailed {
+			err = os.ErrExist
+		}
+		err = pathError("write", f.name, err)
+		f.s3Fs.logOp("Write", f.name, start, err, "size", len(buf))
 		return err
 	}
+	f.s3Fs.logOp("Write", f.name, start, nil, "size", len(buf), "version", aws.StringValue(output.VersionId))
 
-	return nil
+	f.written(int64(len(buf)), aws.StringValue(output.ETag), aws.StringValue(output.VersionId))
+	return pathError("write", f.name, f.s3Fs.waitUntilVisible(f.ctx, f.name, f.etag))
+}
+
+// written records that the object has been written.
+func (f *File) written(size int64, etag, versionId string) {
+	f.etag = etag
+	f.versionId = versionId
+
+	record := AuditRecord{Op: "write", Path: f.name, Size: size, ETag: f.etag}
+	if f.created {
+		record.Op = "create"
+	}
+	f.s3Fs.audit(record)
+
+	if f.s3Fs.writeCache != nil {
+		modTime := time.Now()
+		if f.writeOpts.modTime {
+			modTime = f.opened
+		}
+		contentType := f.lookupContentType()
+		if f.writeOpts.contentType != nil {
+			contentType = f.writeOpts.contentType
+		}
+		oi := ObjectInfo{ETag: etag, VersionId: versionId, ContentType: aws.StringValue(contentType), Uid: -1, Gid: -1}
+		fi := NewFileInfo(f.name, size, modTime).withObjectInfo(oi)
+		f.s3Fs.writeCache.put(f.s3Fs.key(f.name), f.s3Fs.applyDefaultPerm(fi))
+	}
 }
 
 func (f *File) lookupContentType() *string {
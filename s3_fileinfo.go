@@ -4,6 +4,9 @@ import (
 	"os"
 	"path"
 	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
 )
 
 //go:generate runtemplate -v -tpl simple/list.tpl Type=FileInfo MapTo:string Comparable:true
@@ -11,6 +14,12 @@ import (
 // PathSeparator is always a forward slash. This is consistent and not OS-specific.
 const PathSeparator = "/"
 
+// The default permission bits reported by FileInfo.Mode.
+const (
+	DefaultFileMode os.FileMode = 0664
+	DefaultDirMode  os.FileMode = 0755
+)
+
 // FileInfo implements os.FileInfo for a file in S3.
 type FileInfo struct {
 	parent      string
@@ -19,6 +28,76 @@ type FileInfo struct {
 	sizeInBytes int64
 	modTime     time.Time
 	depth       int
+	perm        os.FileMode
+	hasPerm     bool   // perm is the file's own mode, from its metadata
+	linkTarget  string // only for symbolic links
+	object      ObjectInfo
+}
+
+// ObjectInfo holds S3-specific attributes of an object, as captured from
+// the listing or HeadObject response that produced a FileInfo. Fields are
+// blank when S3 did not provide them. It is returned by FileInfo.Sys().
+type ObjectInfo struct {
+	// ETag is the entity tag exactly as provided by S3, including its quotes.
+	ETag                 string
+	ContentType          string
+	StorageClass         string
+	VersionId            string
+	OwnerID              string
+	OwnerDisplayName     string
+	ServerSideEncryption string
+	SSEKMSKeyId          string
+
+	// These are the base64-encoded checksums of objects uploaded with
+	// additional checksums. They are only available via HeadObject
+	// or GetObjectAttributes.
+	ChecksumCRC32  string
+	ChecksumCRC32C string
+	ChecksumSHA1   string
+	ChecksumSHA256 string
+
+	// Uid and Gid are the numeric owner and group stored by Chown (or by
+	// s3fs-fuse) in the object's metadata. They are -1 when not known.
+	Uid int
+	Gid int
+}
+
+func objectInfoFromListing(obj *s3.Object) ObjectInfo {
+	oi := ObjectInfo{
+		ETag:         aws.StringValue(obj.ETag),
+		StorageClass: aws.StringValue(obj.StorageClass),
+		Uid:          -1,
+		Gid:          -1,
+	}
+	if obj.Owner != nil {
+		oi.OwnerID = aws.StringValue(obj.Owner.ID)
+		oi.OwnerDisplayName = aws.StringValue(obj.Owner.DisplayName)
+	}
+	return oi
+}
+
+func objectInfoFromHead(out *s3.HeadObjectOutput) ObjectInfo {
+	oi := ObjectInfo{
+		ETag:                 aws.StringValue(out.ETag),
+		ContentType:          aws.StringValue(out.ContentType),
+		StorageClass:         aws.StringValue(out.StorageClass),
+		VersionId:            aws.StringValue(out.VersionId),
+		ServerSideEncryption: aws.StringValue(out.ServerSideEncryption),
+		SSEKMSKeyId:          aws.StringValue(out.SSEKMSKeyId),
+		ChecksumCRC32:        aws.StringValue(out.ChecksumCRC32),
+		ChecksumCRC32C:       aws.StringValue(out.ChecksumCRC32C),
+		ChecksumSHA1:         aws.StringValue(out.ChecksumSHA1),
+		ChecksumSHA256:       aws.StringValue(out.ChecksumSHA256),
+		Uid:                  -1,
+		Gid:                  -1,
+	}
+	if uid, ok := metadataID(out.Metadata, metadataKeyUid); ok {
+		oi.Uid = uid
+	}
+	if gid, ok := metadataID(out.Metadata, metadataKeyGid); ok {
+		oi.Gid = gid
+	}
+	return oi
 }
 
 // NewFileInfo creates file info.
@@ -34,7 +113,38 @@ func NewFileInfo(name string, sizeInBytes int64, modTime time.Time) FileInfo {
 	}
 }
 
-// NewFileInfo creates directory info.
+func objectInfoFromAttributes(out *s3.GetObjectAttributesOutput) ObjectInfo {
+	oi := ObjectInfo{
+		ETag:         aws.StringValue(out.ETag),
+		StorageClass: aws.StringValue(out.StorageClass),
+		VersionId:    aws.StringValue(out.VersionId),
+		Uid:          -1,
+		Gid:          -1,
+	}
+	if out.Checksum != nil {
+		oi.ChecksumCRC32 = aws.StringValue(out.Checksum.ChecksumCRC32)
+		oi.ChecksumCRC32C = aws.StringValue(out.Checksum.ChecksumCRC32C)
+		oi.ChecksumSHA1 = aws.StringValue(out.Checksum.ChecksumSHA1)
+		oi.ChecksumSHA256 = aws.StringValue(out.Checksum.ChecksumSHA256)
+	}
+	return oi
+}
+
+// withObjectInfo returns a copy of the file info that carries S3 attributes.
+func (fi FileInfo) withObjectInfo(oi ObjectInfo) FileInfo {
+	fi.object = oi
+	return fi
+}
+
+// withPerm returns a copy of the file info that has the given permission bits
+// instead of the default ones.
+func (fi FileInfo) withPerm(perm os.FileMode) FileInfo {
+	fi.perm = perm
+	fi.hasPerm = true
+	return fi
+}
+
+// NewDirectoryInfo creates directory info.
 func NewDirectoryInfo(name string) FileInfo {
 	parent, file := path.Split(trimTrailingSlash(name))
 	return FileInfo{
@@ -67,14 +177,28 @@ func (fi FileInfo) Size() int64 {
 }
 
 // Mode provides the file mode bits. For a file in S3 this defaults to
-// 664 for files, 775 for directories.
+// 664 for files, 755 for directories, unless altered using Fs.WithFileMode
+// or Fs.WithDirMode. However, a mode stored in the object's metadata by
+// Chmod (or s3fs-fuse) is used when known, even if it is zero. Directories
+// always have os.ModeDir set, and symbolic links found by Fs.LstatIfPossible
+// have os.ModeSymlink set.
 // In the future this may return differently depending on the permissions
 // available on the bucket.
 func (fi FileInfo) Mode() os.FileMode {
+	perm := fi.perm
 	if fi.directory {
-		return 0755
+		if perm == 0 && !fi.hasPerm {
+			perm = DefaultDirMode
+		}
+		return os.ModeDir | perm
 	}
-	return 0664
+	if perm == 0 && !fi.hasPerm {
+		perm = DefaultFileMode
+	}
+	if fi.linkTarget != "" {
+		return os.ModeSymlink | perm
+	}
+	return perm
 }
 
 // ModTime provides the last modification time.
@@ -82,12 +206,29 @@ func (fi FileInfo) ModTime() time.Time {
 	return fi.modTime
 }
 
+// ETag provides the entity tag of a file, exactly as provided by S3 (including
+// its quotes). This is blank for directories.
+func (fi FileInfo) ETag() string {
+	return fi.object.ETag
+}
+
+// ContentType provides the MIME type of a file. This is only known when the
+// file info was obtained via Stat; it is blank for listings and directories.
+func (fi FileInfo) ContentType() string {
+	return fi.object.ContentType
+}
+
 // IsDir provides the abbreviation for Mode().IsDir()
 func (fi FileInfo) IsDir() bool {
 	return fi.directory
 }
 
-// Sys provides the underlying data source (can return nil)
+// Sys provides the underlying data source. For files, this is a *ObjectInfo
+// holding the S3 attributes of the object. For directories, it is nil.
 func (fi FileInfo) Sys() interface{} {
-	return nil
+	if fi.directory {
+		return nil
+	}
+	oi := fi.object
+	return &oi
 }
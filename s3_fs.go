@@ -2,38 +2,85 @@ package s3
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"os"
-	"path"
+	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/spf13/afero"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Fs is an FS object backed by S3. It is safe to share Fs objects between
-// goroutines. Note that WithContext and AddMimeTypes modify and return a new
-// version of the Fs object.
+// goroutines. Note that WithContext, AddMimeTypes and the other With...
+// methods modify and return a new version of the Fs object. This is
+// independent of the original, which is unchanged, apart from the state
+// that they share: the caches, statistics, circuit breaker and bandwidth
+// limit, unless the new version replaces these.
 type Fs struct {
-	bucket    string
-	s3API     S3APISubset
-	mimeTypes map[string]string
-	ctx       aws.Context
+	bucket     string
+	client     S3APISubset // as provided to NewFs
+	s3API      S3APISubset // the client with the counting, retry, circuit breaker and hook layers
+	mimeTypes  map[string]string
+	ctx        aws.Context
+	writeOpts  writeOptions
+	writeRules []WriteRule
+	fileMode   os.FileMode
+	dirMode    os.FileMode
+
+	statAttributes bool
+	verifyReads    bool
+	statCache      *statCache
+	missingCache   *statCache
+	dirCache       *statCache
+	writeCache     *statCache
+	diskCache      *diskCache
+	blockCache     *blockCache
+
+	bucketConfig      *bucketConfig
+	noDirMarkers      bool
+	conditionalCreate bool
+	keyPrefix         string
+	rootPrefix        string // the part of keyPrefix that cannot be changed
+	keyValidation     KeyValidation
+	timeouts          timeouts
+
+	retryPolicy RetryPolicy
+	breaker     *circuitBreaker
+	hooks       []Hook
+	logger      Logger
+	auditor     Auditor
+	principal   string
+	tracer      trace.Tracer
+	counters    *counters
+	progress    ProgressFunc
+	limiter     *rateLimiter
+	partSize    int64
+	waitTimeout time.Duration
+	replicas    *replicaSet
+
+	requestOptions []request.Option
 }
 
 // NewFs creates a new Fs object writing files to a given S3 bucket.
+//
+// The bucket can also be the ARN of an access point. For an S3 Object Lambda
+// access point, the file system is read-only: only Open, Stat and directory
+// listings are supported, and other operations fail with an error that
+// matches ErrUnsupportedOperation.
 func NewFs(bucket string, s3API S3APISubset) *Fs {
-	return &Fs{
+	fs := &Fs{
 		bucket:    bucket,
-		s3API:     s3API,
+		client:    s3API,
 		mimeTypes: make(map[string]string),
 		ctx:       context.Background(),
+		counters:  &counters{},
 	}
+	fs.s3API = fs.layeredAPI()
+	return fs
 }
 
 // WithContext sets the context in a new instance of the file system.
@@ -42,6 +89,486 @@ func (fs Fs) WithContext(ctx aws.Context) *Fs {
 	return &fs
 }
 
+// WithRetry sets the retry policy in a new instance of the file system. Every
+// S3 request is retried according to the policy, regardless of whether the
+// S3 client does any retrying itself. This replaces any previous policy; a
+// policy with zero MaxRetries disables retrying.
+func (fs Fs) WithRetry(policy RetryPolicy) *Fs {
+	fs.retryPolicy = policy
+	fs.s3API = fs.layeredAPI()
+	return &fs
+}
+
+// WithCircuitBreaker sets up a circuit breaker in a new instance of the file
+// system. After the given number of consecutive requests have failed because
+// S3 seems to be unavailable (for example, 503 errors, connection failures
+// or timeouts), no more requests are sent until the cool-down period has
+// passed; meanwhile every operation fails immediately with ErrCircuitOpen.
+// Then one trial request is sent to find whether S3 has recovered.
+//
+// A retried request counts as one request. The circuit breaker is shared
+// with any other file systems derived from the new instance. A threshold
+// of zero removes the circuit breaker.
+func (fs Fs) WithCircuitBreaker(threshold int, coolDown time.Duration) *Fs {
+	fs.breaker = nil
+	if threshold > 0 {
+		fs.breaker = newCircuitBreaker(threshold, coolDown)
+	}
+	fs.s3API = fs.layeredAPI()
+	return &fs
+}
+
+// WithHook adds a hook in a new instance of the file system. The hook is
+// called before and after every S3 request. Retried requests are seen by
+// hooks as one request.
+func (fs Fs) WithHook(hook Hook) *Fs {
+	hooks := make([]Hook, len(fs.hooks), len(fs.hooks)+1)
+	copy(hooks, fs.hooks)
+	fs.hooks = append(hooks, hook)
+	fs.s3API = fs.layeredAPI()
+	return &fs
+}
+
+// WithProgress sets a function in a new instance of the file system that is
+// called repeatedly while each file is uploaded or downloaded, e.g. to show
+// a progress bar. Nil disables this, which is the default.
+func (fs Fs) WithProgress(fn ProgressFunc) *Fs {
+	fs.progress = fn
+	return &fs
+}
+
+// WithBandwidthLimit limits the total rate at which files are uploaded and
+// downloaded by a new instance of the file system, in bytes per second. The
+// limit is shared by every file, including those opened using any file
+// systems derived from the new instance. Files can also be limited
+// individually using File.WithBandwidthLimit. Zero means there is no limit,
+// which is the default.
+//
+// The AWS SDK reads the data of each upload twice, once to sign the request
+// and once to send it, so uploads take twice as long as the limit implies.
+func (fs Fs) WithBandwidthLimit(bytesPerSecond int64) *Fs {
+	fs.limiter = nil
+	if bytesPerSecond > 0 {
+		fs.limiter = newRateLimiter(bytesPerSecond)
+	}
+	return &fs
+}
+
+// WithLogger sets the logger in a new instance of the file system. Every
+// operation is logged with its bucket, key and latency: successful ones at
+// LevelDebug and failed ones at LevelError, except when the file does not
+// exist. Retries and the circuit breaker opening are logged at LevelWarn.
+// Nil disables logging, which is the default.
+func (fs Fs) WithLogger(logger Logger) *Fs {
+	fs.logger = logger
+	fs.s3API = fs.layeredAPI()
+	return &fs
+}
+
+// WithTracerProvider enables OpenTelemetry tracing in a new instance of the
+// file system. There is a span for each file system operation, such as Stat
+// or Rename, and a child span for each S3 request that it makes. The spans
+// have the bucket, key, size and error, if any. Nil disables tracing, which
+// is the default.
+func (fs Fs) WithTracerProvider(tp trace.TracerProvider) *Fs {
+	fs.tracer = nil
+	if tp != nil {
+		fs.tracer = tp.Tracer(tracerName)
+	}
+	fs.s3API = fs.layeredAPI()
+	return &fs
+}
+
+// Stats gets the numbers of S3 requests made so far, and the bytes
+// transferred, by this file system and all those derived from it using
+// the With... methods.
+func (fs Fs) Stats() Stats {
+	return fs.counters.snapshot()
+}
+
+// layeredAPI wraps the client in the failover, request options, counting,
+// retry, circuit breaker and hook layers, as configured, and restricts the requests for an
+// Object Lambda access point.
+func (fs Fs) layeredAPI() S3APISubset {
+	api := fs.client
+	if fs.replicas != nil {
+		api = &failoverAPI{S3APISubset: api, replicas: fs.replicas, logger: fs.logger}
+	}
+	api = &optionsAPI{S3APISubset: api, opts: fs.requestOptions}
+	if fs.counters != nil {
+		api = &countingAPI{S3APISubset: api, counters: fs.counters}
+	}
+	if fs.retryPolicy.MaxRetries > 0 {
+		api = &retryingAPI{S3APISubset: api, policy: fs.retryPolicy, logger: fs.logger}
+	}
+	if fs.breaker != nil {
+		api = &breakingAPI{S3APISubset: api, breaker: fs.breaker, logger: fs.logger}
+	}
+	hooks := fs.hooks
+	if fs.tracer != nil {
+		hooks = append(hooks[:len(hooks):len(hooks)], tracingHook{tracer: fs.tracer})
+	}
+	if len(hooks) > 0 {
+		api = &hookingAPI{S3APISubset: api, hooks: hooks}
+	}
+	if isObjectLambdaARN(fs.bucket) {
+		api = objectLambdaAPI{S3APISubset: api}
+	}
+	return api
+}
+
+// WithHeadTimeout sets the time limit for each HeadObject, GetObjectAttributes
+// and DeleteObject request, including any retries, in a new instance of the
+// file system. These are small requests so a short limit makes Stat and Remove
+// fail fast when S3 is unresponsive. Zero means no limit, which is the default.
+func (fs Fs) WithHeadTimeout(d time.Duration) *Fs {
+	fs.timeouts.head = d
+	return &fs
+}
+
+// WithListTimeout sets the time limit for each ListObjectsV2 request, including
+// any retries, in a new instance of the file system. Large listings are made
+// of many requests, each with this limit. Zero means no limit, which is the
+// default.
+func (fs Fs) WithListTimeout(d time.Duration) *Fs {
+	fs.timeouts.list = d
+	return &fs
+}
+
+// WithTransferTimeout sets the time limit for each GetObject, PutObject,
+// CopyObject and SelectObjectContent request, including any retries, in a new
+// instance of the file system. For GetObject and SelectObjectContent, this
+// includes reading the results, up until they are closed.
+// Zero means no limit, which is the default.
+func (fs Fs) WithTransferTimeout(d time.Duration) *Fs {
+	fs.timeouts.transfer = d
+	return &fs
+}
+
+// WithACL sets the canned ACL in a new instance of the file system. This is
+// applied to every object written or copied, unless overridden per file using
+// File.WithACL. For example, cross-account uploads often need
+// s3.ObjectCannedACLBucketOwnerFullControl. A blank string means that no ACL
+// is sent, so the bucket default applies.
+func (fs Fs) WithACL(acl string) *Fs {
+	fs.writeOpts.acl = optionalString(acl)
+	return &fs
+}
+
+// WithObjectLock sets the Object Lock retention in a new instance of the file
+// system. The mode is s3.ObjectLockModeGovernance or s3.ObjectLockModeCompliance
+// and each object written is retained until the given time. A blank mode means
+// that no retention is sent, so the bucket default applies.
+//
+// The bucket must have Object Lock enabled.
+func (fs Fs) WithObjectLock(mode string, retainUntil time.Time) *Fs {
+	fs.writeOpts.setObjectLock(mode, retainUntil)
+	return &fs
+}
+
+// WithLegalHold sets the Object Lock legal hold status in a new instance of the
+// file system. When on, every object written is placed under legal hold.
+//
+// The bucket must have Object Lock enabled.
+func (fs Fs) WithLegalHold(on bool) *Fs {
+	fs.writeOpts.setLegalHold(on)
+	return &fs
+}
+
+// WithMultipartUpload sets the part size in a new instance of the file
+// system, so that files are uploaded in parts as they are written, rather
+// than being held in memory until they are closed. Once the data written to
+// a file reaches the part size, a multipart upload is started and each part
+// is uploaded; the rest is uploaded as the last part when the file is
+// closed. Smaller files are uploaded by a single PutObject request, as
+// usual. S3 requires parts of at least 5 MiB, apart from the last, and no
+// more than 10000 of them. Zero, the default, means files are never uploaded
+// in parts.
+//
+// If an upload fails, the parts already uploaded are kept, so that it can be
+// resumed (see File.ResumeToken and Resume) or else aborted (see
+// AbortMultipartUploads).
+func (fs Fs) WithMultipartUpload(partSize int64) *Fs {
+	fs.partSize = partSize
+	return &fs
+}
+
+// WithContentMD5 sets whether files written by a new instance of the file
+// system send the MD5 of their content (the Content-MD5 header), so that S3
+// rejects uploads that were corrupted in transit. This is on by default.
+// Turning it off saves computing the MD5 of every file written, which may be
+// wanted when an additional checksum is used instead (see WithChecksum), or
+// where MD5 is not allowed, e.g. under FIPS 140.
+func (fs Fs) WithContentMD5(on bool) *Fs {
+	fs.writeOpts.noContentMD5 = !on
+	return &fs
+}
+
+// WithChecksum sets the additional checksum algorithm in a new instance of
+// the file system, e.g. s3.ChecksumAlgorithmSha256. The checksum of every
+// object written is computed and sent with the Content-MD5 (if any; see
+// WithContentMD5), so that S3 both verifies the upload and stores the
+// checksum; objects copied (e.g. by Rename) have their checksums computed by
+// S3. A blank algorithm means that no additional checksum is sent, which is
+// the default.
+//
+// Stat then requests the stored checksums, which are available via
+// ObjectInfo.Checksum.
+func (fs Fs) WithChecksum(algorithm string) *Fs {
+	fs.writeOpts.checksum = algorithm
+	return &fs
+}
+
+// WithVerifiedReads sets whether files read by a new instance of the file
+// system are verified. If so, the content of each file read from the start is
+// checked against the checksum stored by S3 (see WithChecksum) or otherwise
+// against its ETag, if this is the MD5 of the content. If they differ, Read
+// fails with ErrChecksumMismatch (wrapped in an *os.PathError) instead of
+// io.EOF. Nothing is verified for files that were uploaded in parts without
+// an additional checksum, nor for those encrypted using SSE-KMS or SSE-C,
+// nor when reading from an offset or via the block cache (see
+// WithBlockCache). This can be overridden per file using File.WithVerify.
+func (fs Fs) WithVerifiedReads(on bool) *Fs {
+	fs.verifyReads = on
+	return &fs
+}
+
+// WithModTimeMetadata sets whether files written by a new instance of the
+// file system record their modification time in the object's user metadata
+// (x-amz-meta-mtime, as used by Chtimes). The time recorded is when the file
+// was opened for writing, by the local clock; otherwise Stat reports the time
+// S3 stored the object, which is when the file was closed, by the S3 clock.
+//
+// This is needed when using afero.CacheOnReadFs with a non-zero cache time,
+// e.g.
+//
+//	cached := afero.NewCacheOnReadFs(s3Fs.WithModTimeMetadata(true), afero.NewMemMapFs(), time.Minute)
+//
+// CacheOnReadFs writes each file to the layer first, then to this Fs, and
+// treats the cached copy as stale if this Fs reports a later modification
+// time. Without this, every file written through it would be downloaded
+// again once the cache time has passed. Reading through it relies on Chtimes
+// and on Stat reporting the mtime metadata, so WithStatUsingAttributes
+// should not be used with it.
+func (fs Fs) WithModTimeMetadata(on bool) *Fs {
+	fs.writeOpts.modTime = on
+	return &fs
+}
+
+// WithFileMode sets the permission bits reported for files in a new instance
+// of the file system. These are used unless a file has its own mode in its
+// metadata (see Chmod). Zero means DefaultFileMode.
+func (fs Fs) WithFileMode(mode os.FileMode) *Fs {
+	fs.fileMode = mode & permBits
+	return &fs
+}
+
+// WithDirMode sets the permission bits reported for directories in a new
+// instance of the file system. Zero means DefaultDirMode.
+func (fs Fs) WithDirMode(mode os.FileMode) *Fs {
+	fs.dirMode = mode & permBits
+	return &fs
+}
+
+// permBits are the parts of os.FileMode that can be configured or stored.
+const permBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// applyDefaultPerm sets the configured permission bits on file info that
+// does not have any of its own, even if its own are zero.
+func (fs Fs) applyDefaultPerm(fi FileInfo) FileInfo {
+	if !fi.hasPerm {
+		if fi.directory {
+			fi.perm = fs.dirMode
+		} else {
+			fi.perm = fs.fileMode
+		}
+	}
+	return fi
+}
+
+// WithStatUsingAttributes sets whether Stat uses GetObjectAttributes instead
+// of HeadObject, in a new instance of the file system. This returns the
+// checksums of objects uploaded with them (see FileInfo.Sys), but it does
+// not provide the content type nor the user metadata, so the modification
+// time and mode set by Chtimes and Chmod are not available.
+func (fs Fs) WithStatUsingAttributes(on bool) *Fs {
+	fs.statAttributes = on
+	return &fs
+}
+
+// WithKeyPrefix sets a prefix for all the object keys in a new instance of the
+// file system. The file system then only sees the part of the bucket below
+// this prefix, which acts as its root directory. For example, with the
+// prefix "data/2020", the file "/a/b.txt" is stored in the object with the
+// key "data/2020/a/b.txt".
+//
+// For a file system made by WithTenant, the prefix is added to the tenant's
+// prefix, so it cannot be used to escape from the tenant's directory.
+func (fs Fs) WithKeyPrefix(prefix string) *Fs {
+	fs.keyPrefix = fs.rootPrefix + normaliseKeyPrefix(prefix)
+	return &fs
+}
+
+// WithKeyValidation sets how file names are checked before being used as S3
+// object keys, in a new instance of the file system. The default is KeyDefault.
+// Names that are rejected cause errors that wrap an *InvalidKeyError, before
+// any request is sent to S3.
+func (fs Fs) WithKeyValidation(v KeyValidation) *Fs {
+	fs.keyValidation = v
+	return &fs
+}
+
+// WithDirMarkers sets whether Mkdir and MkdirAll create marker objects, in a
+// new instance of the file system. By default, making a directory writes an
+// empty object with the directory's name and a trailing slash. When this is
+// turned off, Mkdir and MkdirAll succeed without writing anything; directories
+// then exist implicitly whenever there are objects within them, so an empty
+// directory made this way does not exist according to Stat.
+func (fs Fs) WithDirMarkers(on bool) *Fs {
+	fs.noDirMarkers = !on
+	return &fs
+}
+
+// WithStatCache enables an in-process cache of Stat results in a new instance
+// of the file system. Because Open and Create use Stat, this reduces the number
+// of requests made to S3 considerably for read-heavy workloads. Entries are kept
+// for the time-to-live given, and no more than maxEntries are kept (unless
+// maxEntries is zero). A zero or negative ttl disables the cache.
+//
+// The cache is shared with the file systems derived from this one (e.g. by
+// WithContext). Writes, renames and removals made through any of them
+// invalidate the affected entries, but changes made by other S3 clients
+// are not seen until entries expire.
+func (fs Fs) WithStatCache(ttl time.Duration, maxEntries int) *Fs {
+	if ttl <= 0 {
+		fs.statCache = nil
+	} else {
+		fs.statCache = newStatCache(ttl, maxEntries)
+	}
+	return &fs
+}
+
+// WithNotFoundCache enables an in-process cache of the names that Stat found
+// not to exist, in a new instance of the file system. This avoids repeated
+// requests when probing for optional files. Entries are kept for the
+// time-to-live given, which should usually be short, and no more than
+// maxEntries are kept (unless maxEntries is zero). A zero or negative ttl
+// disables the cache.
+//
+// Like WithStatCache, the cache is shared with derived file systems and writes
+// made through any of them invalidate the affected entries.
+func (fs Fs) WithNotFoundCache(ttl time.Duration, maxEntries int) *Fs {
+	if ttl <= 0 {
+		fs.missingCache = nil
+	} else {
+		fs.missingCache = newStatCache(ttl, maxEntries)
+	}
+	return &fs
+}
+
+// WithDirCache enables an in-process cache of whether directories exist, in a
+// new instance of the file system. S3 has no real directories, so Stat lists
+// the objects with the directory name as their prefix when no file is found;
+// this cache avoids repeating that. It is also filled by directory listings,
+// which discover subdirectories. Entries are kept for the time-to-live given,
+// and no more than maxEntries are kept (unless maxEntries is zero). A zero or
+// negative ttl disables the cache.
+//
+// Like WithStatCache, the cache is shared with derived file systems and
+// creating or removing files through any of them invalidates the entries
+// for their parent directories.
+func (fs Fs) WithDirCache(ttl time.Duration, maxEntries int) *Fs {
+	if ttl <= 0 {
+		fs.dirCache = nil
+	} else {
+		fs.dirCache = newStatCache(ttl, maxEntries)
+	}
+	return &fs
+}
+
+// WithReadYourWrites sets how long the file system remembers the files it
+// has written, in a new instance of the file system. During that window, Stat
+// (and therefore Open) of such a file is answered from what was known when it
+// was closed - its size, modification time, ETag and version - instead of
+// sending a request that, for some S3-compatible stores, may not yet see the
+// new object. A zero or negative window disables this.
+//
+// Like WithStatCache, the record is shared with derived file systems, and
+// renames and removals through any of them forget the affected files.
+func (fs Fs) WithReadYourWrites(window time.Duration) *Fs {
+	if window <= 0 {
+		fs.writeCache = nil
+	} else {
+		fs.writeCache = newStatCache(window, maxRecentWrites)
+	}
+	return &fs
+}
+
+// maxRecentWrites limits the number of files remembered by WithReadYourWrites.
+const maxRecentWrites = 10000
+
+// WithDiskCache enables a cache of downloaded files in a local directory, in a
+// new instance of the file system. When a file opened using Open is read, its
+// ETag is compared with the cached copy, if any; the cached copy is read
+// instead when they match. Otherwise, the file is downloaded as usual, and a
+// copy is kept in the cache once the whole file has been read. The total size
+// of the cached copies is limited to maxBytes; the least recently used are
+// removed first. A zero or negative maxBytes disables the cache.
+//
+// Copies left in the directory by earlier use are reused. Like WithStatCache,
+// the cache is shared with derived file systems. A directory should not be
+// used by more than one cache at a time.
+func (fs Fs) WithDiskCache(dir string, maxBytes int64) *Fs {
+	if maxBytes <= 0 {
+		fs.diskCache = nil
+	} else {
+		fs.diskCache = newDiskCache(dir, maxBytes)
+	}
+	return &fs
+}
+
+// WithBlockCache enables an in-memory cache of parts of files, in a new
+// instance of the file system. Files are then read in blocks of blockSize
+// bytes, each using a ranged GET request, and the blocks are kept so that
+// repeated small reads and seeks within the same files do not download them
+// again. This suits reading the headers of many files, for example, but not
+// reading whole large files. The total size of the blocks is limited to
+// maxBytes; the least recently used are removed first. A zero or negative
+// blockSize or maxBytes disables the cache; this takes precedence over
+// WithDiskCache.
+//
+// Blocks are identified by the ETag of the file, so changed files are not
+// read from the cache provided the file was opened using Open. All the
+// blocks read using one File come from the same version of the file.
+// Like WithStatCache, the cache is shared with derived file systems.
+func (fs Fs) WithBlockCache(blockSize, maxBytes int64) *Fs {
+	if blockSize <= 0 || maxBytes <= 0 {
+		fs.blockCache = nil
+	} else {
+		fs.blockCache = newBlockCache(blockSize, maxBytes)
+	}
+	return &fs
+}
+
+// forget removes any cached information about a name and its parents.
+func (fs Fs) forget(name string) {
+	key := fs.key(name)
+	fs.statCache.invalidate(key)
+	fs.missingCache.invalidate(key)
+	fs.dirCache.invalidate(key)
+	fs.writeCache.invalidate(key)
+}
+
+// forgetAll removes any cached information about a name, its parents and
+// everything below it.
+func (fs Fs) forgetAll(name string) {
+	key := fs.key(name)
+	fs.statCache.invalidateAll(key)
+	fs.missingCache.invalidateAll(key)
+	fs.dirCache.invalidateAll(key)
+	fs.writeCache.invalidateAll(key)
+}
+
 // AddMimeTypes adds MIME types to new instance of the file system.
 // When uploading (i.e. writing) files, these are used to set the
 // content type based on the file extension.
@@ -49,6 +576,11 @@ func (fs Fs) WithContext(ctx aws.Context) *Fs {
 // Any file uploaded without its MIME type defined here will assume the default,
 // application/octet-stream.
 func (fs Fs) AddMimeTypes(mimeTypes map[string]string) *Fs {
+	existing := fs.mimeTypes
+	fs.mimeTypes = make(map[string]string, len(existing)+len(mimeTypes))
+	for k, v := range existing {
+		fs.mimeTypes[k] = v
+	}
 	for k, v := range mimeTypes {
 		if strings.HasPrefix(k, ".") {
 			k = k[1:]
@@ -63,43 +595,48 @@ func (fs Fs) Name() string { return "S3/" + fs.bucket }
 
 // Create a file.
 func (fs Fs) Create(name string) (afero.File, error) {
+	start := fs.begin("Create", name)
 	file, err := fs.Open(name)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fs.OpenFile(name, os.O_CREATE, 0777)
+			file, err = fs.OpenFile(name, os.O_CREATE, 0777)
+			fs.logOp("Create", name, start, err)
+			return file, err
 		}
-		lgr("Create %s %q > %+v\n", fs.bucket, name, err)
+		fs.logOp("Create", name, start, err)
 		return file, err
 	}
 
-	// Create(), like all of S3, is eventually consistent.
-	// To protect against unexpected behavior, have this method
-	// wait until S3 reports the object exists.
-	//if s3Client, ok := fs.s3API.(*s3.S3); ok {
-	//	return file, s3Client.WaitUntilObjectExists(&s3.HeadObjectInput{
-	//		Bucket: aws.String(fs.bucket),
-	//		Key:    aws.String(name),
-	//	})
-	//}
+	// if need be, Close waits until the object is visible (see WithWaitUntilVisible)
 
 	// TODO improved performance under failure conditions can be achieved by
 	// using a trial PUT operation with status code 100-Continue before
 	// actually processing large amounts of data
 	// (see https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPUT.html)
-	lgr("Create %s %q\n", fs.bucket, name)
+	fs.logOp("Create", name, start, nil)
 	return file, err
 }
 
 // Mkdir makes a directory in S3.
 func (fs Fs) Mkdir(name string, perm os.FileMode) error {
-	file, err := fs.OpenFile(fmt.Sprintf("%s/", path.Clean(name)), os.O_CREATE, perm)
+	start := fs.begin("Mkdir", name)
+	if fs.noDirMarkers {
+		fs.logOp("Mkdir", name, start, nil, "perm", perm, "noop", true)
+		return nil
+	}
+
+	file, err := fs.OpenFile(addTrailingSlash(name), os.O_CREATE, perm)
+	if err == nil {
+		// the marker object is written on closing
+		err = file.Close()
+	}
 	if err != nil {
-		lgr("Mkdir %s %q, %v > %+v\n", fs.bucket, name, perm, err)
+		err = pathError("mkdir", name, err)
+		fs.logOp("Mkdir", name, start, err, "perm", perm)
 		return err
 	}
-	defer file.Close()
 
-	lgr("Mkdir %s %q, %v\n", fs.bucket, name, perm)
+	fs.logOp("Mkdir", name, start, nil, "perm", perm)
 	return nil
 }
 
@@ -110,40 +647,120 @@ func (fs Fs) MkdirAll(path string, perm os.FileMode) error {
 
 // Open a file for reading.
 func (fs Fs) Open(name string) (afero.File, error) {
-	if _, err := fs.Stat(name); err != nil {
-		lgr("Open %s %q > %+v\n", fs.bucket, name, err)
+	start := fs.begin("Open", name)
+	target, info, err := fs.followLinks(name)
+	if err != nil {
+		fs.logOp("Open", name, start, err)
 		return (*File)(nil), err
 	}
 
-	lgr("Open %s %q\n", fs.bucket, name)
-	return NewFile(fs.bucket, name, fs.s3API, fs), nil
+	fs.logOp("Open", name, start, nil)
+	file := NewFile(fs.bucket, target, fs.s3API, fs)
+	file.info = info
+	if fi, ok := info.(FileInfo); ok {
+		file.etag = fi.ETag()
+		file.versionId = fi.object.VersionId
+	}
+	return file, nil
+}
+
+// Resume reopens a file for writing, to continue a multipart upload that was
+// interrupted, e.g. by a network failure or by the process exiting, from
+// where it stopped. The token is from File.ResumeToken; the parts it lists
+// are checked against those that S3 has, including their checksums if an
+// additional checksum is used (see WithChecksum). The data written to the file then
+// follows the token.Size() bytes already uploaded and, as usual, the object
+// is written when the file is closed. If the upload has already been
+// completed or aborted, the error matches os.ErrNotExist.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Resume(name string, token ResumeToken) (*File, error) {
+	if err := fs.checkName("resume", name); err != nil {
+		return nil, err
+	}
+
+	ctx, start := fs.beginWithContext(fs.ctx, "Resume", name)
+	uploaded, err := fs.listParts(ctx, name, token.UploadId)
+	if err == nil {
+		found := make(map[int64]UploadedPart, len(uploaded))
+		for _, p := range uploaded {
+			found[p.Number] = p
+		}
+		token.Parts = append([]UploadedPart(nil), token.Parts...)
+		for i, p := range token.Parts {
+			u, exists := found[p.Number]
+			switch {
+			case !exists || u.ETag != p.ETag:
+				err = errResumeToken
+			case p.Checksum != "" && u.Checksum != "" && p.Checksum != u.Checksum:
+				err = errResumeToken
+			case p.Checksum == "":
+				// the checksum is needed to complete the upload
+				token.Parts[i].Checksum = u.Checksum
+			}
+		}
+	}
+	if err != nil {
+		err = pathError("resume", name, err)
+		fs.logOp("Resume", name, start, err)
+		return nil, err
+	}
+
+	fs.logOp("Resume", name, start, nil, "parts", len(token.Parts), "size", token.Size())
+	file := NewFile(fs.bucket, name, fs.s3API, fs)
+	file.created = true
+	file.multipart = &token
+	file.writeBuf = getWriteBuffer()
+	return file, nil
 }
 
-// OpenFile opens a file.
+// OpenFile opens a file. With os.O_APPEND, the data written follows the
+// existing content of the file; objects of at least 5 MiB are copied within
+// S3 rather than downloaded, so that only the new data is uploaded.
 func (fs Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if err := fs.checkName("open", name); err != nil {
+		return (*File)(nil), err
+	}
+
+	start := fs.begin("OpenFile", name)
+
 	file := NewFile(fs.bucket, name, fs.s3API, fs)
 
-	if flag&os.O_APPEND != 0 {
-		lgr("OpenFile %s %q append disallowed\n", fs.bucket, name)
-		return file, errors.New("S3 is eventually consistent. Appending files will lead to trouble")
+	if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+		if _, err := fs.lstat(name); err == nil {
+			err = &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+			fs.logOp("OpenFile", name, start, err, "flag", flag)
+			return file, err
+		}
+		file.exclusive = true
 	}
 
 	if flag&os.O_CREATE != 0 {
+		file.created = true
 		// write some empty content, forcing the file to
 		// be created upon Close.
 		if _, err := file.WriteString(""); err != nil {
-			lgr("OpenFile %s %q > %+v\n", fs.bucket, name, err)
+			err = pathError("open", name, err)
+			fs.logOp("OpenFile", name, start, err, "flag", flag)
+			return file, err
+		}
+	}
+
+	if flag&os.O_APPEND != 0 {
+		if err := file.openForAppend(flag&os.O_CREATE != 0); err != nil {
+			err = pathError("open", name, err)
+			fs.logOp("OpenFile", name, start, err, "flag", flag)
 			return file, err
 		}
 	}
 
-	lgr("OpenFile %s %q\n", fs.bucket, name)
+	fs.logOp("OpenFile", name, start, nil, "flag", flag)
 	return file, nil
 }
 
-// Remove a file.
+// Remove a file. A symbolic link is removed, not its target.
 func (fs Fs) Remove(name string) error {
-	if _, err := fs.Stat(name); err != nil {
+	if _, err := fs.lstat(name); err != nil {
 		return err
 	}
 	return fs.doForceRemove(name, "Remove")
@@ -156,53 +773,69 @@ func (fs Fs) ForceRemove(name string) error {
 
 // ForceRemove doesn't error if a file does not exist.
 func (fs Fs) doForceRemove(name, info string) error {
-	_, err := fs.s3API.DeleteObjectWithContext(fs.ctx, &s3.DeleteObjectInput{
+	if err := fs.checkName("remove", name); err != nil {
+		return err
+	}
+
+	start := fs.begin(info, name)
+
+	ctx, cancel := withTimeout(fs.ctx, fs.timeouts.head)
+	defer cancel()
+
+	_, err := fs.s3API.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(fs.bucket),
-		Key:    aws.String(name),
+		Key:    aws.String(fs.key(name)),
 	})
+	fs.forget(name)
 
 	if err != nil {
-		lgr("%s %s %q > %+v\n", info, fs.bucket, name, err)
+		err = pathError("remove", name, err)
+		fs.logOp(info, name, start, err)
 		return err
 	}
 
-	lgr("%s %s %q\n", info, fs.bucket, name)
+	fs.logOp(info, name, start, nil)
+	fs.audit(AuditRecord{Op: "remove", Path: name})
 	return nil
 }
 
 // RemoveAll removes a path.
 func (fs Fs) RemoveAll(name string) error {
+	start := fs.begin("RemoveAll", name)
 	fis, err := fs.ListObjects(name, 0, false)
 	if err != nil {
-		lgr("RemoveAll %s Readdir %q > %+v\n", fs.bucket, name, err)
+		err = pathError("removeall", name, err)
+		fs.logOp("RemoveAll", name, start, err)
 		return err
 	}
 
+	defer fs.forgetAll(name)
+
 	dirs, files := fis.SortByDeepestFirst().Partition(func(info FileInfo) bool {
 		return info.IsDir()
 	})
 
 	for _, fi := range files {
 		if err := fs.ForceRemove(fi.Path()); err != nil {
-			lgr("RemoveAll %s %q > %+v\n", fs.bucket, name, err)
+			fs.logOp("RemoveAll", name, start, err)
 			return err
 		}
 	}
 
 	for _, fi := range dirs {
 		if err := fs.ForceRemove(addTrailingSlash(fi.Path())); err != nil {
-			lgr("RemoveAll %s %q > %+v\n", fs.bucket, name, err)
+			fs.logOp("RemoveAll", name, start, err)
 			return err
 		}
 	}
 
 	// finally remove the "file" representing the directory
 	if err := fs.ForceRemove(name); err != nil {
-		lgr("RemoveAll %s %q > %+v\n", fs.bucket, name, err)
+		fs.logOp("RemoveAll", name, start, err)
 		return err
 	}
 
-	lgr("RemoveAll %s %q\n", fs.bucket, name)
+	fs.logOp("RemoveAll", name, start, nil, "count", len(fis))
 	return nil
 }
 
@@ -211,135 +844,299 @@ func (fs Fs) RemoveAll(name string) error {
 // will copy the file to an object with the new name and then delete
 // the original.
 func (fs Fs) Rename(oldname, newname string) error {
+	start := fs.begin("Rename", oldname)
 	if oldname == newname {
-		lgr("Rename %s %q %q (no-op)\n", fs.bucket, oldname, newname)
+		fs.logOp("Rename", oldname, start, nil, "newkey", fs.key(newname), "noop", true)
 		return nil
 	}
 
-	_, err := fs.s3API.CopyObjectWithContext(fs.ctx, &s3.CopyObjectInput{
+	if err := fs.checkName("rename", oldname); err != nil {
+		start.end(err)
+		return err
+	}
+	if err := fs.checkName("rename", newname); err != nil {
+		start.end(err)
+		return err
+	}
+
+	input := &s3.CopyObjectInput{
 		Bucket:               aws.String(fs.bucket),
-		CopySource:           aws.String(fs.bucket + oldname),
-		Key:                  aws.String(newname),
+		CopySource:           aws.String(copySource(fs.bucket, fs.key(oldname))),
+		Key:                  aws.String(fs.key(newname)),
 		ServerSideEncryption: aws.String("AES256"),
-	})
+	}
+	fs.writeOptionsFor(newname).applyToCopy(input)
+
+	ctx, cancel := withTimeout(fs.ctx, fs.timeouts.transfer)
+	output, err := fs.s3API.CopyObjectWithContext(ctx, input)
+	cancel()
+	fs.forget(newname)
 	if err != nil {
-		lgr("Rename %s copy %q %q > %+v\n", fs.bucket, oldname, newname, err)
+		err = &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: conditionOf(err)}
+		fs.logOp("Rename", oldname, start, err, "newkey", fs.key(newname), "stage", "copy")
 		return err
 	}
 
-	_, err = fs.s3API.DeleteObjectWithContext(fs.ctx, &s3.DeleteObjectInput{
+	ctx, cancel = withTimeout(fs.ctx, fs.timeouts.head)
+	_, err = fs.s3API.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(fs.bucket),
-		Key:    aws.String(oldname),
+		Key:    aws.String(fs.key(oldname)),
 	})
+	cancel()
+	fs.forget(oldname)
 
 	if err != nil {
-		lgr("Rename %s %q %q > %+v\n", fs.bucket, oldname, newname, err)
+		err = &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: conditionOf(err)}
+		fs.logOp("Rename", oldname, start, err, "newkey", fs.key(newname), "stage", "delete")
 		return err
 	}
 
-	lgr("Rename %s %q %q\n", fs.bucket, oldname, newname)
+	fs.logOp("Rename", oldname, start, nil, "newkey", fs.key(newname), "version", aws.StringValue(output.VersionId))
+	record := AuditRecord{Op: "rename", Path: oldname, NewPath: newname}
+	if output.CopyObjectResult != nil {
+		record.ETag = aws.StringValue(output.CopyObjectResult.ETag)
+	}
+	fs.audit(record)
 	return nil
 }
 
-// Stat returns a FileInfo describing the named file.
+// Stat returns a FileInfo describing the named file. Symbolic links are
+// followed (see SymlinkIfPossible).
 // If there is an error, it will be of type *os.PathError.
 func (fs Fs) Stat(name string) (os.FileInfo, error) {
-	nameClean := path.Clean(name)
-	out, err := fs.s3API.HeadObjectWithContext(fs.ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(fs.bucket),
-		Key:    aws.String(nameClean),
-	})
+	_, fi, err := fs.followLinks(name)
+	return fi, err
+}
 
-	if err != nil {
-		if re, ok := err.(awserr.RequestFailure); ok && re.StatusCode() == 404 {
-			statDir, e2 := fs.statDirectory(name)
-			return statDir, e2
-		}
-		if ae, ok := err.(awserr.Error); ok && ae.Code() == s3.ErrCodeNoSuchKey {
-			statDir, e2 := fs.statDirectory(name)
-			return statDir, e2
-		}
-		lgr("Stat %s %q > %+v\n", fs.bucket, name, err)
-		return FileInfo{}, &os.PathError{
+// lstat returns a FileInfo describing the named file, without following
+// symbolic links.
+func (fs Fs) lstat(name string) (os.FileInfo, error) {
+	if err := fs.checkName("stat", name); err != nil {
+		return FileInfo{}, err
+	}
+
+	start := fs.begin("Stat", name)
+
+	if fi, ok := fs.statCache.get(fs.key(name)); ok && (fi.IsDir() || !hasTrailingSlash(name)) {
+		fs.logOp("Stat", name, start, nil, "cached", true)
+		return fi, nil
+	}
+
+	if fi, ok := fs.writeCache.get(fs.key(name)); ok && !hasTrailingSlash(name) {
+		fs.logOp("Stat", name, start, nil, "written", true)
+		return fi, nil
+	}
+
+	if _, missing := fs.missingCache.get(fs.key(name)); missing {
+		err := &os.PathError{
 			Op:   "stat",
 			Path: name,
-			Err:  err,
+			Err:  os.ErrNotExist,
 		}
+		fs.logOp("Stat", name, start, err, "cached", true)
+		return FileInfo{}, err
+	}
+
+	var fi FileInfo
+	var err error
+	if fs.statAttributes {
+		fi, err = fs.statObjectAttributes(name)
+	} else {
+		fi, err = fs.statObject(name)
+	}
+
+	if err != nil {
+		if isNotFound(err) {
+			statDir, e2 := fs.statDirectory(name, start)
+			return statDir, e2
+		}
+		err = pathError("stat", name, err)
+		fs.logOp("Stat", name, start, err)
+		return FileInfo{}, err
 	}
 
 	if hasTrailingSlash(name) {
 		// user asked for a directory, but this is a file
-		lgr("Stat %s %q is a file\n", fs.bucket, name)
-		return FileInfo{}, &os.PathError{
+		err = &os.PathError{
 			Op:   "stat",
 			Path: name,
 			Err:  os.ErrNotExist,
 		}
+		fs.logOp("Stat", name, start, err, "file", true)
+		return FileInfo{}, err
 	}
 
-	lgr("Stat %s %q\n", fs.bucket, name)
-	return NewFileInfo(name, *out.ContentLength, *out.LastModified), nil
+	fs.logOp("Stat", name, start, nil, "size", fi.Size())
+	fi = fs.applyDefaultPerm(fi)
+	fs.statCache.put(fs.key(name), fi)
+	return fi, nil
 }
 
-func (fs Fs) statDirectory(name string) (os.FileInfo, error) {
-	nameClean := path.Clean(name)
-	out, err := fs.s3API.ListObjectsV2WithContext(fs.ctx, &s3.ListObjectsV2Input{
+// statObject gets the file info for an object using HeadObject.
+func (fs Fs) statObject(name string) (FileInfo, error) {
+	ctx, cancel := withTimeout(fs.ctx, fs.timeouts.head)
+	defer cancel()
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.key(name)),
+	}
+	if fs.writeOpts.checksum != "" {
+		input.ChecksumMode = aws.String(s3.ChecksumModeEnabled)
+	}
+	out, err := fs.s3API.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	modTime := *out.LastModified
+	if mtime, ok := metadataTime(out.Metadata, metadataKeyMtime); ok {
+		// set by Chtimes or by other tools such as s3fs
+		modTime = mtime
+	}
+
+	fi := NewFileInfo(name, *out.ContentLength, modTime).withObjectInfo(objectInfoFromHead(out))
+	if mode, ok := metadataMode(out.Metadata, metadataKeyMode); ok {
+		fi = fi.withPerm(mode)
+	}
+	if metadataIsSymlink(out.Metadata, metadataKeyMode) {
+		target, err := fs.readLinkTarget(ctx, name)
+		if err != nil {
+			return FileInfo{}, err
+		}
+		fi.linkTarget = target
+	}
+	return fi, nil
+}
+
+// statObjectAttributes gets the file info for an object using GetObjectAttributes.
+// This doesn't provide the content type or user metadata.
+func (fs Fs) statObjectAttributes(name string) (FileInfo, error) {
+	ctx, cancel := withTimeout(fs.ctx, fs.timeouts.head)
+	defer cancel()
+
+	out, err := fs.s3API.GetObjectAttributesWithContext(ctx, &s3.GetObjectAttributesInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.key(name)),
+		ObjectAttributes: aws.StringSlice([]string{
+			s3.ObjectAttributesEtag,
+			s3.ObjectAttributesObjectSize,
+			s3.ObjectAttributesChecksum,
+		}),
+	})
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	fi := NewFileInfo(name, aws.Int64Value(out.ObjectSize), aws.TimeValue(out.LastModified))
+	return fi.withObjectInfo(objectInfoFromAttributes(out)), nil
+}
+
+func (fs Fs) statDirectory(name string, start operation) (os.FileInfo, error) {
+	// The dirCache holds directory info for directories that exist and
+	// blank info for those that don't.
+	key := fs.key(name)
+	if fi, ok := fs.dirCache.get(key); ok {
+		if !fi.IsDir() {
+			err := &os.PathError{
+				Op:   "stat",
+				Path: name,
+				Err:  os.ErrNotExist,
+			}
+			fs.logOp("Stat", name, start, err, "cached", true)
+			return FileInfo{}, err
+		}
+		fs.logOp("Stat", name, start, nil, "dir", true, "cached", true)
+		return fs.applyDefaultPerm(fi), nil
+	}
+
+	ctx, cancel := withTimeout(fs.ctx, fs.timeouts.list)
+	defer cancel()
+
+	out, err := fs.s3API.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
 		Bucket:  aws.String(fs.bucket),
-		Prefix:  aws.String(trimLeadingSlash(nameClean)),
+		Prefix:  aws.String(addTrailingSlash(key)),
 		MaxKeys: aws.Int64(1),
 	})
 
 	if err != nil {
-		lgr("Stat %s %q > os.PathError %+v\n", fs.bucket, name, err)
-		return FileInfo{}, &os.PathError{
-			Op:   "stat",
-			Path: name,
-			Err:  err,
-		}
+		err = pathError("stat", name, err)
+		fs.logOp("Stat", name, start, err)
+		return FileInfo{}, err
 	}
 
-	if *out.KeyCount == 0 && name != "" {
-		lgr("Stat %s %q > os.PathError os.ErrNotExist\n", fs.bucket, name)
-		return FileInfo{}, &os.PathError{
+	if *out.KeyCount == 0 && key != fs.keyPrefix {
+		// the root directory always exists, but anything else must have some content
+		fs.missingCache.put(key, FileInfo{})
+		fs.dirCache.put(key, FileInfo{})
+		err = &os.PathError{
 			Op:   "stat",
 			Path: name,
 			Err:  os.ErrNotExist,
 		}
+		fs.logOp("Stat", name, start, err)
+		return FileInfo{}, err
 	}
 
-	lgr("Stat %s %q is directory\n", fs.bucket, name)
-	return NewDirectoryInfo(name), nil
+	fs.logOp("Stat", name, start, nil, "dir", true)
+	fi := fs.applyDefaultPerm(NewDirectoryInfo(name))
+	fs.statCache.put(key, fi)
+	fs.dirCache.put(key, fi)
+	return fi, nil
 }
 
 // ListObjects gets a list of all the files in the bucket with a given prefix. No
 // more than 'max' results are returned, however 'max' is ignored if it is negative.
+// Any filters omit the entries for which they return false, as each page of
+// the listing arrives.
 //
 // This is an extension to the Afero Fs API.
-func (fs Fs) ListObjects(prefix string, max int, filesOnly bool) (FileInfoList, error) {
-	lister := Lister{
-		bucket:    fs.bucket,
-		name:      prefix,
-		delimiter: nil, // include sub-objects
-		s3Fs:      fs,
-		s3API:     fs.s3API,
-		ctx:       fs.ctx,
-	}
+func (fs Fs) ListObjects(prefix string, max int, filesOnly bool, filters ...Filter) (FileInfoList, error) {
+	start := fs.begin("ListObjects", prefix)
+	lister := fs.newLister(prefix, ListOptions{Recursive: true, IncludeOwner: true, Filters: filters})
 
-	return lister.ListObjects(max, filesOnly)
+	fis, err := lister.ListObjects(max, filesOnly)
+	err = pathError("list", prefix, err)
+	fs.logOp("ListObjects", prefix, start, err, "count", len(fis))
+	return fis, err
 }
 
+// Chmod changes the mode of a file. S3 has no file permissions, so instead
+// the mode is stored in the object's user metadata (x-amz-meta-mode, as used
+// by s3fs-fuse); Stat then reports it in FileInfo.Mode. This copies the
+// object onto itself. Directories can only be changed if they have a marker
+// object, e.g. as created by Mkdir.
 func (fs Fs) Chmod(name string, mode os.FileMode) error {
-	return syscall.EPERM
+	return fs.updateFileOrDirMetadata("chmod", name, func(metadata map[string]*string, dir bool) {
+		setMetadataValue(metadata, metadataKeyMode, formatMetadataMode(mode, dir))
+	})
 }
 
-func (fs Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
-	return syscall.EPERM
+// Chown changes the numeric uid and gid of a file. S3 has no file ownership,
+// so instead these are stored in the object's user metadata (x-amz-meta-uid
+// and x-amz-meta-gid, as used by s3fs-fuse); Stat then reports them via
+// the *ObjectInfo returned by FileInfo.Sys. A uid or gid of -1 means not to
+// change that value. Like Chmod, this copies the object onto itself.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Chown(name string, uid, gid int) error {
+	return fs.updateFileOrDirMetadata("chown", name, func(metadata map[string]*string, dir bool) {
+		if uid >= 0 {
+			setMetadataValue(metadata, metadataKeyUid, strconv.Itoa(uid))
+		}
+		if gid >= 0 {
+			setMetadataValue(metadata, metadataKeyGid, strconv.Itoa(gid))
+		}
+	})
 }
 
-// SetLogger sets a debug logger for observing S3 accesses. This is
-// compatible with 'log.Printf'. The default value is a no-op function.
-func SetLogger(fn func(format string, v ...interface{})) {
-	lgr = fn
+// Chtimes changes the modification time of a file. S3 does not allow the
+// LastModified time to be altered, so instead the time is stored in the
+// object's user metadata (x-amz-meta-mtime, as used by s3fs-fuse); Stat
+// then reports it in preference to LastModified. This copies the object
+// onto itself, so the access time is ignored.
+func (fs Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.updateMetadata("chtimes", name, func(metadata map[string]*string) {
+		setMetadataValue(metadata, metadataKeyMtime, formatMetadataTime(mtime))
+	})
 }
-
-var lgr = func(format string, v ...interface{}) {}
@@ -47,9 +47,8 @@ func TestS3Operations(t *testing.T) {
 		})
 		g.Expect(err).NotTo(HaveOccurred())
 
-		remote := NewFs(bucket, s3.New(sess))
 		// could populate this from /etc/mime.types
-		remote.AddMimeTypes(map[string]string{
+		remote := NewFs(bucket, s3.New(sess)).AddMimeTypes(map[string]string{
 			"txt": "text/plain",
 		})
 
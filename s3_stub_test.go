@@ -3,17 +3,24 @@ package s3
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gstruct"
+	"github.com/rickb777/afero-s3/internal/s3fake"
 	"github.com/spf13/afero"
 )
 
@@ -44,6 +51,73 @@ func TestWithContext(t *testing.T) {
 	g.Expect(file.ctx).To(Equal(c2))
 }
 
+func TestAddMimeTypes(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs0 := NewFs("mybucket", nil).AddMimeTypes(map[string]string{".txt": "text/plain"})
+	fs1 := fs0.AddMimeTypes(map[string]string{"json": "application/json"})
+	g.Expect(fs0.mimeTypes).To(Equal(map[string]string{"txt": "text/plain"}))
+	g.Expect(fs1.mimeTypes).To(Equal(map[string]string{"txt": "text/plain", "json": "application/json"}))
+
+	// instances can be made concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fs0.AddMimeTypes(map[string]string{fmt.Sprint(i): "x"})
+		}(i)
+	}
+	wg.Wait()
+	g.Expect(fs0.mimeTypes).To(HaveLen(1))
+}
+
+func TestKey(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", nil)
+	g.Expect(fs.key("/a/b/c.txt")).To(Equal("a/b/c.txt"))
+	g.Expect(fs.key("a/b/c.txt")).To(Equal("a/b/c.txt"))
+	g.Expect(fs.key("/a/./b//c.txt")).To(Equal("a/b/c.txt"))
+	g.Expect(fs.key("/a/b/")).To(Equal("a/b/"))
+	g.Expect(fs.key("/")).To(Equal(""))
+	g.Expect(fs.key("")).To(Equal(""))
+	g.Expect(fs.pathOf("a/b/c.txt")).To(Equal("/a/b/c.txt"))
+
+	fs = fs.WithKeyPrefix("/data/2020")
+	g.Expect(fs.key("/a/b/c.txt")).To(Equal("data/2020/a/b/c.txt"))
+	g.Expect(fs.key("/../c.txt")).To(Equal("data/2020/c.txt"))
+	g.Expect(fs.key("/")).To(Equal("data/2020/"))
+	g.Expect(fs.pathOf("data/2020/a/b/c.txt")).To(Equal("/a/b/c.txt"))
+}
+
+func TestKeyValidation(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub)
+
+	_, err := fs.Stat("/a/b\x01.txt")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.(*os.PathError).Err).To(Equal(&InvalidKeyError{Key: "a/b\x01.txt", Reason: "contains a control character"}))
+	g.Expect(stub.headKey).To(BeNil())
+
+	_, err = fs.Stat("/" + strings.Repeat("x", MaxKeyLength+1))
+	g.Expect(err.(*os.PathError).Err.(*InvalidKeyError).Reason).To(Equal("longer than 1024 bytes"))
+
+	_, err = fs.Stat("/a\\b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = fs.WithKeyValidation(KeyStrict).Stat("/a\\b.txt")
+	g.Expect(err.(*os.PathError).Err.(*InvalidKeyError).Reason).To(Equal("contains a backslash"))
+
+	fs = fs.WithKeyValidation(KeySanitize)
+	g.Expect(fs.key("/a\\b\x01\xff.txt")).To(Equal("a/b__.txt"))
+	_, err = fs.Stat("/a\\b\x01.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.headKey).To(gstruct.PointTo(Equal("a/b_.txt")))
+}
+
 func TestReadAFile(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -53,11 +127,13 @@ func TestReadAFile(t *testing.T) {
 
 	f, err := fs.Open("/a/b/c.png")
 	g.Expect(err).NotTo(HaveOccurred())
-	g.Expect(stub.headKey).To(gstruct.PointTo(Equal("/a/b/c.png")))
+	g.Expect(stub.headKey).To(gstruct.PointTo(Equal("a/b/c.png")))
+	g.Expect(f.(*File).ETag()).To(Equal(`"abc123"`))
 
 	_, err = io.Copy(ioutil.Discard, f)
 	g.Expect(err).NotTo(HaveOccurred())
-	g.Expect(stub.getKey).To(gstruct.PointTo(Equal("/a/b/c.png")))
+	g.Expect(stub.getKey).To(gstruct.PointTo(Equal("a/b/c.png")))
+	g.Expect(f.(*File).ETag()).To(Equal(`"def456"`))
 
 	err = f.Close()
 	g.Expect(err).NotTo(HaveOccurred())
@@ -73,58 +149,914 @@ func TestWriteABigFile(t *testing.T) {
 
 	f, err := fs.Create("/a/b/c.png")
 	g.Expect(err).NotTo(HaveOccurred())
-	g.Expect(stub.headKey).To(gstruct.PointTo(Equal("/a/b/c.png")))
+	g.Expect(stub.headKey).To(gstruct.PointTo(Equal("a/b/c.png")))
 
 	_, err = io.Copy(f, buf)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	err = f.Close()
 	g.Expect(err).NotTo(HaveOccurred())
-	g.Expect(stub.putKey).To(gstruct.PointTo(Equal("/a/b/c.png")))
+	g.Expect(stub.putKey).To(gstruct.PointTo(Equal("a/b/c.png")))
+	g.Expect(f.(*File).ETag()).To(Equal(`"ghi789"`))
+}
+
+func TestErrorsArePathErrors(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	failure := awserr.New("InternalError", "We encountered an internal error. Please try again.", nil)
+	stub := &s3stub{buf: &bytes.Buffer{}, failure: failure}
+	fs := NewFs("mybucket", stub)
+
+	err := fs.Remove("/a/b/c.png")
+	g.Expect(err).To(Equal(&os.PathError{Op: "remove", Path: "/a/b/c.png", Err: failure}))
+
+	err = fs.Rename("/a/b/c.png", "/a/b/d.png")
+	g.Expect(err).To(Equal(&os.LinkError{Op: "rename", Old: "/a/b/c.png", New: "/a/b/d.png", Err: failure}))
+
+	err = fs.Mkdir("/a/b", 0755)
+	g.Expect(err).To(Equal(&os.PathError{Op: "write", Path: "/a/b/", Err: failure}))
+
+	_, err = fs.ListObjects("/a", 0, false)
+	g.Expect(err).To(Equal(&os.PathError{Op: "list", Path: "/a", Err: failure}))
+
+	f, err := fs.Open("/a/b/c.png")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = f.Read(make([]byte, 10))
+	g.Expect(err).To(Equal(&os.PathError{Op: "read", Path: "/a/b/c.png", Err: failure}))
+
+	_, err = f.Readdir(0)
+	g.Expect(err).To(Equal(&os.PathError{Op: "readdir", Path: "/a/b/c.png", Err: failure}))
+
+	_, err = f.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+	err = f.Close()
+	g.Expect(err).To(Equal(&os.PathError{Op: "write", Path: "/a/b/c.png", Err: failure}))
+}
+
+func TestErrorsMapToOsErrors(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub)
+
+	f, err := fs.Open("/a/b/c.png")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	stub.failure = awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil)
+	_, err = f.Read(make([]byte, 10))
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+
+	stub.failure = awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, "")
+	err = fs.Remove("/a/b/c.png")
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+	g.Expect(errors.Is(err, os.ErrPermission)).To(BeTrue())
+
+	_, err = f.Readdir(0)
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+
+	_, err = f.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+	err = f.Close()
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+}
+
+func TestConditionErrors(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub)
+
+	stub.failure = awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchBucket, "The specified bucket does not exist", nil), 404, "")
+	_, err := fs.ListObjects("/a", 0, false)
+	g.Expect(errors.Is(err, ErrBucketNotFound)).To(BeTrue())
+	g.Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+	g.Expect(errors.Is(err, ErrObjectNotFound)).To(BeTrue())
+
+	f, err := fs.Open("/a/b/c.png")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	stub.failure = awserr.NewRequestFailure(awserr.New(s3.ErrCodeInvalidObjectState, "The operation is not valid for the object's storage class", nil), 403, "")
+	_, err = f.Read(make([]byte, 10))
+	g.Expect(errors.Is(err, ErrObjectArchived)).To(BeTrue())
+	g.Expect(errors.Is(err, ErrAccessDenied)).To(BeFalse())
+
+	stub.failure = awserr.NewRequestFailure(awserr.New("PreconditionFailed", "At least one of the pre-conditions you specified did not hold", nil), 412, "")
+	err = fs.Rename("/a/b/c.png", "/a/b/d.png")
+	g.Expect(errors.Is(err, ErrPreconditionFailed)).To(BeTrue())
+}
+
+func TestConditionalRead(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	g.Expect(afero.WriteFile(fs, "/a/b.txt", []byte("hello"), 0644)).To(Succeed())
+
+	f, err := fs.Open("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	etag := f.(*File).ETag()
+
+	_, err = f.(*File).WithIfNoneMatch(etag).Read(make([]byte, 10))
+	g.Expect(errors.Is(err, ErrNotModified)).To(BeTrue())
+	g.Expect(err.(*os.PathError).Op).To(Equal("read"))
+
+	_, err = f.(*File).WithIfModifiedSince(time.Now().Add(time.Hour)).Read(make([]byte, 10))
+	g.Expect(errors.Is(err, ErrNotModified)).To(BeTrue())
+
+	g.Expect(afero.WriteFile(fs, "/a/b.txt", []byte("changed"), 0644)).To(Succeed())
+	b, err := ioutil.ReadAll(f.(*File).WithIfNoneMatch(etag))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("changed"))
+}
+
+func TestRetry(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+	fs := NewFs("mybucket", stub).WithRetry(policy)
+
+	slowDown := awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), 503, "")
+	stub.failure, stub.failures = slowDown, 2
+	f, err := fs.Create("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+	err = f.Close()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.putBodies).To(Equal([]string{"hello", "hello", "hello"}))
+
+	stub.failure, stub.failures = slowDown, 3
+	err = fs.Remove("/a/b/c.txt")
+	g.Expect(errors.Is(err, slowDown)).To(BeTrue())
+
+	stub.failure, stub.failures = awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, ""), 1
+	err = fs.Remove("/a/b/c.txt")
+	g.Expect(os.IsPermission(err)).To(BeTrue())
+	g.Expect(stub.failure).To(BeNil())
+
+	// retrying can be disabled again
+	stub.failure, stub.failures = slowDown, 1
+	err = fs.WithRetry(RetryPolicy{}).Remove("/a/b/c.txt")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	p := RetryPolicy{BaseDelay: time.Second, ThrottleDelay: 5 * time.Second, MaxDelay: 30 * time.Second}
+	g.Expect(p.delay(0, false)).To(Equal(time.Second))
+	g.Expect(p.delay(2, false)).To(Equal(4 * time.Second))
+	g.Expect(p.delay(0, true)).To(Equal(5 * time.Second))
+	g.Expect(p.delay(9, true)).To(Equal(30 * time.Second))
+
+	p.Jitter = true
+	g.Expect(p.delay(1, false)).To(BeNumerically(">=", time.Second))
+	g.Expect(p.delay(1, false)).To(BeNumerically("<=", 2*time.Second))
+}
+
+func TestTimeouts(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: bytes.NewBufferString("hello")}
+	fs := NewFs("mybucket", stub).
+		WithHeadTimeout(time.Second).
+		WithListTimeout(time.Minute).
+		WithTransferTimeout(time.Hour)
+
+	f, err := fs.Open("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.deadlines["head"]).To(BeNumerically("~", time.Second, time.Second/2))
+
+	_, err = ioutil.ReadAll(f)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.deadlines["get"]).To(BeNumerically("~", time.Hour, time.Minute))
+
+	_, err = f.Readdir(0)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.deadlines["list"]).To(BeNumerically("~", time.Minute, time.Second))
+
+	err = f.Close()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = fs.Remove("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.deadlines["delete"]).To(BeNumerically("~", time.Second, time.Second/2))
+
+	err = NewFs("mybucket", stub).Remove("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.deadlines["delete"]).To(BeZero())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub).WithCircuitBreaker(2, time.Minute)
+	now := time.Now()
+	fs.breaker.now = func() time.Time { return now }
+
+	unavailable := awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "Service Unavailable", nil), 503, "")
+	stub.failure = unavailable
+	g.Expect(fs.ForceRemove("/a/b/c.txt")).To(HaveOccurred())
+	g.Expect(fs.ForceRemove("/a/b/c.txt")).To(HaveOccurred())
+
+	// open: S3 is not called
+	stub.failure = nil
+	stub.deleteKey = nil
+	err := fs.ForceRemove("/a/b/c.txt")
+	g.Expect(errors.Is(err, ErrCircuitOpen)).To(BeTrue())
+	g.Expect(stub.deleteKey).To(BeNil())
+
+	// half-open: the trial request fails
+	now = now.Add(time.Minute + time.Second)
+	stub.failure = unavailable
+	g.Expect(errors.Is(fs.ForceRemove("/a/b/c.txt"), unavailable)).To(BeTrue())
+	g.Expect(errors.Is(fs.ForceRemove("/a/b/c.txt"), ErrCircuitOpen)).To(BeTrue())
+
+	// half-open: the trial request succeeds
+	now = now.Add(time.Minute + time.Second)
+	stub.failure = nil
+	g.Expect(fs.ForceRemove("/a/b/c.txt")).NotTo(HaveOccurred())
+	g.Expect(fs.ForceRemove("/a/b/c.txt")).NotTo(HaveOccurred())
+
+	// errors that don't suggest an outage are ignored
+	stub.failure = awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, "")
+	for i := 0; i < 3; i++ {
+		g.Expect(os.IsPermission(fs.ForceRemove("/a/b/c.txt"))).To(BeTrue())
+	}
+}
+
+func TestHooks(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	var calls []string
+	var after *Request
+	fs := NewFs("mybucket", stub).
+		WithKeyPrefix("app").
+		WithHook(HookFuncs{
+			BeforeFunc: func(r *Request) {
+				calls = append(calls, "1:"+r.Op+" "+r.Key)
+				if in, ok := r.Input.(*s3.PutObjectInput); ok {
+					in.CacheControl = aws.String("no-cache")
+				}
+			},
+			AfterFunc: func(r *Request) {
+				calls = append(calls, "1:done")
+				after = r
+			},
+		}).
+		WithHook(HookFuncs{
+			BeforeFunc: func(r *Request) {
+				calls = append(calls, "2:"+r.Op)
+				if r.Op == "HeadObject" {
+					// served from a cache
+					r.Output = &s3.HeadObjectOutput{ContentLength: aws.Int64(5), LastModified: aws.Time(time.Now())}
+				}
+			},
+		})
+
+	fi, err := fs.Stat("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Size()).To(Equal(int64(5)))
+	g.Expect(stub.headCount).To(Equal(0))
+	g.Expect(calls).To(Equal([]string{"1:HeadObject app/a/b.txt", "2:HeadObject", "1:done"}))
+
+	calls = nil
+	stub.failure = awserr.New("InternalError", "We encountered an internal error. Please try again.", nil)
+	err = afero.WriteFile(fs, "/a/b.txt", []byte("hello"), 0644)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(calls).To(ContainElement("1:PutObject app/a/b.txt"))
+	g.Expect(stub.putInput.CacheControl).To(gstruct.PointTo(Equal("no-cache")))
+	g.Expect(after.Op).To(Equal("PutObject"))
+	g.Expect(after.Bucket).To(Equal("mybucket"))
+	g.Expect(after.Err).To(Equal(stub.failure))
+}
+
+func TestLogger(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	type entry struct {
+		level   LogLevel
+		msg     string
+		keyvals map[string]interface{}
+	}
+	var entries []entry
+	logger := LoggerFunc(func(level LogLevel, msg string, keyvals ...interface{}) {
+		e := entry{level: level, msg: msg, keyvals: make(map[string]interface{})}
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			e.keyvals[keyvals[i].(string)] = keyvals[i+1]
+		}
+		entries = append(entries, e)
+	})
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub).WithLogger(logger)
+	other := NewFs("otherbucket", stub)
+
+	_, err := fs.Stat("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = other.Stat("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(entries).To(HaveLen(1))
+	g.Expect(entries[0].level).To(Equal(LevelDebug))
+	g.Expect(entries[0].msg).To(Equal("Stat"))
+	g.Expect(entries[0].keyvals).To(HaveKeyWithValue("bucket", "mybucket"))
+	g.Expect(entries[0].keyvals).To(HaveKeyWithValue("key", "a/b.txt"))
+	g.Expect(entries[0].keyvals).To(HaveKeyWithValue("op", "Stat"))
+	g.Expect(entries[0].keyvals).To(HaveKey("latency"))
+
+	entries = nil
+	stub.failure = awserr.New("InternalError", "We encountered an internal error. Please try again.", nil)
+	err = fs.ForceRemove("/a/b.txt")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(entries).To(HaveLen(1))
+	g.Expect(entries[0].level).To(Equal(LevelError))
+	g.Expect(entries[0].keyvals).To(HaveKeyWithValue("error", err))
+}
+
+func TestStats(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: bytes.NewBufferString("hello world")}
+	fs := NewFs("mybucket", stub)
+	derived := fs.WithRetry(RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond})
+
+	_, err := afero.ReadFile(fs, "/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	stub.failure, stub.failures = awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), 503, ""), 1
+	err = afero.WriteFile(derived, "/a/c.txt", []byte("hello"), 0644)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	stats := derived.Stats()
+	g.Expect(stats).To(Equal(fs.Stats()))
+	g.Expect(stats.Get).To(Equal(int64(1)))
+	g.Expect(stats.Put).To(Equal(int64(2)))
+	g.Expect(stats.BytesDown).To(Equal(int64(11)))
+	g.Expect(stats.BytesUp).To(Equal(int64(10)))
+	g.Expect(stats.Head).To(BeNumerically(">=", 1))
+	g.Expect(stats.Requests()).To(Equal(stats.Get + stats.Put + stats.Head + stats.List))
+
+	cost := Stats{Put: 1000, Get: 1000, Delete: 1000, BytesDown: 1 << 30}.Cost(Pricing{PerThousandWrites: 5, PerThousandReads: 0.4, PerGBDownloaded: 90})
+	g.Expect(cost).To(BeNumerically("~", 95.4, 1e-9))
+}
+
+func TestProgress(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	type report struct {
+		name               string
+		transferred, total int64
+	}
+	var reports []report
+	stub := &s3stub{buf: bytes.NewBufferString("hello world")}
+	fs := NewFs("mybucket", stub).WithProgress(func(name string, transferred, total int64) {
+		reports = append(reports, report{name, transferred, total})
+	})
+
+	f, err := fs.Open("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = io.Copy(ioutil.Discard, iotest.OneByteReader(f))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reports).To(HaveLen(11))
+	g.Expect(reports[0]).To(Equal(report{"/a/b.txt", 1, 123}))
+	g.Expect(reports[10]).To(Equal(report{"/a/b.txt", 11, 123}))
+
+	reports = nil
+	err = afero.WriteFile(fs, "/a/c.txt", []byte("hello"), 0644)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reports).To(Equal([]report{{"/a/c.txt", 5, 5}}))
+}
+
+func TestWriteWithACL(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub).WithACL(s3.ObjectCannedACLBucketOwnerFullControl)
+
+	f, err := fs.Create("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = f.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = f.Close()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.putInput.ACL).To(gstruct.PointTo(Equal("bucket-owner-full-control")))
+
+	f2 := NewFile("mybucket", "/a/b/d.txt", stub, *fs).WithACL(s3.ObjectCannedACLPublicRead)
+	_, err = f2.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = f2.Close()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.putInput.ACL).To(gstruct.PointTo(Equal("public-read")))
+}
+
+func TestWriteWithObjectLock(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	until := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub).
+		WithObjectLock(s3.ObjectLockModeCompliance, until).
+		WithLegalHold(true)
+
+	f := NewFile("mybucket", "/a/b/c.txt", stub, *fs)
+	_, err := f.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = f.Close()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.putInput.ObjectLockMode).To(gstruct.PointTo(Equal("COMPLIANCE")))
+	g.Expect(stub.putInput.ObjectLockRetainUntilDate).To(gstruct.PointTo(Equal(until)))
+	g.Expect(stub.putInput.ObjectLockLegalHoldStatus).To(gstruct.PointTo(Equal("ON")))
+
+	f = NewFile("mybucket", "/a/b/d.txt", stub, *fs).WithObjectLock("", time.Time{}).WithLegalHold(false)
+	_, err = f.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = f.Close()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.putInput.ObjectLockMode).To(BeNil())
+	g.Expect(stub.putInput.ObjectLockRetainUntilDate).To(BeNil())
+	g.Expect(stub.putInput.ObjectLockLegalHoldStatus).To(BeNil())
+}
+
+func TestStatProvidesObjectInfo(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub)
+
+	fi, err := fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.(FileInfo).ETag()).To(Equal(`"abc123"`))
+	g.Expect(fi.(FileInfo).ContentType()).To(Equal("text/plain"))
+	g.Expect(fi.Sys()).To(Equal(&ObjectInfo{
+		ETag:                 `"abc123"`,
+		ContentType:          "text/plain",
+		StorageClass:         "STANDARD",
+		VersionId:            "v1",
+		ServerSideEncryption: "AES256",
+		Uid:                  -1,
+		Gid:                  -1,
+	}))
+}
+
+func TestStatUsingAttributes(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub).WithStatUsingAttributes(true)
+
+	fi, err := fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.headKey).To(BeNil())
+	g.Expect(stub.attributesInput.ObjectAttributes).To(ConsistOf(
+		gstruct.PointTo(Equal("ETag")), gstruct.PointTo(Equal("ObjectSize")), gstruct.PointTo(Equal("Checksum"))))
+	g.Expect(fi.Size()).To(Equal(int64(456)))
+	g.Expect(fi.Sys().(*ObjectInfo).ChecksumSHA256).To(Equal("c2hhMjU2"))
+}
+
+func TestStatCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub).WithStatCache(time.Minute, 100)
+
+	_, err := fs.Open("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.headCount).To(Equal(1))
+
+	_, err = fs.WithContext(context.Background()).Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.headCount).To(Equal(1))
+
+	err = fs.Chtimes("/a/b/c.txt", time.Now(), time.Now())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.headCount).To(Equal(2))
+
+	_, err = fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.headCount).To(Equal(3))
+}
+
+func TestNotFoundCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}, missing: true}
+	fs := NewFs("mybucket", stub).WithNotFoundCache(time.Minute, 100)
+
+	_, err := fs.Stat("/a/b/c.txt")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(stub.headCount).To(Equal(1))
+	g.Expect(stub.listCount).To(Equal(1))
+
+	_, err = fs.Stat("/a/b/c.txt")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(stub.headCount).To(Equal(1))
+	g.Expect(stub.listCount).To(Equal(1))
+
+	f, err := fs.OpenFile("/a/b/c.txt", os.O_CREATE, 0644)
+	g.Expect(err).NotTo(HaveOccurred())
+	err = f.Close()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = fs.Stat("/a/b/c.txt")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(stub.headCount).To(Equal(2))
+	g.Expect(stub.listCount).To(Equal(2))
+}
+
+func TestDirCache(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}, missing: true}
+	fs := NewFs("mybucket", stub).WithDirCache(time.Minute, 100)
+
+	_, err := fs.Stat("/a/b")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(stub.listCount).To(Equal(1))
+
+	_, err = fs.Stat("/a/b")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(stub.headCount).To(Equal(2))
+	g.Expect(stub.listCount).To(Equal(1))
+
+	fs.dirCache.put("a/b", NewDirectoryInfo("/a/b"))
+	fi, err := fs.Stat("/a/b")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.IsDir()).To(BeTrue())
+	g.Expect(stub.listCount).To(Equal(1))
+
+	err = fs.ForceRemove("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fs.dirCache.entries).To(BeEmpty())
+}
+
+func TestMkdirWithoutDirMarkers(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub).WithDirMarkers(false)
+
+	err := fs.MkdirAll("/a/b", 0755)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.putKey).To(BeNil())
+
+	fs = fs.WithDirMarkers(true)
+	err = fs.Mkdir("/a/b", 0755)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.putKey).To(gstruct.PointTo(Equal("a/b/")))
+}
+
+func TestStatDirectoryUsesPrefix(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}, missing: true}
+	fs := NewFs("mybucket", stub)
+
+	_, err := fs.Stat("/a/b")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(stub.listInput.Prefix).To(gstruct.PointTo(Equal("a/b/")))
+}
+
+func TestChtimes(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mtime := time.Date(2020, 2, 3, 4, 5, 6, 789, time.UTC)
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub)
+
+	err := fs.Chtimes("/a/b/c.txt", time.Now(), mtime)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.copyInput.CopySource).To(gstruct.PointTo(Equal("mybucket/a/b/c.txt")))
+	g.Expect(stub.copyInput.Key).To(gstruct.PointTo(Equal("a/b/c.txt")))
+	g.Expect(stub.copyInput.MetadataDirective).To(gstruct.PointTo(Equal("REPLACE")))
+	g.Expect(stub.copyInput.ContentType).To(gstruct.PointTo(Equal("text/plain")))
+	g.Expect(stub.copyInput.Metadata).To(HaveKeyWithValue("mtime", gstruct.PointTo(Equal("1580702706.000000789"))))
+	g.Expect(stub.copyInput.Expires).To(gstruct.PointTo(Equal(time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC))))
+
+	stub.metadata = stub.copyInput.Metadata
+	fi, err := fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.ModTime().Equal(mtime)).To(BeTrue())
+}
+
+func TestChmod(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub)
+
+	fi, err := fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Mode()).To(Equal(os.FileMode(0664)))
+
+	err = fs.Chmod("/a/b/c.txt", 0600|os.ModeSetgid)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.copyInput.MetadataDirective).To(gstruct.PointTo(Equal("REPLACE")))
+	g.Expect(stub.copyInput.Metadata).To(HaveKeyWithValue("mode", gstruct.PointTo(Equal("34176"))))
+	g.Expect(stub.copyInput.Expires).To(gstruct.PointTo(Equal(time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC))))
+
+	stub.metadata = stub.copyInput.Metadata
+	fi, err = fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Mode()).To(Equal(0600 | os.ModeSetgid))
+
+	// a mode of zero is not replaced by the default
+	g.Expect(fs.Chmod("/a/b/c.txt", 0)).To(Succeed())
+	g.Expect(stub.copyInput.Metadata).To(HaveKeyWithValue("mode", gstruct.PointTo(Equal("32768"))))
+	stub.metadata = stub.copyInput.Metadata
+	for _, fs := range []*Fs{fs, fs.WithFileMode(0640)} {
+		fi, err = fs.Stat("/a/b/c.txt")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(fi.Mode()).To(Equal(os.FileMode(0)))
+	}
+}
+
+func TestFileModes(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(NewFileInfo("/a/b/c.txt", 1, time.Now()).Mode()).To(Equal(os.FileMode(0664)))
+	g.Expect(NewDirectoryInfo("/a/b/").Mode()).To(Equal(os.ModeDir | 0755))
+	g.Expect(NewDirectoryInfo("/a/b/").Mode().IsDir()).To(BeTrue())
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub).WithFileMode(0640).WithDirMode(0750)
+
+	fi, err := fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Mode()).To(Equal(os.FileMode(0640)))
+
+	g.Expect(fs.applyDefaultPerm(NewDirectoryInfo("/a/b/")).Mode()).To(Equal(os.ModeDir | 0750))
+}
+
+func TestChown(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: &bytes.Buffer{}}
+	fs := NewFs("mybucket", stub)
+
+	fi, err := fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Sys().(*ObjectInfo).Uid).To(Equal(-1))
+	g.Expect(fi.Sys().(*ObjectInfo).Gid).To(Equal(-1))
+
+	err = fs.Chown("/a/b/c.txt", 1000, -1)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stub.copyInput.Metadata).To(HaveKeyWithValue("uid", gstruct.PointTo(Equal("1000"))))
+	g.Expect(stub.copyInput.Metadata).NotTo(HaveKey("gid"))
+
+	stub.metadata = stub.copyInput.Metadata
+	fi, err = fs.Stat("/a/b/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Sys().(*ObjectInfo).Uid).To(Equal(1000))
+	g.Expect(fi.Sys().(*ObjectInfo).Gid).To(Equal(-1))
+}
+
+func TestMetadataTime(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cases := map[string]time.Time{
+		"1580702706":           time.Unix(1580702706, 0),
+		"1580702706.5":         time.Unix(1580702706, 500000000),
+		"1580702706.000000789": time.Unix(1580702706, 789),
+	}
+
+	for s, exp := range cases {
+		act, ok := metadataTime(map[string]*string{"Mtime": aws.String(s)}, metadataKeyMtime)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(act.Equal(exp)).To(BeTrue(), s)
+	}
+
+	_, ok := metadataTime(map[string]*string{"Mtime": aws.String("junk")}, metadataKeyMtime)
+	g.Expect(ok).To(BeFalse())
 }
 
 //-------------------------------------------------------------------------------------------------
 
 type s3stub struct {
-	buf     *bytes.Buffer
-	headKey *string
-	getKey  *string
-	putKey  *string
+	buf       *bytes.Buffer
+	headKey   *string
+	getKey    *string
+	putKey    *string
+	deleteKey *string
+	putInput  *s3.PutObjectInput
+	copyInput *s3.CopyObjectInput
+
+	attributesInput *s3.GetObjectAttributesInput
+	headCount       int
+	getCount        int
+	listCount       int
+	listInput       *s3.ListObjectsV2Input
+	missing         bool
+	metadata        map[string]*string
+	failure         error // returned by all requests except HeadObject
+	failures        int   // when non-zero, the number of requests that fail
+	putBodies       []string
+	deadlines       map[string]time.Duration // the time remaining in each request's context
+}
+
+func (s *s3stub) record(op string, ctx aws.Context) {
+	if s.deadlines == nil {
+		s.deadlines = make(map[string]time.Duration)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.deadlines[op] = time.Until(deadline)
+	} else {
+		s.deadlines[op] = 0
+	}
+}
+
+// fail returns the failure, if any, counting down the failures.
+func (s *s3stub) fail() error {
+	if s.failure == nil {
+		return nil
+	}
+	if s.failures > 0 {
+		s.failures--
+		if s.failures == 0 {
+			defer func() { s.failure = nil }()
+		}
+	}
+	return s.failure
 }
 
-func (*s3stub) CopyObjectWithContext(ctx aws.Context, req *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
-	panic("implement me")
+func (s *s3stub) AbortMultipartUploadWithContext(ctx aws.Context, req *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	s.record("delete", ctx)
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.AbortMultipartUploadOutput{}, nil
 }
 
-func (*s3stub) DeleteObjectWithContext(ctx aws.Context, req *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
-	panic("implement me")
+func (s *s3stub) CompleteMultipartUploadWithContext(ctx aws.Context, req *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	s.record("put", ctx)
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.CompleteMultipartUploadOutput{ETag: aws.String(`"ghi789-1"`)}, nil
+}
+
+func (s *s3stub) CopyObjectWithContext(ctx aws.Context, req *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	s.record("copy", ctx)
+	s.copyInput = req
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (s *s3stub) CreateBucketWithContext(ctx aws.Context, req *s3.CreateBucketInput, opts ...request.Option) (*s3.CreateBucketOutput, error) {
+	s.record("put", ctx)
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (s *s3stub) CreateMultipartUploadWithContext(ctx aws.Context, req *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	s.record("put", ctx)
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload1")}, nil
+}
+
+func (s *s3stub) DeleteObjectWithContext(ctx aws.Context, req *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	s.record("delete", ctx)
+	s.deleteKey = req.Key
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.DeleteObjectOutput{}, nil
 }
 
 func (s *s3stub) HeadObjectWithContext(ctx aws.Context, req *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	s.record("head", ctx)
 	s.headKey = req.Key
+	s.headCount++
+	if s.missing {
+		return nil, awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, "")
+	}
 	return &s3.HeadObjectOutput{
-		ContentLength: aws.Int64(123),
-		LastModified:  aws.Time(time.Now()),
+		ContentLength:        aws.Int64(123),
+		LastModified:         aws.Time(time.Now()),
+		ETag:                 aws.String(`"abc123"`),
+		ContentType:          aws.String("text/plain"),
+		Metadata:             s.metadata,
+		StorageClass:         aws.String(s3.StorageClassStandard),
+		VersionId:            aws.String("v1"),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+		Expires:              aws.String("Wed, 01 Jan 2031 00:00:00 GMT"),
+	}, nil
+}
+
+func (s *s3stub) GetObjectAttributesWithContext(ctx aws.Context, req *s3.GetObjectAttributesInput, opts ...request.Option) (*s3.GetObjectAttributesOutput, error) {
+	s.attributesInput = req
+	return &s3.GetObjectAttributesOutput{
+		ObjectSize:   aws.Int64(456),
+		LastModified: aws.Time(time.Now()),
+		ETag:         aws.String(`"abc123"`),
+		Checksum:     &s3.Checksum{ChecksumSHA256: aws.String("c2hhMjU2")},
 	}, nil
 }
 
 func (s *s3stub) GetObjectWithContext(ctx aws.Context, req *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	s.record("get", ctx)
 	s.getKey = req.Key
+	s.getCount++
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	if req.Range != nil {
+		return s.getRange(*req.Range)
+	}
 	return &s3.GetObjectOutput{
 		Body:          ioutil.NopCloser(s.buf),
 		ContentLength: aws.Int64(123),
 		LastModified:  aws.Time(time.Now()),
+		ETag:          aws.String(`"def456"`),
+	}, nil
+}
+
+// getRange serves part of the buffer, without consuming it.
+func (s *s3stub) getRange(byteRange string) (*s3.GetObjectOutput, error) {
+	first, last := 0, -1
+	fmt.Sscanf(byteRange, "bytes=%d-%d", &first, &last)
+	data := s.buf.Bytes()
+	if first >= len(data) {
+		return nil, awserr.NewRequestFailure(awserr.New("InvalidRange", "The requested range is not satisfiable", nil), 416, "")
+	}
+	if last < 0 || last >= len(data) {
+		last = len(data) - 1
+	}
+	return &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(bytes.NewReader(data[first : last+1])),
+		ContentLength: aws.Int64(int64(last + 1 - first)),
+		ContentRange:  aws.String(fmt.Sprintf("bytes %d-%d/%d", first, last, len(data))),
+		ETag:          aws.String(`"def456"`),
+	}, nil
+}
+
+func (s *s3stub) ListObjectsV2WithContext(ctx aws.Context, req *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	s.record("list", ctx)
+	s.listCount++
+	s.listInput = req
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.ListObjectsV2Output{
+		IsTruncated: aws.Bool(false),
+		KeyCount:    aws.Int64(0),
 	}, nil
 }
 
-func (*s3stub) ListObjectsV2WithContext(ctx aws.Context, req *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
-	panic("implement me")
+func (s *s3stub) ListMultipartUploadsWithContext(ctx aws.Context, req *s3.ListMultipartUploadsInput, opts ...request.Option) (*s3.ListMultipartUploadsOutput, error) {
+	s.record("list", ctx)
+	s.listCount++
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.ListMultipartUploadsOutput{IsTruncated: aws.Bool(false)}, nil
+}
+
+func (s *s3stub) ListObjectVersionsWithContext(ctx aws.Context, req *s3.ListObjectVersionsInput, opts ...request.Option) (*s3.ListObjectVersionsOutput, error) {
+	s.record("list", ctx)
+	s.listCount++
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.ListObjectVersionsOutput{IsTruncated: aws.Bool(false)}, nil
+}
+
+func (s *s3stub) ListPartsWithContext(ctx aws.Context, req *s3.ListPartsInput, opts ...request.Option) (*s3.ListPartsOutput, error) {
+	s.record("list", ctx)
+	s.listCount++
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.ListPartsOutput{IsTruncated: aws.Bool(false)}, nil
 }
 
 func (s *s3stub) PutObjectWithContext(ctx aws.Context, req *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	s.record("put", ctx)
 	s.putKey = req.Key
+	s.putInput = req
+	body, _ := ioutil.ReadAll(req.Body)
+	s.putBodies = append(s.putBodies, string(body))
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
 	return &s3.PutObjectOutput{
-		ETag:                 nil,
+		ETag:                 aws.String(`"ghi789"`),
 		Expiration:           nil,
 		RequestCharged:       nil,
 		SSECustomerAlgorithm: nil,
@@ -134,3 +1066,27 @@ func (s *s3stub) PutObjectWithContext(ctx aws.Context, req *s3.PutObjectInput, o
 		VersionId:            nil,
 	}, nil
 }
+
+func (s *s3stub) SelectObjectContentWithContext(ctx aws.Context, req *s3.SelectObjectContentInput, opts ...request.Option) (*s3.SelectObjectContentOutput, error) {
+	s.record("get", ctx)
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return nil, awserr.New("NotImplemented", "not implemented", nil)
+}
+
+func (s *s3stub) UploadPartWithContext(ctx aws.Context, req *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	s.record("put", ctx)
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.UploadPartOutput{ETag: aws.String(`"ghi789"`)}, nil
+}
+
+func (s *s3stub) UploadPartCopyWithContext(ctx aws.Context, req *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	s.record("copy", ctx)
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return &s3.UploadPartCopyOutput{CopyPartResult: &s3.CopyPartResult{ETag: aws.String(`"ghi789"`)}}, nil
+}
@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"errors"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var errSelectIncomplete = errors.New("the query results ended unexpectedly")
+
+// Select runs an S3 Select query on a CSV, JSON or Parquet file, so that only
+// the rows and columns it selects are downloaded, e.g.
+//
+//	fs.Select("/data.csv", "SELECT s.name FROM S3Object s WHERE s.size > 100",
+//		&s3.InputSerialization{CSV: &s3.CSVInput{FileHeaderInfo: aws.String(s3.FileHeaderInfoUse)}},
+//		&s3.OutputSerialization{CSV: &s3.CSVOutput{}})
+//
+// The query is SQL, as documented for S3 Select. The formats of the file and
+// of the results are described by the input and output serialization. The
+// results are streamed as they arrive, from the reader returned, which must
+// be closed. If the stream ends before S3 has sent all the results, reading
+// fails rather than returning io.EOF.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Select(name, query string, input *s3.InputSerialization, output *s3.OutputSerialization) (io.ReadCloser, error) {
+	if err := fs.checkName("select", name); err != nil {
+		return nil, err
+	}
+
+	ctx, start := fs.beginWithContext(fs.ctx, "Select", name)
+	ctx, cancel := withTimeout(ctx, fs.timeouts.transfer)
+	out, err := fs.s3API.SelectObjectContentWithContext(ctx, &s3.SelectObjectContentInput{
+		Bucket:              aws.String(fs.bucket),
+		Key:                 aws.String(fs.key(name)),
+		Expression:          aws.String(query),
+		ExpressionType:      aws.String(s3.ExpressionTypeSql),
+		InputSerialization:  input,
+		OutputSerialization: output,
+	})
+	if err != nil {
+		cancel()
+		err = pathError("select", name, err)
+		fs.logOp("Select", name, start, err)
+		return nil, err
+	}
+
+	fs.logOp("Select", name, start, nil)
+	return cancelOnClose{ReadCloser: &selectReader{stream: out.EventStream}, cancel: cancel}, nil
+}
+
+// Select runs an S3 Select query on the file; see Fs.Select.
+//
+// This is an extension to the Afero File API.
+func (f *File) Select(query string, input *s3.InputSerialization, output *s3.OutputSerialization) (io.ReadCloser, error) {
+	return f.s3Fs.WithContext(f.ctx).Select(f.name, query, input, output)
+}
+
+// selectReader reads the records from the event stream of an S3 Select query.
+type selectReader struct {
+	stream  *s3.SelectObjectContentEventStream
+	pending []byte
+	ended   bool
+}
+
+func (r *selectReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.ended {
+			return 0, io.EOF
+		}
+
+		event, ok := <-r.stream.Events()
+		if !ok {
+			if err := r.stream.Err(); err != nil {
+				return 0, err
+			}
+			return 0, errSelectIncomplete
+		}
+
+		switch e := event.(type) {
+		case *s3.RecordsEvent:
+			r.pending = e.Payload
+		case *s3.EndEvent:
+			r.ended = true
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *selectReader) Close() error {
+	return r.stream.Close()
+}
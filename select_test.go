@@ -0,0 +1,79 @@
+package s3
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+var (
+	csvInput  = &s3.InputSerialization{CSV: &s3.CSVInput{FileHeaderInfo: aws.String(s3.FileHeaderInfoUse)}}
+	csvOutput = &s3.OutputSerialization{CSV: &s3.CSVOutput{}}
+)
+
+func TestSelect(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	g.Expect(afero.WriteFile(fs, "/a.csv", []byte("name,size\na,1\nb,2\n"), 0644)).To(Succeed())
+
+	rc, err := fs.Select("/a.csv", "SELECT * FROM S3Object", csvInput, csvOutput)
+	g.Expect(err).NotTo(HaveOccurred())
+	data, err := ioutil.ReadAll(rc)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("name,size\na,1\nb,2\n"))
+	g.Expect(rc.Close()).To(Succeed())
+
+	af, err := fs.Open("/a.csv")
+	g.Expect(err).NotTo(HaveOccurred())
+	rc, err = af.(*File).Select("SELECT * FROM S3Object", csvInput, csvOutput)
+	g.Expect(err).NotTo(HaveOccurred())
+	data, err = ioutil.ReadAll(rc)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(HaveLen(18))
+
+	_, err = fs.Select("/missing.csv", "SELECT * FROM S3Object", csvInput, csvOutput)
+	g.Expect(err).To(HaveOccurred())
+}
+
+// truncatingBucket ends the results of every query without an end event.
+type truncatingBucket struct {
+	*s3fake.Bucket
+}
+
+func (b truncatingBucket) SelectObjectContentWithContext(ctx aws.Context, req *s3.SelectObjectContentInput, opts ...request.Option) (*s3.SelectObjectContentOutput, error) {
+	events := make(chan s3.SelectObjectContentEventStreamEvent, 1)
+	events <- &s3.RecordsEvent{Payload: []byte("a,1\n")}
+	close(events)
+	stream := s3.NewSelectObjectContentEventStream(func(es *s3.SelectObjectContentEventStream) {
+		es.Reader = stubEventReader{events: events}
+		es.StreamCloser = es.Reader
+	})
+	return &s3.SelectObjectContentOutput{EventStream: stream}, nil
+}
+
+type stubEventReader struct {
+	events chan s3.SelectObjectContentEventStreamEvent
+}
+
+func (r stubEventReader) Events() <-chan s3.SelectObjectContentEventStreamEvent { return r.events }
+func (r stubEventReader) Close() error                                          { return nil }
+func (r stubEventReader) Err() error                                            { return nil }
+
+func TestSelectIncomplete(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", truncatingBucket{s3fake.New()})
+	rc, err := fs.Select("/a.csv", "SELECT * FROM S3Object", csvInput, csvOutput)
+	g.Expect(err).NotTo(HaveOccurred())
+	data, err := ioutil.ReadAll(rc)
+	g.Expect(err).To(Equal(errSelectIncomplete))
+	g.Expect(string(data)).To(Equal("a,1\n"))
+}
@@ -0,0 +1,138 @@
+package s3
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statCache is an in-process cache of Stat results, with a time-to-live and
+// a maximum number of entries; the least recently used entries are evicted
+// first. It is shared by every copy of the Fs that created it, so writes,
+// renames and removals via any of them invalidate the affected entries.
+//
+// All the methods do nothing when the cache is nil.
+type statCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+type statCacheEntry struct {
+	key     string
+	info    FileInfo
+	expires time.Time
+}
+
+func newStatCache(ttl time.Duration, size int) *statCache {
+	return &statCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// cacheKey normalises an object key so that files and directories of the
+// same name share the same entry.
+func cacheKey(key string) string {
+	return trimTrailingSlash(key)
+}
+
+func (c *statCache) get(key string) (FileInfo, bool) {
+	if c == nil {
+		return FileInfo{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, exists := c.entries[cacheKey(key)]
+	if !exists {
+		return FileInfo{}, false
+	}
+
+	entry := el.Value.(*statCacheEntry)
+	if c.now().After(entry.expires) {
+		c.remove(el)
+		return FileInfo{}, false
+	}
+
+	c.lru.MoveToFront(el)
+	return entry.info, true
+}
+
+func (c *statCache) put(key string, info FileInfo) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key = cacheKey(key)
+	entry := &statCacheEntry{key: key, info: info, expires: c.now().Add(c.ttl)}
+
+	if el, exists := c.entries[key]; exists {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidate removes the entry for a key. The entries for its parent
+// directories are also removed because their existence may depend on it.
+func (c *statCache) invalidate(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key = cacheKey(key)
+	for {
+		if el, exists := c.entries[key]; exists {
+			c.remove(el)
+		}
+		if key == "" {
+			return
+		}
+		key = trimTrailingSlash(key[:strings.LastIndex(key, PathSeparator)+1])
+	}
+}
+
+// invalidateAll removes the entry for a key, its parents, and everything below it.
+func (c *statCache) invalidateAll(key string) {
+	if c == nil {
+		return
+	}
+
+	c.invalidate(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := addTrailingSlash(cacheKey(key))
+	for k, el := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *statCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*statCacheEntry).key)
+}
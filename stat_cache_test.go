@@ -0,0 +1,69 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestStatCacheExpiry(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newStatCache(time.Minute, 0)
+	c.now = func() time.Time { return now }
+
+	c.put("a/b/c.txt", NewFileInfo("/a/b/c.txt", 1, now))
+	_, ok := c.get("a/b/c.txt")
+	g.Expect(ok).To(BeTrue())
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.get("a/b/c.txt")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(c.lru.Len()).To(Equal(0))
+}
+
+func TestStatCacheEviction(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	c := newStatCache(time.Minute, 2)
+	c.put("a", NewDirectoryInfo("/a"))
+	c.put("b", NewDirectoryInfo("/b"))
+	_, ok := c.get("a")
+	g.Expect(ok).To(BeTrue())
+
+	c.put("c", NewDirectoryInfo("/c"))
+	_, ok = c.get("b")
+	g.Expect(ok).To(BeFalse())
+	_, ok = c.get("a")
+	g.Expect(ok).To(BeTrue())
+	_, ok = c.get("c")
+	g.Expect(ok).To(BeTrue())
+}
+
+func TestStatCacheInvalidation(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	c := newStatCache(time.Minute, 0)
+	c.put("a", NewDirectoryInfo("/a"))
+	c.put("a/b", NewDirectoryInfo("/a/b"))
+	c.put("a/b/c.txt", NewFileInfo("/a/b/c.txt", 1, time.Now()))
+	c.put("a/d.txt", NewFileInfo("/a/d.txt", 1, time.Now()))
+
+	c.invalidate("a/b/c.txt")
+	g.Expect(c.entries).To(HaveLen(1))
+	g.Expect(c.entries).To(HaveKey("a/d.txt"))
+
+	c.put("a", NewDirectoryInfo("/a"))
+	c.put("a/b", NewDirectoryInfo("/a/b"))
+	c.put("x", NewDirectoryInfo("/x"))
+	c.invalidateAll("a/")
+	g.Expect(c.entries).To(HaveLen(1))
+	g.Expect(c.entries).To(HaveKey("x"))
+
+	var nilCache *statCache
+	nilCache.put("a", NewDirectoryInfo("/a"))
+	_, ok := nilCache.get("a")
+	g.Expect(ok).To(BeFalse())
+}
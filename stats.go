@@ -0,0 +1,193 @@
+package s3
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Stats counts the S3 requests made by a file system, and the bytes
+// uploaded and downloaded. Retried requests are counted each time they
+// are sent, because S3 charges for each of them.
+type Stats struct {
+	Get        int64 // GetObject and SelectObjectContent requests
+	Put        int64 // PutObject, CreateBucket, CreateMultipartUpload, UploadPart and CompleteMultipartUpload requests
+	Copy       int64 // CopyObject and UploadPartCopy requests
+	List       int64 // ListObjectsV2, ListObjectVersions, ListMultipartUploads and ListParts requests
+	Head       int64 // HeadObject requests
+	Attributes int64 // GetObjectAttributes requests
+	Delete     int64 // DeleteObject and AbortMultipartUpload requests
+	BytesUp    int64 // bytes sent in PutObject and UploadPart requests
+	BytesDown  int64 // bytes read from GetObject responses
+}
+
+// Requests gets the total number of requests.
+func (s Stats) Requests() int64 {
+	return s.Get + s.Put + s.Copy + s.List + s.Head + s.Attributes + s.Delete
+}
+
+// Pricing holds the prices of S3 requests and data transfer, in dollars or any
+// other currency. These vary by region and storage class.
+type Pricing struct {
+	// PerThousandWrites is the price of 1000 PUT, COPY or LIST requests.
+	PerThousandWrites float64
+	// PerThousandReads is the price of 1000 GET, HEAD or other requests.
+	PerThousandReads float64
+	// PerGBDownloaded is the price of transferring 1 GB out of S3. This is zero
+	// within a region but applies for transfer to the internet.
+	PerGBDownloaded float64
+}
+
+// StandardPricing is the pricing for the Standard storage class in us-east-1
+// at the time of writing, excluding data transfer. Check the current prices
+// for your region.
+var StandardPricing = Pricing{
+	PerThousandWrites: 0.005,
+	PerThousandReads:  0.0004,
+}
+
+// Cost estimates the cost of the requests. DELETE requests are free.
+func (s Stats) Cost(p Pricing) float64 {
+	writes := float64(s.Put + s.Copy + s.List)
+	reads := float64(s.Get + s.Head + s.Attributes)
+	gb := float64(s.BytesDown) / (1 << 30)
+	return writes*p.PerThousandWrites/1000 + reads*p.PerThousandReads/1000 + gb*p.PerGBDownloaded
+}
+
+// counters is the shared, concurrency-safe version of Stats.
+type counters struct {
+	stats Stats
+}
+
+func (c *counters) snapshot() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	return Stats{
+		Get:        atomic.LoadInt64(&c.stats.Get),
+		Put:        atomic.LoadInt64(&c.stats.Put),
+		Copy:       atomic.LoadInt64(&c.stats.Copy),
+		List:       atomic.LoadInt64(&c.stats.List),
+		Head:       atomic.LoadInt64(&c.stats.Head),
+		Attributes: atomic.LoadInt64(&c.stats.Attributes),
+		Delete:     atomic.LoadInt64(&c.stats.Delete),
+		BytesUp:    atomic.LoadInt64(&c.stats.BytesUp),
+		BytesDown:  atomic.LoadInt64(&c.stats.BytesDown),
+	}
+}
+
+// countingAPI counts the requests sent to the S3 API it wraps.
+type countingAPI struct {
+	S3APISubset
+	counters *counters
+}
+
+// countingReader counts the bytes read from a response body.
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
+
+func (c *countingAPI) AbortMultipartUploadWithContext(ctx aws.Context, input *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Delete, 1)
+	return c.S3APISubset.AbortMultipartUploadWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) CompleteMultipartUploadWithContext(ctx aws.Context, input *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Put, 1)
+	return c.S3APISubset.CompleteMultipartUploadWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) CopyObjectWithContext(ctx aws.Context, input *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Copy, 1)
+	return c.S3APISubset.CopyObjectWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) CreateBucketWithContext(ctx aws.Context, input *s3.CreateBucketInput, opts ...request.Option) (*s3.CreateBucketOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Put, 1)
+	return c.S3APISubset.CreateBucketWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) CreateMultipartUploadWithContext(ctx aws.Context, input *s3.CreateMultipartUploadInput, opts ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Put, 1)
+	return c.S3APISubset.CreateMultipartUploadWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Delete, 1)
+	return c.S3APISubset.DeleteObjectWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Get, 1)
+	output, err := c.S3APISubset.GetObjectWithContext(ctx, input, opts...)
+	if err == nil && output.Body != nil {
+		output.Body = countingReader{ReadCloser: output.Body, n: &c.counters.stats.BytesDown}
+	}
+	return output, err
+}
+
+func (c *countingAPI) GetObjectAttributesWithContext(ctx aws.Context, input *s3.GetObjectAttributesInput, opts ...request.Option) (*s3.GetObjectAttributesOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Attributes, 1)
+	return c.S3APISubset.GetObjectAttributesWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Head, 1)
+	return c.S3APISubset.HeadObjectWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) ListMultipartUploadsWithContext(ctx aws.Context, input *s3.ListMultipartUploadsInput, opts ...request.Option) (*s3.ListMultipartUploadsOutput, error) {
+	atomic.AddInt64(&c.counters.stats.List, 1)
+	return c.S3APISubset.ListMultipartUploadsWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) ListObjectsV2WithContext(ctx aws.Context, input *s3.ListObjectsV2Input, opts ...request.Option) (*s3.ListObjectsV2Output, error) {
+	atomic.AddInt64(&c.counters.stats.List, 1)
+	return c.S3APISubset.ListObjectsV2WithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) ListObjectVersionsWithContext(ctx aws.Context, input *s3.ListObjectVersionsInput, opts ...request.Option) (*s3.ListObjectVersionsOutput, error) {
+	atomic.AddInt64(&c.counters.stats.List, 1)
+	return c.S3APISubset.ListObjectVersionsWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) ListPartsWithContext(ctx aws.Context, input *s3.ListPartsInput, opts ...request.Option) (*s3.ListPartsOutput, error) {
+	atomic.AddInt64(&c.counters.stats.List, 1)
+	return c.S3APISubset.ListPartsWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Put, 1)
+	if size := requestSize(&Request{Input: input}); size > 0 {
+		atomic.AddInt64(&c.counters.stats.BytesUp, size)
+	}
+	return c.S3APISubset.PutObjectWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) SelectObjectContentWithContext(ctx aws.Context, input *s3.SelectObjectContentInput, opts ...request.Option) (*s3.SelectObjectContentOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Get, 1)
+	return c.S3APISubset.SelectObjectContentWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Put, 1)
+	if size := requestSize(&Request{Input: input}); size > 0 {
+		atomic.AddInt64(&c.counters.stats.BytesUp, size)
+	}
+	return c.S3APISubset.UploadPartWithContext(ctx, input, opts...)
+}
+
+func (c *countingAPI) UploadPartCopyWithContext(ctx aws.Context, input *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	atomic.AddInt64(&c.counters.stats.Copy, 1)
+	return c.S3APISubset.UploadPartCopyWithContext(ctx, input, opts...)
+}
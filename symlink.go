@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/afero"
+)
+
+// maxSymlinks limits the number of symbolic links followed when resolving a
+// name, so that loops are detected.
+const maxSymlinks = 40
+
+// maxLinkTarget limits the size of the target path read from a symbolic link.
+const maxLinkTarget = 4096
+
+var (
+	errNotSymlink    = errors.New("not a symbolic link")
+	errTooManyLinks  = errors.New("too many levels of symbolic links")
+	errBadLinkTarget = errors.New("symbolic link target is blank or too long")
+)
+
+var _ afero.Lstater = Fs{}
+
+// SymlinkIfPossible creates newname as a symbolic link to oldname. S3 has no
+// symbolic links, so this writes a small object in the same way as s3fs-fuse:
+// its content is the target path and its mode metadata marks it as a link.
+//
+// Stat and Open follow symbolic links. A relative target is relative to the
+// directory containing the link; an absolute target is within this file
+// system, i.e. after any key prefix. Only links to files can be followed,
+// because S3 has no way to follow a link in the middle of a name. Listings
+// do not include the metadata, so Readdir reports links as small files.
+func (fs Fs) SymlinkIfPossible(oldname, newname string) error {
+	if err := fs.checkName("symlink", newname); err != nil {
+		return err
+	}
+
+	start := fs.begin("Symlink", newname)
+
+	if oldname == "" || len(oldname) > maxLinkTarget {
+		err := &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errBadLinkTarget}
+		fs.logOp("Symlink", newname, start, err)
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(fs.bucket),
+		Key:           aws.String(fs.key(newname)),
+		Body:          strings.NewReader(oldname),
+		ContentLength: aws.Int64(int64(len(oldname))),
+		Metadata: map[string]*string{
+			metadataKeyMode: aws.String(strconv.FormatUint(unixTypeSymlink|uint64(os.ModePerm), 10)),
+		},
+	}
+	fs.writeOpts.applyToPut(input)
+
+	ctx, cancel := withTimeout(fs.ctx, fs.timeouts.transfer)
+	_, err := fs.s3API.PutObjectWithContext(ctx, input)
+	cancel()
+	fs.forget(newname)
+
+	if err != nil {
+		err = &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: conditionOf(err)}
+		fs.logOp("Symlink", newname, start, err)
+		return err
+	}
+
+	fs.logOp("Symlink", newname, start, nil, "target", oldname)
+	fs.audit(AuditRecord{Op: "symlink", Path: newname, NewPath: oldname})
+	return nil
+}
+
+// ReadlinkIfPossible gets the target of a symbolic link created by
+// SymlinkIfPossible (or by s3fs-fuse).
+func (fs Fs) ReadlinkIfPossible(name string) (string, error) {
+	fi, err := fs.lstat(name)
+	if err != nil {
+		return "", err
+	}
+
+	if info, ok := fi.(FileInfo); ok && info.linkTarget != "" {
+		return info.linkTarget, nil
+	}
+	return "", &os.PathError{Op: "readlink", Path: name, Err: errNotSymlink}
+}
+
+// LstatIfPossible returns a FileInfo describing the named file, as for Stat,
+// except that a symbolic link is not followed; its mode has os.ModeSymlink
+// set instead. This implements afero.Lstater.
+func (fs Fs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	fi, err := fs.lstat(name)
+	return fi, true, err
+}
+
+// followLinks gets the file info for a name, following symbolic links. It
+// also returns the name of the file that was finally found.
+func (fs Fs) followLinks(name string) (string, os.FileInfo, error) {
+	original := name
+	fi, err := fs.lstat(name)
+	for hops := 0; err == nil; hops++ {
+		info, ok := fi.(FileInfo)
+		if !ok || info.linkTarget == "" {
+			break
+		}
+		if hops == maxSymlinks {
+			return name, FileInfo{}, &os.PathError{Op: "stat", Path: original, Err: errTooManyLinks}
+		}
+		name = resolveLink(name, info.linkTarget)
+		fi, err = fs.lstat(name)
+	}
+	return name, fi, err
+}
+
+// resolveLink gets the name of the target of a symbolic link.
+func resolveLink(link, target string) string {
+	if strings.HasPrefix(target, PathSeparator) {
+		return path.Clean(target)
+	}
+	return path.Join(path.Dir(link), target)
+}
+
+// readLinkTarget reads the content of a symbolic link object.
+func (fs Fs) readLinkTarget(ctx aws.Context, name string) (string, error) {
+	out, err := fs.s3API.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.key(name)),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer out.Body.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(out.Body, maxLinkTarget+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) == 0 || len(b) > maxLinkTarget {
+		return "", errBadLinkTarget
+	}
+	return string(b), nil
+}
@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestSymlink(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New()).WithKeyPrefix("site")
+	g.Expect(afero.WriteFile(fs, "/releases/2024/app.js", []byte("v2024"), 0644)).To(Succeed())
+
+	g.Expect(fs.SymlinkIfPossible("releases/2024/app.js", "/app.js")).To(Succeed())
+	g.Expect(fs.SymlinkIfPossible("/app.js", "/latest/app.js")).To(Succeed())
+
+	target, err := fs.ReadlinkIfPossible("/app.js")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(target).To(Equal("releases/2024/app.js"))
+
+	fi, lstat, err := fs.LstatIfPossible("/latest/app.js")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lstat).To(BeTrue())
+	g.Expect(fi.Mode() & os.ModeSymlink).NotTo(BeZero())
+
+	fi, err = fs.Stat("/latest/app.js")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Mode().IsRegular()).To(BeTrue())
+	g.Expect(fi.Size()).To(Equal(int64(5)))
+
+	b, err := afero.ReadFile(fs, "/latest/app.js")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("v2024"))
+
+	_, err = fs.ReadlinkIfPossible("/releases/2024/app.js")
+	g.Expect(errors.Is(err, errNotSymlink)).To(BeTrue())
+
+	g.Expect(fs.SymlinkIfPossible("loop2", "/loop1")).To(Succeed())
+	g.Expect(fs.SymlinkIfPossible("loop1", "/loop2")).To(Succeed())
+	_, err = fs.Stat("/loop1")
+	g.Expect(errors.Is(err, errTooManyLinks)).To(BeTrue())
+
+	g.Expect(fs.SymlinkIfPossible("missing", "/dangling")).To(Succeed())
+	_, err = fs.Stat("/dangling")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	_, _, err = fs.LstatIfPossible("/dangling")
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestSymlinkRemoveAndCreate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	g.Expect(afero.WriteFile(fs, "/target.txt", []byte("target"), 0644)).To(Succeed())
+	g.Expect(fs.SymlinkIfPossible("/target.txt", "/link")).To(Succeed())
+	g.Expect(fs.SymlinkIfPossible("/nowhere", "/dangling")).To(Succeed())
+
+	// an exclusive create fails even if the link's target does not exist
+	_, err := fs.OpenFile("/dangling", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	g.Expect(os.IsExist(err)).To(BeTrue())
+
+	// a dangling link exists, as for os.Lstat
+	matches, err := fs.Glob("/dangling")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(matches).To(Equal([]string{"/dangling"}))
+
+	// a dangling link can be removed
+	g.Expect(fs.Remove("/dangling")).To(Succeed())
+	_, _, err = fs.LstatIfPossible("/dangling")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+
+	// removing a link leaves its target
+	g.Expect(fs.Remove("/link")).To(Succeed())
+	_, _, err = fs.LstatIfPossible("/link")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(afero.Exists(fs, "/target.txt")).To(BeTrue())
+}
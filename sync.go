@@ -0,0 +1,341 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// SyncOptions controls what a Sync copies.
+type SyncOptions struct {
+	// Concurrency is the number of files copied at once. The default is 1.
+	Concurrency int
+	// Include, if not empty, limits the files copied to those that match
+	// any of these patterns, as used by path.Match. A pattern without a "/"
+	// is matched with the name of each file, e.g. "*.jpg"; otherwise it is
+	// matched with the path relative to the directory, e.g. "2024/*/*.jpg".
+	Include []string
+	// Exclude omits the files that match any of these patterns, in the same
+	// way as Include, even if they are included.
+	Exclude []string
+	// Compare is how the files that are already identical are found, so that
+	// they are not copied again.
+	Compare SyncCompare
+	// Delete removes the files in the destination that are not in the
+	// source, apart from those that are excluded, so that it mirrors the
+	// source.
+	Delete bool
+}
+
+// SyncCompare is a strategy for deciding whether a file needs to be copied
+// by a Sync.
+type SyncCompare int
+
+const (
+	// CompareSizeAndTime treats files as identical if they have the same size
+	// and the destination was modified no earlier than the source, as for
+	// "aws s3 sync". This is the default.
+	CompareSizeAndTime SyncCompare = iota
+	// CompareSize treats files as identical if they have the same size, as
+	// for "aws s3 sync --size-only".
+	CompareSize
+	// CompareChecksum compares the content of the files, using their MD5
+	// hashes. The ETags of S3 objects are used where possible, but other
+	// files must be read.
+	CompareChecksum
+	// CompareNone copies every file, regardless.
+	CompareNone
+)
+
+// SyncResult summarises what a Sync did.
+type SyncResult struct {
+	Copied  int
+	Skipped int // because they were identical
+	Deleted int
+	Bytes   int64 // copied
+}
+
+// Sync copies a tree of files from one file system to another, skipping the
+// files that are already identical. One of the file systems would usually
+// be an *Fs and the other a local disk (afero.OsFs) or memory.
+type Sync struct {
+	src, dst       afero.Fs
+	srcDir, dstDir string
+	opts           SyncOptions
+	s3Fs           Fs // for logging
+}
+
+// SyncFrom creates a Sync that copies the files in a directory of another
+// file system, and all its subdirectories, to a directory of this one.
+// Nothing is copied until Run is called.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) SyncFrom(src afero.Fs, srcDir, dir string, opts SyncOptions) *Sync {
+	return &Sync{src: src, srcDir: srcDir, dst: &fs, dstDir: dir, opts: opts, s3Fs: fs}
+}
+
+// SyncTo creates a Sync that copies the files in a directory of this file
+// system, and all its subdirectories, to a directory of another one.
+// Nothing is copied until Run is called.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) SyncTo(dst afero.Fs, dstDir, dir string, opts SyncOptions) *Sync {
+	return &Sync{src: &fs, srcDir: dir, dst: dst, dstDir: dstDir, opts: opts, s3Fs: fs}
+}
+
+// Run copies the files. It stops at the first error, once the files
+// already being copied are done.
+func (s *Sync) Run() (SyncResult, error) {
+	start := s.s3Fs.begin("Sync", s.srcDir)
+	result, err := s.run()
+	s.s3Fs.logOp("Sync", s.srcDir, start, err, "to", s.dstDir,
+		"copied", result.Copied, "skipped", result.Skipped, "deleted", result.Deleted, "bytes", result.Bytes)
+	return result, err
+}
+
+func (s *Sync) run() (SyncResult, error) {
+	var result SyncResult
+	srcFiles, err := s.files(s.src, s.srcDir)
+	if err != nil {
+		return result, err
+	}
+	dstFiles, err := s.files(s.dst, s.dstDir)
+	if err != nil && !os.IsNotExist(err) {
+		return result, err
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	n := s.opts.Concurrency
+	if n < 1 {
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				if failed() {
+					continue
+				}
+				identical, err := s.identical(rel, srcFiles[rel], dstFiles[rel])
+				if err != nil {
+					fail(err)
+					continue
+				}
+
+				var size int64
+				if !identical {
+					if size, err = s.copy(rel, srcFiles[rel]); err != nil {
+						fail(err)
+						continue
+					}
+				}
+
+				mu.Lock()
+				if identical {
+					result.Skipped++
+				} else {
+					result.Copied++
+					result.Bytes += size
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, rel := range sortedKeys(srcFiles) {
+		if failed() {
+			break
+		}
+		jobs <- rel
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil || !s.opts.Delete {
+		return result, firstErr
+	}
+
+	for _, rel := range sortedKeys(dstFiles) {
+		if _, exists := srcFiles[rel]; !exists {
+			if err := s.dst.Remove(path.Join(s.dstDir, rel)); err != nil {
+				return result, err
+			}
+			result.Deleted++
+		}
+	}
+	return result, nil
+}
+
+// files finds the files in a directory and its subdirectories that are
+// wanted, by their slash-separated paths relative to the directory.
+func (s *Sync) files(fs afero.Fs, dir string) (map[string]os.FileInfo, error) {
+	files := make(map[string]os.FileInfo)
+	walkFn := func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if s.wanted(rel) {
+			files[rel] = info
+		}
+		return nil
+	}
+
+	if s3Fs, ok := fs.(*Fs); ok {
+		return files, s3Fs.Walk(dir, walkFn)
+	}
+	return files, afero.Walk(fs, dir, walkFn)
+}
+
+// wanted tests whether a file passes the include and exclude patterns.
+func (s *Sync) wanted(rel string) bool {
+	if len(s.opts.Include) > 0 && !syncMatch(s.opts.Include, rel) {
+		return false
+	}
+	return !syncMatch(s.opts.Exclude, rel)
+}
+
+func syncMatch(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// identical tests whether the destination file is the same as the source.
+func (s *Sync) identical(rel string, src, dst os.FileInfo) (bool, error) {
+	if dst == nil || src.Size() != dst.Size() {
+		return false, nil
+	}
+
+	switch s.opts.Compare {
+	case CompareNone:
+		return false, nil
+	case CompareSize:
+		return true, nil
+	case CompareSizeAndTime:
+		// S3 times are only to the second
+		return !dst.ModTime().Before(src.ModTime().Truncate(time.Second)), nil
+	}
+
+	srcSum, err := fileMD5(s.src, path.Join(s.srcDir, rel), src)
+	if err != nil {
+		return false, err
+	}
+	dstSum, err := fileMD5(s.dst, path.Join(s.dstDir, rel), dst)
+	if err != nil {
+		return false, err
+	}
+	return srcSum == dstSum, nil
+}
+
+// fileMD5 gets the MD5 hash of a file, in hex, from its ETag if possible.
+// The ETags of objects uploaded in parts, or encrypted using KMS, are not
+// MD5 hashes.
+func fileMD5(fs afero.Fs, name string, info os.FileInfo) (string, error) {
+	if fi, ok := info.(FileInfo); ok {
+		etag := strings.Trim(fi.ETag(), `"`)
+		if len(etag) == 32 && fi.object.SSEKMSKeyId == "" {
+			return etag, nil
+		}
+	}
+
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copy copies one file, giving the number of bytes copied.
+func (s *Sync) copy(rel string, info os.FileInfo) (int64, error) {
+	srcName, dstName := path.Join(s.srcDir, rel), path.Join(s.dstDir, rel)
+	r, err := s.src.Open(srcName)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	_, dstIsS3 := s.dst.(*Fs)
+	if !dstIsS3 {
+		if err := s.dst.MkdirAll(path.Dir(dstName), 0777); err != nil {
+			return 0, err
+		}
+	}
+
+	w, err := s.dst.Create(dstName)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		w.Close()
+		return n, err
+	}
+	if err := w.Close(); err != nil {
+		return n, err
+	}
+
+	if !dstIsS3 {
+		// so that the file is seen to be identical next time; S3 sets the
+		// time itself, which is later anyway
+		if err := s.dst.Chtimes(dstName, info.ModTime(), info.ModTime()); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func sortedKeys(files map[string]os.FileInfo) []string {
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
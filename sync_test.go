@@ -0,0 +1,95 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestSyncFromAndTo(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	local := afero.NewMemMapFs()
+	for name, content := range map[string]string{
+		"/src/a.txt":     "aaa",
+		"/src/b.jpg":     "bbb",
+		"/src/sub/c.txt": "ccc",
+		"/src/tmp/d.txt": "ddd",
+	} {
+		g.Expect(afero.WriteFile(local, name, []byte(content), 0644)).To(Succeed())
+	}
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	opts := SyncOptions{Concurrency: 3, Include: []string{"*.txt"}, Exclude: []string{"tmp/*"}}
+
+	result, err := fs.SyncFrom(local, "/src", "/backup", opts).Run()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(SyncResult{Copied: 2, Bytes: 6}))
+
+	list, err := fs.ListObjects("/backup", -1, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Paths()).To(Equal([]string{"/backup/a.txt", "/backup/sub/c.txt"}))
+
+	// unchanged files are skipped
+	puts := mem.Puts
+	result, err = fs.SyncFrom(local, "/src", "/backup", opts).Run()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(SyncResult{Skipped: 2}))
+	g.Expect(mem.Puts).To(Equal(puts))
+
+	// changed files are copied, using checksums
+	g.Expect(afero.WriteFile(local, "/src/a.txt", []byte("AAA"), 0644)).To(Succeed())
+	g.Expect(local.Chtimes("/src/a.txt", time.Time{}, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+	opts.Compare = CompareChecksum
+	result, err = fs.SyncFrom(local, "/src", "/backup", opts).Run()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(SyncResult{Copied: 1, Skipped: 1, Bytes: 3}))
+
+	// and back, mirroring
+	g.Expect(afero.WriteFile(local, "/restore/old.txt", []byte("old"), 0644)).To(Succeed())
+	opts = SyncOptions{Delete: true}
+	result, err = fs.SyncTo(local, "/restore", "/backup", opts).Run()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(SyncResult{Copied: 2, Deleted: 1, Bytes: 6}))
+
+	data, err := afero.ReadFile(local, "/restore/sub/c.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("ccc"))
+
+	result, err = fs.SyncTo(local, "/restore", "/backup", opts).Run()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(SyncResult{Skipped: 2}))
+}
+
+func TestSyncCompare(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	local := afero.NewMemMapFs()
+	g.Expect(afero.WriteFile(local, "/src/a.txt", []byte("aaa"), 0644)).To(Succeed())
+	fs := NewFs("mybucket", s3fake.New())
+
+	_, err := fs.SyncFrom(local, "/src", "/", SyncOptions{}).Run()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the source is now newer, with the same size
+	g.Expect(afero.WriteFile(local, "/src/a.txt", []byte("AAA"), 0644)).To(Succeed())
+	g.Expect(local.Chtimes("/src/a.txt", time.Time{}, time.Now().Add(time.Hour))).To(Succeed())
+
+	for compare, copied := range map[SyncCompare]int{
+		CompareSize:        0,
+		CompareNone:        1,
+		CompareSizeAndTime: 1,
+	} {
+		result, err := fs.SyncFrom(local, "/src", "/", SyncOptions{Compare: compare}).Run()
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.Copied).To(Equal(copied), "%d", compare)
+	}
+
+	result, err := fs.SyncFrom(local, "/src", "/", SyncOptions{Compare: CompareChecksum}).Run()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Skipped).To(Equal(1))
+}
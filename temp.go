@@ -0,0 +1,125 @@
+package s3
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultTempDir is the directory used by TempFile and TempDir when none is
+// given.
+const DefaultTempDir = "/tmp"
+
+// maxTempAttempts limits the number of names tried by TempFile and TempDir.
+const maxTempAttempts = 100
+
+var (
+	errPatternHasSeparator = errors.New("pattern contains path separator")
+	errTempExhausted       = errors.New("no unused name found")
+)
+
+// WithConditionalCreate sets whether a new instance of the file system
+// writes files opened with os.O_EXCL (including those made by TempFile)
+// using a conditional PUT, so that the write fails if another client has
+// created the same file in the meantime. The error then matches os.ErrExist.
+// This needs S3 to support "If-None-Match: *" for PutObject, which AWS S3
+// does but some S3-compatible stores do not. Otherwise, os.O_EXCL only
+// checks that the file does not exist when it is opened. The default is off.
+func (fs Fs) WithConditionalCreate(on bool) *Fs {
+	fs.conditionalCreate = on
+	return &fs
+}
+
+// TempFile creates a new file in the directory dir, opened for writing, in
+// the same way as afero.TempFile. Its name is made from the pattern by
+// replacing the last "*" with a random string, or by appending one if there
+// is no "*". If dir is blank, DefaultTempDir is used. The caller can use the
+// file's Name method to find its name. As usual, the file is only written to
+// S3 when it is closed.
+//
+// The file is opened using os.O_EXCL, so a name that is already in use is
+// not chosen; see also WithConditionalCreate. S3 has no temporary storage,
+// so it is the caller's responsibility to remove the file when no longer
+// needed, or to set a lifecycle rule for the directory.
+func (fs Fs) TempFile(dir, pattern string) (afero.File, error) {
+	dir, prefix, suffix, err := tempPattern(dir, pattern)
+	if err != nil {
+		return nil, &os.PathError{Op: "createtemp", Path: pattern, Err: err}
+	}
+
+	for i := 0; i < maxTempAttempts; i++ {
+		name := path.Join(dir, prefix+randomString()+suffix)
+		f, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	return nil, &os.PathError{Op: "createtemp", Path: path.Join(dir, prefix+"*"+suffix), Err: errTempExhausted}
+}
+
+// TempDir creates a new directory in the directory dir and returns its name,
+// in the same way as afero.TempDir. The name is chosen in the same way as
+// for TempFile. If directory markers are disabled (see WithDirMarkers),
+// the directory only exists once a file has been written into it.
+func (fs Fs) TempDir(dir, pattern string) (string, error) {
+	dir, prefix, suffix, err := tempPattern(dir, pattern)
+	if err != nil {
+		return "", &os.PathError{Op: "mkdirtemp", Path: pattern, Err: err}
+	}
+
+	for i := 0; i < maxTempAttempts; i++ {
+		name := path.Join(dir, prefix+randomString()+suffix)
+		if _, err := fs.lstat(name); err == nil {
+			continue
+		}
+
+		if fs.noDirMarkers {
+			return name, nil
+		}
+
+		f, err := fs.OpenFile(addTrailingSlash(name), os.O_CREATE|os.O_EXCL, 0700)
+		if err == nil {
+			// the marker object is written on closing
+			err = f.Close()
+		}
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", pathError("mkdirtemp", name, err)
+		}
+		return name, nil
+	}
+
+	return "", &os.PathError{Op: "mkdirtemp", Path: path.Join(dir, prefix+"*"+suffix), Err: errTempExhausted}
+}
+
+// tempPattern splits a pattern for TempFile or TempDir at its last "*".
+func tempPattern(dir, pattern string) (string, string, string, error) {
+	if dir == "" {
+		dir = DefaultTempDir
+	}
+	if strings.Contains(pattern, PathSeparator) {
+		return "", "", "", errPatternHasSeparator
+	}
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		return dir, pattern[:i], pattern[i+1:], nil
+	}
+	return dir, pattern, "", nil
+}
+
+func randomString() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestTempFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+
+	f, err := fs.TempFile("/work", "upload-*.csv")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.Name()).To(HavePrefix("/work/upload-"))
+	g.Expect(f.Name()).To(HaveSuffix(".csv"))
+	_, err = f.WriteString("a,b,c")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.Close()).To(Succeed())
+
+	b, err := afero.ReadFile(fs, f.Name())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("a,b,c"))
+
+	f2, err := afero.TempFile(fs, "", "x")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f2.Close()).To(Succeed())
+
+	f3, err := fs.TempFile("", "x")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f3.Name()).To(HavePrefix(DefaultTempDir + "/x"))
+	g.Expect(f3.Name()).NotTo(Equal(f2.Name()))
+
+	_, err = fs.TempFile("/work", "a/b*")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestTempDir(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+
+	name, err := fs.TempDir("/work", "job")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(strings.HasPrefix(name, "/work/job")).To(BeTrue())
+
+	fi, err := fs.Stat(name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.IsDir()).To(BeTrue())
+}
+
+func TestOpenFileExclusive(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New()).WithConditionalCreate(true)
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("first"), 0644)).To(Succeed())
+
+	_, err := fs.OpenFile("/a.txt", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	g.Expect(os.IsExist(err)).To(BeTrue())
+
+	// another client creates the file after it has been opened
+	f, err := fs.OpenFile("/b.txt", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(afero.WriteFile(fs, "/b.txt", []byte("theirs"), 0644)).To(Succeed())
+	_, err = f.WriteString("mine")
+	g.Expect(err).NotTo(HaveOccurred())
+	err = f.Close()
+	g.Expect(os.IsExist(err)).To(BeTrue())
+
+	b, err := afero.ReadFile(fs, "/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(b)).To(Equal("theirs"))
+}
@@ -0,0 +1,49 @@
+package s3
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tenantsDir is the directory that holds the directory of each tenant.
+const tenantsDir = "tenants"
+
+// WithTenant derives a new instance of the file system for one tenant of a
+// multi-tenant service, confined to the directory "tenants/<id>" below the
+// key prefix, if any. It can then be handed to the request handlers for that
+// tenant.
+//
+// Names are always resolved within that directory: "..", as in
+// "/../other/a.txt", cannot go above its root, and nor can the targets of
+// symbolic links. The confinement is kept by the file systems derived from
+// the new instance; WithKeyPrefix then adds to the tenant's prefix rather
+// than replacing it.
+//
+// The ID must be a single path segment, so it cannot be blank, "." or "..",
+// nor contain slashes, backslashes or control characters. Otherwise, the
+// error is an *InvalidKeyError.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) WithTenant(id string) (*Fs, error) {
+	if reason := invalidTenantID(id); reason != "" {
+		return nil, &InvalidKeyError{Key: id, Reason: reason}
+	}
+	fs.keyPrefix = fs.keyPrefix + tenantsDir + PathSeparator + id + PathSeparator
+	fs.rootPrefix = fs.keyPrefix
+	return &fs, nil
+}
+
+// invalidTenantID gives the reason a tenant ID is not allowed, or blank.
+func invalidTenantID(id string) string {
+	switch {
+	case id == "":
+		return "tenant ID is blank"
+	case id == "." || id == "..":
+		return "tenant ID is a relative directory"
+	case strings.ContainsAny(id, `/\`):
+		return "tenant ID contains a slash"
+	case strings.IndexFunc(id, unicode.IsControl) >= 0:
+		return "tenant ID contains a control character"
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestWithTenant(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem).WithKeyPrefix("app")
+	g.Expect(afero.WriteFile(fs, "/secret.txt", []byte("secret"), 0644)).To(Succeed())
+
+	acme, err := fs.WithTenant("acme")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(afero.WriteFile(acme, "/a.txt", []byte("acme"), 0644)).To(Succeed())
+
+	data, err := afero.ReadFile(fs, "/tenants/acme/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("acme"))
+
+	// names cannot escape from the tenant's directory
+	for _, name := range []string{"/../../secret.txt", "../../../secret.txt", "/a/../../../secret.txt"} {
+		_, err = acme.Stat(name)
+		g.Expect(os.IsNotExist(err)).To(BeTrue(), name)
+	}
+	g.Expect(acme.SymlinkIfPossible("../../../secret.txt", "/link")).To(Succeed())
+	_, err = afero.ReadFile(acme, "/link")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+
+	// nor can the prefix be replaced
+	_, err = acme.WithKeyPrefix("").Stat("/secret.txt")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+	g.Expect(afero.WriteFile(acme.WithKeyPrefix("sub"), "/b.txt", []byte("b"), 0644)).To(Succeed())
+	g.Expect(afero.Exists(fs, "/tenants/acme/sub/b.txt")).To(BeTrue())
+
+	// tenants see only their own files
+	other, err := fs.WithTenant("other")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(afero.Exists(other, "/a.txt")).To(BeFalse())
+
+	for _, id := range []string{"", ".", "..", "a/b", `a\b`, "a\x00"} {
+		_, err = fs.WithTenant(id)
+		_, isInvalid := err.(*InvalidKeyError)
+		g.Expect(isInvalid).To(BeTrue(), id)
+	}
+}
@@ -0,0 +1,91 @@
+package s3
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that limits the rate of data transfer. It
+// allows bursts of up to one second's worth of data. It is safe for
+// concurrent use, so one limiter can be shared by many transfers.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(time.Duration)
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// burst is the largest number of bytes that should be transferred at once.
+func (l *rateLimiter) burst() int {
+	return int(l.rate)
+}
+
+// wait takes n tokens from the bucket, blocking until they are available.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit > 0 {
+		l.sleep(time.Duration(deficit / l.rate * float64(time.Second)))
+	}
+}
+
+// throttle limits a read to the burst sizes of the limiters, does it, then
+// waits for the limiters.
+func throttle(p []byte, read func([]byte) (int, error), limiters ...*rateLimiter) (int, error) {
+	for _, l := range limiters {
+		if l != nil && len(p) > l.burst() && l.burst() > 0 {
+			p = p[:l.burst()]
+		}
+	}
+
+	n, err := read(p)
+
+	for _, l := range limiters {
+		if l != nil && n > 0 {
+			l.wait(n)
+		}
+	}
+	return n, err
+}
+
+// throttledReader limits the rate at which a download is read.
+type throttledReader struct {
+	io.ReadCloser
+	limiters []*rateLimiter
+}
+
+func (r throttledReader) Read(p []byte) (int, error) {
+	return throttle(p, r.ReadCloser.Read, r.limiters...)
+}
+
+// throttledReadSeeker limits the rate at which an upload is read.
+type throttledReadSeeker struct {
+	io.ReadSeeker
+	limiters []*rateLimiter
+}
+
+func (r throttledReadSeeker) Read(p []byte) (int, error) {
+	return throttle(p, r.ReadSeeker.Read, r.limiters...)
+}
@@ -0,0 +1,62 @@
+package s3
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRateLimiter(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	now := time.Now()
+	var slept time.Duration
+	l := newRateLimiter(1000)
+	l.last = now
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+
+	// the initial burst is free
+	l.wait(1000)
+	g.Expect(slept).To(BeZero())
+
+	l.wait(500)
+	g.Expect(slept).To(Equal(500 * time.Millisecond))
+
+	// tokens accumulate while idle, up to the burst size
+	now = now.Add(time.Hour)
+	slept = 0
+	l.wait(1000)
+	l.wait(250)
+	g.Expect(slept).To(Equal(250 * time.Millisecond))
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stub := &s3stub{buf: bytes.NewBuffer(make([]byte, 4000))}
+	fs := NewFs("mybucket", stub).WithBandwidthLimit(1000)
+
+	var slept time.Duration
+	now := time.Now()
+	fs.limiter.last = now
+	fs.limiter.now = func() time.Time { return now }
+	fs.limiter.sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+
+	f, err := fs.Open("/a/b.bin")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	data, err := ioutil.ReadAll(f)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(HaveLen(4000))
+	g.Expect(slept).To(Equal(3 * time.Second))
+}
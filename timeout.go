@@ -0,0 +1,39 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// timeouts are the time limits for each kind of S3 request, including any
+// retries. Zero means there is no limit other than that of the context.
+type timeouts struct {
+	head     time.Duration // HeadObject, GetObjectAttributes and DeleteObject
+	list     time.Duration // ListObjectsV2
+	transfer time.Duration // GetObject, PutObject and CopyObject
+}
+
+// withTimeout derives a context with a time limit, if there is one.
+func withTimeout(ctx aws.Context, d time.Duration) (aws.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// cancelOnClose is a response body that cancels its request's context when
+// it is closed. The context must not be cancelled sooner because that would
+// abort reading the body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
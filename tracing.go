@@ -0,0 +1,130 @@
+package s3
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans created by this package.
+const tracerName = "github.com/rickb777/afero-s3"
+
+// Span attribute keys. The bucket and key follow the OpenTelemetry semantic
+// conventions for S3.
+const (
+	attrBucket = attribute.Key("aws.s3.bucket")
+	attrKey    = attribute.Key("aws.s3.key")
+	attrSize   = attribute.Key("file.size")
+)
+
+// operation tracks a file system operation, for logging and tracing.
+type operation struct {
+	start time.Time
+	span  trace.Span // nil if there is no span
+}
+
+// begin starts an operation. The context of the Fs is replaced, so that the
+// S3 requests made by the operation are traced as its children.
+func (fs *Fs) begin(op, name string) operation {
+	var o operation
+	fs.ctx, o = fs.beginWithContext(fs.ctx, op, name)
+	return o
+}
+
+// beginWithContext starts an operation that uses a given context, returning
+// the context to be used for its S3 requests.
+func (fs Fs) beginWithContext(ctx aws.Context, op, name string) (aws.Context, operation) {
+	o := operation{start: time.Now()}
+	if fs.tracer != nil {
+		ctx, o.span = fs.tracer.Start(ctx, "S3 "+op, trace.WithAttributes(
+			attrBucket.String(fs.bucket),
+			attrKey.String(fs.key(name)),
+		))
+	}
+	return ctx, o
+}
+
+// end ends the span of an operation, if any, recording the error and other attributes.
+func (o operation) end(err error, keyvals ...interface{}) {
+	if o.span == nil {
+		return
+	}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if keyvals[i] == "size" {
+			o.span.SetAttributes(attrSize.Int64(toInt64(keyvals[i+1])))
+		}
+	}
+	endSpan(o.span, err)
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return -1
+}
+
+// endSpan ends a span, recording the error, if any. Files that don't exist
+// or have not been modified are normal, so they are not treated as errors.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !isExpected(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingHook creates a span for each S3 request. It is the innermost hook,
+// so the span covers only the request itself.
+type tracingHook struct {
+	tracer trace.Tracer
+}
+
+func (h tracingHook) Before(r *Request) {
+	r.Context, _ = h.tracer.Start(r.Context, "S3."+r.Op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attrBucket.String(r.Bucket),
+		attrKey.String(r.Key),
+	))
+}
+
+func (h tracingHook) After(r *Request) {
+	span := trace.SpanFromContext(r.Context)
+	if size := requestSize(r); size >= 0 {
+		span.SetAttributes(attrSize.Int64(size))
+	}
+	endSpan(span, conditionOf(r.Err))
+}
+
+// requestSize gets the size of the object written or read by a request, or -1.
+func requestSize(r *Request) int64 {
+	switch v := r.Output.(type) {
+	case *s3.GetObjectOutput:
+		if v != nil && v.ContentLength != nil {
+			return *v.ContentLength
+		}
+	case *s3.HeadObjectOutput:
+		if v != nil && v.ContentLength != nil {
+			return *v.ContentLength
+		}
+	}
+
+	if in, ok := r.Input.(*s3.PutObjectInput); ok {
+		if in.ContentLength != nil {
+			return *in.ContentLength
+		}
+		if body, ok := in.Body.(interface{ Size() int64 }); ok {
+			return body.Size()
+		}
+	}
+	if in, ok := r.Input.(*s3.UploadPartInput); ok && in.ContentLength != nil {
+		return *in.ContentLength
+	}
+	return -1
+}
@@ -0,0 +1,91 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type testTracerProvider struct {
+	noop.TracerProvider
+	spans []*testSpan
+}
+
+func (tp *testTracerProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return testTracer{tp: tp}
+}
+
+type testTracer struct {
+	noop.Tracer
+	tp *testTracerProvider
+}
+
+func (t testTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &testSpan{name: name, attrs: make(map[attribute.Key]attribute.Value)}
+	if parent, ok := trace.SpanFromContext(ctx).(*testSpan); ok {
+		span.parent = parent.name
+	}
+	config := trace.NewSpanStartConfig(opts...)
+	for _, kv := range config.Attributes() {
+		span.attrs[kv.Key] = kv.Value
+	}
+	t.tp.spans = append(t.tp.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type testSpan struct {
+	noop.Span
+	name   string
+	parent string
+	attrs  map[attribute.Key]attribute.Value
+	status codes.Code
+	ended  bool
+}
+
+func (s *testSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *testSpan) SetStatus(code codes.Code, description string) { s.status = code }
+
+func (s *testSpan) End(options ...trace.SpanEndOption) { s.ended = true }
+
+func TestTracing(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tp := &testTracerProvider{}
+	stub := &s3stub{buf: bytes.NewBufferString("hello")}
+	fs := NewFs("mybucket", stub).WithTracerProvider(tp)
+
+	_, err := fs.Stat("/a/b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(tp.spans).To(HaveLen(2))
+	g.Expect(tp.spans[0].name).To(Equal("S3 Stat"))
+	g.Expect(tp.spans[0].attrs).To(HaveKeyWithValue(attrBucket, attribute.StringValue("mybucket")))
+	g.Expect(tp.spans[0].attrs).To(HaveKeyWithValue(attrKey, attribute.StringValue("a/b.txt")))
+	g.Expect(tp.spans[0].ended).To(BeTrue())
+	g.Expect(tp.spans[1].name).To(Equal("S3.HeadObject"))
+	g.Expect(tp.spans[1].parent).To(Equal("S3 Stat"))
+	g.Expect(tp.spans[1].attrs).To(HaveKeyWithValue(attrSize, attribute.Int64Value(123)))
+	g.Expect(tp.spans[1].ended).To(BeTrue())
+
+	tp.spans = nil
+	stub.failure = awserr.New("InternalError", "We encountered an internal error. Please try again.", nil)
+	err = fs.ForceRemove("/a/b.txt")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(tp.spans).To(HaveLen(2))
+	g.Expect(tp.spans[0].name).To(Equal("S3 ForceRemove"))
+	g.Expect(tp.spans[0].status).To(Equal(codes.Error))
+	g.Expect(tp.spans[1].name).To(Equal("S3.DeleteObject"))
+	g.Expect(tp.spans[1].status).To(Equal(codes.Error))
+}
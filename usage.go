@@ -0,0 +1,50 @@
+package s3
+
+import "strings"
+
+// Usage is the total size and number of the files below a directory, as
+// found by Fs.Usage.
+type Usage struct {
+	Bytes   int64
+	Objects int
+
+	// Subdirs breaks down the totals by each immediate subdirectory, keyed by
+	// its name. The files directly in the directory are only in the totals.
+	// The breakdowns themselves have no Subdirs.
+	Subdirs map[string]Usage
+}
+
+// Usage finds the disk usage of a directory, in the manner of "du", i.e. the
+// total size and number of the files in it and all its subdirectories,
+// with a breakdown by each immediate subdirectory. Directory markers are
+// not counted. This pages through a listing of every file, so the files
+// needn't all be held in memory, but it takes one request per 1000 files.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Usage(prefix string) (Usage, error) {
+	start := fs.begin("Usage", prefix)
+	usage := Usage{Subdirs: make(map[string]Usage)}
+
+	base := addTrailingSlash(fs.key(prefix))
+	it := fs.NewLister(prefix, ListOptions{Recursive: true, FilesOnly: true}).Iterator()
+	for it.Next() {
+		fi := it.FileInfo()
+		usage.Bytes += fi.Size()
+		usage.Objects++
+
+		rel := strings.TrimPrefix(fs.key(fi.Path()), base)
+		if i := strings.Index(rel, PathSeparator); i >= 0 {
+			sub := usage.Subdirs[rel[:i]]
+			sub.Bytes += fi.Size()
+			sub.Objects++
+			usage.Subdirs[rel[:i]] = sub
+		}
+	}
+	if err := it.Err(); err != nil {
+		fs.logOp("Usage", prefix, start, err)
+		return Usage{}, err
+	}
+
+	fs.logOp("Usage", prefix, start, nil, "bytes", usage.Bytes, "count", usage.Objects)
+	return usage, nil
+}
@@ -0,0 +1,42 @@
+package s3
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestUsage(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	for name, size := range map[string]int{
+		"/d/a.txt":       10,
+		"/d/b/c.txt":     20,
+		"/d/b/e/f.txt":   30,
+		"/d/g/h.txt":     40,
+		"/other/big.bin": 1000,
+	} {
+		g.Expect(afero.WriteFile(fs, name, []byte(strings.Repeat("x", size)), 0644)).To(Succeed())
+	}
+	g.Expect(fs.Mkdir("/d/empty", 0755)).To(Succeed())
+
+	usage, err := fs.Usage("/d")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(usage).To(Equal(Usage{
+		Bytes:   100,
+		Objects: 4,
+		Subdirs: map[string]Usage{
+			"b": {Bytes: 50, Objects: 2},
+			"g": {Bytes: 40, Objects: 1},
+		},
+	}))
+
+	usage, err = fs.Usage("/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(usage.Bytes).To(BeEquivalentTo(1100))
+	g.Expect(usage.Subdirs).To(HaveLen(2))
+}
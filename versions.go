@@ -0,0 +1,174 @@
+package s3
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Version is one version of an object in a bucket that has versioning
+// enabled, as listed by ListVersions. A delete marker is the version that
+// records the deletion of an object; it has no size or ETag.
+type Version struct {
+	Path           string
+	VersionId      string
+	IsLatest       bool
+	IsDeleteMarker bool
+	Size           int64
+	ModTime        time.Time
+	ETag           string
+}
+
+// ListVersions lists every version of the objects in the bucket with a given
+// prefix, including delete markers, so that the history of the files can be
+// audited. The prefix is a path; it selects every key that starts with it, so
+// "/logs/" lists the directory, including its subdirectories, whereas
+// "/logs/a.txt" lists the history of that file (and of any others whose
+// names begin with "a.txt"). The versions are in the order of their keys
+// and, for each key, newest first.
+//
+// On a bucket without versioning, each object has a single version whose
+// VersionId is "null".
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) ListVersions(prefix string) ([]Version, error) {
+	ctx, start := fs.beginWithContext(fs.ctx, "ListVersions", prefix)
+	versions, err := fs.listVersions(ctx, fs.key(prefix))
+	if err != nil {
+		err = pathError("list", prefix, err)
+		fs.logOp("ListVersions", prefix, start, err)
+		return nil, err
+	}
+
+	fs.logOp("ListVersions", prefix, start, nil, "count", len(versions))
+	return versions, nil
+}
+
+// Undelete restores a file that was removed from a bucket that has versioning
+// enabled. Removing a file only adds a delete marker, which hides the
+// previous versions; Undelete removes the latest delete markers, so that the
+// latest version of the file is current again. If the file has not been
+// removed, this does nothing. If it has no previous version to restore, the
+// error matches os.ErrNotExist.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Undelete(name string) error {
+	if err := fs.checkName("undelete", name); err != nil {
+		return err
+	}
+
+	ctx, start := fs.beginWithContext(fs.ctx, "Undelete", name)
+	key := fs.key(name)
+	versions, err := fs.listVersions(ctx, key)
+	if err != nil {
+		err = pathError("undelete", name, err)
+		fs.logOp("Undelete", name, start, err)
+		return err
+	}
+
+	// the delete markers newer than the latest version are removed
+	var markers []Version
+	found := false
+	for _, v := range versions {
+		if v.Path != fs.pathOf(key) {
+			continue // another key with the same prefix
+		}
+		if !v.IsDeleteMarker {
+			found = true
+			break
+		}
+		markers = append(markers, v)
+	}
+	if !found {
+		err = pathError("undelete", name, os.ErrNotExist)
+		fs.logOp("Undelete", name, start, err)
+		return err
+	}
+
+	defer fs.forget(name)
+	for _, m := range markers {
+		ctx, cancel := withTimeout(ctx, fs.timeouts.head)
+		_, err = fs.s3API.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket:    aws.String(fs.bucket),
+			Key:       aws.String(key),
+			VersionId: aws.String(m.VersionId),
+		})
+		cancel()
+		if err != nil {
+			err = pathError("undelete", name, err)
+			fs.logOp("Undelete", name, start, err)
+			return err
+		}
+	}
+
+	fs.logOp("Undelete", name, start, nil, "markers", len(markers))
+	return nil
+}
+
+// listVersions lists every version of the objects whose keys start with a
+// prefix, paging through the listing.
+func (fs Fs) listVersions(ctx aws.Context, prefix string) ([]Version, error) {
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	var versions []Version
+	for {
+		ctx, cancel := withTimeout(ctx, fs.timeouts.list)
+		output, err := fs.s3API.ListObjectVersionsWithContext(ctx, input)
+		cancel()
+
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, fs.versionsOf(output)...)
+
+		if !aws.BoolValue(output.IsTruncated) {
+			return versions, nil
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.VersionIdMarker = output.NextVersionIdMarker
+	}
+}
+
+// versionsOf merges the versions and delete markers of one page of a
+// listing, which S3 returns separately.
+func (fs Fs) versionsOf(output *s3.ListObjectVersionsOutput) []Version {
+	versions := make([]Version, 0, len(output.Versions)+len(output.DeleteMarkers))
+	for _, v := range output.Versions {
+		versions = append(versions, Version{
+			Path:      fs.pathOf(aws.StringValue(v.Key)),
+			VersionId: aws.StringValue(v.VersionId),
+			IsLatest:  aws.BoolValue(v.IsLatest),
+			Size:      aws.Int64Value(v.Size),
+			ModTime:   aws.TimeValue(v.LastModified),
+			ETag:      aws.StringValue(v.ETag),
+		})
+	}
+	for _, m := range output.DeleteMarkers {
+		versions = append(versions, Version{
+			Path:           fs.pathOf(aws.StringValue(m.Key)),
+			VersionId:      aws.StringValue(m.VersionId),
+			IsLatest:       aws.BoolValue(m.IsLatest),
+			IsDeleteMarker: true,
+			ModTime:        aws.TimeValue(m.LastModified),
+		})
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.IsLatest != b.IsLatest {
+			return a.IsLatest
+		}
+		return a.ModTime.After(b.ModTime)
+	})
+	return versions
+}
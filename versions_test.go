@@ -0,0 +1,127 @@
+package s3
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestListVersions(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.Versioning = true
+	fs := NewFs("mybucket", mem)
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tick := 0
+	mem.Now = func() time.Time {
+		tick++
+		return t0.Add(time.Duration(tick) * time.Minute)
+	}
+
+	g.Expect(afero.WriteFile(fs, "/d/a.txt", []byte("one"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/d/a.txt", []byte("three"), 0644)).To(Succeed())
+	g.Expect(fs.Remove("/d/a.txt")).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/d/b.txt", []byte("bb"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/e/c.txt", []byte("c"), 0644)).To(Succeed())
+
+	versions, err := fs.ListVersions("/d/")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(versions).To(HaveLen(4))
+
+	g.Expect(versions[0].Path).To(Equal("/d/a.txt"))
+	g.Expect(versions[0].IsDeleteMarker).To(BeTrue())
+	g.Expect(versions[0].IsLatest).To(BeTrue())
+
+	g.Expect(versions[1].Path).To(Equal("/d/a.txt"))
+	g.Expect(versions[1].IsDeleteMarker).To(BeFalse())
+	g.Expect(versions[1].IsLatest).To(BeFalse())
+	g.Expect(versions[1].Size).To(BeEquivalentTo(5))
+	g.Expect(versions[1].ModTime.After(versions[2].ModTime)).To(BeTrue())
+
+	g.Expect(versions[2].Size).To(BeEquivalentTo(3))
+	g.Expect(versions[2].VersionId).NotTo(Equal(versions[1].VersionId))
+
+	g.Expect(versions[3].Path).To(Equal("/d/b.txt"))
+	g.Expect(versions[3].IsLatest).To(BeTrue())
+	g.Expect(versions[3].ETag).NotTo(BeEmpty())
+}
+
+func TestVersionIDOfWrittenFile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.Versioning = true
+	fs := NewFs("mybucket", mem)
+
+	f, err := fs.Create("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = f.WriteString("hello")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(f.(*File).VersionID()).To(BeEmpty())
+	g.Expect(f.Close()).To(Succeed())
+
+	v1 := f.(*File).VersionID()
+	g.Expect(v1).NotTo(BeEmpty())
+
+	fi, err := f.Stat()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Sys().(*ObjectInfo).VersionId).To(Equal(v1))
+
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("again"), 0644)).To(Succeed())
+
+	r, err := fs.Open("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r.(*File).VersionID()).NotTo(Equal(v1))
+	g.Expect(r.Close()).To(Succeed())
+
+	versions, err := fs.ListVersions("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(versions).To(HaveLen(2))
+	g.Expect(versions[1].VersionId).To(Equal(v1))
+}
+
+func TestUndelete(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	mem.Versioning = true
+	fs := NewFs("mybucket", mem)
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tick := 0
+	mem.Now = func() time.Time {
+		tick++
+		return t0.Add(time.Duration(tick) * time.Minute)
+	}
+
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("one"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("two"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/a.txt.bak", []byte("bak"), 0644)).To(Succeed())
+	g.Expect(fs.Remove("/a.txt")).To(Succeed())
+	g.Expect(fs.ForceRemove("/a.txt")).To(Succeed())
+
+	_, err := fs.Stat("/a.txt")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+
+	g.Expect(fs.Undelete("/a.txt")).To(Succeed())
+
+	data, err := afero.ReadFile(fs, "/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("two"))
+
+	// undeleting an existing file does nothing
+	g.Expect(fs.Undelete("/a.txt")).To(Succeed())
+
+	versions, err := fs.ListVersions("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(versions).To(HaveLen(3))
+
+	err = fs.Undelete("/b.txt")
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+}
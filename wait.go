@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var errNotVisible = errors.New("the object written is not yet visible")
+
+// The polling interval starts at minWaitInterval and doubles after each
+// attempt, up to maxWaitInterval.
+const (
+	minWaitInterval = 50 * time.Millisecond
+	maxWaitInterval = 2 * time.Second
+)
+
+// WithWaitUntilVisible sets a time limit in a new instance of the file system
+// for waiting until each file written is visible, i.e. until HeadObject
+// reports the object with the ETag it was written with. Close returns when
+// it is visible, or fails if it is not within the time limit. Zero, the
+// default, means there is no wait.
+//
+// S3 itself has strong read-after-write consistency, so this is only needed
+// for S3-compatible stores that are eventually consistent.
+func (fs Fs) WithWaitUntilVisible(timeout time.Duration) *Fs {
+	fs.waitTimeout = timeout
+	return &fs
+}
+
+// waitUntilVisible polls HeadObject until the object has the given ETag, if
+// the file system is configured to wait.
+func (fs Fs) waitUntilVisible(ctx aws.Context, name, etag string) error {
+	if fs.waitTimeout <= 0 {
+		return nil
+	}
+
+	ctx, start := fs.beginWithContext(ctx, "Wait", name)
+	ctx, cancel := withTimeout(ctx, fs.waitTimeout)
+	defer cancel()
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.key(name)),
+	}
+	interval := minWaitInterval
+	for attempts := 1; ; attempts++ {
+		output, err := fs.s3API.HeadObjectWithContext(ctx, input)
+		if err == nil && aws.StringValue(output.ETag) == etag {
+			fs.logOp("Wait", name, start, nil, "attempts", attempts)
+			return nil
+		}
+		if err != nil && !isNotFound(err) && ctx.Err() == nil {
+			fs.logOp("Wait", name, start, err, "attempts", attempts)
+			return err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fs.logOp("Wait", name, start, errNotVisible, "attempts", attempts)
+			return errNotVisible
+		case <-timer.C:
+		}
+		if interval *= 2; interval > maxWaitInterval {
+			interval = maxWaitInterval
+		}
+	}
+}
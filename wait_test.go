@@ -0,0 +1,79 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+// laggingBucket reports that objects don't exist until they have been
+// requested a few times, like an eventually consistent store.
+type laggingBucket struct {
+	*s3fake.Bucket
+	lag   int
+	heads int
+}
+
+func (b *laggingBucket) HeadObjectWithContext(ctx aws.Context, req *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	b.heads++
+	if b.heads <= b.lag {
+		return nil, awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, "")
+	}
+	return b.Bucket.HeadObjectWithContext(ctx, req, opts...)
+}
+
+func TestWaitUntilVisible(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &laggingBucket{Bucket: s3fake.New(), lag: 3}
+	fs := NewFs("mybucket", mem)
+
+	// by default, there is no wait
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("a"), 0644)).To(Succeed())
+	g.Expect(mem.heads).To(Equal(0))
+
+	g.Expect(afero.WriteFile(fs.WithWaitUntilVisible(time.Minute), "/b.txt", []byte("b"), 0644)).To(Succeed())
+	g.Expect(mem.heads).To(Equal(4))
+
+	mem.heads, mem.lag = 0, 1000
+	err := afero.WriteFile(fs.WithWaitUntilVisible(100*time.Millisecond), "/c.txt", []byte("c"), 0644)
+	g.Expect(err).To(MatchError(ContainSubstring(errNotVisible.Error())))
+	g.Expect(mem.heads).To(BeNumerically(">", 1))
+}
+
+func TestWithReadYourWrites(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &laggingBucket{Bucket: s3fake.New(), lag: 1000}
+	fs := NewFs("mybucket", mem).WithReadYourWrites(time.Minute)
+
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("hello"), 0644)).To(Succeed())
+
+	fi, err := fs.Stat("/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fi.Size()).To(Equal(int64(5)))
+	g.Expect(fi.Sys().(*ObjectInfo).ETag).NotTo(BeEmpty())
+
+	data, err := afero.ReadFile(fs, "/a.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(Equal("hello"))
+	g.Expect(mem.heads).To(Equal(0))
+
+	// removal forgets the record
+	g.Expect(fs.Remove("/a.txt")).To(Succeed())
+	_, err = fs.Stat("/a.txt")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(mem.heads).To(Equal(1))
+
+	// without the record, Stat asks S3
+	g.Expect(afero.WriteFile(fs.WithReadYourWrites(0), "/b.txt", []byte("b"), 0644)).To(Succeed())
+	_, err = fs.Stat("/b.txt")
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,121 @@
+package s3
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Walk walks the file tree rooted at root, calling walkFn for each file or
+// directory in the tree, including root, in the same way as afero.Walk and
+// filepath.Walk. The files are walked in lexical order and filepath.SkipDir
+// and filepath.SkipAll are supported.
+//
+// Unlike afero.Walk, which lists each directory and gets the file info for
+// each entry separately, this lists everything below root at once, without
+// a delimiter, and infers the directories from the keys. This needs only one
+// request per 1000 files, however deep the tree is. The whole listing is held
+// in memory while walking. Directories that are only implied by the keys
+// have no modification time.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Walk(root string, walkFn filepath.WalkFunc) error {
+	info, err := fs.lstat(root)
+	switch {
+	case err != nil:
+		err = walkFn(root, nil, err)
+	case !info.IsDir():
+		err = walkFn(root, info, nil)
+	default:
+		var tree map[string][]os.FileInfo
+		tree, err = fs.walkTree(root)
+		if err != nil {
+			err = walkFn(root, info, err)
+		} else {
+			err = walkTree(root, "", info, tree, walkFn)
+		}
+	}
+
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkTree lists everything below root and groups the entries by the
+// directory that contains them, relative to root, sorted by name.
+func (fs Fs) walkTree(root string) (map[string][]os.FileInfo, error) {
+	prefix := fs.key(root)
+	if prefix != "" {
+		prefix = addTrailingSlash(prefix)
+	}
+
+	entries := make(map[string]map[string]os.FileInfo)
+	add := func(rel string, fi os.FileInfo) {
+		dir, name := path.Split(rel)
+		dir = trimTrailingSlash(dir)
+		if entries[dir] == nil {
+			entries[dir] = make(map[string]os.FileInfo)
+		}
+		if _, exists := entries[dir][name]; !exists || !fi.ModTime().IsZero() {
+			entries[dir][name] = fi
+		}
+	}
+
+	it := fs.NewLister(root, ListOptions{Recursive: true, IncludeOwner: true}).Iterator()
+	for it.Next() {
+		fi := it.FileInfo()
+		key := fs.key(fi.Path())
+		if len(key) <= len(prefix) {
+			continue // the marker of root itself
+		}
+		rel := key[len(prefix):]
+		add(rel, fi)
+
+		// the parent directories are implied, even without markers
+		for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+			add(dir, fs.applyDefaultPerm(NewDirectoryInfo(path.Join(root, dir))))
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	tree := make(map[string][]os.FileInfo, len(entries))
+	for dir, children := range entries {
+		list := make([]os.FileInfo, 0, len(children))
+		for _, fi := range children {
+			list = append(list, fi)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+		tree[dir] = list
+	}
+	return tree, nil
+}
+
+// walkTree walks a directory within the tree found by Fs.walkTree.
+func walkTree(name, rel string, info os.FileInfo, tree map[string][]os.FileInfo, walkFn filepath.WalkFunc) error {
+	if err := walkFn(name, info, nil); err != nil {
+		return err
+	}
+
+	for _, child := range tree[rel] {
+		childName := path.Join(name, child.Name())
+		var err error
+		if child.IsDir() {
+			err = walkTree(childName, path.Join(rel, child.Name()), child, tree, walkFn)
+		} else {
+			err = walkFn(childName, child, nil)
+		}
+
+		if err == filepath.SkipDir {
+			if !child.IsDir() {
+				return nil // skip the rest of this directory
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestWalk(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem)
+	g.Expect(fs.Mkdir("/root/empty", 0755)).To(Succeed())
+	for _, name := range []string{"/root/a/b/c/deep.txt", "/root/a-c.txt", "/root/a/x.txt", "/root/z/skip/me.txt", "/root/z/y.txt", "/other.txt"} {
+		g.Expect(afero.WriteFile(fs, name, []byte("x"), 0644)).To(Succeed())
+	}
+
+	var visited []string
+	walkFn := func(p string, info os.FileInfo, err error) error {
+		g.Expect(err).NotTo(HaveOccurred())
+		visited = append(visited, p)
+		if info.Name() == "skip" {
+			g.Expect(info.IsDir()).To(BeTrue())
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	lists := mem.Lists
+	g.Expect(fs.Walk("/root", walkFn)).To(Succeed())
+	g.Expect(visited).To(Equal([]string{
+		"/root",
+		"/root/a",
+		"/root/a/b",
+		"/root/a/b/c",
+		"/root/a/b/c/deep.txt",
+		"/root/a/x.txt",
+		"/root/a-c.txt",
+		"/root/empty",
+		"/root/z",
+		"/root/z/skip",
+		"/root/z/y.txt",
+	}))
+	// one for Stat of the root directory and one for the whole tree
+	g.Expect(mem.Lists - lists).To(Equal(2))
+
+	// the same as afero.Walk
+	fast := visited
+	visited = nil
+	g.Expect(afero.Walk(fs, "/root", walkFn)).To(Succeed())
+	g.Expect(fast).To(Equal(visited))
+
+	visited = nil
+	err := fs.Walk("/root", func(p string, info os.FileInfo, err error) error {
+		visited = append(visited, p)
+		if p == "/root/a/b" {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(visited).To(Equal([]string{"/root", "/root/a", "/root/a/b"}))
+
+	err = fs.Walk("/missing", func(p string, info os.FileInfo, err error) error {
+		return err
+	})
+	g.Expect(os.IsNotExist(err)).To(BeTrue())
+}
@@ -0,0 +1,187 @@
+package s3
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventOp is the kind of change described by an Event.
+type EventOp int
+
+const (
+	// Created means that a file has appeared.
+	Created EventOp = iota + 1
+	// Modified means that a file has been replaced by a different version.
+	Modified
+	// Removed means that a file has gone.
+	Removed
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case Created:
+		return "CREATE"
+	case Modified:
+		return "MODIFY"
+	case Removed:
+		return "REMOVE"
+	}
+	return "?"
+}
+
+// Event describes a change to a file, as seen by a Watcher.
+type Event struct {
+	Op   EventOp
+	Path string
+	// Info describes the file, except for Removed, when it describes the file
+	// as it was last seen, if known.
+	Info FileInfo
+}
+
+// Watcher sends an Event on its Events channel for each change to the files
+// being watched, in the manner of fsnotify. Errors, such as failed listings,
+// are sent on its Errors channel; the watcher carries on regardless. Both
+// channels must be received from until Close is called, which then closes
+// them.
+type Watcher struct {
+	Events <-chan Event
+	Errors <-chan error
+
+	events chan Event
+	errors chan error
+	done   chan struct{}
+	stop   func() // cancels any request in progress, if not nil
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+func newWatcher() *Watcher {
+	w := &Watcher{
+		events: make(chan Event),
+		errors: make(chan error),
+		done:   make(chan struct{}),
+	}
+	w.Events, w.Errors = w.events, w.errors
+	return w
+}
+
+// Close stops watching and closes the channels.
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		if w.stop != nil {
+			w.stop()
+		}
+		w.wg.Wait()
+		close(w.events)
+		close(w.errors)
+	})
+	return nil
+}
+
+// sendEvent sends an event, unless the watcher is closed. It reports whether
+// the event was sent.
+func (w *Watcher) sendEvent(e Event) bool {
+	select {
+	case w.events <- e:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// sendError sends an error, unless the watcher is closed.
+func (w *Watcher) sendError(err error) bool {
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// Watch watches the files in the bucket with a given prefix, including
+// those in all subdirectories, by listing them periodically. Each listing
+// is compared with the one before, using the ETags and modification times
+// of the files, and an event is sent for each difference. The first listing
+// is done before Watch returns, so only later changes are reported. Any
+// cached information about the changed files is discarded.
+//
+// Each listing needs one request per 1000 files, so the interval should
+// allow for the number of files and the cost of the requests. Changes that
+// are undone within the interval are not seen.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Watch(prefix string, interval time.Duration) (*Watcher, error) {
+	previous, err := fs.snapshot(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	w := newWatcher()
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+
+			current, err := fs.snapshot(prefix)
+			if err != nil {
+				if !w.sendError(err) {
+					return
+				}
+				continue
+			}
+
+			for _, e := range changes(previous, current) {
+				fs.forgetAll(e.Path)
+				if !w.sendEvent(e) {
+					return
+				}
+			}
+			previous = current
+		}
+	}()
+	return w, nil
+}
+
+// snapshot lists the files with a given prefix, by path.
+func (fs Fs) snapshot(prefix string) (map[string]FileInfo, error) {
+	files := make(map[string]FileInfo)
+	it := fs.Objects(prefix)
+	for it.Next() {
+		fi := it.FileInfo()
+		files[fi.Path()] = fi
+	}
+	return files, it.Err()
+}
+
+// changes compares two snapshots, giving the events in order of their paths.
+func changes(previous, current map[string]FileInfo) []Event {
+	var events []Event
+	for p, fi := range current {
+		was, existed := previous[p]
+		switch {
+		case !existed:
+			events = append(events, Event{Op: Created, Path: p, Info: fi})
+		case was.ETag() != fi.ETag() || !was.ModTime().Equal(fi.ModTime()):
+			events = append(events, Event{Op: Modified, Path: p, Info: fi})
+		}
+	}
+	for p, fi := range previous {
+		if _, exists := current[p]; !exists {
+			events = append(events, Event{Op: Removed, Path: p, Info: fi})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	return events
+}
@@ -0,0 +1,182 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// SQSAPISubset is the subset of
+// github.com/aws/aws-sdk-go/service/sqs/sqsiface.SQSAPI used by WatchQueue.
+type SQSAPISubset interface {
+	ReceiveMessageWithContext(aws.Context, *sqs.ReceiveMessageInput, ...request.Option) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageWithContext(aws.Context, *sqs.DeleteMessageInput, ...request.Option) (*sqs.DeleteMessageOutput, error)
+}
+
+// queueRetryDelay is the pause after a failure to receive messages.
+const queueRetryDelay = 5 * time.Second
+
+// WatchQueue watches the files in the bucket with a given prefix by
+// consuming the S3 event notifications sent to an SQS queue, as configured
+// on the bucket, which gives near-real-time changes without listing. The
+// notifications may be sent directly by S3, via SNS or via EventBridge.
+// ObjectCreated notifications are sent as Created events and ObjectRemoved
+// notifications as Removed events; S3 does not distinguish new files from
+// replaced ones. Any cached information about the changed files is
+// discarded, so this can also be used only to keep the caches up to date.
+//
+// Each message is deleted from the queue once its events have been sent,
+// including messages about other buckets and prefixes, so the queue should
+// not be shared with other consumers. Failures to receive messages are sent
+// on the watcher's Errors channel and then retried after a pause.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) WatchQueue(sqsAPI SQSAPISubset, queueURL, prefix string) *Watcher {
+	ctx, cancel := context.WithCancel(fs.ctx)
+	w := newWatcher()
+	w.stop = cancel
+	base := addTrailingSlash(fs.key(prefix))
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for {
+			output, err := sqsAPI.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+				QueueUrl:            aws.String(queueURL),
+				MaxNumberOfMessages: aws.Int64(10),
+				WaitTimeSeconds:     aws.Int64(20),
+			})
+			if ctx.Err() != nil {
+				return // closed
+			}
+			if err != nil {
+				if !w.sendError(err) {
+					return
+				}
+				select {
+				case <-w.done:
+					return
+				case <-time.After(queueRetryDelay):
+				}
+				continue
+			}
+
+			for _, m := range output.Messages {
+				for _, e := range fs.parseNotification(aws.StringValue(m.Body), base) {
+					fs.forgetAll(e.Path)
+					if !w.sendEvent(e) {
+						return
+					}
+				}
+
+				_, err := sqsAPI.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+					QueueUrl:      aws.String(queueURL),
+					ReceiptHandle: m.ReceiptHandle,
+				})
+				if err != nil && ctx.Err() == nil && !w.sendError(err) {
+					return
+				}
+			}
+		}
+	}()
+	return w
+}
+
+// notification is the union of the message formats of S3 event
+// notifications: S3's own, wrapped in an SNS notification, and EventBridge.
+type notification struct {
+	// S3
+	Records []struct {
+		EventName string    `json:"eventName"`
+		EventTime time.Time `json:"eventTime"`
+		S3        struct {
+			Bucket notifiedBucket `json:"bucket"`
+			Object notifiedObject `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+
+	// SNS
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+
+	// EventBridge
+	DetailType string    `json:"detail-type"`
+	Time       time.Time `json:"time"`
+	Detail     struct {
+		Bucket notifiedBucket `json:"bucket"`
+		Object notifiedObject `json:"object"`
+	} `json:"detail"`
+}
+
+type notifiedBucket struct {
+	Name string `json:"name"`
+}
+
+type notifiedObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"eTag"`
+	Etag      string `json:"etag"` // EventBridge
+	VersionId string `json:"versionId"`
+	// EventBridge uses "version-id"
+	Version string `json:"version-id"`
+}
+
+// parseNotification gets the events in a message about the files in this
+// bucket whose keys start with base. Messages that cannot be parsed, such as
+// the test event sent by S3 when notifications are configured, are ignored.
+func (fs Fs) parseNotification(body, base string) []Event {
+	var n notification
+	if err := json.Unmarshal([]byte(body), &n); err != nil {
+		return nil
+	}
+	if n.Type == "Notification" && n.Message != "" {
+		return fs.parseNotification(n.Message, base)
+	}
+
+	var events []Event
+	add := func(op EventOp, bucket string, obj notifiedObject, t time.Time, encoded bool) {
+		key := obj.Key
+		if encoded {
+			// S3 notifications have URL-encoded keys, but EventBridge does not
+			var err error
+			if key, err = url.QueryUnescape(key); err != nil {
+				return
+			}
+		}
+		if bucket != fs.bucket || !strings.HasPrefix(key, base) || hasTrailingSlash(key) {
+			return
+		}
+
+		oi := ObjectInfo{VersionId: obj.VersionId + obj.Version, Uid: -1, Gid: -1}
+		if etag := obj.ETag + obj.Etag; etag != "" {
+			oi.ETag = `"` + etag + `"`
+		}
+		p := fs.pathOf(key)
+		fi := fs.applyDefaultPerm(NewFileInfo(p, obj.Size, t).withObjectInfo(oi))
+		events = append(events, Event{Op: op, Path: p, Info: fi})
+	}
+
+	for _, r := range n.Records {
+		switch {
+		case strings.HasPrefix(r.EventName, "ObjectCreated:"):
+			add(Created, r.S3.Bucket.Name, r.S3.Object, r.EventTime, true)
+		case strings.HasPrefix(r.EventName, "ObjectRemoved:"):
+			add(Removed, r.S3.Bucket.Name, r.S3.Object, r.EventTime, true)
+		}
+	}
+
+	switch n.DetailType {
+	case "Object Created":
+		add(Created, n.Detail.Bucket.Name, n.Detail.Object, n.Time, false)
+	case "Object Deleted":
+		add(Removed, n.Detail.Bucket.Name, n.Detail.Object, n.Time, false)
+	}
+	return events
+}
@@ -0,0 +1,102 @@
+package s3
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+// sqsStub serves a list of messages, then waits until cancelled.
+type sqsStub struct {
+	mu       sync.Mutex
+	messages []*sqs.Message
+	deleted  []string
+}
+
+func (s *sqsStub) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	s.mu.Lock()
+	messages := s.messages
+	s.messages = nil
+	s.mu.Unlock()
+
+	if len(messages) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (s *sqsStub) DeleteMessageWithContext(ctx aws.Context, input *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, aws.StringValue(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func message(handle, body string) *sqs.Message {
+	return &sqs.Message{ReceiptHandle: aws.String(handle), Body: aws.String(body)}
+}
+
+func TestWatchQueue(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New()).WithStatCache(time.Hour, 100)
+	g.Expect(afero.WriteFile(fs, "/d/a b.txt", []byte("a"), 0644)).To(Succeed())
+	_, err := fs.Stat("/d/a b.txt")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, cached := fs.statCache.get(fs.key("/d/a b.txt"))
+	g.Expect(cached).To(BeTrue())
+
+	queue := &sqsStub{messages: []*sqs.Message{
+		message("1", `{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"mybucket"}`),
+		message("2", `{"Records":[
+			{"eventName":"ObjectCreated:Put","eventTime":"2024-01-01T10:00:00.000Z",
+			 "s3":{"bucket":{"name":"mybucket"},"object":{"key":"d/a+b.txt","size":7,"eTag":"abc"}}},
+			{"eventName":"ObjectCreated:Put","eventTime":"2024-01-01T10:00:00.000Z",
+			 "s3":{"bucket":{"name":"other"},"object":{"key":"d/x.txt","size":1,"eTag":"xyz"}}},
+			{"eventName":"ObjectCreated:Put","eventTime":"2024-01-01T10:00:00.000Z",
+			 "s3":{"bucket":{"name":"mybucket"},"object":{"key":"e/y.txt","size":1,"eTag":"xyz"}}}]}`),
+		message("3", `{"Type":"Notification","Message":"{\"Records\":[{\"eventName\":\"ObjectRemoved:Delete\",\"s3\":{\"bucket\":{\"name\":\"mybucket\"},\"object\":{\"key\":\"d/c.txt\"}}}]}"}`),
+		message("4", `{"version":"0","detail-type":"Object Created","source":"aws.s3","time":"2024-01-01T11:00:00Z",
+			"detail":{"bucket":{"name":"mybucket"},"object":{"key":"d/sub/e f.txt","size":3,"etag":"def","version-id":"v1"}}}`),
+	}}
+
+	w := fs.WatchQueue(queue, "https://sqs.example/queue", "/d")
+
+	var events []Event
+	for len(events) < 3 {
+		select {
+		case e := <-w.Events:
+			events = append(events, e)
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d events", len(events))
+		}
+	}
+	g.Expect(w.Close()).To(Succeed())
+
+	g.Expect(events[0].Op).To(Equal(Created))
+	g.Expect(events[0].Path).To(Equal("/d/a b.txt"))
+	g.Expect(events[0].Info.Size()).To(BeEquivalentTo(7))
+	g.Expect(events[0].Info.ETag()).To(Equal(`"abc"`))
+	g.Expect(events[0].Info.ModTime()).To(Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)))
+	g.Expect(events[1].Op).To(Equal(Removed))
+	g.Expect(events[1].Path).To(Equal("/d/c.txt"))
+	g.Expect(events[2].Op).To(Equal(Created))
+	g.Expect(events[2].Path).To(Equal("/d/sub/e f.txt"))
+	g.Expect(events[2].Info.Sys().(*ObjectInfo).VersionId).To(Equal("v1"))
+
+	g.Expect(queue.deleted).To(Equal([]string{"1", "2", "3", "4"}))
+
+	// the cached info was discarded
+	_, cached = fs.statCache.get(fs.key("/d/a b.txt"))
+	g.Expect(cached).To(BeFalse())
+}
@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestWatch(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	fs := NewFs("mybucket", s3fake.New())
+	g.Expect(afero.WriteFile(fs, "/d/a.txt", []byte("a"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/d/b.txt", []byte("b"), 0644)).To(Succeed())
+
+	w, err := fs.Watch("/d", 10*time.Millisecond)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer w.Close()
+
+	g.Expect(afero.WriteFile(fs, "/d/a.txt", []byte("changed"), 0644)).To(Succeed())
+	g.Expect(fs.Remove("/d/b.txt")).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/d/sub/c.txt", []byte("c"), 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/e/d.txt", []byte("d"), 0644)).To(Succeed())
+
+	var events []Event
+	timeout := time.After(5 * time.Second)
+	for len(events) < 3 {
+		select {
+		case e := <-w.Events:
+			events = append(events, e)
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("only %d events", len(events))
+		}
+	}
+
+	g.Expect(events[0].Op).To(Equal(Modified))
+	g.Expect(events[0].Path).To(Equal("/d/a.txt"))
+	g.Expect(events[0].Info.Size()).To(BeEquivalentTo(7))
+	g.Expect(events[1].Op).To(Equal(Removed))
+	g.Expect(events[1].Path).To(Equal("/d/b.txt"))
+	g.Expect(events[2].Op).To(Equal(Created))
+	g.Expect(events[2].Path).To(Equal("/d/sub/c.txt"))
+
+	g.Expect(w.Close()).To(Succeed())
+	_, open := <-w.Events
+	g.Expect(open).To(BeFalse())
+}
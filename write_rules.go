@@ -0,0 +1,119 @@
+package s3
+
+import (
+	"os"
+	"time"
+)
+
+// WriteRule sets properties of the files written whose names match a
+// pattern; see Fs.WithWriteRules. Zero fields leave the properties unchanged.
+type WriteRule struct {
+	// Path is a pattern, as used by path.Match, for the names of the files
+	// affected. It also affects everything below the directories it matches,
+	// so "/public" affects "/public/a/b.txt".
+	Path string
+
+	// Expires sets the Expires header of the files to the time they are
+	// written plus this duration, so that browsers and CDNs cache them until
+	// then.
+	Expires time.Duration
+
+	// CacheControl sets the Cache-Control header of the files, e.g.
+	// "public, max-age=86400". It can be overridden using File.SetHeader.
+	CacheControl string
+
+	// RedirectLocation sets the x-amz-website-redirect-location header of the
+	// files, so that a bucket configured for static website hosting redirects
+	// requests for them to this URL, or to this path within the bucket if it
+	// starts with a slash.
+	RedirectLocation string
+
+	// EncryptionContext sets the KMS encryption context of the files, e.g. to
+	// the ID of the tenant that owns them, so that KMS key policies can
+	// restrict who may decrypt them. This implies SSE-KMS, using the key set
+	// by Fs.WithKMSEncryption, if any. It applies to files that are renamed
+	// too.
+	EncryptionContext map[string]string
+}
+
+// WithWriteRules sets rules for the files written by a new instance of the
+// file system, replacing any set before. Each rule whose pattern matches the
+// name of a file is applied when the file is opened, in order, so later
+// rules take precedence. They override the defaults set for the file system,
+// e.g. by WithExpires, but not the settings made for a file, e.g. by
+// File.WithExpires.
+func (fs Fs) WithWriteRules(rules ...WriteRule) *Fs {
+	fs.writeRules = append([]WriteRule(nil), rules...)
+	return &fs
+}
+
+// WithExpires sets the Expires header of every file written by a new instance
+// of the file system to the time it is written plus the given duration, so
+// that browsers and CDNs cache it until then. This can be overridden using
+// WithWriteRules or File.WithExpires. Zero means that no Expires header is
+// sent, which is the default.
+func (fs Fs) WithExpires(after time.Duration) *Fs {
+	fs.writeOpts.expiresAfter = after
+	return &fs
+}
+
+// writeOptionsFor gets the write options for a file, i.e. the defaults with
+// the matching rules applied.
+func (fs Fs) writeOptionsFor(name string) writeOptions {
+	opts := fs.writeOpts
+	for _, r := range fs.writeRules {
+		if !pathMatches(r.Path, name) {
+			continue
+		}
+		if r.Expires != 0 {
+			opts.expiresAfter = r.Expires
+			opts.expires = nil
+		}
+		if r.CacheControl != "" {
+			opts.headers.cacheControl = &r.CacheControl
+		}
+		if r.RedirectLocation != "" {
+			opts.redirect = &r.RedirectLocation
+		}
+		if len(r.EncryptionContext) > 0 {
+			opts.kmsContext = encryptionContext(r.EncryptionContext)
+		}
+	}
+	return opts
+}
+
+// WithExpires sets the Expires header of a new instance of the file,
+// overriding the defaults set by Fs.WithExpires and Fs.WithWriteRules. The
+// zero time means that no Expires header is sent.
+func (f File) WithExpires(at time.Time) *File {
+	f.writeOpts.expiresAfter = 0
+	if at.IsZero() {
+		f.writeOpts.expires = nil
+	} else {
+		f.writeOpts.expires = &at
+	}
+	return &f
+}
+
+// WithRedirectLocation sets the x-amz-website-redirect-location header of a
+// new instance of the file, overriding any set by Fs.WithWriteRules. A
+// bucket configured for static website hosting then redirects requests for
+// the file to this URL, or to this path within the bucket if it starts with
+// a slash. A blank location means that no redirect is set.
+func (f File) WithRedirectLocation(location string) *File {
+	f.writeOpts.redirect = optionalString(location)
+	return &f
+}
+
+// Redirect writes an empty file that a bucket configured for static website
+// hosting redirects to another location, which is a URL or a path within the
+// bucket starting with a slash; see File.WithRedirectLocation.
+//
+// This is an extension to the Afero Fs API.
+func (fs Fs) Redirect(name, location string) error {
+	file, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fs.fileMode)
+	if err != nil {
+		return err
+	}
+	return file.(*File).WithRedirectLocation(location).Close()
+}
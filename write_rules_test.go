@@ -0,0 +1,86 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	. "github.com/onsi/gomega"
+	"github.com/rickb777/afero-s3/internal/s3fake"
+	"github.com/spf13/afero"
+)
+
+func TestWithExpires(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &recordingBucket{Bucket: s3fake.New()}
+	fs := NewFs("mybucket", mem).
+		WithExpires(time.Hour).
+		WithWriteRules(
+			WriteRule{Path: "/static", Expires: 24 * time.Hour},
+			WriteRule{Path: "/static/*.html", Expires: time.Minute},
+		)
+
+	before := time.Now()
+	for _, name := range []string{"/a.txt", "/static/b.css", "/static/c.html", "/static/d.html"} {
+		af, err := fs.Create(name)
+		g.Expect(err).NotTo(HaveOccurred())
+		if name == "/static/d.html" {
+			af = af.(*File).WithExpires(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+		}
+		g.Expect(af.Close()).To(Succeed())
+	}
+	g.Expect(afero.WriteFile(NewFs("mybucket", mem), "/e.txt", nil, 0644)).To(Succeed())
+
+	g.Expect(mem.puts).To(HaveLen(5))
+	g.Expect(aws.TimeValue(mem.puts[0].Expires)).To(BeTemporally("~", before.Add(time.Hour), time.Second))
+	g.Expect(aws.TimeValue(mem.puts[1].Expires)).To(BeTemporally("~", before.Add(24*time.Hour), time.Second))
+	g.Expect(aws.TimeValue(mem.puts[2].Expires)).To(BeTemporally("~", before.Add(time.Minute), time.Second))
+	g.Expect(aws.TimeValue(mem.puts[3].Expires)).To(Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+	g.Expect(mem.puts[4].Expires).To(BeNil())
+}
+
+func TestWithRedirectLocation(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := &recordingBucket{Bucket: s3fake.New()}
+	fs := NewFs("mybucket", mem).
+		WithWriteRules(WriteRule{Path: "/old/*.html", RedirectLocation: "/new/index.html"})
+
+	g.Expect(afero.WriteFile(fs, "/old/a.html", nil, 0644)).To(Succeed())
+	g.Expect(afero.WriteFile(fs, "/old/b.css", nil, 0644)).To(Succeed())
+
+	af, err := fs.Create("/old/c.html")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(af.(*File).WithRedirectLocation("https://example.com/").Close()).To(Succeed())
+
+	g.Expect(fs.Redirect("/d.html", "/new/d.html")).To(Succeed())
+
+	g.Expect(mem.puts).To(HaveLen(4))
+	g.Expect(aws.StringValue(mem.puts[0].WebsiteRedirectLocation)).To(Equal("/new/index.html"))
+	g.Expect(mem.puts[1].WebsiteRedirectLocation).To(BeNil())
+	g.Expect(aws.StringValue(mem.puts[2].WebsiteRedirectLocation)).To(Equal("https://example.com/"))
+	g.Expect(aws.StringValue(mem.puts[3].WebsiteRedirectLocation)).To(Equal("/new/d.html"))
+	g.Expect(aws.Int64Value(mem.puts[3].ContentLength)).To(BeZero())
+}
+
+func TestExpiresPreservedByChmod(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	mem := s3fake.New()
+	fs := NewFs("mybucket", mem).WithExpires(time.Hour)
+	g.Expect(afero.WriteFile(fs, "/a.txt", []byte("a"), 0644)).To(Succeed())
+
+	head := func() string {
+		out, err := mem.HeadObjectWithContext(aws.BackgroundContext(), &s3.HeadObjectInput{Bucket: aws.String("mybucket"), Key: aws.String("a.txt")})
+		g.Expect(err).NotTo(HaveOccurred())
+		return aws.StringValue(out.Expires)
+	}
+	expires := head()
+	g.Expect(expires).NotTo(BeEmpty())
+
+	g.Expect(NewFs("mybucket", mem).Chmod("/a.txt", 0600)).To(Succeed())
+	g.Expect(NewFs("mybucket", mem).Chtimes("/a.txt", time.Now(), time.Now())).To(Succeed())
+	g.Expect(head()).To(Equal(expires))
+}